@@ -2,17 +2,23 @@ package cmd
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
 	"github.com/ramonvermeulen/whosthere/internal/core"
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
 	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"github.com/ramonvermeulen/whosthere/internal/core/ports"
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
 	"github.com/ramonvermeulen/whosthere/internal/core/version"
 )
@@ -24,6 +30,7 @@ var daemonCmd = &cobra.Command{
 
 Examples:
  whosthere daemon --port 8080
+ whosthere daemon --port 8080 --portscan-token "$TOKEN"
 `,
 	RunE: runDaemon,
 }
@@ -34,8 +41,9 @@ func runDaemon(cmd *cobra.Command, _ []string) error {
 		port = "8080"
 		zap.L().Info("no port specified, using default port", zap.String("port", port))
 	}
+	portScanToken, _ := cmd.Flags().GetString("portscan-token")
 
-	result, err := InitComponents("", whosthereFlags.NetworkInterface, true)
+	result, err := InitComponents("", whosthereFlags.NetworkInterfaces, true)
 	if err != nil {
 		return err
 	}
@@ -44,13 +52,23 @@ func runDaemon(cmd *cobra.Command, _ []string) error {
 
 	appState := state.NewAppState(result.Config, version.Version)
 
-	eng := core.BuildEngine(result.Interface, result.OuiDB, []string{"ssdp", "arp", "mdns"}, 30*time.Second)
+	eng, err := core.BuildEngine(result.Interfaces, result.OuiDB, result.Config.Scanners, core.GetEnabledFromCfg(result.Config), 30*time.Second, discovery.WithMergePolicy(discovery.MergePolicyFromConfig(result.Config)))
+	if err != nil {
+		return err
+	}
 
 	http.HandleFunc("/devices", func(w http.ResponseWriter, r *http.Request) {
 		handleDevices(w, r, appState)
 	})
 	http.HandleFunc("/devices/", func(w http.ResponseWriter, r *http.Request) {
-		handleDeviceByIP(w, r, appState)
+		handleDeviceByID(w, r, appState)
+	})
+	http.HandleFunc("/scans/last", func(w http.ResponseWriter, r *http.Request) {
+		handleLastScan(w, r, appState)
+	})
+	portScans := &portScanJob{token: portScanToken}
+	http.HandleFunc("/portscan", func(w http.ResponseWriter, r *http.Request) {
+		handlePortScan(w, r, appState, portScans, result.Interfaces)
 	})
 	http.HandleFunc("/health", handleHealth)
 
@@ -63,12 +81,13 @@ func runDaemon(cmd *cobra.Command, _ []string) error {
 
 	for {
 		zap.L().Info("starting scan cycle")
-		_, err := eng.Stream(ctx, func(d *discovery.Device) {
+		_, report, err := eng.Stream(ctx, func(d *discovery.Device) {
 			appState.UpsertDevice(d)
 		})
 		if err != nil {
 			zap.L().Error("scan failed", zap.Error(err))
 		}
+		appState.SetLastScanReport(report)
 		time.Sleep(result.Config.ScanInterval)
 	}
 }
@@ -76,6 +95,15 @@ func runDaemon(cmd *cobra.Command, _ []string) error {
 func handleDevices(w http.ResponseWriter, r *http.Request, appState *state.AppState) {
 	zap.L().Info("incoming request", zap.String("method", r.Method), zap.String("path", r.URL.Path))
 	devices := appState.DevicesSnapshot()
+	if iface := r.URL.Query().Get("interface"); iface != "" {
+		filtered := devices[:0]
+		for _, d := range devices {
+			if d.Interface == iface {
+				filtered = append(filtered, d)
+			}
+		}
+		devices = filtered
+	}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(devices); err != nil {
 		http.Error(w, "Failed to encode devices", http.StatusInternalServerError)
@@ -83,19 +111,15 @@ func handleDevices(w http.ResponseWriter, r *http.Request, appState *state.AppSt
 	}
 }
 
-func handleDeviceByIP(w http.ResponseWriter, r *http.Request, appState *state.AppState) {
+// handleDeviceByID serves /devices/{id}, where id is a device ID, MAC address, IP address or hostname.
+func handleDeviceByID(w http.ResponseWriter, r *http.Request, appState *state.AppState) {
 	zap.L().Info("incoming request", zap.String("method", r.Method), zap.String("path", r.URL.Path))
-	ipStr := strings.TrimPrefix(r.URL.Path, "/devices/")
-	if ipStr == "" {
+	id := strings.TrimPrefix(r.URL.Path, "/devices/")
+	if id == "" {
 		http.NotFound(w, r)
 		return
 	}
-	parsedIP := net.ParseIP(ipStr)
-	if parsedIP == nil {
-		http.Error(w, "Invalid IP address", http.StatusBadRequest)
-		return
-	}
-	device, ok := appState.GetDevice(ipStr)
+	device, ok := appState.GetDevice(id)
 	if !ok {
 		http.NotFound(w, r)
 		return
@@ -107,6 +131,131 @@ func handleDeviceByIP(w http.ResponseWriter, r *http.Request, appState *state.Ap
 	}
 }
 
+func handleLastScan(w http.ResponseWriter, r *http.Request, appState *state.AppState) {
+	zap.L().Info("incoming request", zap.String("method", r.Method), zap.String("path", r.URL.Path))
+	report, ok := appState.LastScanReport()
+	if !ok {
+		http.Error(w, "No scan completed yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, "Failed to encode scan report", http.StatusInternalServerError)
+		return
+	}
+}
+
+// portScanJob is the bulk port scan running in the background, there is at most one.
+type portScanJob struct {
+	token  string // bearer token that allows starting and stopping scans, loopback clients only when empty
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// authorize reports whether r may start or stop a port scan, and writes the error otherwise.
+// Browsers send an Origin header, cross-origin requests are rejected so a web page cannot
+// make the daemon scan the network.
+func (job *portScanJob) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || !strings.EqualFold(u.Host, r.Host) {
+			http.Error(w, "Cross-origin requests are not allowed", http.StatusForbidden)
+			return false
+		}
+	}
+	if job.token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(job.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+		http.Error(w, "Port scans are only accepted from localhost without --portscan-token", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// handlePortScan serves /portscan. POST port scans the known devices, narrowed by the
+// interface and filter query parameters, on the ports of the ports spec (the configured
+// ports when empty) with the timing profile (the configured profile when empty). GET
+// returns the per-host progress of the last scan, DELETE stops it. POST and DELETE need
+// the token of the daemon, or a loopback client when it has none.
+func handlePortScan(w http.ResponseWriter, r *http.Request, appState *state.AppState, job *portScanJob, ifaces []*discovery.InterfaceInfo) {
+	zap.L().Info("incoming request", zap.String("method", r.Method), zap.String("path", r.URL.Path))
+	if (r.Method == http.MethodPost || r.Method == http.MethodDelete) && !job.authorize(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(appState.PortScanProgress()); err != nil {
+			http.Error(w, "Failed to encode port scan progress", http.StatusInternalServerError)
+		}
+	case http.MethodPost:
+		query := r.URL.Query()
+		var tcp []int
+		if spec := query.Get("ports"); spec != "" {
+			var err error
+			if tcp, err = ports.Parse(spec); err != nil {
+				http.Error(w, "Invalid ports: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		cfg := appState.Config().PortScanner
+		if timing := query.Get("timing"); timing != "" {
+			cfg.Timing = config.TimingProfile(timing)
+			if err := cfg.Timing.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		devices, err := core.FilterDevices(appState.DevicesSnapshot(), query.Get("interface"), query.Get("filter"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		if job.cancel != nil {
+			http.Error(w, "Port scan already running", http.StatusConflict)
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		job.cancel = cancel
+		appState.ClearPortScanProgress()
+		appState.SetIsPortscanning(true)
+
+		go func() {
+			err := core.PortScanDevices(ctx, devices, ifaces, cfg, tcp, appState.UpsertDevice, appState.SetPortScanProgress)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				zap.L().Warn("port scan failed", zap.Error(err))
+			}
+			appState.SetIsPortscanning(false)
+			job.mu.Lock()
+			job.cancel = nil
+			job.mu.Unlock()
+			cancel()
+		}()
+
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodDelete:
+		job.mu.Lock()
+		if job.cancel != nil {
+			job.cancel()
+		}
+		job.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	zap.L().Info("incoming request", zap.String("method", r.Method), zap.String("path", r.URL.Path))
 	w.WriteHeader(http.StatusOK)
@@ -115,5 +264,6 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 
 func init() {
 	daemonCmd.Flags().StringP("port", "p", "", "Port for the HTTP API server")
+	daemonCmd.Flags().String("portscan-token", "", "Bearer token required to start or stop port scans, only localhost may when empty")
 	rootCmd.AddCommand(daemonCmd)
 }
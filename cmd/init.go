@@ -12,14 +12,16 @@ import (
 )
 
 type InitResult struct {
-	Logger    *zap.Logger
-	LogPath   string
-	Config    *config.Config
-	OuiDB     *oui.Registry
-	Interface *discovery.InterfaceInfo
+	Logger     *zap.Logger
+	LogPath    string
+	Config     *config.Config
+	OuiDB      *oui.Registry
+	Interfaces []*discovery.InterfaceInfo
 }
 
-func InitComponents(configFileOverride, interfaceOverride string, enableStdout bool) (*InitResult, error) {
+// InitComponents sets up logging, the config, the OUI database and the interfaces to scan.
+// interfaceOverride replaces the interfaces from the config when not empty.
+func InitComponents(configFileOverride string, interfaceOverride []string, enableStdout bool) (*InitResult, error) {
 	level := logging.LevelFromEnv(zapcore.InfoLevel)
 	logger, logPath, err := logging.Init(level, enableStdout)
 	if err != nil {
@@ -39,21 +41,21 @@ func InitComponents(configFileOverride, interfaceOverride string, enableStdout b
 		ouiDB = nil
 	}
 
-	networkInterface := cfg.NetworkInterface
-	if interfaceOverride != "" {
-		networkInterface = interfaceOverride
+	names := cfg.Interfaces()
+	if len(interfaceOverride) > 0 {
+		names = interfaceOverride
 	}
 
-	iface, err := discovery.NewInterfaceInfo(networkInterface)
+	ifaces, err := discovery.NewInterfaceInfos(names)
 	if err != nil {
 		return nil, err
 	}
 
 	return &InitResult{
-		Logger:    logger,
-		LogPath:   logPath,
-		Config:    cfg,
-		OuiDB:     ouiDB,
-		Interface: iface,
+		Logger:     logger,
+		LogPath:    logPath,
+		Config:     cfg,
+		OuiDB:      ouiDB,
+		Interfaces: ifaces,
 	}, nil
 }
@@ -1,12 +1,14 @@
 package cmd
 
 import (
+	"fmt"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"strings"
 
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/ports"
 	"github.com/ramonvermeulen/whosthere/internal/core/version"
 	"github.com/ramonvermeulen/whosthere/internal/ui"
 	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
@@ -71,7 +73,7 @@ func SetVersion(v string) {
 }
 
 func run(*cobra.Command, []string) error {
-	result, err := InitComponents(whosthereFlags.ConfigFile, whosthereFlags.NetworkInterface, false)
+	result, err := InitComponents(whosthereFlags.ConfigFile, whosthereFlags.NetworkInterfaces, false)
 	if err != nil {
 		return err
 	}
@@ -81,6 +83,14 @@ func run(*cobra.Command, []string) error {
 	cfg := result.Config
 	ouiDB := result.OuiDB
 
+	if whosthereFlags.Ports != "" {
+		tcp, err := ports.Parse(whosthereFlags.Ports)
+		if err != nil {
+			return fmt.Errorf("--ports: %w", err)
+		}
+		cfg.PortScanner.TCP = tcp
+	}
+
 	logger.Info("logger initialized", zap.String("path", logPath), zap.String("level", logger.Level().String()))
 
 	if ouiDB == nil {
@@ -96,7 +106,7 @@ func run(*cobra.Command, []string) error {
 		}()
 	}
 
-	app, err := ui.NewApp(cfg, ouiDB, version.Version)
+	app, err := ui.NewApp(cfg, ouiDB, result.Interfaces, version.Version)
 	if err != nil {
 		logger.Error("failed to create app", zap.Error(err))
 		return err
@@ -123,11 +133,17 @@ func initWhosthereFlags() {
 		"",
 		"Pprof HTTP server port for debugging and profiling purposes (e.g., 6060)",
 	)
-	rootCmd.PersistentFlags().StringVarP(
-		&whosthereFlags.NetworkInterface,
+	rootCmd.PersistentFlags().StringSliceVarP(
+		&whosthereFlags.NetworkInterfaces,
 		"interface", "i",
+		nil,
+		"Network interfaces to scan, comma-separated or repeated (overrides config).",
+	)
+	rootCmd.Flags().StringVar(
+		&whosthereFlags.Ports,
+		"ports",
 		"",
-		"Network interface to use for scanning (overrides config).",
+		"TCP ports to port scan, e.g. 1-1024,3389, top100 or web (overrides config).",
 	)
 }
 
@@ -10,65 +10,128 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/ramonvermeulen/whosthere/internal/core"
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
 	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"github.com/ramonvermeulen/whosthere/internal/core/ports"
 )
 
 var scanCmd = &cobra.Command{
 	Use:   "scan",
 	Short: "Run network scanners standalone for debugging/experimentation",
-	Long: `Run one or more scanners directly (mdns, ssdp, arp).
+	Long: `Run one or more registered scanners directly (see --scanner for the list).
 
 Examples:
  whosthere scan -s mdns
  whosthere scan -s "arp,ssdp" -t 30
+ whosthere scan --ports top100 --filter raspberry
+ whosthere scan --ports iot --timing polite
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		scannerNames, _ := cmd.Flags().GetString("scanner")
 		timeoutSec, _ := cmd.Flags().GetInt("timeout")
 		scanDuration := time.Duration(timeoutSec) * time.Second
+		portSpec, _ := cmd.Flags().GetString("ports")
+		filter, _ := cmd.Flags().GetString("filter")
+		timing, _ := cmd.Flags().GetString("timing")
 
-		result, err := InitComponents("", whosthereFlags.NetworkInterface, true)
+		var tcp []int
+		if portSpec != "" {
+			var err error
+			if tcp, err = ports.Parse(portSpec); err != nil {
+				return fmt.Errorf("--ports: %w", err)
+			}
+		}
+
+		result, err := InitComponents("", whosthereFlags.NetworkInterfaces, true)
 		if err != nil {
 			return err
 		}
 
 		ctx := context.Background()
 
-		var enabled []string
-		requested := strings.Split(scannerNames, ",")
-		for _, r := range requested {
-			r = strings.TrimSpace(strings.ToLower(r))
-			switch r {
-			case "", "all":
-				enabled = []string{"ssdp", "arp", "mdns"}
-			case "ssdp", "arp", "mdns":
-				enabled = append(enabled, r)
-			default:
-				return fmt.Errorf("unknown scanner: %s", r)
+		var requested []string
+		for _, r := range strings.Split(scannerNames, ",") {
+			if r = strings.TrimSpace(strings.ToLower(r)); r != "" {
+				requested = append(requested, r)
 			}
 		}
+		if len(requested) == 0 {
+			requested = []string{"all"}
+		}
+
+		enabled, err := core.ParseScannerNames(requested)
+		if err != nil {
+			return err
+		}
 
 		if len(enabled) == 0 {
 			return fmt.Errorf("no scanners selected")
 		}
 
-		eng := core.BuildEngine(result.Interface, result.OuiDB, enabled, scanDuration)
+		eng, err := core.BuildEngine(result.Interfaces, result.OuiDB, result.Config.Scanners, enabled, scanDuration, discovery.WithMergePolicy(discovery.MergePolicyFromConfig(result.Config)))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = eng.Close()
+		}()
 
 		ctx, cancel := context.WithTimeout(ctx, scanDuration)
 		defer cancel()
 
-		devices, err := eng.Stream(ctx, func(_ *discovery.Device) {})
+		devices, report, err := eng.Stream(ctx, func(_ *discovery.Device) {})
 		if err != nil {
 			return err
 		}
 
-		zap.L().Info("scan complete", zap.Int("devices", len(devices)))
+		zap.L().Info("scan complete", zap.Int("devices", len(devices)), zap.Duration("duration", report.Duration))
+		for _, sr := range report.Scanners {
+			zap.L().Info("scanner",
+				zap.String("name", sr.Name),
+				zap.String("interface", sr.Interface),
+				zap.Duration("duration", sr.Duration),
+				zap.Int("devices", sr.DevicesEmitted),
+				zap.Bool("deadline_exceeded", sr.DeadlineExceeded),
+				zap.String("error", sr.Error),
+			)
+		}
+
+		if len(tcp) > 0 {
+			if devices, err = core.FilterDevices(devices, "", filter); err != nil {
+				return fmt.Errorf("--filter: %w", err)
+			}
+			portScanner := result.Config.PortScanner
+			if timing != "" {
+				portScanner.Timing = config.TimingProfile(timing)
+				if err := portScanner.Timing.Validate(); err != nil {
+					return fmt.Errorf("--timing: %w", err)
+				}
+			}
+			zap.L().Info("starting port scan", zap.Int("devices", len(devices)), zap.Int("tcp", len(tcp)), zap.Int("udp", len(portScanner.UDP)), zap.String("timing", string(portScanner.Timing)))
+			scanned := map[string]discovery.PortMap{}
+			err = core.PortScanDevices(context.Background(), devices, result.Interfaces, portScanner, tcp,
+				func(d *discovery.Device) { scanned[d.IP.String()] = d.OpenPorts },
+				func(p discovery.HostProgress) {
+					if p.Finished() {
+						zap.L().Info("port scan host complete", zap.String("ip", p.IP), zap.Int("ports", p.Total), zap.Int("open", p.Open))
+					}
+				})
+			if err != nil {
+				return err
+			}
+			for i := range devices {
+				devices[i].OpenPorts = scanned[devices[i].IP.String()]
+			}
+		}
+
 		for _, d := range devices {
 			zap.L().Info("device",
 				zap.String("ip", d.IP.String()),
+				zap.String("interface", d.Interface),
 				zap.String("hostname", d.DisplayName),
 				zap.String("mac", d.MAC),
 				zap.String("manufacturer", d.Manufacturer),
+				zap.Any("open_ports", d.OpenPorts),
 			)
 		}
 		return nil
@@ -76,7 +139,10 @@ Examples:
 }
 
 func init() {
-	scanCmd.Flags().StringP("scanner", "s", "all", "Comma-separated scanners to run (mdns,ssdp,arp,all)")
+	scanCmd.Flags().StringP("scanner", "s", "all", fmt.Sprintf("Comma-separated scanners to run (%s,all)", strings.Join(core.ScannerNames(), ",")))
 	scanCmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for the scan")
+	scanCmd.Flags().String("ports", "", "Port scan the discovered devices, e.g. 22,80,8000-8100, top100 or web")
+	scanCmd.Flags().String("filter", "", "Only port scan devices matching this regular expression")
+	scanCmd.Flags().String("timing", "", "Port scan timing profile (polite, normal or aggressive), the configured profile when empty")
 	rootCmd.AddCommand(scanCmd)
 }
@@ -2,9 +2,15 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"slices"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/ramonvermeulen/whosthere/internal/core/ports"
 )
 
 const (
@@ -15,13 +21,26 @@ const (
 	DefaultScanInterval    = 20 * time.Second
 	DefaultScanDuration    = 10 * time.Second
 	DefaultPortScanTimeout = 5 * time.Second
+	DefaultPortScanTiming  = TimingNormal
 
 	DefaultThemeName = "default"
 	CustomThemeName  = "custom"
+
+	DefaultSNMPEnabled = true
+	DefaultSNMPVersion = "2c"
+	DefaultSNMPPort    = 161
+	DefaultSNMPTimeout = 2 * time.Second
+	DefaultSNMPRetries = 1
 )
 
 var DefaultTCPPorts = []int{21, 22, 23, 25, 80, 110, 135, 139, 143, 389, 443, 445, 993, 995, 1433, 1521, 3306, 3389, 5432, 5900, 8080, 8443, 9000, 9090, 9200, 9300, 10000, 27017}
 
+// DefaultUDPPorts are the services with a protocol-aware UDP probe: DNS, TFTP, NTP,
+// NetBIOS, SNMP, IKE, SSDP and mDNS.
+var DefaultUDPPorts = []int{53, 69, 123, 137, 161, 500, 1900, 5353}
+
+var DefaultSNMPCommunities = []string{"public"}
+
 // ThemeConfig selects a theme by name and optionally carries custom color overrides.
 type ThemeConfig struct {
 	Enabled                     bool   `yaml:"enabled"`
@@ -39,10 +58,156 @@ type ThemeConfig struct {
 	ContrastSecondaryTextColor  string `yaml:"contrast_secondary_text_color"`
 }
 
-// PortScannerConfig defines TCP ports to scan.
+// PortScannerConfig defines TCP and UDP ports to scan.
 type PortScannerConfig struct {
-	TCP     []int         `yaml:"tcp"`
-	Timeout time.Duration `yaml:"timeout"`
+	TCP     PortList      `yaml:"tcp"`
+	UDP     PortList      `yaml:"udp"`     // empty disables the UDP scan
+	Timeout time.Duration `yaml:"timeout"` // longest wait for a port to answer, 0 uses the timing profile
+	Timing  TimingProfile `yaml:"timing"`
+	Workers int           `yaml:"workers"` // concurrent probes, shared by all hosts of a bulk scan, 0 uses the timing profile
+	Rate    int           `yaml:"rate"`    // probes sent per second, 0 uses the timing profile
+}
+
+// TimingProfile selects how fast and how patiently the port scanner probes.
+type TimingProfile string
+
+const (
+	// TimingPolite probes slowly with few sockets and retries, for fragile devices.
+	TimingPolite TimingProfile = "polite"
+	// TimingNormal probes at full speed with timeouts adapted to the round trip time.
+	TimingNormal TimingProfile = "normal"
+	// TimingAggressive probes with many sockets, short timeouts and no retries.
+	TimingAggressive TimingProfile = "aggressive"
+)
+
+// TimingProfiles are the timing profiles from slowest to fastest.
+var TimingProfiles = []TimingProfile{TimingPolite, TimingNormal, TimingAggressive}
+
+// Validate resets an unknown profile to normal, an empty one silently.
+func (t *TimingProfile) Validate() error {
+	switch *t {
+	case TimingPolite, TimingNormal, TimingAggressive:
+		return nil
+	case "":
+		*t = DefaultPortScanTiming
+		return nil
+	default:
+		profile := *t
+		*t = DefaultPortScanTiming
+		return fmt.Errorf("timing must be one of polite, normal or aggressive, got %q", profile)
+	}
+}
+
+// PortList is a list of ports, written in YAML as a list of ports and port specs
+// ([22, "8000-8100", web]) or as one spec ("1-1024,3389" or top100), see ports.Parse.
+type PortList []int
+
+// UnmarshalYAML expands the port specs of the list.
+func (l *PortList) UnmarshalYAML(data []byte) error {
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var terms []string
+	switch v := raw.(type) {
+	case nil:
+		*l = PortList{}
+		return nil
+	case []any:
+		if len(v) == 0 {
+			*l = PortList{}
+			return nil
+		}
+		for _, term := range v {
+			terms = append(terms, fmt.Sprint(term))
+		}
+	default:
+		terms = []string{fmt.Sprint(v)}
+	}
+	parsed, err := ports.Parse(strings.Join(terms, ","))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+// SNMPConfig holds the SNMP credentials used by the device probe.
+type SNMPConfig struct {
+	Enabled         bool `yaml:"enabled"`
+	SNMPCredentials `yaml:",inline"`
+}
+
+// SNMPCredentials select the SNMP version and credentials. With version 1 or 2c
+// the communities are tried in order, version 3 uses the v3 user.
+type SNMPCredentials struct {
+	Version     string        `yaml:"version"` // 1, 2c or 3
+	Communities []string      `yaml:"communities"`
+	Port        int           `yaml:"port"`
+	Timeout     time.Duration `yaml:"timeout"` // per attempt
+	Retries     int           `yaml:"retries"`
+	V3          SNMPv3Config  `yaml:"v3,omitempty"`
+}
+
+// SNMPv3Config is an SNMPv3 user of the user based security model, without privacy (encryption).
+type SNMPv3Config struct {
+	Username       string `yaml:"username"`
+	AuthProtocol   string `yaml:"auth_protocol"` // none, md5 or sha
+	AuthPassphrase string `yaml:"auth_passphrase"`
+}
+
+// DefaultSNMPCredentials returns SNMPv2c credentials with the default communities.
+func DefaultSNMPCredentials() SNMPCredentials {
+	return SNMPCredentials{
+		Version:     DefaultSNMPVersion,
+		Communities: append([]string(nil), DefaultSNMPCommunities...),
+		Port:        DefaultSNMPPort,
+		Timeout:     DefaultSNMPTimeout,
+		Retries:     DefaultSNMPRetries,
+	}
+}
+
+// Normalize checks the credentials and resets invalid values to their defaults,
+// it returns one message per problem.
+func (c *SNMPCredentials) Normalize() []string {
+	var errs []string
+	if strings.TrimSpace(c.Version) == "" {
+		c.Version = DefaultSNMPVersion
+	}
+	switch c.Version {
+	case "1", "2c":
+	case "3":
+		if strings.TrimSpace(c.V3.Username) == "" {
+			errs = append(errs, "v3.username is required with version 3")
+		}
+		switch strings.ToLower(c.V3.AuthProtocol) {
+		case "", "none":
+		case "md5", "sha":
+			// RFC 3414 section 11.2 requires passphrases of at least 8 characters
+			if len(c.V3.AuthPassphrase) < 8 {
+				errs = append(errs, "v3.auth_passphrase must be at least 8 characters")
+			}
+		default:
+			errs = append(errs, "v3.auth_protocol must be one of none, md5 or sha, got "+c.V3.AuthProtocol)
+			c.V3.AuthProtocol = ""
+		}
+	default:
+		errs = append(errs, "version must be one of 1, 2c or 3, got "+c.Version)
+		c.Version = DefaultSNMPVersion
+	}
+	if c.Version != "3" && len(c.Communities) == 0 {
+		c.Communities = append([]string(nil), DefaultSNMPCommunities...)
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		c.Port = DefaultSNMPPort
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultSNMPTimeout
+	}
+	if c.Retries < 0 {
+		c.Retries = 0
+	}
+	return errs
 }
 
 // SplashConfig controls the splash screen visibility and timing.
@@ -51,27 +216,106 @@ type SplashConfig struct {
 	Delay   time.Duration `yaml:"delay"`
 }
 
-// ScannerConfig groups scanner enablement flags.
-type ScannerConfig struct {
-	MDNS ScannerToggle `yaml:"mdns"`
-	SSDP ScannerToggle `yaml:"ssdp"`
-	ARP  ScannerToggle `yaml:"arp"`
+// MergeConfig controls how values reported by different scanners are combined
+// into a single device. Strategies: first-wins, most-specific, longest,
+// most-recent, source-priority and union (map fields only).
+type MergeConfig struct {
+	Default        string                      `yaml:"default"`
+	SourcePriority []string                    `yaml:"source_priority"`
+	Fields         map[string]FieldMergeConfig `yaml:"fields"`
+}
+
+// FieldMergeConfig overrides the merge strategy for a single device field.
+type FieldMergeConfig struct {
+	Strategy       string   `yaml:"strategy"`
+	SourcePriority []string `yaml:"source_priority"`
+}
+
+// SourceLocal is the source of the device running whosthere, it is not a scanner.
+const SourceLocal = "local"
+
+// mergeStrategies are the strategy names accepted by the merge section.
+var mergeStrategies = []string{"first-wins", "most-specific", "longest", "most-recent", "source-priority", "union"}
+
+// mergeScalarFields accept every strategy except union, mergeMapFields only union and most-recent.
+var (
+	mergeScalarFields = []string{"mac", "hostname", "interface", "subnet", "display_name", "manufacturer", "device_type", "os", "netbios_name", "reverse_dns", "http_title", "http_server"}
+	mergeMapFields    = []string{"extra_data"}
+)
+
+// Validate checks the strategies, fields and source names of the merge section.
+// Source names are the local device, the registered scanners and the extra sources they report.
+func (m MergeConfig) Validate() error {
+	var errs []string
+	if m.Default != "" {
+		switch s, ok := parseMergeStrategy(m.Default); {
+		case !ok:
+			errs = append(errs, fmt.Sprintf("merge.default: unknown merge strategy %q", m.Default))
+		case s == "union":
+			errs = append(errs, fmt.Sprintf("merge.default: %q is only valid for map fields", s))
+		}
+	}
+	errs = append(errs, validateSources("merge.source_priority", m.SourcePriority)...)
+
+	fields := make([]string, 0, len(m.Fields))
+	for field := range m.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		fc := m.Fields[field]
+		s, ok := parseMergeStrategy(fc.Strategy)
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Sprintf("merge.fields.%s: unknown merge strategy %q", field, fc.Strategy))
+		case slices.Contains(mergeScalarFields, field):
+			if s == "union" {
+				errs = append(errs, fmt.Sprintf("merge.fields.%s: %q is only valid for map fields", field, s))
+			}
+		case slices.Contains(mergeMapFields, field):
+			if s != "union" && s != "most-recent" {
+				errs = append(errs, fmt.Sprintf("merge.fields.%s: map fields only support \"union\" and \"most-recent\"", field))
+			}
+		default:
+			errs = append(errs, fmt.Sprintf("merge.fields.%s: unknown field", field))
+		}
+		errs = append(errs, validateSources("merge.fields."+field+".source_priority", fc.SourcePriority)...)
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// parseMergeStrategy normalizes a strategy name and reports whether it is known.
+func parseMergeStrategy(name string) (string, bool) {
+	s := strings.ToLower(strings.TrimSpace(name))
+	return s, slices.Contains(mergeStrategies, s)
 }
 
-// ScannerToggle lets users enable/disable a scanner.
-type ScannerToggle struct {
-	Enabled bool `yaml:"enabled"`
+func validateSources(key string, sources []string) []string {
+	var errs []string
+	for _, src := range sources {
+		if src = strings.ToLower(strings.TrimSpace(src)); src != "" && !knownSource(src) {
+			errs = append(errs, fmt.Sprintf("%s: unknown source %q", key, src))
+		}
+	}
+	return errs
 }
 
 // Config captures all configurable parameters for the application.
 type Config struct {
-	ScanInterval     time.Duration     `yaml:"scan_interval"`
-	ScanDuration     time.Duration     `yaml:"scan_duration"`
-	Splash           SplashConfig      `yaml:"splash"`
-	Theme            ThemeConfig       `yaml:"theme"`
-	Scanners         ScannerConfig     `yaml:"scanners"`
-	PortScanner      PortScannerConfig `yaml:"port_scanner"`
-	NetworkInterface string            `yaml:"network_interface"`
+	ScanInterval      time.Duration     `yaml:"scan_interval"`
+	ScanDuration      time.Duration     `yaml:"scan_duration"`
+	Splash            SplashConfig      `yaml:"splash"`
+	Theme             ThemeConfig       `yaml:"theme"`
+	Scanners          ScannersConfig    `yaml:"scanners"`
+	PortScanner       PortScannerConfig `yaml:"port_scanner"`
+	SNMP              SNMPConfig        `yaml:"snmp"`
+	Merge             MergeConfig       `yaml:"merge"`
+	NetworkInterface  string            `yaml:"network_interface"`
+	NetworkInterfaces []string          `yaml:"network_interfaces"` // scanned concurrently, combined with network_interface
 }
 
 // DefaultConfig builds a Config pre-populated with baked-in defaults.
@@ -84,11 +328,28 @@ func DefaultConfig() *Config {
 			Delay:   DefaultSplashDelay,
 		},
 		Theme:       ThemeConfig{Name: DefaultThemeName, Enabled: DefaultThemeEnabled},
-		Scanners:    ScannerConfig{MDNS: ScannerToggle{Enabled: true}, SSDP: ScannerToggle{Enabled: true}, ARP: ScannerToggle{Enabled: true}},
-		PortScanner: PortScannerConfig{TCP: DefaultTCPPorts, Timeout: DefaultPortScanTimeout},
+		Scanners:    DefaultScannersConfig(),
+		PortScanner: PortScannerConfig{TCP: DefaultTCPPorts, UDP: DefaultUDPPorts, Timing: DefaultPortScanTiming},
+		SNMP:        SNMPConfig{Enabled: DefaultSNMPEnabled, SNMPCredentials: DefaultSNMPCredentials()},
 	}
 }
 
+// Interfaces returns the names of the interfaces to scan, network_interface first and
+// without duplicates. An empty list means the OS default interface.
+func (c *Config) Interfaces() []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, name := range append([]string{c.NetworkInterface}, c.NetworkInterfaces...) {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out
+}
+
 // validateAndNormalize validates the config and fixes up out-of-range values.
 func (c *Config) validateAndNormalize() error {
 	var errs []string
@@ -113,19 +374,40 @@ func (c *Config) validateAndNormalize() error {
 		c.ScanDuration = c.ScanInterval
 	}
 
-	if !c.Scanners.MDNS.Enabled && !c.Scanners.SSDP.Enabled && !c.Scanners.ARP.Enabled {
-		errs = append(errs, "at least one scanner must be enabled")
-		c.Scanners.MDNS.Enabled = true
-		c.Scanners.SSDP.Enabled = true
-		c.Scanners.ARP.Enabled = true
+	if c.Scanners == nil {
+		c.Scanners = DefaultScannersConfig()
+	}
+	errs = append(errs, c.Scanners.validateAndNormalize()...)
+
+	if err := c.Merge.Validate(); err != nil {
+		errs = append(errs, err.Error())
+		c.Merge = MergeConfig{}
 	}
 
 	if len(c.PortScanner.TCP) == 0 {
 		c.PortScanner.TCP = DefaultTCPPorts
 	}
 
-	if c.PortScanner.Timeout <= 0 {
-		c.PortScanner.Timeout = DefaultPortScanTimeout
+	if c.PortScanner.Timeout < 0 {
+		errs = append(errs, "port_scanner.timeout must be >= 0")
+		c.PortScanner.Timeout = 0
+	}
+
+	if err := c.PortScanner.Timing.Validate(); err != nil {
+		errs = append(errs, "port_scanner."+err.Error())
+	}
+
+	if c.PortScanner.Workers < 0 {
+		c.PortScanner.Workers = 0
+	}
+
+	if c.PortScanner.Rate < 0 {
+		errs = append(errs, "port_scanner.rate must be >= 0")
+		c.PortScanner.Rate = 0
+	}
+
+	for _, err := range c.SNMP.Normalize() {
+		errs = append(errs, "snmp."+err)
 	}
 
 	if strings.TrimSpace(c.Theme.Name) == "" {
@@ -138,6 +420,20 @@ func (c *Config) validateAndNormalize() error {
 		}
 	}
 
+	var ifaces []string
+	for _, name := range c.NetworkInterfaces {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, err := net.InterfaceByName(name); err != nil {
+			errs = append(errs, "network_interfaces: interface does not exist: "+name)
+			continue
+		}
+		ifaces = append(ifaces, name)
+	}
+	c.NetworkInterfaces = ifaces
+
 	if len(errs) > 0 {
 		return errors.New(strings.Join(errs, "; "))
 	}
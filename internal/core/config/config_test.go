@@ -2,19 +2,49 @@ package config
 
 import (
 	"errors"
+	"net"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/goccy/go-yaml"
+	"github.com/ramonvermeulen/whosthere/internal/core/ports"
 )
 
+// testScannerConfig mimics a scanner with its own settings next to the enabled toggle.
+type testScannerConfig struct {
+	ScannerToggle `yaml:",inline"`
+	Interval      time.Duration `yaml:"interval"`
+}
+
+func (c *testScannerConfig) Validate() error {
+	if c.Interval <= 0 {
+		c.Interval = time.Minute
+		return errors.New("interval must be > 0")
+	}
+	return nil
+}
+
+func init() {
+	// The real scanners register through the discovery package, which imports config.
+	RegisterScanner(ScannerSpec{Name: "mdns", DefaultEnabled: true})
+	RegisterScanner(ScannerSpec{Name: "ssdp", DefaultEnabled: true})
+	RegisterScanner(ScannerSpec{
+		Name:           "arp",
+		Description:    "test scanner with settings",
+		DefaultEnabled: true,
+		Sources:        []string{"reachability"},
+		NewConfig:      func() ScannerSettings { return &testScannerConfig{Interval: time.Minute} },
+	})
+}
+
 func TestValidateAndNormalizeDurations(t *testing.T) {
 	cfg := &Config{
 		ScanInterval: -1,
 		ScanDuration: 0,
 		Splash:       SplashConfig{Enabled: true, Delay: -1},
-		Scanners:     ScannerConfig{MDNS: ScannerToggle{Enabled: true}},
+		Scanners:     ScannersConfig{"mdns": &ScannerToggle{Enabled: true}},
 	}
 
 	err := cfg.validateAndNormalize()
@@ -49,7 +79,7 @@ func TestValidateAndNormalizeDurationRelationship(t *testing.T) {
 		ScanInterval: 5 * time.Second,
 		ScanDuration: 10 * time.Second,
 		Splash:       SplashConfig{Enabled: true, Delay: DefaultSplashDelay},
-		Scanners:     ScannerConfig{MDNS: ScannerToggle{Enabled: true}},
+		Scanners:     ScannersConfig{"mdns": &ScannerToggle{Enabled: true}},
 	}
 
 	err := cfg.validateAndNormalize()
@@ -70,7 +100,7 @@ func TestValidateAndNormalizeScanners(t *testing.T) {
 		ScanInterval: DefaultScanInterval,
 		ScanDuration: DefaultScanDuration,
 		Splash:       SplashConfig{Enabled: true, Delay: DefaultSplashDelay},
-		Scanners:     ScannerConfig{},
+		Scanners:     ScannersConfig{},
 	}
 
 	err := cfg.validateAndNormalize()
@@ -82,7 +112,7 @@ func TestValidateAndNormalizeScanners(t *testing.T) {
 		t.Errorf("expected scanner error, got %v", err)
 	}
 
-	if !cfg.Scanners.MDNS.Enabled || !cfg.Scanners.SSDP.Enabled || !cfg.Scanners.ARP.Enabled {
+	if !cfg.Scanners.Enabled("mdns") || !cfg.Scanners.Enabled("ssdp") || !cfg.Scanners.Enabled("arp") {
 		t.Errorf("expected all scanners default-enabled when none specified, got %+v", cfg.Scanners)
 	}
 }
@@ -92,10 +122,10 @@ func TestValidateAndNormalizeHappyPath(t *testing.T) {
 		ScanInterval: 15 * time.Second,
 		ScanDuration: 5 * time.Second,
 		Splash:       SplashConfig{Enabled: false, Delay: 2 * time.Second},
-		Scanners: ScannerConfig{
-			MDNS: ScannerToggle{Enabled: true},
-			SSDP: ScannerToggle{Enabled: false},
-			ARP:  ScannerToggle{Enabled: true},
+		Scanners: ScannersConfig{
+			"mdns": &ScannerToggle{Enabled: true},
+			"ssdp": &ScannerToggle{Enabled: false},
+			"arp":  &testScannerConfig{ScannerToggle: ScannerToggle{Enabled: true}, Interval: time.Minute},
 		},
 	}
 
@@ -161,12 +191,15 @@ port_scanner:
 	if got, want := cfg.Splash.Delay, 750*time.Millisecond; got != want {
 		t.Errorf("splash delay: got %v, want %v", got, want)
 	}
-	if !cfg.Scanners.MDNS.Enabled || cfg.Scanners.SSDP.Enabled || !cfg.Scanners.ARP.Enabled {
+	if !cfg.Scanners.Enabled("mdns") || cfg.Scanners.Enabled("ssdp") || !cfg.Scanners.Enabled("arp") {
 		t.Errorf("scanner flags unexpected: %+v", cfg.Scanners)
 	}
 	if len(cfg.PortScanner.TCP) != 2 || cfg.PortScanner.TCP[0] != 80 || cfg.PortScanner.TCP[1] != 443 {
 		t.Errorf("tcp ports unexpected: %v", cfg.PortScanner.TCP)
 	}
+	if !slices.Equal(cfg.PortScanner.UDP, DefaultUDPPorts) {
+		t.Errorf("expected default udp ports when not configured, got %v", cfg.PortScanner.UDP)
+	}
 	if cfg.PortScanner.Timeout != DefaultPortScanTimeout {
 		t.Errorf("timeout unexpected: got %v, want %v", cfg.PortScanner.Timeout, DefaultPortScanTimeout)
 	}
@@ -222,7 +255,276 @@ scanners:
 	if cfg.Splash.Delay != DefaultSplashDelay {
 		t.Errorf("expected default splash delay %v, got %v", DefaultSplashDelay, cfg.Splash.Delay)
 	}
-	if !cfg.Scanners.MDNS.Enabled || !cfg.Scanners.SSDP.Enabled || !cfg.Scanners.ARP.Enabled {
+	if !cfg.Scanners.Enabled("mdns") || !cfg.Scanners.Enabled("ssdp") || !cfg.Scanners.Enabled("arp") {
 		t.Errorf("expected scanners re-enabled to defaults, got %+v", cfg.Scanners)
 	}
 }
+
+func TestYAMLUnmarshalScannerSettings(t *testing.T) {
+	raw := `
+scanners:
+  arp:
+    interval: 2m
+  bogus:
+    enabled: true
+`
+
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(raw), cfg); err != nil {
+		t.Fatalf("unmarshal yaml: %v", err)
+	}
+
+	err := cfg.validateAndNormalize()
+	if err == nil || !strings.Contains(err.Error(), "unknown scanner: bogus") {
+		t.Fatalf("expected unknown scanner error, got %v", err)
+	}
+	if _, ok := cfg.Scanners["bogus"]; ok {
+		t.Errorf("expected unknown scanner to be removed")
+	}
+
+	arp, ok := cfg.Scanners["arp"].(*testScannerConfig)
+	if !ok {
+		t.Fatalf("expected typed arp config, got %T", cfg.Scanners["arp"])
+	}
+	if !arp.Enabled {
+		t.Errorf("expected arp to keep its default enabled flag")
+	}
+	if arp.Interval != 2*time.Minute {
+		t.Errorf("interval: got %v, want %v", arp.Interval, 2*time.Minute)
+	}
+	if !cfg.Scanners.Enabled("mdns") || !cfg.Scanners.Enabled("ssdp") {
+		t.Errorf("expected unconfigured scanners to keep defaults, got %v", cfg.Scanners.EnabledNames())
+	}
+}
+
+func TestValidateAndNormalizeScannerSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Scanners["arp"].(*testScannerConfig).Interval = -1
+
+	err := cfg.validateAndNormalize()
+	if err == nil || !strings.Contains(err.Error(), "scanners.arp: interval must be > 0") {
+		t.Fatalf("expected scanner settings error, got %v", err)
+	}
+	if got := cfg.Scanners["arp"].(*testScannerConfig).Interval; got != time.Minute {
+		t.Errorf("expected interval reset to default, got %v", got)
+	}
+}
+
+func TestMarshalConfigWithCommentsScanners(t *testing.T) {
+	data, err := marshalConfigWithComments(DefaultConfig())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(data), "  # test scanner with settings\n  arp:\n    enabled: true\n    interval: 1m0s\n") {
+		t.Errorf("expected arp block in generated config, got:\n%s", data)
+	}
+
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		t.Fatalf("unmarshal generated config: %v", err)
+	}
+	if err := cfg.validateAndNormalize(); err != nil {
+		t.Fatalf("expected generated config to be valid, got %v", err)
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	cfg := &Config{NetworkInterface: "eth0", NetworkInterfaces: []string{"eth1", "eth0", " ", "eth2"}}
+	got := cfg.Interfaces()
+	want := []string{"eth0", "eth1", "eth2"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	if got := (&Config{}).Interfaces(); len(got) != 0 {
+		t.Errorf("expected no interfaces for the OS default, got %v", got)
+	}
+}
+
+func TestValidateAndNormalizeNetworkInterfaces(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	if err != nil || len(ifaces) == 0 {
+		t.Skip("no network interfaces available")
+	}
+
+	cfg := DefaultConfig()
+	cfg.NetworkInterfaces = []string{ifaces[0].Name, "does-not-exist0"}
+	err = cfg.validateAndNormalize()
+	if err == nil || !strings.Contains(err.Error(), "does-not-exist0") {
+		t.Fatalf("expected unknown interface error, got %v", err)
+	}
+	if len(cfg.NetworkInterfaces) != 1 || cfg.NetworkInterfaces[0] != ifaces[0].Name {
+		t.Errorf("expected unknown interface to be dropped, got %v", cfg.NetworkInterfaces)
+	}
+}
+
+func TestScanModeValidate(t *testing.T) {
+	for _, mode := range []ScanMode{ScanModeActive, ScanModePassive, ScanModeBoth} {
+		m := mode
+		if err := m.Validate(); err != nil || m != mode {
+			t.Errorf("%s: expected valid mode, got %v", mode, err)
+		}
+	}
+
+	m := ScanMode("loud")
+	if err := m.Validate(); err == nil {
+		t.Fatal("expected an error for an unknown mode")
+	}
+	if m != ScanModeActive {
+		t.Errorf("expected mode reset to active, got %q", m)
+	}
+	if !ScanModeBoth.Queries() || !ScanModeBoth.Listens() || ScanModePassive.Queries() || ScanModeActive.Listens() {
+		t.Errorf("unexpected Queries/Listens")
+	}
+}
+
+func TestValidateAndNormalizePortScanTiming(t *testing.T) {
+	for _, profile := range TimingProfiles {
+		cfg := DefaultConfig()
+		cfg.PortScanner.Timing = profile
+		if err := cfg.validateAndNormalize(); err != nil || cfg.PortScanner.Timing != profile {
+			t.Errorf("%s: expected valid profile, got %v", profile, err)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.PortScanner.Timing = ""
+	cfg.PortScanner.Workers = -1
+	if err := cfg.validateAndNormalize(); err != nil {
+		t.Fatalf("expected empty profile to be valid, got %v", err)
+	}
+	if cfg.PortScanner.Timing != TimingNormal || cfg.PortScanner.Workers != 0 {
+		t.Errorf("expected normal profile and profile workers, got %+v", cfg.PortScanner)
+	}
+
+	cfg = DefaultConfig()
+	cfg.PortScanner.Timing = "insane"
+	err := cfg.validateAndNormalize()
+	if err == nil || !strings.Contains(err.Error(), "port_scanner.timing") {
+		t.Fatalf("expected port_scanner.timing error, got %v", err)
+	}
+	if cfg.PortScanner.Timing != DefaultPortScanTiming {
+		t.Errorf("expected profile reset to normal, got %q", cfg.PortScanner.Timing)
+	}
+
+	cfg = DefaultConfig()
+	cfg.PortScanner.Timeout = -time.Second
+	err = cfg.validateAndNormalize()
+	if err == nil || !strings.Contains(err.Error(), "port_scanner.timeout") || cfg.PortScanner.Timeout != 0 {
+		t.Errorf("expected port_scanner.timeout error and the profile timeout, got %v and %v", err, cfg.PortScanner.Timeout)
+	}
+}
+
+func TestValidateAndNormalizeMerge(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Merge = MergeConfig{
+		Default:        "Longest",
+		SourcePriority: []string{"local", "mdns", "reachability"},
+		Fields:         map[string]FieldMergeConfig{"extra_data": {Strategy: "most-recent"}},
+	}
+	if err := cfg.validateAndNormalize(); err != nil || cfg.Merge.Default != "Longest" {
+		t.Fatalf("expected valid merge config, got %v", err)
+	}
+
+	tests := []struct {
+		merge MergeConfig
+		want  string
+	}{
+		{MergeConfig{Default: "best", SourcePriority: []string{"arp"}}, `merge.default: unknown merge strategy "best"`},
+		{MergeConfig{Default: "union"}, `merge.default: "union" is only valid for map fields`},
+		{MergeConfig{SourcePriority: []string{"mdns", "bonjour"}}, `merge.source_priority: unknown source "bonjour"`},
+		{MergeConfig{Fields: map[string]FieldMergeConfig{"color": {Strategy: "longest"}}}, "merge.fields.color: unknown field"},
+		{MergeConfig{Fields: map[string]FieldMergeConfig{"extra_data": {Strategy: "longest"}}}, "merge.fields.extra_data: map fields only support"},
+		{MergeConfig{Fields: map[string]FieldMergeConfig{"os": {Strategy: "source-priority", SourcePriority: []string{"nmap"}}}}, `merge.fields.os.source_priority: unknown source "nmap"`},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Merge = tt.merge
+		err := cfg.validateAndNormalize()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: expected error %q, got %v", tt.merge, tt.want, err)
+		}
+		if cfg.Merge.Default != "" || cfg.Merge.SourcePriority != nil || cfg.Merge.Fields != nil {
+			t.Errorf("expected merge section reset, got %+v", cfg.Merge)
+		}
+	}
+}
+
+func TestYAMLUnmarshalSNMP(t *testing.T) {
+	raw := `
+snmp:
+  version: 1
+  communities: [private, public]
+  timeout: 0s
+`
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(raw), cfg); err != nil {
+		t.Fatalf("unmarshal yaml: %v", err)
+	}
+	if err := cfg.validateAndNormalize(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if cfg.SNMP.Version != "1" || len(cfg.SNMP.Communities) != 2 || cfg.SNMP.Communities[0] != "private" {
+		t.Errorf("unexpected snmp config %+v", cfg.SNMP)
+	}
+	if cfg.SNMP.Timeout != DefaultSNMPTimeout || cfg.SNMP.Port != DefaultSNMPPort || !cfg.SNMP.Enabled {
+		t.Errorf("expected defaults for unset snmp keys, got %+v", cfg.SNMP)
+	}
+}
+
+func TestValidateAndNormalizeSNMP(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SNMP.Version = "4"
+	cfg.SNMP.Communities = nil
+	err := cfg.validateAndNormalize()
+	if err == nil || !strings.Contains(err.Error(), "snmp.version") {
+		t.Fatalf("expected snmp.version error, got %v", err)
+	}
+	if cfg.SNMP.Version != DefaultSNMPVersion || len(cfg.SNMP.Communities) == 0 {
+		t.Errorf("expected defaults, got %+v", cfg.SNMP)
+	}
+
+	cfg = DefaultConfig()
+	cfg.SNMP.Version = "3"
+	cfg.SNMP.V3 = SNMPv3Config{Username: "monitor", AuthProtocol: "sha", AuthPassphrase: "short"}
+	err = cfg.validateAndNormalize()
+	if err == nil || !strings.Contains(err.Error(), "snmp.v3.auth_passphrase") {
+		t.Fatalf("expected snmp.v3.auth_passphrase error, got %v", err)
+	}
+}
+
+func TestYAMLUnmarshalPortSpecs(t *testing.T) {
+	raw := `
+port_scanner:
+  tcp: [22, "8000-8002", web]
+  udp: "53,123"
+`
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(raw), cfg); err != nil {
+		t.Fatalf("unmarshal yaml: %v", err)
+	}
+	// 8000 is also part of the web set
+	if got := cfg.PortScanner.TCP; len(got) != 4+len(ports.Sets["web"])-1 || got[0] != 22 || got[3] != 8002 {
+		t.Errorf("tcp ports unexpected: %v", got)
+	}
+	if !slices.Equal(cfg.PortScanner.UDP, []int{53, 123}) {
+		t.Errorf("udp ports unexpected: %v", cfg.PortScanner.UDP)
+	}
+
+	cfg = DefaultConfig()
+	if err := yaml.Unmarshal([]byte("port_scanner:\n  tcp: top100\n  udp: []\n"), cfg); err != nil {
+		t.Fatalf("unmarshal yaml: %v", err)
+	}
+	if len(cfg.PortScanner.TCP) != 100 || len(cfg.PortScanner.UDP) != 0 {
+		t.Errorf("expected top 100 tcp ports and no udp ports, got %d and %v", len(cfg.PortScanner.TCP), cfg.PortScanner.UDP)
+	}
+
+	if err := yaml.Unmarshal([]byte("port_scanner:\n  tcp: [http]\n"), DefaultConfig()); err == nil {
+		t.Error("expected error for an unknown port set")
+	}
+}
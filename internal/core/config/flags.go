@@ -2,7 +2,8 @@ package config
 
 // Flags represents CLI overrides provided by the user.
 type Flags struct {
-	ConfigFile       string
-	PprofPort        string
-	NetworkInterface string
+	ConfigFile        string
+	PprofPort         string
+	NetworkInterfaces []string
+	Ports             string
 }
@@ -6,6 +6,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/goccy/go-yaml"
@@ -115,6 +116,21 @@ func marshalConfigWithComments(cfg *Config) ([]byte, error) {
 		tcpPorts[i] = fmt.Sprintf("%d", p)
 	}
 
+	udpPorts := make([]string, len(cfg.PortScanner.UDP))
+	for i, p := range cfg.PortScanner.UDP {
+		udpPorts[i] = fmt.Sprintf("%d", p)
+	}
+
+	communities := make([]string, len(cfg.SNMP.Communities))
+	for i, c := range cfg.SNMP.Communities {
+		communities[i] = strconv.Quote(c)
+	}
+
+	scanners, err := marshalScanners(cfg.Scanners)
+	if err != nil {
+		return nil, err
+	}
+
 	commented := fmt.Sprintf(`# whosthere configuration file
 # For more information, visit: https://github.com/ramonvermeulen/whosthere
 
@@ -154,21 +170,57 @@ theme:
 
 # Scanner configuration
 scanners:
-  mdns:
-    enabled: %t
-  ssdp:
-    enabled: %t
-  arp:
-    enabled: %t
-
+%s
 # Port scanner configuration
 port_scanner:
+  # Longest wait for a port to answer, 0 uses the timing profile, the timeout adapts to the round trip time of the host below it
   timeout: %s
-  # List of TCP ports to scan on discovered devices
+  # Timing profile: polite (few probes per second and retries, for fragile devices), normal or aggressive
+  timing: %s
+  # TCP ports to scan on discovered devices, a list or a spec such as "1-1024,3389", top100 or web
+  # (ports 22, ranges 8000-8100, top1 to top1000 and the sets web, db, iot and windows)
   tcp: [%s]
+  # List of UDP ports to scan with protocol-aware probes, empty disables the UDP scan
+  udp: [%s]
+  # Concurrent probes, shared by all hosts when scanning many devices at once, 0 uses the timing profile
+  workers: %d
+  # Probes sent per second across all hosts, 0 uses the timing profile
+  rate: %d
+
+# SNMP credentials used by the device probe
+snmp:
+  enabled: %t
+  # 1, 2c or 3
+  version: %s
+  # Communities tried in order with version 1 and 2c
+  communities: [%s]
+  port: %d
+  timeout: %s
+  retries: %d
+  # SNMPv3 user (authNoPriv or noAuthNoPriv), auth_protocol is none, md5 or sha
+  # v3:
+  #   username: monitor
+  #   auth_protocol: sha
+  #   auth_passphrase: changeme123
+
+# Merge strategies used when multiple scanners report the same device
+# Strategies: first-wins, most-specific, longest, most-recent, source-priority, union (extra_data only)
+# merge:
+#   default: first-wins
+#   source_priority: [local, mdns, ssdp, arp, wsd, snmp, netbios, llmnr, dhcp-leases, rdns, icmp, reachability]
+#   fields:
+#     display_name:
+#       strategy: most-specific
+#     manufacturer:
+#       strategy: source-priority
+#       source_priority: [mdns, arp]
 
 # Uncomment the next line to configure a specific network interface - uses OS default if not set
 # network_interface: eth0
+# Uncomment the next lines to scan multiple interfaces at once (e.g. the LAN and a lab VLAN)
+# network_interfaces:
+#   - eth0
+#   - eth0.20
 `,
 		cfg.ScanInterval,
 		cfg.ScanDuration,
@@ -176,11 +228,19 @@ port_scanner:
 		cfg.Splash.Delay,
 		cfg.Theme.Enabled,
 		cfg.Theme.Name,
-		cfg.Scanners.MDNS.Enabled,
-		cfg.Scanners.SSDP.Enabled,
-		cfg.Scanners.ARP.Enabled,
+		scanners,
 		cfg.PortScanner.Timeout,
+		cfg.PortScanner.Timing,
 		strings.Join(tcpPorts, ", "),
+		strings.Join(udpPorts, ", "),
+		cfg.PortScanner.Workers,
+		cfg.PortScanner.Rate,
+		cfg.SNMP.Enabled,
+		cfg.SNMP.Version,
+		strings.Join(communities, ", "),
+		cfg.SNMP.Port,
+		cfg.SNMP.Timeout,
+		cfg.SNMP.Retries,
 	)
 
 	return []byte(commented), nil
@@ -199,3 +259,22 @@ func Save(cfg *Config, pathOverride string) error {
 
 	return writeConfigFile(resolvedPath, cfg)
 }
+
+// marshalScanners renders every registered scanner block, preceded by its description.
+func marshalScanners(sc ScannersConfig) (string, error) {
+	var b strings.Builder
+	for _, spec := range ScannerSpecs() {
+		data, err := yaml.Marshal(sc.Settings(spec.Name))
+		if err != nil {
+			return "", fmt.Errorf("scanners.%s: %w", spec.Name, err)
+		}
+		if spec.Description != "" {
+			fmt.Fprintf(&b, "  # %s\n", spec.Description)
+		}
+		fmt.Fprintf(&b, "  %s:\n", spec.Name)
+		for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+			fmt.Fprintf(&b, "    %s\n", line)
+		}
+	}
+	return b.String(), nil
+}
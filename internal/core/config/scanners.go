@@ -0,0 +1,253 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+)
+
+// ScannerSettings is implemented by the typed config block of every scanner.
+// Blocks usually embed ScannerToggle inline to get the `enabled` key.
+// A block may also implement `Validate() error` to check and normalize its own values.
+type ScannerSettings interface {
+	IsEnabled() bool
+	SetEnabled(enabled bool)
+}
+
+// ScannerToggle lets users enable/disable a scanner.
+type ScannerToggle struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+func (t *ScannerToggle) IsEnabled() bool { return t.Enabled }
+
+func (t *ScannerToggle) SetEnabled(enabled bool) { t.Enabled = enabled }
+
+// ScanMode selects how a multicast scanner (mDNS, SSDP) discovers devices.
+type ScanMode string
+
+const (
+	// ScanModeActive sends a query every scan and reads the responses.
+	ScanModeActive ScanMode = "active"
+	// ScanModePassive listens across scans for announcements and goodbyes, it sends nothing.
+	ScanModePassive ScanMode = "passive"
+	// ScanModeBoth queries every scan and listens in between.
+	ScanModeBoth ScanMode = "both"
+)
+
+// Validate resets an unknown mode to active.
+func (m *ScanMode) Validate() error {
+	switch *m {
+	case ScanModeActive, ScanModePassive, ScanModeBoth:
+		return nil
+	default:
+		mode := *m
+		*m = ScanModeActive
+		return fmt.Errorf("mode must be one of active, passive or both, got %q", mode)
+	}
+}
+
+// Queries reports whether the scanner sends a query every scan.
+func (m ScanMode) Queries() bool { return m != ScanModePassive }
+
+// Listens reports whether the scanner keeps listening between scans.
+func (m ScanMode) Listens() bool { return m == ScanModePassive || m == ScanModeBoth }
+
+// ScannerSpec describes the config side of a scanner, it is registered through discovery.Register.
+type ScannerSpec struct {
+	Name           string
+	Description    string
+	DefaultEnabled bool
+	// Sources are the device sources the scanner reports besides its name (e.g. llmnr).
+	Sources []string
+	// NewConfig returns a typed config block populated with defaults, Enabled is set from DefaultEnabled.
+	NewConfig func() ScannerSettings
+}
+
+var (
+	specsMu sync.RWMutex
+	specs   = map[string]ScannerSpec{}
+)
+
+// RegisterScanner makes a scanner config block known to the config loader.
+// It panics when the name is empty or already registered.
+func RegisterScanner(spec ScannerSpec) {
+	specsMu.Lock()
+	defer specsMu.Unlock()
+	if spec.Name == "" {
+		panic("config: RegisterScanner with empty name")
+	}
+	if _, dup := specs[spec.Name]; dup {
+		panic("config: RegisterScanner called twice for scanner " + spec.Name)
+	}
+	if spec.NewConfig == nil {
+		spec.NewConfig = func() ScannerSettings { return &ScannerToggle{} }
+	}
+	specs[spec.Name] = spec
+}
+
+// ScannerSpecs returns all registered scanner specs sorted by name.
+func ScannerSpecs() []ScannerSpec {
+	specsMu.RLock()
+	defer specsMu.RUnlock()
+	out := make([]ScannerSpec, 0, len(specs))
+	for _, s := range specs {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// LookupScannerSpec returns the registered spec for name.
+func LookupScannerSpec(name string) (ScannerSpec, bool) {
+	specsMu.RLock()
+	defer specsMu.RUnlock()
+	s, ok := specs[name]
+	return s, ok
+}
+
+// knownSource reports whether name is a device source: the local device, a registered
+// scanner or an extra source of one.
+func knownSource(name string) bool {
+	if name == SourceLocal {
+		return true
+	}
+	specsMu.RLock()
+	defer specsMu.RUnlock()
+	for _, s := range specs {
+		if s.Name == name || slices.Contains(s.Sources, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// newScannerSettings returns the default config block of a registered scanner.
+func (s ScannerSpec) newScannerSettings() ScannerSettings {
+	block := s.NewConfig()
+	block.SetEnabled(s.DefaultEnabled)
+	return block
+}
+
+// ScannersConfig maps scanner names to their typed config blocks (the YAML `scanners:` section).
+type ScannersConfig map[string]ScannerSettings
+
+// unknownScanner holds the block of a scanner name that is not registered,
+// it is reported and removed by validateAndNormalize.
+type unknownScanner struct {
+	ScannerToggle `yaml:",inline"`
+}
+
+// DefaultScannersConfig returns the default config block for every registered scanner.
+func DefaultScannersConfig() ScannersConfig {
+	sc := ScannersConfig{}
+	for _, spec := range ScannerSpecs() {
+		sc[spec.Name] = spec.newScannerSettings()
+	}
+	return sc
+}
+
+// UnmarshalYAML decodes every scanner block into the typed config of the registered scanner,
+// keeping defaults for keys that are not set.
+func (sc *ScannersConfig) UnmarshalYAML(data []byte) error {
+	var raw map[string]yaml.RawMessage
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if *sc == nil {
+		*sc = ScannersConfig{}
+	}
+	for name, block := range raw {
+		name = strings.ToLower(strings.TrimSpace(name))
+		settings, ok := (*sc)[name]
+		if !ok {
+			if spec, registered := LookupScannerSpec(name); registered {
+				settings = spec.newScannerSettings()
+			} else {
+				settings = &unknownScanner{}
+			}
+		}
+		if err := yaml.Unmarshal(block, settings); err != nil {
+			return fmt.Errorf("scanners.%s: %w", name, err)
+		}
+		(*sc)[name] = settings
+	}
+	return nil
+}
+
+// Enabled reports whether the scanner with the given name is enabled.
+func (sc ScannersConfig) Enabled(name string) bool {
+	s, ok := sc[name]
+	return ok && s.IsEnabled()
+}
+
+// EnabledNames returns the names of all enabled registered scanners, sorted by name.
+func (sc ScannersConfig) EnabledNames() []string {
+	var names []string
+	for _, spec := range ScannerSpecs() {
+		if sc.Enabled(spec.Name) {
+			names = append(names, spec.Name)
+		}
+	}
+	return names
+}
+
+// Settings returns the config block for name, or the registered defaults when it is not configured.
+func (sc ScannersConfig) Settings(name string) ScannerSettings {
+	if s, ok := sc[name]; ok {
+		return s
+	}
+	if spec, ok := LookupScannerSpec(name); ok {
+		return spec.newScannerSettings()
+	}
+	return nil
+}
+
+// validateAndNormalize removes unknown scanners, validates typed blocks and makes sure
+// at least one scanner is enabled.
+func (sc ScannersConfig) validateAndNormalize() []string {
+	var errs []string
+
+	names := make([]string, 0, len(sc))
+	for name := range sc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := LookupScannerSpec(name); !ok {
+			errs = append(errs, "unknown scanner: "+name)
+			delete(sc, name)
+			continue
+		}
+		if v, ok := sc[name].(interface{ Validate() error }); ok {
+			if err := v.Validate(); err != nil {
+				errs = append(errs, fmt.Sprintf("scanners.%s: %v", name, err))
+			}
+		}
+	}
+
+	// Blocks missing from an explicit scanners section are disabled, Load starts
+	// from DefaultConfig so registered defaults are already present there.
+	for _, spec := range ScannerSpecs() {
+		if _, ok := sc[spec.Name]; !ok {
+			block := spec.NewConfig()
+			block.SetEnabled(false)
+			sc[spec.Name] = block
+		}
+	}
+
+	if len(sc) > 0 && len(sc.EnabledNames()) == 0 {
+		errs = append(errs, "at least one scanner must be enabled")
+		for _, spec := range ScannerSpecs() {
+			if spec.DefaultEnabled {
+				sc[spec.Name].SetEnabled(true)
+			}
+		}
+	}
+
+	return errs
+}
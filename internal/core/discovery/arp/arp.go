@@ -8,7 +8,11 @@ import (
 	"go.uber.org/zap"
 )
 
-var _ discovery.Scanner = (*Scanner)(nil)
+// SourceReachability is the source of devices that answered a reachability sweep
+// instead of being found in the ARP cache.
+const SourceReachability = "reachability"
+
+var _ discovery.InterfaceScanner = (*Scanner)(nil)
 
 // Scanner implements ARP-based discovery by reading the ARP cache.
 // Optional Sweeper can populate the cache in the background.
@@ -25,6 +29,9 @@ func NewScanner(iface *discovery.InterfaceInfo, sweeper *Sweeper) *Scanner {
 
 func (s *Scanner) Name() string { return "arp" }
 
+// Interface returns the network interface the scanner is bound to.
+func (s *Scanner) Interface() *discovery.InterfaceInfo { return s.iface }
+
 // Scan performs ARP discovery.
 func (s *Scanner) Scan(ctx context.Context, out chan<- discovery.Device) error {
 	if s.logger == nil {
@@ -38,5 +45,28 @@ func (s *Scanner) Scan(ctx context.Context, out chan<- discovery.Device) error {
 
 	time.Sleep(1 * time.Second)
 
-	return s.readARPCache(ctx, out)
+	if err := s.readARPCache(ctx, out); err != nil {
+		return err
+	}
+	return s.emitReachable(ctx, out)
+}
+
+// emitReachable emits the hosts that answered a reachability sweep of the Sweeper.
+func (s *Scanner) emitReachable(ctx context.Context, out chan<- discovery.Device) error {
+	if s.Sweeper == nil {
+		return nil
+	}
+	for _, h := range s.Sweeper.Reachable() {
+		d := discovery.NewDevice(h.IP)
+		d.Sources[SourceReachability] = struct{}{}
+		d.LastSeen = h.LastSeen
+		d.FirstSeen = h.LastSeen
+		d.Addresses[0].FirstSeen, d.Addresses[0].LastSeen = h.LastSeen, h.LastSeen
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case out <- d:
+		}
+	}
+	return nil
 }
@@ -22,11 +22,61 @@ func (s *Scanner) readARPCache(ctx context.Context, out chan<- discovery.Device)
 	}
 }
 
-// Entry represents a single ARP cache entry.
+// hasIPv6 reports whether the neighbor cache should be read for IPv6 as well.
+func hasIPv6(iface *discovery.InterfaceInfo) bool {
+	return iface.IPv6LinkLocal != nil || len(iface.IPv6Addrs) > 0
+}
+
+// NeighborState is the reachability state of a neighbor cache entry, modelled after
+// the Linux NUD states. Readers that cannot tell leave it at StateUnknown.
+type NeighborState uint8
+
+const (
+	StateUnknown    NeighborState = iota // the reader does not expose a state (e.g. /proc/net/arp)
+	StateIncomplete                      // address resolution is in progress
+	StateReachable                       // recently confirmed reachable
+	StateStale                           // valid but not confirmed recently
+	StateDelay                           // stale, waiting before probing
+	StateProbe                           // stale, being probed
+	StateFailed                          // address resolution failed
+	StateNoARP                           // no resolution needed (e.g. point-to-point links)
+	StatePermanent                       // statically configured
+)
+
+func (s NeighborState) String() string {
+	switch s {
+	case StateIncomplete:
+		return "incomplete"
+	case StateReachable:
+		return "reachable"
+	case StateStale:
+		return "stale"
+	case StateDelay:
+		return "delay"
+	case StateProbe:
+		return "probe"
+	case StateFailed:
+		return "failed"
+	case StateNoARP:
+		return "noarp"
+	case StatePermanent:
+		return "permanent"
+	default:
+		return "unknown"
+	}
+}
+
+// usable reports whether entries in this state map a live device to its MAC.
+func (s NeighborState) usable() bool {
+	return s != StateIncomplete && s != StateFailed && s != StateNoARP
+}
+
+// Entry represents a single ARP (IPv4) or neighbor (IPv6) cache entry.
 type Entry struct {
 	IP            net.IP
 	MAC           net.HardwareAddr
-	Age           time.Duration
+	Age           time.Duration // time since the entry was last confirmed, 0 when unknown
+	State         NeighborState
 	InterfaceName string
 }
 
@@ -37,7 +87,7 @@ func (s *Scanner) emitARPEntries(ctx context.Context, out chan<- discovery.Devic
 	subnet := s.iface.IPv4Net
 
 	for _, entry := range entries {
-		if entry.IP == nil || entry.MAC == nil {
+		if entry.IP == nil || entry.MAC == nil || !entry.State.usable() {
 			continue
 		}
 
@@ -49,17 +99,23 @@ func (s *Scanner) emitARPEntries(ctx context.Context, out chan<- discovery.Devic
 		// - skip multicast MACs (I/G bit set)
 		// - skip broadcast MAC (FF:FF:FF:FF:FF:FF)
 		// - skip IPv4 broadcast address for our subnet
-		// - skip IPv4 multicast ranges (224.0.0.0/4)
+		// - skip IPv4 multicast ranges (224.0.0.0/4) and IPv6 multicast (ff00::/8)
 		if isMulticastMAC(entry.MAC) || isBroadcastMAC(entry.MAC) || isMulticastIPv4(entry.IP) || isBroadcastIPv4(entry.IP, subnet) {
 			continue
 		}
+		if entry.IP.To4() == nil && (entry.IP.IsMulticast() || entry.IP.IsUnspecified()) {
+			continue
+		}
 
 		dd := discovery.NewDevice(entry.IP)
 		dd.MAC = entry.MAC.String()
 		dd.Sources["arp"] = struct{}{}
 
 		if entry.Age > 0 {
+			// the device was last heard from when the kernel confirmed the entry
 			dd.LastSeen = now.Add(-entry.Age)
+			dd.FirstSeen = dd.LastSeen
+			dd.Addresses[0].FirstSeen, dd.Addresses[0].LastSeen = dd.LastSeen, dd.LastSeen
 		} else {
 			dd.LastSeen = now
 		}
@@ -1,8 +1,12 @@
 package arp
 
 import (
+	"context"
 	"net"
 	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
 )
 
 func TestIsMulticastMAC(t *testing.T) {
@@ -98,3 +102,40 @@ func TestIsMulticastIPv4(t *testing.T) {
 		})
 	}
 }
+
+func TestEmitARPEntries(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("192.168.1.0/24")
+	s := NewScanner(&discovery.InterfaceInfo{Interface: &net.Interface{Name: "eth0"}, IPv4Net: subnet}, nil)
+	mac := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+	entries := []Entry{
+		{IP: net.ParseIP("192.168.1.20"), MAC: mac, State: StateStale, Age: 3 * time.Minute, InterfaceName: "eth0"},
+		{IP: net.ParseIP("192.168.1.21"), MAC: mac, State: StateFailed, InterfaceName: "eth0"},
+		{IP: net.ParseIP("192.168.1.22"), MAC: mac, State: StateIncomplete, InterfaceName: "eth0"},
+		{IP: net.ParseIP("192.168.1.23"), MAC: mac, State: StateUnknown, InterfaceName: "eth0"},
+		{IP: net.ParseIP("192.168.1.24"), MAC: mac, State: StateReachable, InterfaceName: "wlan0"},
+	}
+
+	out := make(chan discovery.Device, len(entries))
+	if err := s.emitARPEntries(context.Background(), out, entries); err != nil {
+		t.Fatalf("emitARPEntries: %v", err)
+	}
+	close(out)
+
+	var got []discovery.Device
+	for d := range out {
+		got = append(got, d)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 devices, got %d", len(got))
+	}
+	if age := time.Since(got[0].LastSeen); age < 3*time.Minute || age > 4*time.Minute {
+		t.Errorf("expected LastSeen about 3m ago, got %s", age)
+	}
+	if !got[0].Addresses[0].LastSeen.Equal(got[0].LastSeen) {
+		t.Errorf("expected address LastSeen to follow the entry age")
+	}
+	if got[1].IP.String() != "192.168.1.23" || time.Since(got[1].LastSeen) > time.Minute {
+		t.Errorf("expected entry without age to be seen now, got %s at %s", got[1].IP, got[1].LastSeen)
+	}
+}
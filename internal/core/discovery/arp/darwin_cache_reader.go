@@ -8,6 +8,7 @@ import (
 	"net"
 
 	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"go.uber.org/zap"
 	"golang.org/x/net/route"
 	"golang.org/x/sys/unix"
 )
@@ -15,16 +16,24 @@ import (
 // readDarwinARPCache reads ARP table on Darwin/BSD systems using x/net/route.
 // We fetch the IPv4 routing RIB and filter RouteMessages with RTF_LLINFO (neighbor cache).
 // see https://man.freebsd.org/cgi/man.cgi?query=rtentry&sektion=9&manpath=FreeBSD+6.1-RELEASE
+// The IPv6 neighbor cache is read the same way from the AF_INET6 RIB.
 func (s *Scanner) readDarwinARPCache(ctx context.Context, out chan<- discovery.Device) error {
-	entries, err := s.readDarwinARPCacheRaw()
+	entries, err := s.readDarwinARPCacheRaw(unix.AF_INET)
 	if err != nil {
 		return fmt.Errorf("read darwin arp cache: %w", err)
 	}
+	if hasIPv6(s.iface) {
+		neighbors, err := s.readDarwinARPCacheRaw(unix.AF_INET6)
+		if err != nil {
+			zap.L().Debug("failed to read IPv6 neighbor cache", zap.String("scanner", "arp"), zap.Error(err))
+		}
+		entries = append(entries, neighbors...)
+	}
 	return s.emitARPEntries(ctx, out, entries)
 }
 
-func (s *Scanner) readDarwinARPCacheRaw() ([]Entry, error) {
-	b, err := route.FetchRIB(unix.AF_INET, route.RIBTypeRoute, 0)
+func (s *Scanner) readDarwinARPCacheRaw(family int) ([]Entry, error) {
+	b, err := route.FetchRIB(family, route.RIBTypeRoute, 0)
 	if err != nil {
 		return nil, fmt.Errorf("route.FetchRIB: %w", err)
 	}
@@ -53,6 +62,10 @@ func (s *Scanner) readDarwinARPCacheRaw() ([]Entry, error) {
 				if ip == nil {
 					ip = net.IPv4(v.IP[0], v.IP[1], v.IP[2], v.IP[3])
 				}
+			case *route.Inet6Addr:
+				if ip == nil {
+					ip = append(net.IP(nil), v.IP[:]...)
+				}
 			case *route.LinkAddr:
 				if mac == nil && len(v.Addr) >= 6 {
 					mac = v.Addr[:6]
@@ -13,17 +13,31 @@ import (
 
 	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
 	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
 )
 
-// readLinuxARPCache reads /proc/net/arp and emits completed entries.
+// procNetARPPath is the IPv4 ARP table exposed by procfs, used when netlink is unavailable.
+const procNetARPPath = "/proc/net/arp"
+
+// readLinuxARPCache reads the kernel neighbor table over rtnetlink and emits usable entries.
+// When the netlink dump fails (e.g. in restricted sandboxes) it falls back to /proc/net/arp,
+// which only carries completed IPv4 entries without state or age.
 // see https://man7.org/linux/man-pages/man5/proc_pid_net.5.html for more information about /proc/net/arp.
 func (s *Scanner) readLinuxARPCache(ctx context.Context, out chan<- discovery.Device) error {
 	log := zap.L().With(zap.String("scanner", "arp"))
 
-	entries, err := parseProcNetARP(ctx, "/proc/net/arp")
+	family := unix.AF_INET
+	if hasIPv6(s.iface) {
+		family = unix.AF_UNSPEC
+	}
+	entries, err := readNetlinkNeighbors(family)
 	if err != nil {
-		log.Debug("failed to parse /proc/net/arp", zap.Error(err))
-		return err
+		log.Debug("failed to read neighbor table over netlink, falling back to "+procNetARPPath, zap.Error(err))
+		entries, err = parseProcNetARP(ctx, procNetARPPath)
+		if err != nil {
+			log.Debug("failed to parse "+procNetARPPath, zap.Error(err))
+			return err
+		}
 	}
 	return s.emitARPEntries(ctx, out, entries)
 }
@@ -80,7 +94,13 @@ func parseProcNetARP(ctx context.Context, path string) ([]Entry, error) {
 
 		interfaceName := fields[5]
 
-		entries = append(entries, Entry{IP: ip, MAC: mac, InterfaceName: interfaceName})
+		// procfs does not expose NUD states, only whether the entry is static (ATF_PERM)
+		state := StateUnknown
+		if flags&0x4 != 0 {
+			state = StatePermanent
+		}
+
+		entries = append(entries, Entry{IP: ip, MAC: mac, State: state, InterfaceName: interfaceName})
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -0,0 +1,42 @@
+//go:build linux
+
+package arp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseProcNetARP(t *testing.T) {
+	entries, err := parseProcNetARP(context.Background(), "testdata/proc_net_arp")
+	if err != nil {
+		t.Fatalf("parseProcNetARP: %v", err)
+	}
+
+	want := []struct {
+		ip, mac, iface string
+		state          NeighborState
+	}{
+		{"192.168.1.1", "a4:91:b1:12:34:56", "eth0", StateUnknown},
+		{"192.168.1.20", "00:11:22:33:44:55", "eth0", StatePermanent},
+		{"10.8.0.5", "02:42:ac:11:00:02", "docker0", StateUnknown},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %d: %+v", len(want), len(entries), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.IP.String() != w.ip || e.MAC.String() != w.mac || e.InterfaceName != w.iface || e.State != w.state {
+			t.Errorf("entry %d: got %s %s %s %s, want %s %s %s %s", i, e.IP, e.MAC, e.InterfaceName, e.State, w.ip, w.mac, w.iface, w.state)
+		}
+		if e.Age != 0 {
+			t.Errorf("entry %d: expected no age from procfs, got %s", i, e.Age)
+		}
+	}
+}
+
+func TestParseProcNetARPMissingFile(t *testing.T) {
+	if _, err := parseProcNetARP(context.Background(), "testdata/does-not-exist"); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,202 @@
+//go:build linux
+
+package arp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// userHZ is the unit of the nda_cacheinfo timestamps (clock_t ticks), fixed at 100 on Linux.
+const userHZ = 100
+
+// readNetlinkNeighbors dumps the kernel neighbor table of the given address family
+// (unix.AF_INET, unix.AF_INET6 or unix.AF_UNSPEC for both) with an RTM_GETNEIGH request.
+// Unlike /proc/net/arp the dump carries the NUD state and the age of every entry.
+// see https://man7.org/linux/man-pages/man7/rtnetlink.7.html
+func readNetlinkNeighbors(family int) ([]Entry, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("netlink socket: %w", err)
+	}
+	defer func() {
+		_ = unix.Close(fd)
+	}()
+
+	sa := &unix.SockaddrNetlink{Family: unix.AF_NETLINK}
+	if err := unix.Bind(fd, sa); err != nil {
+		return nil, fmt.Errorf("netlink bind: %w", err)
+	}
+
+	req := make([]byte, unix.NLMSG_HDRLEN+unix.SizeofNdMsg)
+	binary.NativeEndian.PutUint32(req[0:4], uint32(len(req)))
+	binary.NativeEndian.PutUint16(req[4:6], unix.RTM_GETNEIGH)
+	binary.NativeEndian.PutUint16(req[6:8], unix.NLM_F_REQUEST|unix.NLM_F_DUMP)
+	binary.NativeEndian.PutUint32(req[8:12], 1) // sequence number
+	req[unix.NLMSG_HDRLEN] = byte(family)
+	if err := unix.Sendto(fd, req, 0, sa); err != nil {
+		return nil, fmt.Errorf("netlink send: %w", err)
+	}
+
+	names := map[int]string{}
+	var entries []Entry
+	buf := make([]byte, 1<<16)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("netlink receive: %w", err)
+		}
+		batch, done, err := parseNeighborMessages(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		for _, nb := range batch {
+			name, ok := names[nb.ifindex]
+			if !ok {
+				if iface, err := net.InterfaceByIndex(nb.ifindex); err == nil {
+					name = iface.Name
+				}
+				names[nb.ifindex] = name
+			}
+			entries = append(entries, nb.entry(name))
+		}
+		if done {
+			return entries, nil
+		}
+	}
+}
+
+// neighbor is a decoded RTM_NEWNEIGH message.
+type neighbor struct {
+	ip      net.IP
+	mac     net.HardwareAddr
+	ifindex int
+	state   uint16
+	age     time.Duration
+}
+
+// entry converts the neighbor to an Entry of the named interface.
+func (nb neighbor) entry(ifaceName string) Entry {
+	e := Entry{IP: nb.ip, MAC: nb.mac, State: nudState(nb.state), InterfaceName: ifaceName}
+	if e.State != StatePermanent {
+		// confirmed timestamps of static entries are meaningless
+		e.Age = nb.age
+	}
+	return e
+}
+
+// nudState maps the NUD_* bit of an ndmsg to a NeighborState.
+func nudState(state uint16) NeighborState {
+	switch {
+	case state&unix.NUD_PERMANENT != 0:
+		return StatePermanent
+	case state&unix.NUD_REACHABLE != 0:
+		return StateReachable
+	case state&unix.NUD_STALE != 0:
+		return StateStale
+	case state&unix.NUD_DELAY != 0:
+		return StateDelay
+	case state&unix.NUD_PROBE != 0:
+		return StateProbe
+	case state&unix.NUD_INCOMPLETE != 0:
+		return StateIncomplete
+	case state&unix.NUD_FAILED != 0:
+		return StateFailed
+	case state&unix.NUD_NOARP != 0:
+		return StateNoARP
+	default:
+		return StateUnknown
+	}
+}
+
+// parseNeighborMessages decodes one netlink receive buffer. done is true once the
+// NLMSG_DONE message of the dump was seen.
+func parseNeighborMessages(b []byte) (neighbors []neighbor, done bool, err error) {
+	for len(b) >= unix.NLMSG_HDRLEN {
+		msgLen := int(binary.NativeEndian.Uint32(b[0:4]))
+		msgType := binary.NativeEndian.Uint16(b[4:6])
+		if msgLen < unix.NLMSG_HDRLEN || msgLen > len(b) {
+			return nil, false, fmt.Errorf("netlink: invalid message length %d", msgLen)
+		}
+		body := b[unix.NLMSG_HDRLEN:msgLen]
+
+		switch msgType {
+		case unix.NLMSG_DONE:
+			return neighbors, true, nil
+		case unix.NLMSG_ERROR:
+			if len(body) >= 4 {
+				if errno := int32(binary.NativeEndian.Uint32(body[0:4])); errno != 0 {
+					return nil, false, fmt.Errorf("netlink: %w", syscall.Errno(-errno))
+				}
+			}
+		case unix.RTM_NEWNEIGH:
+			if nb, ok := parseNdMsg(body); ok {
+				neighbors = append(neighbors, nb)
+			}
+		}
+
+		next := nlmsgAlign(msgLen)
+		if next > len(b) {
+			break
+		}
+		b = b[next:]
+	}
+	return neighbors, false, nil
+}
+
+// parseNdMsg decodes the ndmsg header and the NDA_DST, NDA_LLADDR and NDA_CACHEINFO attributes.
+// Entries without a link-layer address (e.g. INCOMPLETE or FAILED ones) keep a nil mac.
+func parseNdMsg(body []byte) (neighbor, bool) {
+	if len(body) < unix.SizeofNdMsg {
+		return neighbor{}, false
+	}
+	nb := neighbor{
+		ifindex: int(int32(binary.NativeEndian.Uint32(body[4:8]))),
+		state:   binary.NativeEndian.Uint16(body[8:10]),
+	}
+
+	attrs := body[unix.SizeofNdMsg:]
+	for len(attrs) >= unix.SizeofRtAttr {
+		attrLen := int(binary.NativeEndian.Uint16(attrs[0:2]))
+		attrType := binary.NativeEndian.Uint16(attrs[2:4])
+		if attrLen < unix.SizeofRtAttr || attrLen > len(attrs) {
+			break
+		}
+		data := attrs[unix.SizeofRtAttr:attrLen]
+		switch attrType {
+		case unix.NDA_DST:
+			nb.ip = append(net.IP(nil), data...)
+		case unix.NDA_LLADDR:
+			nb.mac = append(net.HardwareAddr(nil), data...)
+		case unix.NDA_CACHEINFO:
+			// struct nda_cacheinfo { ndm_confirmed, ndm_used, ndm_updated, ndm_refcnt }
+			if len(data) >= 4 {
+				confirmed := binary.NativeEndian.Uint32(data[0:4])
+				nb.age = time.Duration(confirmed) * time.Second / userHZ
+			}
+		}
+		next := nlmsgAlign(attrLen)
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+
+	if len(nb.ip) != net.IPv4len && len(nb.ip) != net.IPv6len {
+		return neighbor{}, false
+	}
+	if len(nb.mac) > 0 && (isBroadcastMAC(nb.mac) || isMulticastMAC(nb.mac)) {
+		return neighbor{}, false
+	}
+	return nb, true
+}
+
+// nlmsgAlign rounds n up to the 4 byte netlink alignment.
+func nlmsgAlign(n int) int {
+	return (n + unix.NLMSG_ALIGNTO - 1) &^ (unix.NLMSG_ALIGNTO - 1)
+}
@@ -0,0 +1,105 @@
+//go:build linux
+
+package arp
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// rtattr encodes a netlink route attribute, padded to the 4 byte alignment.
+func rtattr(typ uint16, data []byte) []byte {
+	b := make([]byte, nlmsgAlign(unix.SizeofRtAttr+len(data)))
+	binary.NativeEndian.PutUint16(b[0:2], uint16(unix.SizeofRtAttr+len(data)))
+	binary.NativeEndian.PutUint16(b[2:4], typ)
+	copy(b[unix.SizeofRtAttr:], data)
+	return b
+}
+
+// newNeighMsg encodes an RTM_NEWNEIGH message as the kernel sends it in a dump.
+func newNeighMsg(family uint8, ifindex int32, state uint16, ip net.IP, mac net.HardwareAddr, confirmedTicks uint32) []byte {
+	body := make([]byte, unix.SizeofNdMsg)
+	body[0] = family
+	binary.NativeEndian.PutUint32(body[4:8], uint32(ifindex))
+	binary.NativeEndian.PutUint16(body[8:10], state)
+	body = append(body, rtattr(unix.NDA_DST, ip)...)
+	if mac != nil {
+		body = append(body, rtattr(unix.NDA_LLADDR, mac)...)
+	}
+	cacheinfo := make([]byte, 16)
+	binary.NativeEndian.PutUint32(cacheinfo[0:4], confirmedTicks)
+	body = append(body, rtattr(unix.NDA_CACHEINFO, cacheinfo)...)
+	return nlmsg(unix.RTM_NEWNEIGH, body)
+}
+
+func nlmsg(typ uint16, body []byte) []byte {
+	b := make([]byte, unix.NLMSG_HDRLEN, unix.NLMSG_HDRLEN+len(body))
+	binary.NativeEndian.PutUint32(b[0:4], uint32(unix.NLMSG_HDRLEN+len(body)))
+	binary.NativeEndian.PutUint16(b[4:6], typ)
+	return append(b, body...)
+}
+
+func TestParseNeighborMessages(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	var buf []byte
+	buf = append(buf, newNeighMsg(unix.AF_INET, 2, unix.NUD_REACHABLE, net.ParseIP("192.168.1.20").To4(), mac, 150)...)
+	buf = append(buf, newNeighMsg(unix.AF_INET6, 2, unix.NUD_STALE, net.ParseIP("fe80::211:22ff:fe33:4455"), mac, 6000)...)
+	buf = append(buf, newNeighMsg(unix.AF_INET, 2, unix.NUD_FAILED, net.ParseIP("192.168.1.30").To4(), nil, 0)...)
+	buf = append(buf, newNeighMsg(unix.AF_INET, 2, unix.NUD_PERMANENT, net.ParseIP("192.168.1.1").To4(), mac, 90000)...)
+	buf = append(buf, newNeighMsg(unix.AF_INET, 2, unix.NUD_NOARP, net.ParseIP("224.0.0.251").To4(), net.HardwareAddr{0x01, 0x00, 0x5e, 0, 0, 0xfb}, 0)...)
+
+	neighbors, done, err := parseNeighborMessages(buf)
+	if err != nil {
+		t.Fatalf("parseNeighborMessages: %v", err)
+	}
+	if done {
+		t.Errorf("expected dump to continue without NLMSG_DONE")
+	}
+
+	want := []struct {
+		ip    string
+		state NeighborState
+		age   time.Duration
+		mac   bool
+	}{
+		{"192.168.1.20", StateReachable, 1500 * time.Millisecond, true},
+		{"fe80::211:22ff:fe33:4455", StateStale, time.Minute, true},
+		{"192.168.1.30", StateFailed, 0, false},
+		{"192.168.1.1", StatePermanent, 0, true},
+	}
+	if len(neighbors) != len(want) {
+		t.Fatalf("expected %d neighbors, got %d: %+v", len(want), len(neighbors), neighbors)
+	}
+	for i, w := range want {
+		e := neighbors[i].entry("eth0")
+		if e.IP.String() != w.ip || e.State != w.state || e.Age != w.age || (e.MAC != nil) != w.mac {
+			t.Errorf("entry %d: got %s %s %s mac=%v, want %s %s %s mac=%v", i, e.IP, e.State, e.Age, e.MAC, w.ip, w.state, w.age, w.mac)
+		}
+		if e.InterfaceName != "eth0" {
+			t.Errorf("entry %d: expected interface eth0, got %q", i, e.InterfaceName)
+		}
+	}
+}
+
+func TestParseNeighborMessagesDoneAndError(t *testing.T) {
+	_, done, err := parseNeighborMessages(nlmsg(unix.NLMSG_DONE, make([]byte, 4)))
+	if err != nil || !done {
+		t.Errorf("expected done without error, got done=%v err=%v", done, err)
+	}
+
+	errBody := make([]byte, 4)
+	errno := -int32(unix.EPERM)
+	binary.NativeEndian.PutUint32(errBody, uint32(errno))
+	if _, _, err := parseNeighborMessages(nlmsg(unix.NLMSG_ERROR, errBody)); err == nil {
+		t.Errorf("expected NLMSG_ERROR to be returned")
+	}
+
+	truncated := newNeighMsg(unix.AF_INET, 2, unix.NUD_REACHABLE, net.ParseIP("192.168.1.20").To4(), nil, 0)
+	if _, _, err := parseNeighborMessages(truncated[:len(truncated)-4]); err == nil {
+		t.Errorf("expected an error for a truncated message")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package arp
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isRefused reports whether a dial or read failed because the host refused the connection.
+func isRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package arp
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// isRefused reports whether a dial failed because the host refused the connection.
+// Windows does not report ICMP port unreachable on UDP sockets, only TCP resets count.
+func isRefused(err error) bool {
+	return errors.Is(err, windows.WSAECONNREFUSED)
+}
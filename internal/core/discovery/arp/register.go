@@ -0,0 +1,102 @@
+package arp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+const (
+	DefaultSweepInterval = 5 * time.Minute
+	defaultSweepDebounce = time.Minute
+)
+
+// Config is the `scanners.arp` config block.
+type Config struct {
+	config.ScannerToggle `yaml:",inline"`
+	// SweepInterval is how often the subnet is swept to populate the ARP cache.
+	SweepInterval time.Duration `yaml:"sweep_interval"`
+	// Targets are extra IPv4 prefixes swept per interface name, e.g. routed subnets or hosts behind a VPN.
+	Targets map[string][]string `yaml:"targets"`
+	// Reachability reports the hosts of the local subnet that answer the sweep directly,
+	// not only through the ARP cache. Hosts of routed targets are always reported this way.
+	Reachability bool `yaml:"reachability"`
+}
+
+// Validate resets an invalid sweep interval to its default and drops targets that are not IPv4 prefixes.
+func (c *Config) Validate() error {
+	var errs []string
+	if c.SweepInterval <= 0 {
+		c.SweepInterval = DefaultSweepInterval
+		errs = append(errs, "sweep_interval must be > 0")
+	}
+	for name, targets := range c.Targets {
+		valid := targets[:0]
+		for _, target := range targets {
+			if _, err := parseTarget(target); err != nil {
+				errs = append(errs, fmt.Sprintf("targets.%s: %v", name, err))
+				continue
+			}
+			valid = append(valid, target)
+		}
+		c.Targets[name] = valid
+	}
+	if len(errs) > 0 {
+		slices.Sort(errs)
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// parseTarget parses an IPv4 prefix, a single address is a /32.
+func parseTarget(target string) (*net.IPNet, error) {
+	if ip := net.ParseIP(target).To4(); ip != nil {
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	_, prefix, err := net.ParseCIDR(target)
+	if err != nil || prefix.IP.To4() == nil {
+		return nil, fmt.Errorf("%q is not an IPv4 prefix", target)
+	}
+	return prefix, nil
+}
+
+// targetsOf returns the parsed targets of the interface.
+func (c *Config) targetsOf(iface *discovery.InterfaceInfo) []*net.IPNet {
+	if iface == nil || iface.Interface == nil {
+		return nil
+	}
+	var out []*net.IPNet
+	for _, target := range c.Targets[iface.Interface.Name] {
+		if prefix, err := parseTarget(target); err == nil {
+			out = append(out, prefix)
+		}
+	}
+	return out
+}
+
+func init() {
+	discovery.Register(discovery.Registration{
+		Name:           "arp",
+		Description:    "ARP cache reader, sweeps the subnet and targets to populate the cache",
+		DefaultEnabled: true,
+		Sources:        []string{SourceReachability},
+		NewConfig: func() config.ScannerSettings {
+			return &Config{SweepInterval: DefaultSweepInterval}
+		},
+		New: func(iface *discovery.InterfaceInfo, settings config.ScannerSettings) (discovery.Scanner, error) {
+			cfg, ok := settings.(*Config)
+			if !ok {
+				return nil, fmt.Errorf("unexpected config type %T", settings)
+			}
+			sweeper := NewSweeper(iface, cfg.SweepInterval, defaultSweepDebounce,
+				WithTargets(cfg.targetsOf(iface)), WithReachability(cfg.Reachability))
+			return NewScanner(iface, sweeper), nil
+		},
+	})
+}
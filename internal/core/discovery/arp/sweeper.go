@@ -28,10 +28,16 @@ var (
 // by sending UDP/TCP packets to IPs in the target subnet. This causes the OS
 // to send ARP requests for those IPs, populating the ARP cache which can
 // then be read by the ARP scanner.
+//
+// Hosts of routed subnets and hosts behind point-to-point (VPN) links never
+// show up in the ARP cache. For those the sweep checks whether the host answers
+// the packets itself and records it in a reachability table, see Reachable.
 type Sweeper struct {
-	iface    *discovery.InterfaceInfo
-	interval time.Duration
-	debounce time.Duration
+	iface        *discovery.InterfaceInfo
+	interval     time.Duration
+	debounce     time.Duration
+	targets      []*net.IPNet
+	reachability bool
 
 	logger *zap.Logger
 
@@ -39,9 +45,34 @@ type Sweeper struct {
 	started  bool
 	inFlight map[string]time.Time
 	workCh   chan *net.IPNet
+
+	reachableMu sync.Mutex
+	reachable   map[string]ReachableHost
+}
+
+// ReachableHost is a host that answered a reachability sweep.
+type ReachableHost struct {
+	IP       net.IP
+	LastSeen time.Time
 }
 
-func NewSweeper(iface *discovery.InterfaceInfo, interval, debounce time.Duration) *Sweeper {
+// SweeperOption configures optional Sweeper behavior.
+type SweeperOption func(*Sweeper)
+
+// WithTargets sweeps the prefixes besides the subnet of the interface, e.g. routed lab
+// subnets or the hosts behind a VPN. Hosts of targets outside the subnet are recorded
+// in the reachability table.
+func WithTargets(targets []*net.IPNet) SweeperOption {
+	return func(s *Sweeper) { s.targets = targets }
+}
+
+// WithReachability records the hosts of the subnet of the interface in the
+// reachability table too, not only in the ARP cache.
+func WithReachability(enabled bool) SweeperOption {
+	return func(s *Sweeper) { s.reachability = enabled }
+}
+
+func NewSweeper(iface *discovery.InterfaceInfo, interval, debounce time.Duration, opts ...SweeperOption) *Sweeper {
 	if interval <= 0 {
 		interval = 5 * time.Minute
 	}
@@ -52,14 +83,19 @@ func NewSweeper(iface *discovery.InterfaceInfo, interval, debounce time.Duration
 		zap.String("scanner", "arp"),
 		zap.String("component", "Sweeper"),
 	)
-	return &Sweeper{
-		iface:    iface,
-		interval: interval,
-		debounce: debounce,
-		logger:   logger,
-		inFlight: make(map[string]time.Time),
-		workCh:   make(chan *net.IPNet, 8),
+	s := &Sweeper{
+		iface:     iface,
+		interval:  interval,
+		debounce:  debounce,
+		logger:    logger,
+		inFlight:  make(map[string]time.Time),
+		workCh:    make(chan *net.IPNet, 8),
+		reachable: make(map[string]ReachableHost),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 func (s *Sweeper) Start(ctx context.Context) {
@@ -71,9 +107,20 @@ func (s *Sweeper) Start(ctx context.Context) {
 	s.started = true
 	s.mu.Unlock()
 
-	subnet := s.iface.IPv4Net
+	if s.iface.IPv4Addr == nil {
+		// IPv6 neighbors are found through multicast discovery, there is no subnet to sweep
+		s.logger.Debug("interface has no IPv4 address, not sweeping")
+		return
+	}
+	subnets := s.subnets()
+	if len(subnets) == 0 {
+		s.logger.Debug("interface has no IPv4 subnet and no targets, not sweeping")
+		return
+	}
 	localIP := *s.iface.IPv4Addr
-	s.enqueue(subnet)
+	for _, sn := range subnets {
+		s.enqueue(sn)
+	}
 
 	ticker := time.NewTicker(s.interval)
 	go func() {
@@ -83,7 +130,9 @@ func (s *Sweeper) Start(ctx context.Context) {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				s.enqueue(subnet)
+				for _, sn := range subnets {
+					s.enqueue(sn)
+				}
 			case sn := <-s.workCh:
 				if sn == nil {
 					continue
@@ -94,6 +143,49 @@ func (s *Sweeper) Start(ctx context.Context) {
 	}()
 }
 
+// subnets returns the subnet of the interface followed by the targets.
+func (s *Sweeper) subnets() []*net.IPNet {
+	var out []*net.IPNet
+	if s.iface.IPv4Net != nil {
+		out = append(out, s.iface.IPv4Net)
+	}
+	return append(out, s.targets...)
+}
+
+// recordsReachability reports whether the hosts of subnet are recorded in the reachability
+// table: they are when reachability is enabled, or when they cannot be in the ARP cache
+// because the subnet is routed or the interface is a point-to-point link.
+func (s *Sweeper) recordsReachability(subnet *net.IPNet) bool {
+	if s.reachability || s.iface.PointToPoint() {
+		return true
+	}
+	local := s.iface.IPv4Net
+	return local == nil || !local.Contains(subnet.IP)
+}
+
+// Reachable returns the hosts that answered a reachability sweep within the last two
+// sweep intervals, older entries are dropped.
+func (s *Sweeper) Reachable() []ReachableHost {
+	cutoff := time.Now().Add(-2 * s.interval)
+	s.reachableMu.Lock()
+	defer s.reachableMu.Unlock()
+	out := make([]ReachableHost, 0, len(s.reachable))
+	for key, h := range s.reachable {
+		if h.LastSeen.Before(cutoff) {
+			delete(s.reachable, key)
+			continue
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+func (s *Sweeper) markReachable(ip net.IP) {
+	s.reachableMu.Lock()
+	defer s.reachableMu.Unlock()
+	s.reachable[ip.String()] = ReachableHost{IP: ip, LastSeen: time.Now()}
+}
+
 func (s *Sweeper) Trigger(subnet *net.IPNet) {
 	s.enqueue(subnet)
 }
@@ -124,12 +216,24 @@ func (s *Sweeper) runSweep(ctx context.Context, subnet *net.IPNet, localIP net.I
 		return
 	}
 
+	if s.recordsReachability(subnet) {
+		s.logger.Debug("Checking reachability of subnet", zap.String("subnet", subnet.String()))
+		triggerSubnetSweep(ctx, ips, func(ip net.IP) {
+			if probeTarget(ip) {
+				s.markReachable(ip)
+			}
+		})
+		s.logger.Debug("Reachability sweep completed", zap.String("subnet", subnet.String()))
+		return
+	}
+
 	s.logger.Debug("Triggering ARP requests for subnet", zap.String("subnet", subnet.Mask.String()))
-	triggerSubnetSweep(ctx, ips)
+	triggerSubnetSweep(ctx, ips, sendARPTarget)
 	s.logger.Debug("ARP triggering completed", zap.String("subnet", subnet.String()))
 }
 
-func triggerSubnetSweep(ctx context.Context, ips []net.IP) {
+// triggerSubnetSweep runs send for every IP, at most maxConcurrentTriggers at a time.
+func triggerSubnetSweep(ctx context.Context, ips []net.IP, send func(net.IP)) {
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, maxConcurrentTriggers)
 	total := len(ips)
@@ -154,7 +258,7 @@ func triggerSubnetSweep(ctx context.Context, ips []net.IP) {
 		go func(targetIP net.IP) {
 			defer wg.Done()
 			defer func() { <-sem }()
-			sendARPTarget(targetIP)
+			send(targetIP)
 		}(ip)
 	}
 
@@ -183,3 +287,36 @@ func sendARPTarget(ip net.IP) {
 		}
 	}
 }
+
+// probeTarget sends the same packets as sendARPTarget and reports whether the host
+// answered them: an accepted or refused TCP connection, or an ICMP port unreachable
+// in response to a UDP packet. Silence and ICMP host unreachable mean no host.
+func probeTarget(ip net.IP) bool {
+	for _, p := range tcpTriggerPorts {
+		addr := net.JoinHostPort(ip.String(), strconv.Itoa(p))
+		c, err := net.DialTimeout("tcp", addr, tcpDialTimeout)
+		if err == nil {
+			_ = c.Close()
+			return true
+		}
+		if isRefused(err) {
+			return true
+		}
+	}
+
+	for _, p := range udpTriggerPorts {
+		conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: ip, Port: p})
+		if err != nil {
+			continue
+		}
+		_ = conn.SetDeadline(time.Now().Add(triggerDeadline))
+		_, _ = conn.Write([]byte{0})
+		// the port unreachable is reported as a refused read on the connected socket
+		_, err = conn.Read(make([]byte, 1))
+		_ = conn.Close()
+		if err == nil || isRefused(err) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package arp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+func lanInterface(flags net.Flags) *discovery.InterfaceInfo {
+	ip := net.ParseIP("192.168.1.10").To4()
+	return &discovery.InterfaceInfo{
+		Interface: &net.Interface{Name: "eth0", Flags: flags},
+		IPv4Addr:  &ip,
+		IPv4Net:   &net.IPNet{IP: ip, Mask: net.CIDRMask(24, 32)},
+	}
+}
+
+func TestSweeperRecordsReachability(t *testing.T) {
+	_, local, _ := net.ParseCIDR("192.168.1.0/24")
+	_, routed, _ := net.ParseCIDR("10.20.0.0/24")
+
+	tests := []struct {
+		name   string
+		s      *Sweeper
+		subnet *net.IPNet
+		want   bool
+	}{
+		{"local subnet uses the ARP cache", NewSweeper(lanInterface(net.FlagBroadcast), 0, 0), local, false},
+		{"routed target", NewSweeper(lanInterface(net.FlagBroadcast), 0, 0), routed, true},
+		{"reachability enabled", NewSweeper(lanInterface(net.FlagBroadcast), 0, 0, WithReachability(true)), local, true},
+		{"point-to-point link", NewSweeper(lanInterface(net.FlagPointToPoint), 0, 0), local, true},
+	}
+	for _, tt := range tests {
+		if got := tt.s.recordsReachability(tt.subnet); got != tt.want {
+			t.Errorf("%s: recordsReachability = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSweeperSubnets(t *testing.T) {
+	_, routed, _ := net.ParseCIDR("10.20.0.0/24")
+	s := NewSweeper(lanInterface(net.FlagBroadcast), 0, 0, WithTargets([]*net.IPNet{routed}))
+	subnets := s.subnets()
+	if len(subnets) != 2 || subnets[0].String() != "192.168.1.10/24" || subnets[1] != routed {
+		t.Errorf("expected interface subnet and target, got %v", subnets)
+	}
+}
+
+func TestSweeperReachableExpires(t *testing.T) {
+	s := NewSweeper(lanInterface(net.FlagBroadcast), time.Minute, 0)
+	s.markReachable(net.ParseIP("10.20.0.5"))
+	s.reachable["10.20.0.6"] = ReachableHost{IP: net.ParseIP("10.20.0.6"), LastSeen: time.Now().Add(-3 * time.Minute)}
+
+	hosts := s.Reachable()
+	if len(hosts) != 1 || hosts[0].IP.String() != "10.20.0.5" {
+		t.Errorf("expected only the recent host, got %v", hosts)
+	}
+	if _, ok := s.reachable["10.20.0.6"]; ok {
+		t.Error("expected expired host to be dropped")
+	}
+}
+
+func TestProbeTargetRefused(t *testing.T) {
+	// loopback accepts or refuses the TCP triggers, either proves the host is there
+	if !probeTarget(net.ParseIP("127.0.0.1")) {
+		t.Error("expected loopback to be reachable")
+	}
+}
+
+func TestRunSweepRecordsRoutedHosts(t *testing.T) {
+	_, target, _ := net.ParseCIDR("127.0.0.1/32")
+	s := NewSweeper(lanInterface(net.FlagBroadcast), 0, 0, WithTargets([]*net.IPNet{target}))
+	s.runSweep(context.Background(), target, net.ParseIP("192.168.1.10"))
+
+	scanner := NewScanner(s.iface, s)
+	out := make(chan discovery.Device, 1)
+	if err := scanner.emitReachable(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+	d, ok := <-out
+	if !ok || d.IP.String() != "127.0.0.1" {
+		t.Fatalf("expected device for the routed host, got %+v", d)
+	}
+	if _, ok := d.Sources[SourceReachability]; !ok {
+		t.Errorf("expected source %q, got %v", SourceReachability, d.Sources)
+	}
+}
+
+func TestConfigTargets(t *testing.T) {
+	c := &Config{SweepInterval: DefaultSweepInterval, Targets: map[string][]string{
+		"wg0":  {"10.100.0.0/24", "10.100.1.7", "fd00::/64"},
+		"eth0": {"lab"},
+	}}
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for the IPv6 and the invalid target")
+	}
+	if len(c.Targets["wg0"]) != 2 || len(c.Targets["eth0"]) != 0 {
+		t.Errorf("expected invalid targets dropped, got %v", c.Targets)
+	}
+
+	wg0 := &discovery.InterfaceInfo{Interface: &net.Interface{Name: "wg0"}}
+	targets := c.targetsOf(wg0)
+	if len(targets) != 2 || targets[0].String() != "10.100.0.0/24" || targets[1].String() != "10.100.1.7/32" {
+		t.Errorf("unexpected targets of wg0 %v", targets)
+	}
+	if got := c.targetsOf(lanInterface(net.FlagBroadcast)); len(got) != 0 {
+		t.Errorf("expected no targets for eth0, got %v", got)
+	}
+}
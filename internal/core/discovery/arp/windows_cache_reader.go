@@ -54,6 +54,14 @@ func (s *Scanner) readWindowsARPCache(ctx context.Context, out chan<- discovery.
 		return err
 	}
 
+	if hasIPv6(s.iface) {
+		neighbors, err := s.getIpNetTable2(ctx)
+		if err != nil {
+			log.Debug("failed to get windows IPv6 neighbor table via API", zap.Error(err))
+		}
+		entries = append(entries, neighbors...)
+	}
+
 	return s.emitARPEntries(ctx, out, entries)
 }
 
@@ -168,10 +176,15 @@ func (s *Scanner) getIpNetTable(ctx context.Context) ([]Entry, error) {
 		if row.Type == 2 {
 			continue
 		}
+		state := StateUnknown
+		if row.Type == 4 {
+			state = StatePermanent
+		}
 
 		entries = append(entries, Entry{
 			IP:            ip,
 			MAC:           mac,
+			State:         state,
 			InterfaceName: s.iface.Interface.Name,
 			Age:           0,
 		})
@@ -0,0 +1,103 @@
+//go:build windows
+
+package arp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Windows API definitions for GetIpNetTable2, which unlike GetIpNetTable also returns IPv6 neighbors.
+// https://learn.microsoft.com/en-us/windows/win32/api/netioapi/nf-netioapi-getipnettable2
+
+// NL_NEIGHBOR_STATE values.
+// https://learn.microsoft.com/en-us/windows/win32/api/nldef/ne-nldef-nl_neighbor_state
+var nlNeighborStates = map[uint32]NeighborState{
+	0: StateFailed, // NlnsUnreachable
+	1: StateIncomplete,
+	2: StateProbe,
+	3: StateDelay,
+	4: StateStale,
+	5: StateReachable,
+	6: StatePermanent,
+}
+
+const (
+	// mibIPNetTable2RowOffset is the offset of the first row, NumEntries is padded to the 8 byte row alignment.
+	mibIPNetTable2RowOffset = 8
+	// sockaddrIn6AddrOffset is the offset of sin6_addr in SOCKADDR_IN6.
+	sockaddrIn6AddrOffset = 8
+)
+
+// MIB_IPNET_ROW2 stores information about a neighbor IP address.
+type MIB_IPNET_ROW2 struct {
+	Address               [28]byte // SOCKADDR_INET
+	InterfaceIndex        uint32
+	InterfaceLuid         uint64
+	PhysicalAddress       [32]byte
+	PhysicalAddressLength uint32
+	State                 uint32
+	Flags                 uint8
+	_                     [3]byte
+	ReachabilityTime      uint32
+}
+
+var (
+	procGetIpNetTable2 = modiphlpapi.NewProc("GetIpNetTable2")
+	procFreeMibTable   = modiphlpapi.NewProc("FreeMibTable")
+)
+
+// getIpNetTable2 returns the IPv6 neighbor entries of our interface.
+func (s *Scanner) getIpNetTable2(ctx context.Context) ([]Entry, error) {
+	var table unsafe.Pointer
+	r1, _, _ := procGetIpNetTable2.Call(
+		uintptr(windows.AF_INET6),
+		uintptr(unsafe.Pointer(&table)),
+	)
+	if r1 != 0 {
+		return nil, fmt.Errorf("GetIpNetTable2 failed with error code %d", r1)
+	}
+	defer func() {
+		_, _, _ = procFreeMibTable.Call(uintptr(table))
+	}()
+
+	numEntries := *(*uint32)(table)
+	if numEntries == 0 {
+		return []Entry{}, nil
+	}
+	rows := unsafe.Slice((*MIB_IPNET_ROW2)(unsafe.Add(table, mibIPNetTable2RowOffset)), numEntries)
+
+	var entries []Entry
+	for _, row := range rows {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		if int(row.InterfaceIndex) != s.iface.Interface.Index {
+			continue
+		}
+		if row.PhysicalAddressLength == 0 || row.PhysicalAddressLength > uint32(len(row.PhysicalAddress)) {
+			continue
+		}
+
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, row.Address[sockaddrIn6AddrOffset:sockaddrIn6AddrOffset+net.IPv6len])
+		mac := make(net.HardwareAddr, row.PhysicalAddressLength)
+		copy(mac, row.PhysicalAddress[:row.PhysicalAddressLength])
+
+		entries = append(entries, Entry{
+			IP:            ip,
+			MAC:           mac,
+			State:         nlNeighborStates[row.State],
+			InterfaceName: s.iface.Interface.Name,
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+)
+
+// Backlog queues the devices a long-running listener observes between scans, Stream hands
+// them to the scan that runs next. When the backlog is full the oldest devices are dropped.
+type Backlog struct {
+	limit int
+
+	mu      sync.Mutex
+	pending []Device
+	notify  chan struct{}
+}
+
+func NewBacklog(limit int) *Backlog {
+	return &Backlog{limit: limit, notify: make(chan struct{}, 1)}
+}
+
+// Add queues an observation.
+func (b *Backlog) Add(d Device) {
+	b.mu.Lock()
+	b.pending = append(b.pending, d)
+	b.trim()
+	b.mu.Unlock()
+
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Stream sends the queued observations and every new one to out until ctx is done.
+// Observations that could not be sent stay queued for the next scan.
+func (b *Backlog) Stream(ctx context.Context, out chan<- Device) error {
+	for {
+		pending := b.drain()
+		for i, d := range pending {
+			select {
+			case out <- d:
+			case <-ctx.Done():
+				b.requeue(pending[i:])
+				return ctx.Err()
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.notify:
+		}
+	}
+}
+
+// Len returns the number of queued observations.
+func (b *Backlog) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending)
+}
+
+func (b *Backlog) drain() []Device {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	pending := b.pending
+	b.pending = nil
+	return pending
+}
+
+// requeue puts observations back in front of the queue.
+func (b *Backlog) requeue(devices []Device) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(append([]Device(nil), devices...), b.pending...)
+	b.trim()
+}
+
+// trim drops the oldest observations beyond the limit, the caller holds b.mu.
+func (b *Backlog) trim() {
+	if b.limit > 0 && len(b.pending) > b.limit {
+		b.pending = b.pending[len(b.pending)-b.limit:]
+	}
+}
@@ -0,0 +1,59 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBacklogStreamKeepsUnsent(t *testing.T) {
+	b := NewBacklog(0)
+	b.Add(NewDevice(net.ParseIP("10.0.0.1")))
+	b.Add(NewDevice(net.ParseIP("10.0.0.2")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	out := make(chan Device, 1)
+	if err := b.Stream(ctx, out); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if d := <-out; !d.IP.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("expected the oldest observation first, got %s", d.IP)
+	}
+	if b.Len() != 1 {
+		t.Errorf("expected the unsent observation to stay queued, got %d", b.Len())
+	}
+}
+
+func TestBacklogStreamReceivesNew(t *testing.T) {
+	b := NewBacklog(0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out := make(chan Device)
+	go func() { _ = b.Stream(ctx, out) }()
+
+	b.Add(NewDevice(net.ParseIP("10.0.0.3")))
+	select {
+	case d := <-out:
+		if !d.IP.Equal(net.ParseIP("10.0.0.3")) {
+			t.Errorf("unexpected device %s", d.IP)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected the observation to be streamed")
+	}
+}
+
+func TestBacklogLimit(t *testing.T) {
+	b := NewBacklog(2)
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		b.Add(NewDevice(net.ParseIP(ip)))
+	}
+	if b.Len() != 2 {
+		t.Fatalf("expected 2 queued observations, got %d", b.Len())
+	}
+	if d := b.drain(); !d[0].IP.Equal(net.ParseIP("10.0.0.2")) {
+		t.Errorf("expected the oldest observation to be dropped, got %s", d[0].IP)
+	}
+}
@@ -0,0 +1,150 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BulkTarget is a host of a bulk port scan.
+type BulkTarget struct {
+	IP      string
+	Iface   *InterfaceInfo // interface the host is reached on, nil for the OS default
+	Latency time.Duration  // round trip time seeding the timeouts of the host, 0 when unknown
+}
+
+// HostProgress is the progress of the port scan of one host.
+type HostProgress struct {
+	IP    string `json:"ip"`
+	Done  int    `json:"done"`  // ports probed so far
+	Total int    `json:"total"` // ports to probe
+	Open  int    `json:"open"`  // open and open|filtered ports found so far
+}
+
+// Finished reports whether every port of the host was probed.
+func (p HostProgress) Finished() bool { return p.Done >= p.Total }
+
+// BulkScanner port scans many hosts with one worker pool and rate limit shared by all
+// hosts, so scanning a whole subnet costs no more sockets than scanning one host. The
+// timeouts adapt to the round trip time of every host on its own.
+type BulkScanner struct {
+	timing  Timing
+	limiter *rateLimiter
+
+	// newScanner returns the port scanner of a host, its dialer binds the interface.
+	newScanner func(iface *InterfaceInfo) *PortScanner
+}
+
+// NewBulkScanner returns a scanner running at most timing.Workers probes at once and
+// sending at most timing.Rate probes per second over all hosts.
+func NewBulkScanner(timing Timing) *BulkScanner {
+	timing = timing.normalize()
+	return &BulkScanner{
+		timing:  timing,
+		limiter: newRateLimiter(timing.Rate),
+		newScanner: func(iface *InterfaceInfo) *PortScanner {
+			return NewPortScanner(timing, iface)
+		},
+	}
+}
+
+// bulkJob is one port of one host.
+type bulkJob struct {
+	host     int
+	port     int
+	protocol string // ProtocolTCP or ProtocolUDP
+}
+
+// Scan probes the tcp and udp ports of every target. The hosts are interleaved, so all
+// of them make progress and no host receives the whole budget at once. onPort is called
+// for every open TCP port and every UDP port that is open or did not answer
+// (PortOpenFiltered), onProgress after every probe. The callbacks are not called
+// concurrently. Scan returns when every port was probed or ctx is done.
+func (b *BulkScanner) Scan(ctx context.Context, targets []BulkTarget, tcp, udp []int, onPort func(ip, protocol string, port int, state PortState), onProgress func(HostProgress)) error {
+	if len(targets) == 0 || len(tcp)+len(udp) == 0 {
+		return nil
+	}
+
+	scanners := make([]*PortScanner, len(targets))
+	rtts := make([]*rttEstimator, len(targets))
+	progress := make([]HostProgress, len(targets))
+	for i, t := range targets {
+		ps := b.newScanner(t.Iface)
+		ps.timing, ps.limiter = b.timing, b.limiter
+		scanners[i] = ps
+		rtts[i] = newRTTEstimator(t.Latency, b.timing)
+		progress[i] = HostProgress{IP: t.IP, Total: len(tcp) + len(udp)}
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	jobs := make(chan bulkJob)
+	for i := 0; i < b.timing.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				ip := targets[job.host].IP
+				state := probeJob(ctx, scanners[job.host], rtts[job.host], ip, job)
+				if ctx.Err() != nil {
+					continue // the probe was cut short, its result is meaningless
+				}
+
+				mu.Lock()
+				p := &progress[job.host]
+				p.Done++
+				if state == PortOpen || state == PortOpenFiltered {
+					p.Open++
+					if onPort != nil {
+						onPort(ip, job.protocol, job.port, state)
+					}
+				}
+				if onProgress != nil {
+					onProgress(*p)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	err := b.feed(ctx, jobs, len(targets), tcp, udp)
+	close(jobs)
+	wg.Wait()
+	return err
+}
+
+// feed sends the jobs round-robin over the hosts, the probes wait for the rate limit.
+func (b *BulkScanner) feed(ctx context.Context, jobs chan<- bulkJob, hosts int, tcp, udp []int) error {
+	ports := make([]bulkJob, 0, len(tcp)+len(udp))
+	for _, port := range tcp {
+		ports = append(ports, bulkJob{port: port, protocol: ProtocolTCP})
+	}
+	for _, port := range udp {
+		ports = append(ports, bulkJob{port: port, protocol: ProtocolUDP})
+	}
+
+	for _, job := range ports {
+		for host := 0; host < hosts; host++ {
+			job.host = host
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// probeJob scans the port of the job and returns its state, TCP ports are open or closed.
+func probeJob(ctx context.Context, ps *PortScanner, rtt *rttEstimator, ip string, job bulkJob) PortState {
+	if job.protocol == ProtocolUDP {
+		return ps.udpPortState(ctx, ip, job.port, rtt)
+	}
+	if ps.isPortOpen(ctx, ip, job.port, rtt) {
+		return PortOpen
+	}
+	return PortClosed
+}
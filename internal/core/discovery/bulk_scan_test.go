@@ -0,0 +1,90 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newMockBulkScanner(workers int, mock *mockDialer) *BulkScanner {
+	b := NewBulkScanner(Timing{Workers: workers, MaxTimeout: 100 * time.Millisecond})
+	b.newScanner = func(*InterfaceInfo) *PortScanner {
+		return &PortScanner{dialer: mock}
+	}
+	return b
+}
+
+func TestBulkScanner_Scan(t *testing.T) {
+	mock := &mockDialer{
+		openPorts: map[string]bool{
+			"10.0.0.1:22":  true,
+			"10.0.0.1:80":  true,
+			"10.0.0.2:443": true,
+		},
+	}
+	b := newMockBulkScanner(4, mock)
+	targets := []BulkTarget{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}, {IP: "10.0.0.3"}}
+
+	open := map[string][]int{}
+	progress := map[string]HostProgress{}
+	err := b.Scan(context.Background(), targets, []int{22, 80, 443, 8080}, nil,
+		func(ip, protocol string, port int, state PortState) {
+			if protocol != ProtocolTCP || state != PortOpen {
+				t.Errorf("expected open %s port, got %s %s", ProtocolTCP, state, protocol)
+			}
+			open[ip] = append(open[ip], port)
+		},
+		func(p HostProgress) { progress[p.IP] = p })
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	want := map[string][]int{"10.0.0.1": {22, 80}, "10.0.0.2": {443}}
+	for ip, ports := range want {
+		got := open[ip]
+		slices.Sort(got)
+		if !slices.Equal(got, ports) {
+			t.Errorf("%s: expected open ports %v, got %v", ip, ports, got)
+		}
+	}
+	if len(open["10.0.0.3"]) != 0 {
+		t.Errorf("10.0.0.3: expected no open ports, got %v", open["10.0.0.3"])
+	}
+
+	for _, target := range targets {
+		p := progress[target.IP]
+		if !p.Finished() || p.Total != 4 {
+			t.Errorf("%s: expected 4/4 ports probed, got %d/%d", target.IP, p.Done, p.Total)
+		}
+		if p.Open != len(want[target.IP]) {
+			t.Errorf("%s: expected %d open, got %d", target.IP, len(want[target.IP]), p.Open)
+		}
+	}
+}
+
+func TestBulkScanner_ScanCancelled(t *testing.T) {
+	mock := &mockDialer{openPorts: map[string]bool{"10.0.0.1:22": true}}
+	b := newMockBulkScanner(2, mock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := b.Scan(ctx, []BulkTarget{{IP: "10.0.0.1"}}, []int{22, 80}, nil,
+		func(ip, protocol string, port int, _ PortState) { t.Errorf("unexpected open port %s:%d", ip, port) },
+		nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestBulkScanner_ScanNothing(t *testing.T) {
+	b := newMockBulkScanner(1, &mockDialer{})
+	if err := b.Scan(context.Background(), nil, []int{22}, nil, nil, nil); err != nil {
+		t.Errorf("expected no error without targets, got %v", err)
+	}
+	if err := b.Scan(context.Background(), []BulkTarget{{IP: "10.0.0.1"}}, nil, nil, nil, nil); err != nil {
+		t.Errorf("expected no error without ports, got %v", err)
+	}
+}
@@ -1,155 +1,284 @@
 package discovery
 
 import (
+	"encoding/json"
+	"maps"
 	"net"
+	"slices"
 	"time"
-)
 
-// TODO(ramon): Maybe it could be nice to have a merge strategy? E.g. when multiple scanners return the same device.
-// Per column we could for example choose to have a specific Merge strategy, so that the "best" data from different
-// scanners could be combined into a single device representation.
-// maybe something like?:
-// const (
-//    MergeFirstWins     MergeStrategy = iota   // First scanner's value wins
-//    MergeMostSpecific                         // More specific value wins
-//    MergeLongest                              // Longer value (usually more complete)
-//    MergeUnion                                // Combine arrays/maps
-//    MergeMostRecent                           // Newer timestamp wins
-// )
-// END TODO
+	"github.com/ramonvermeulen/whosthere/internal/core/ports"
+)
 
 // Device represents a discovered network device aggregated from multiple scanners.
 type Device struct {
-	IP           net.IP              `json:"ip"`           // Primary IP address (identity key)
-	MAC          string              `json:"mac"`          // MAC address of the device
-	DisplayName  string              `json:"displayName"`  // Most user-friendly name discovered
-	Manufacturer string              `json:"manufacturer"` // Vendor from OUI table
-	Services     map[string]int      `json:"services"`     // service name -> port (or 0 if unknown)
-	Sources      map[string]struct{} `json:"sources"`      // set of scanners that contributed info
-	FirstSeen    time.Time           `json:"firstSeen"`    // first time any scanner saw the device
-	LastSeen     time.Time           `json:"lastSeen"`     // last time any scanner saw the device
-	ExtraData    map[string]string   `json:"extraData"`    // additional key/value metadata discovered from protocols
-	OpenPorts    map[string][]int    `json:"-"`            // protocol -> list of open ports
-	LastPortScan time.Time           `json:"-"`            // last time port scan was performed
-	Latency      time.Duration       `json:"-"`            // TCP ping round-trip latency
-	ReverseDNS   string              `json:"-"`            // reverse DNS hostname (PTR record)
-	Banners      map[int]string      `json:"-"`            // port -> service banner text
-	HTTPTitle    string              `json:"-"`            // HTML <title> from web server
-	HTTPServer   string              `json:"-"`            // HTTP Server header value
-	DeviceType   string              `json:"deviceType"`   // fingerprinted device classification
-	OS           string              `json:"os"`           // detected operating system
-	NetBIOSName  string              `json:"netbiosName"`  // NetBIOS/SMB hostname
-	LastProbe    time.Time           `json:"-"`            // last time deep probe was performed
+	ID           string              `json:"id"`                  // stable identity: MAC address when known, IP address otherwise
+	IP           net.IP              `json:"ip"`                  // primary (most recently used) IP address
+	Addresses    []AddressRecord     `json:"addresses"`           // current and historical addresses
+	MAC          string              `json:"mac"`                 // MAC address of the device
+	Interface    string              `json:"interface"`           // local network interface the device was seen on
+	Subnet       string              `json:"subnet"`              // on-link subnet of the interface containing IP
+	Hostname     string              `json:"hostname"`            // hostname announced by the device (e.g. mDNS SRV target)
+	DisplayName  string              `json:"displayName"`         // Most user-friendly name discovered
+	Manufacturer string              `json:"manufacturer"`        // Vendor from OUI table
+	Services     []ServiceInstance   `json:"services"`            // service instances announced by the device
+	Sources      map[string]struct{} `json:"sources"`             // set of scanners that contributed info
+	FirstSeen    time.Time           `json:"firstSeen"`           // first time any scanner saw the device
+	LastSeen     time.Time           `json:"lastSeen"`            // last time any scanner saw the device
+	ExtraData    map[string]string   `json:"extraData"`           // additional key/value metadata discovered from protocols
+	OpenPorts    PortMap             `json:"openPorts,omitempty"` // protocol -> list of open ports
+	LastPortScan time.Time           `json:"-"`                   // last time port scan was performed
+	Latency      time.Duration       `json:"-"`                   // round-trip latency (ICMP echo or TCP ping)
+	ReverseDNS   string              `json:"-"`                   // reverse DNS hostname (PTR record)
+	Banners      map[int]string      `json:"-"`                   // port -> service banner text
+	HTTPTitle    string              `json:"-"`                   // HTML <title> from web server
+	HTTPServer   string              `json:"-"`                   // HTTP Server header value
+	DeviceType   string              `json:"deviceType"`          // fingerprinted device classification
+	OS           string              `json:"os"`                  // detected operating system
+	NetBIOSName  string              `json:"netbiosName"`         // NetBIOS/SMB hostname
+	SNMP         SNMPSystem          `json:"snmp,omitzero"`       // MIB-2 system group read over SNMP
+	LastProbe    time.Time           `json:"-"`                   // last time deep probe was performed
+	FieldSources map[string]string   `json:"fieldSources"`        // field name -> source whose value won the merge
+	DepartedAt   time.Time           `json:"departedAt,omitzero"` // when the device announced it left the network (mDNS goodbye), zero while present
+
+	// RemovedServices lists services withdrawn by an observation (e.g. an mDNS goodbye),
+	// matching instances are removed from the device it is merged into. An empty
+	// instance name or protocol matches every instance of the service type.
+	RemovedServices []ServiceInstance `json:"-"`
+
+	// EnrichOnly marks an observation that did not see the device itself (e.g. a cached
+	// PTR record). It is only merged into a device seen by another scanner, observations
+	// of unknown devices are held back until the device is seen.
+	EnrichOnly bool `json:"-"`
+}
+
+// PortMap lists the open ports of a device by protocol, see ProtocolTCP and ProtocolUDP.
+type PortMap map[string][]Port
+
+// Port is a port of a PortMap, open or, for UDP ports that did not answer, open|filtered.
+type Port struct {
+	Number int
+	State  PortState
+}
+
+// OpenPort is an open port in the JSON of a PortMap, with its well-known service name.
+type OpenPort struct {
+	Port    int    `json:"port"`
+	Service string `json:"service,omitempty"`
+	State   string `json:"state"`
+}
+
+// Add adds port with its state, a port that is already listed keeps PortOpen.
+func (m PortMap) Add(protocol string, port int, state PortState) {
+	for i, p := range m[protocol] {
+		if p.Number == port {
+			if state == PortOpen {
+				m[protocol][i].State = PortOpen
+			}
+			return
+		}
+	}
+	m[protocol] = append(m[protocol], Port{Number: port, State: state})
+}
+
+// Numbers returns the port numbers of protocol.
+func (m PortMap) Numbers(protocol string) []int {
+	var out []int
+	for _, p := range m[protocol] {
+		out = append(out, p.Number)
+	}
+	return out
 }
 
+// Clone returns a deep copy of m.
+func (m PortMap) Clone() PortMap {
+	out := make(PortMap, len(m))
+	for protocol, list := range m {
+		out[protocol] = slices.Clone(list)
+	}
+	return out
+}
+
+// MarshalJSON adds the well-known service name and the state to every port.
+func (m PortMap) MarshalJSON() ([]byte, error) {
+	out := make(map[string][]OpenPort, len(m))
+	for protocol, list := range m {
+		entries := make([]OpenPort, len(list))
+		for i, p := range list {
+			entries[i] = OpenPort{Port: p.Number, Service: ports.ServiceName(p.Number, protocol), State: p.State.String()}
+		}
+		out[protocol] = entries
+	}
+	return json.Marshal(out)
+}
+
+// SNMPSystem is the MIB-2 system group (RFC 3418) of a device that answers SNMP.
+type SNMPSystem struct {
+	Name     string        `json:"sysName,omitempty"`
+	Descr    string        `json:"sysDescr,omitempty"`
+	ObjectID string        `json:"sysObjectID,omitempty"` // vendor and model OID, e.g. 1.3.6.1.4.1.9.1.1208
+	Contact  string        `json:"sysContact,omitempty"`
+	Location string        `json:"sysLocation,omitempty"`
+	UpTime   time.Duration `json:"sysUpTime,omitempty"` // time since the agent (re)started
+}
+
+// IsZero reports whether no system data was read.
+func (s SNMPSystem) IsZero() bool { return s == SNMPSystem{} }
+
+// Departed reports whether the device announced it left and was not seen since.
+func (d *Device) Departed() bool { return !d.DepartedAt.IsZero() }
+
 // NewDevice builds a Device with initialized maps and current timestamp as first/last seen.
 func NewDevice(ip net.IP) Device {
 	now := time.Now()
-	return Device{IP: ip, Services: map[string]int{}, Sources: map[string]struct{}{}, FirstSeen: now, LastSeen: now, ExtraData: map[string]string{}, OpenPorts: map[string][]int{}, Banners: map[int]string{}}
+	var addrs []AddressRecord
+	if ip != nil {
+		addrs = []AddressRecord{{IP: ip, FirstSeen: now, LastSeen: now, Current: true}}
+	}
+	return Device{IP: ip, Addresses: addrs, Sources: map[string]struct{}{}, FirstSeen: now, LastSeen: now, ExtraData: map[string]string{}, OpenPorts: PortMap{}, Banners: map[int]string{}, FieldSources: map[string]string{}}
+}
+
+// FieldSource returns the source that provided the current value of field.
+// Devices that were never merged fall back to their only source.
+func (d *Device) FieldSource(field string) string {
+	if src, ok := d.FieldSources[field]; ok {
+		return src
+	}
+	if len(d.Sources) == 1 {
+		for src := range d.Sources {
+			return src
+		}
+	}
+	return ""
 }
 
-// Merge merges fields into an existing Device
+// scalarFieldPtrs maps the mergeable string fields to their storage in d.
+func (d *Device) scalarFieldPtrs() map[string]*string {
+	return map[string]*string{
+		FieldMAC:          &d.MAC,
+		FieldHostname:     &d.Hostname,
+		FieldInterface:    &d.Interface,
+		FieldSubnet:       &d.Subnet,
+		FieldDisplayName:  &d.DisplayName,
+		FieldManufacturer: &d.Manufacturer,
+		FieldDeviceType:   &d.DeviceType,
+		FieldOS:           &d.OS,
+		FieldNetBIOSName:  &d.NetBIOSName,
+		FieldReverseDNS:   &d.ReverseDNS,
+		FieldHTTPTitle:    &d.HTTPTitle,
+		FieldHTTPServer:   &d.HTTPServer,
+	}
+}
+
+// Merge merges fields into an existing Device using the DefaultMergePolicy.
 func (d *Device) Merge(other *Device) {
-	// todo allow for more advanced merge strategies per field?
+	d.MergeWith(other, nil)
+}
+
+// MergeWith merges fields into an existing Device, resolving conflicting scalar
+// values with the given policy (DefaultMergePolicy when nil). The source of every
+// winning value is recorded in FieldSources.
+func (d *Device) MergeWith(other *Device, policy *MergePolicy) {
 	if other == nil {
 		return
 	}
+	if policy == nil {
+		policy = defaultMergePolicy
+	}
 	if d.IP == nil && other.IP != nil {
 		d.IP = other.IP
 	}
-	if d.MAC == "" && other.MAC != "" {
-		d.MAC = other.MAC
-	}
-	if d.DisplayName == "" && other.DisplayName != "" {
-		d.DisplayName = other.DisplayName
-	}
-	if d.Manufacturer == "" && other.Manufacturer != "" {
-		d.Manufacturer = other.Manufacturer
-	}
-	if d.Services == nil {
-		d.Services = map[string]int{}
-	}
-	for name, port := range other.Services {
-		if _, ok := d.Services[name]; !ok || d.Services[name] == 0 {
-			if d.Services[name] == 0 || port != 0 {
-				d.Services[name] = port
+
+	// the maps are copied first, snapshots handed out by AppState share the old ones
+	d.FieldSources = cloneMap(d.FieldSources)
+	d.Sources = cloneMap(d.Sources)
+	d.ExtraData = cloneMap(d.ExtraData)
+	d.Banners = cloneMap(d.Banners)
+	newer := !other.LastSeen.Before(d.LastSeen)
+	otherFields := other.scalarFieldPtrs()
+	for field, cur := range d.scalarFieldPtrs() {
+		if *cur != "" && d.FieldSources[field] == "" {
+			// attribute values set before the first merge, while d had a single source
+			if src := d.FieldSource(field); src != "" {
+				d.FieldSources[field] = src
+			}
+		}
+		incoming := mergeCandidate{value: *otherFields[field], source: other.FieldSource(field), newer: newer}
+		current := mergeCandidate{value: *cur, source: d.FieldSources[field]}
+		if policy.pick(field, current, incoming) {
+			*cur = incoming.value
+			if incoming.source != "" {
+				d.FieldSources[field] = incoming.source
+			} else {
+				delete(d.FieldSources, field)
 			}
 		}
 	}
-	if d.Sources == nil {
-		d.Sources = map[string]struct{}{}
-	}
+
+	d.mergeServices(other)
 	for src := range other.Sources {
 		d.Sources[src] = struct{}{}
 	}
-	if d.ExtraData == nil {
-		d.ExtraData = map[string]string{}
-	}
+	extraStrategy, _ := policy.strategyFor(FieldExtraData)
 	for k, v := range other.ExtraData {
-		// prefer existing value, only set if missing
-		if _, ok := d.ExtraData[k]; !ok {
+		// union prefers the existing value and only sets missing keys
+		if _, ok := d.ExtraData[k]; !ok || (extraStrategy == MergeMostRecent && newer) {
 			d.ExtraData[k] = v
 		}
 	}
 	if d.FirstSeen.IsZero() || (!other.FirstSeen.IsZero() && other.FirstSeen.Before(d.FirstSeen)) {
 		d.FirstSeen = other.FirstSeen
 	}
+	switch {
+	case other.Departed():
+		if other.DepartedAt.After(d.DepartedAt) {
+			d.DepartedAt = other.DepartedAt
+		}
+	case other.LastSeen.After(d.DepartedAt):
+		// seen again after it said goodbye
+		d.DepartedAt = time.Time{}
+	}
 	if other.LastSeen.After(d.LastSeen) {
 		d.LastSeen = other.LastSeen
 	}
 	if d.OpenPorts == nil {
-		d.OpenPorts = map[string][]int{}
-	}
-	for protocol, ports := range other.OpenPorts {
-		if _, ok := d.OpenPorts[protocol]; !ok {
-			d.OpenPorts[protocol] = make([]int, len(ports))
-			copy(d.OpenPorts[protocol], ports)
-		} else {
-			portSet := make(map[int]bool)
-			for _, p := range d.OpenPorts[protocol] {
-				portSet[p] = true
-			}
-			for _, p := range ports {
-				if !portSet[p] {
-					d.OpenPorts[protocol] = append(d.OpenPorts[protocol], p)
-					portSet[p] = true
-				}
-			}
-		}
+		d.OpenPorts = PortMap{}
 	}
-	if other.LastPortScan.After(d.LastPortScan) {
+	switch {
+	case other.LastPortScan.After(d.LastPortScan):
+		// a newer port scan replaces the ports found by the previous one
+		d.OpenPorts = other.OpenPorts.Clone()
 		d.LastPortScan = other.LastPortScan
-	}
-	if d.ReverseDNS == "" && other.ReverseDNS != "" {
-		d.ReverseDNS = other.ReverseDNS
-	}
-	if d.NetBIOSName == "" && other.NetBIOSName != "" {
-		d.NetBIOSName = other.NetBIOSName
-	}
-	if d.DeviceType == "" && other.DeviceType != "" {
-		d.DeviceType = other.DeviceType
-	}
-	if d.OS == "" && other.OS != "" {
-		d.OS = other.OS
-	}
-	if d.HTTPTitle == "" && other.HTTPTitle != "" {
-		d.HTTPTitle = other.HTTPTitle
-	}
-	if d.HTTPServer == "" && other.HTTPServer != "" {
-		d.HTTPServer = other.HTTPServer
+	case len(other.OpenPorts) > 0 && !other.LastPortScan.Before(d.LastPortScan):
+		// the ports are copied first, snapshots handed out by AppState share the old ones
+		d.OpenPorts = d.OpenPorts.Clone()
+		for protocol, list := range other.OpenPorts {
+			for _, p := range list {
+				d.OpenPorts.Add(protocol, p.Number, p.State)
+			}
+		}
 	}
 	if other.Latency > 0 && (d.Latency == 0 || other.Latency < d.Latency) {
 		d.Latency = other.Latency
 	}
-	if d.Banners == nil {
-		d.Banners = map[int]string{}
-	}
 	for port, banner := range other.Banners {
 		if _, ok := d.Banners[port]; !ok {
 			d.Banners[port] = banner
 		}
 	}
+	if !other.SNMP.IsZero() && (d.SNMP.IsZero() || newer) {
+		d.SNMP = other.SNMP
+	}
 	if other.LastProbe.After(d.LastProbe) {
 		d.LastProbe = other.LastProbe
 	}
+	d.mergeAddresses(other)
+}
+
+// cloneMap returns a copy of m that is safe to write to, never nil.
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return map[K]V{}
+	}
+	return maps.Clone(m)
 }
@@ -1,7 +1,10 @@
 package discovery
 
 import (
+	"encoding/json"
 	"net"
+	"reflect"
+	"slices"
 	"testing"
 	"time"
 )
@@ -11,7 +14,7 @@ func TestDeviceMerge(t *testing.T) {
 		IP:           net.ParseIP("10.0.0.1"),
 		DisplayName:  "host",
 		Manufacturer: "",
-		Services:     map[string]int{"svc": 1},
+		Services:     []ServiceInstance{{Type: "svc", Port: 1}},
 		Sources:      map[string]struct{}{"a": {}},
 		ExtraData:    map[string]string{"k1": "v1"},
 		FirstSeen:    time.Unix(100, 0),
@@ -23,7 +26,7 @@ func TestDeviceMerge(t *testing.T) {
 		MAC:          "aa:bb",
 		DisplayName:  "new-host",
 		Manufacturer: "manu",
-		Services:     map[string]int{"svc": 0, "svc2": 2},
+		Services:     []ServiceInstance{{Type: "svc"}, {Type: "svc2", Port: 2}},
 		Sources:      map[string]struct{}{"b": {}},
 		ExtraData:    map[string]string{"k2": "v2"},
 		FirstSeen:    time.Unix(50, 0),
@@ -41,7 +44,7 @@ func TestDeviceMerge(t *testing.T) {
 	if base.Manufacturer != "manu" {
 		t.Fatalf("Manufacturer merge failed, got %s", base.Manufacturer)
 	}
-	if base.Services["svc"] != 1 || base.Services["svc2"] != 2 {
+	if svc, _ := base.Service("svc"); svc.Port != 1 || len(base.Services) != 2 || base.Services[1].Port != 2 {
 		t.Fatalf("services merge failed: %+v", base.Services)
 	}
 	if _, ok := base.Sources["a"]; !ok {
@@ -65,3 +68,80 @@ func TestDeviceMergeNilOther(t *testing.T) {
 	d := Device{}
 	d.Merge(nil)
 }
+
+func TestDeviceMergeSNMP(t *testing.T) {
+	d := Device{LastSeen: time.Unix(200, 0), SNMP: SNMPSystem{Name: "sw1", UpTime: time.Hour}}
+
+	// an older observation without SNMP data keeps the system group
+	d.Merge(&Device{LastSeen: time.Unix(100, 0)})
+	if d.SNMP.Name != "sw1" {
+		t.Fatalf("expected SNMP data kept, got %+v", d.SNMP)
+	}
+
+	d.Merge(&Device{LastSeen: time.Unix(300, 0), SNMP: SNMPSystem{Name: "sw1", UpTime: 2 * time.Hour}})
+	if d.SNMP.UpTime != 2*time.Hour {
+		t.Fatalf("expected newer SNMP data, got %+v", d.SNMP)
+	}
+}
+
+func TestPortMapJSON(t *testing.T) {
+	b, err := json.Marshal(PortMap{
+		ProtocolTCP: {{Number: 22, State: PortOpen}, {Number: 40000, State: PortOpen}},
+		ProtocolUDP: {{Number: 123, State: PortOpenFiltered}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"tcp":[{"port":22,"service":"ssh","state":"open"},{"port":40000,"state":"open"}],"udp":[{"port":123,"service":"ntp","state":"open|filtered"}]}`
+	if string(b) != want {
+		t.Errorf("unexpected JSON\n got %s\nwant %s", b, want)
+	}
+}
+
+func TestMergeOpenPortStates(t *testing.T) {
+	d := NewDevice(net.ParseIP("10.0.0.1"))
+	d.OpenPorts.Add(ProtocolUDP, 161, PortOpen)
+	d.OpenPorts.Add(ProtocolUDP, 123, PortOpenFiltered)
+
+	other := NewDevice(net.ParseIP("10.0.0.1"))
+	other.OpenPorts.Add(ProtocolUDP, 161, PortOpenFiltered)
+	other.OpenPorts.Add(ProtocolUDP, 123, PortOpen)
+	other.OpenPorts.Add(ProtocolTCP, 22, PortOpen)
+	d.Merge(&other)
+
+	want := PortMap{
+		ProtocolUDP: {{Number: 161, State: PortOpen}, {Number: 123, State: PortOpen}},
+		ProtocolTCP: {{Number: 22, State: PortOpen}},
+	}
+	if !reflect.DeepEqual(d.OpenPorts, want) {
+		t.Errorf("expected answered ports to stay open, got %+v", d.OpenPorts)
+	}
+}
+
+func TestMergeOpenPortsOfNewerScan(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	d := NewDevice(net.ParseIP("10.0.0.1"))
+	d.OpenPorts.Add(ProtocolTCP, 22, PortOpen)
+	d.OpenPorts.Add(ProtocolTCP, 80, PortOpen)
+	d.LastPortScan = t0
+
+	rescan := NewDevice(net.ParseIP("10.0.0.1"))
+	rescan.OpenPorts.Add(ProtocolTCP, 22, PortOpen)
+	rescan.LastPortScan = t0.Add(time.Minute)
+	d.Merge(&rescan)
+	if got := d.OpenPorts.Numbers(ProtocolTCP); !slices.Equal(got, []int{22}) || !d.LastPortScan.Equal(rescan.LastPortScan) {
+		t.Errorf("expected the newer scan to replace the ports, got %v at %v", got, d.LastPortScan)
+	}
+
+	progress := NewDevice(net.ParseIP("10.0.0.1"))
+	progress.OpenPorts.Add(ProtocolTCP, 443, PortOpen)
+	progress.LastPortScan = rescan.LastPortScan
+	stale := NewDevice(net.ParseIP("10.0.0.1"))
+	stale.OpenPorts.Add(ProtocolTCP, 80, PortOpen)
+	stale.LastPortScan = t0
+	d.Merge(&progress)
+	d.Merge(&stale)
+	if got := d.OpenPorts.Numbers(ProtocolTCP); !slices.Equal(got, []int{22, 443}) {
+		t.Errorf("expected ports of the same scan added and of an older scan ignored, got %v", got)
+	}
+}
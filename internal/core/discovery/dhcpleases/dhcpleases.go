@@ -0,0 +1,88 @@
+package dhcpleases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"go.uber.org/zap"
+)
+
+// Name is the scanner and source name of the DHCP lease scanner.
+const Name = "dhcp-leases"
+
+var _ discovery.InterfaceScanner = (*Scanner)(nil)
+
+// Scanner imports the leases of DHCP servers running on the same host (e.g. a gateway).
+// Lease files know the MAC address and hostname of every client, including devices
+// that never answer mDNS or SSDP. Only leases in a subnet of the interface are emitted.
+type Scanner struct {
+	iface *discovery.InterfaceInfo
+	paths []string
+	now   func() time.Time
+}
+
+func NewScanner(iface *discovery.InterfaceInfo, paths []string) *Scanner {
+	return &Scanner{iface: iface, paths: paths, now: time.Now}
+}
+
+func (s *Scanner) Name() string { return Name }
+
+// Interface returns the network interface the scanner is bound to.
+func (s *Scanner) Interface() *discovery.InterfaceInfo { return s.iface }
+
+// Scan reads every configured lease file and emits the active leases. Missing files are
+// skipped, an error is only returned when none of the files could be read.
+func (s *Scanner) Scan(ctx context.Context, out chan<- discovery.Device) error {
+	log := zap.L().With(zap.String("scanner", Name))
+	now := s.now()
+
+	var read int
+	var errs []error
+	for _, path := range s.paths {
+		leases, err := readLeaseFile(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				log.Debug("lease file not found", zap.String("path", path))
+			} else {
+				log.Debug("failed to read lease file", zap.String("path", path), zap.Error(err))
+			}
+			errs = append(errs, err)
+			continue
+		}
+		read++
+
+		for _, l := range leases {
+			if !l.active(now) || s.iface.SubnetOf(l.IP) == "" {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case out <- l.device(now):
+			}
+		}
+	}
+
+	if read == 0 && len(errs) > 0 {
+		return fmt.Errorf("no lease file could be read: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// readLeaseFile parses a lease file in any of the supported formats.
+func readLeaseFile(path string) ([]Lease, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	leases, err := ParseLeases(b)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return leases, nil
+}
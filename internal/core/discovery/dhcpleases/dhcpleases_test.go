@@ -0,0 +1,81 @@
+package dhcpleases
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+func testInterface() *discovery.InterfaceInfo {
+	_, ipNet, _ := net.ParseCIDR("192.168.1.0/24")
+	return &discovery.InterfaceInfo{
+		Interface: &net.Interface{Name: "eth0"},
+		IPv4Addr:  &net.IP{192, 168, 1, 1},
+		IPv4Net:   ipNet,
+	}
+}
+
+func scan(t *testing.T, s *Scanner) ([]discovery.Device, error) {
+	t.Helper()
+	s.now = func() time.Time { return now }
+	out := make(chan discovery.Device, 16)
+	err := s.Scan(context.Background(), out)
+	close(out)
+	var devices []discovery.Device
+	for d := range out {
+		devices = append(devices, d)
+	}
+	return devices, err
+}
+
+func TestScan(t *testing.T) {
+	s := NewScanner(testInterface(), []string{
+		filepath.Join("testdata", "dnsmasq.leases"),
+		filepath.Join("testdata", "missing.leases"),
+		filepath.Join("testdata", "kea-leases4.csv"),
+	})
+	devices, err := scan(t, s)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// fd00::10 is not in a subnet of the interface
+	byIP := map[string]discovery.Device{}
+	for _, d := range devices {
+		byIP[d.IP.String()] = d
+	}
+	if len(byIP) != 4 {
+		t.Fatalf("expected 4 devices, got %d: %v", len(byIP), byIP)
+	}
+
+	nas := byIP["192.168.1.30"]
+	if nas.Hostname != "nas.lan" || nas.DisplayName != "nas.lan" || nas.MAC != "aa:bb:cc:dd:ee:30" {
+		t.Errorf("unexpected device %+v", nas)
+	}
+	if _, ok := nas.Sources[Name]; !ok {
+		t.Errorf("expected source %q, got %v", Name, nas.Sources)
+	}
+	if nas.ExtraData[ExtraLeaseStart] != time.Unix(1893456000, 0).Format(time.RFC3339) {
+		t.Errorf("unexpected lease start %q", nas.ExtraData[ExtraLeaseStart])
+	}
+	if nas.ExtraData[ExtraLeaseExpiry] != time.Unix(1893459600, 0).Format(time.RFC3339) {
+		t.Errorf("unexpected lease expiry %q", nas.ExtraData[ExtraLeaseExpiry])
+	}
+	if nas.ExtraData[ExtraLeaseServer] != FormatKea {
+		t.Errorf("unexpected lease server %q", nas.ExtraData[ExtraLeaseServer])
+	}
+	if got := byIP["192.168.1.11"].ExtraData[ExtraLeaseExpiry]; got != "never" {
+		t.Errorf("expected infinite lease expiry, got %q", got)
+	}
+}
+
+func TestScanNoReadableFile(t *testing.T) {
+	s := NewScanner(testInterface(), []string{filepath.Join("testdata", "missing.leases")})
+	if _, err := scan(t, s); err == nil {
+		t.Fatal("expected an error when no lease file could be read")
+	}
+}
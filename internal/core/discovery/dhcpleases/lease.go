@@ -0,0 +1,311 @@
+package dhcpleases
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+// Lease file formats.
+const (
+	FormatDnsmasq = "dnsmasq"
+	FormatISC     = "isc-dhcpd"
+	FormatKea     = "kea"
+)
+
+// ExtraData keys set on devices found in lease files.
+const (
+	ExtraLeaseStart  = "dhcp_lease_start"
+	ExtraLeaseExpiry = "dhcp_lease_expiry"
+	ExtraLeaseServer = "dhcp_server"
+)
+
+// Lease is a single DHCP lease. Start and Expiry are zero when the file does not record
+// them, a zero Expiry with Infinite set is a lease that never expires.
+type Lease struct {
+	IP       net.IP
+	MAC      net.HardwareAddr
+	Hostname string
+	Start    time.Time
+	Expiry   time.Time
+	Infinite bool
+	Format   string
+}
+
+// active reports whether the lease is still valid at now.
+func (l *Lease) active(now time.Time) bool {
+	return l.Infinite || l.Expiry.IsZero() || l.Expiry.After(now)
+}
+
+// device converts the lease to a Device. The lease start is used as LastSeen when it is
+// known, that is when the client last talked to the DHCP server.
+func (l *Lease) device(now time.Time) discovery.Device {
+	d := discovery.NewDevice(l.IP)
+	if l.MAC != nil {
+		d.MAC = l.MAC.String()
+	}
+	d.Hostname = l.Hostname
+	d.DisplayName = l.Hostname
+	d.Sources[Name] = struct{}{}
+	d.ExtraData[ExtraLeaseServer] = l.Format
+	if !l.Start.IsZero() {
+		d.ExtraData[ExtraLeaseStart] = l.Start.Format(time.RFC3339)
+		if l.Start.Before(now) {
+			d.FirstSeen, d.LastSeen = l.Start, l.Start
+			d.Addresses[0].FirstSeen, d.Addresses[0].LastSeen = l.Start, l.Start
+		}
+	}
+	switch {
+	case l.Infinite:
+		d.ExtraData[ExtraLeaseExpiry] = "never"
+	case !l.Expiry.IsZero():
+		d.ExtraData[ExtraLeaseExpiry] = l.Expiry.Format(time.RFC3339)
+	}
+	return d
+}
+
+// ParseLeases detects the format of a lease file and parses it. Later entries for an
+// address replace earlier ones, ISC and Kea append renewals to the file.
+func ParseLeases(b []byte) ([]Lease, error) {
+	switch detectFormat(b) {
+	case FormatKea:
+		return parseKea(bytes.NewReader(b))
+	case FormatISC:
+		return parseISC(bytes.NewReader(b))
+	case FormatDnsmasq:
+		return parseDnsmasq(bytes.NewReader(b))
+	default:
+		return nil, errors.New("unknown lease file format")
+	}
+}
+
+// detectFormat looks at the first meaningful line of a lease file.
+func detectFormat(b []byte) string {
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "address,"):
+			return FormatKea
+		case strings.HasPrefix(line, "lease ") || strings.HasPrefix(line, "server-duid") ||
+			strings.HasPrefix(line, "authoring-byte-order") || strings.HasPrefix(line, "failover "):
+			return FormatISC
+		case strings.HasPrefix(line, "duid "):
+			return FormatDnsmasq
+		}
+		if fields := strings.Fields(line); len(fields) >= 4 {
+			if _, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+				return FormatDnsmasq
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// dedupe keeps the last lease per IP address, in order of first appearance.
+func dedupe(leases []Lease) []Lease {
+	index := map[string]int{}
+	var out []Lease
+	for _, l := range leases {
+		key := l.IP.String()
+		if i, ok := index[key]; ok {
+			out[i] = l
+			continue
+		}
+		index[key] = len(out)
+		out = append(out, l)
+	}
+	return out
+}
+
+// parseDnsmasq parses a dnsmasq.leases file:
+//
+//	<expiry epoch> <mac> <ip> <hostname|*> <client-id|*>
+//
+// IPv6 leases carry the IAID instead of the MAC and follow a "duid" line.
+func parseDnsmasq(r io.Reader) ([]Lease, error) {
+	var leases []Lease
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 || fields[0] == "duid" {
+			continue
+		}
+		ip := net.ParseIP(fields[2])
+		if ip == nil {
+			continue
+		}
+		l := Lease{IP: ip, Format: FormatDnsmasq}
+		if expiry, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			if expiry == 0 {
+				l.Infinite = true
+			} else {
+				l.Expiry = time.Unix(expiry, 0)
+			}
+		}
+		if mac, err := net.ParseMAC(fields[1]); err == nil {
+			l.MAC = mac
+		}
+		if fields[3] != "*" {
+			l.Hostname = fields[3]
+		}
+		leases = append(leases, l)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return dedupe(leases), nil
+}
+
+// parseISC parses an ISC dhcpd.leases file, see dhcpd.leases(5). Leases that are not in
+// the active binding state are returned expired, so a lease that was freed, released or
+// expired replaces the earlier active block of its address.
+func parseISC(r io.Reader) ([]Lease, error) {
+	var leases []Lease
+	var cur *Lease
+	var state string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if cur == nil {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && (fields[0] == "lease" || fields[0] == "iaaddr") {
+				if ip := net.ParseIP(fields[1]); ip != nil {
+					cur = &Lease{IP: ip, Format: FormatISC}
+					state = ""
+				}
+			}
+			continue
+		}
+		if line == "}" {
+			if state != "" && state != "active" {
+				cur.Expiry, cur.Infinite = time.Unix(0, 0), false
+			}
+			leases = append(leases, *cur)
+			cur = nil
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimSuffix(line, ";"))
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "starts":
+			cur.Start, _ = parseISCTime(fields[1:])
+		case "ends":
+			if fields[1] == "never" {
+				cur.Infinite = true
+			} else {
+				cur.Expiry, _ = parseISCTime(fields[1:])
+			}
+		case "binding":
+			if len(fields) >= 3 && fields[1] == "state" {
+				state = fields[2]
+			}
+		case "hardware":
+			if len(fields) >= 3 {
+				if mac, err := net.ParseMAC(fields[2]); err == nil {
+					cur.MAC = mac
+				}
+			}
+		case "client-hostname":
+			cur.Hostname = strings.Trim(strings.Join(fields[1:], " "), `"`)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if cur != nil {
+		return nil, errors.New("unterminated lease block")
+	}
+	return dedupe(leases), nil
+}
+
+// parseISCTime parses "<weekday> <yyyy/mm/dd> <hh:mm:ss>" (UTC) or "epoch <seconds>".
+func parseISCTime(fields []string) (time.Time, error) {
+	if len(fields) >= 2 && fields[0] == "epoch" {
+		secs, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(secs, 0), nil
+	}
+	if len(fields) < 3 {
+		return time.Time{}, fmt.Errorf("invalid lease time %q", strings.Join(fields, " "))
+	}
+	return time.Parse("2006/01/02 15:04:05", fields[1]+" "+fields[2])
+}
+
+// parseKea parses a Kea memfile lease CSV (kea-leases4.csv or kea-leases6.csv), the
+// columns are looked up by the header names. Declined and reclaimed leases are skipped.
+func parseKea(r io.Reader) ([]Lease, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[strings.TrimSpace(name)] = i
+	}
+	get := func(rec []string, name string) string {
+		if i, ok := col[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+
+	var leases []Lease
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		ip := net.ParseIP(get(rec, "address"))
+		if ip == nil {
+			continue
+		}
+		l := Lease{IP: ip, Format: FormatKea, Hostname: strings.TrimSuffix(get(rec, "hostname"), ".")}
+		if mac, err := net.ParseMAC(get(rec, "hwaddr")); err == nil {
+			l.MAC = mac
+		}
+		expire, _ := strconv.ParseInt(get(rec, "expire"), 10, 64)
+		lifetime, _ := strconv.ParseInt(get(rec, "valid_lifetime"), 10, 64)
+		if expire > 0 {
+			l.Expiry = time.Unix(expire, 0)
+			if lifetime > 0 && lifetime != 0xffffffff {
+				l.Start = l.Expiry.Add(-time.Duration(lifetime) * time.Second)
+			}
+		}
+		if lifetime == 0xffffffff {
+			l.Infinite = true
+		}
+		// the state of the last row for an address wins, keep removed leases so they replace earlier rows
+		if state := get(rec, "state"); state != "" && state != "0" {
+			l.Expiry, l.Infinite = time.Unix(0, 0), false
+		}
+		leases = append(leases, l)
+	}
+	return dedupe(leases), nil
+}
@@ -0,0 +1,129 @@
+package dhcpleases
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// now is before every active lease in testdata expires.
+var now = time.Date(2029, 12, 31, 12, 0, 0, 0, time.UTC)
+
+func readFixture(t *testing.T, name string) []Lease {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	leases, err := ParseLeases(b)
+	if err != nil {
+		t.Fatalf("ParseLeases: %v", err)
+	}
+	return leases
+}
+
+func activeLeases(leases []Lease) map[string]Lease {
+	out := map[string]Lease{}
+	for _, l := range leases {
+		if l.active(now) {
+			out[l.IP.String()] = l
+		}
+	}
+	return out
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"dnsmasq", "1893456000 aa:bb:cc:dd:ee:01 192.168.1.10 laptop *\n", FormatDnsmasq},
+		{"dnsmasq duid first", "duid 00:01:00:01\n", FormatDnsmasq},
+		{"isc", "# comment\n\nlease 192.168.1.20 {\n}\n", FormatISC},
+		{"kea", "address,hwaddr,client_id\n", FormatKea},
+		{"unknown", "hello world\n", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectFormat([]byte(tt.data)); got != tt.want {
+				t.Errorf("detectFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDnsmasq(t *testing.T) {
+	leases := activeLeases(readFixture(t, "dnsmasq.leases"))
+	if len(leases) != 3 {
+		t.Fatalf("expected 3 active leases, got %d: %v", len(leases), leases)
+	}
+
+	laptop := leases["192.168.1.10"]
+	if laptop.Hostname != "laptop" || laptop.MAC.String() != "aa:bb:cc:dd:ee:01" || laptop.Format != FormatDnsmasq {
+		t.Errorf("unexpected lease %+v", laptop)
+	}
+	if !laptop.Expiry.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected expiry %v", laptop.Expiry)
+	}
+
+	if l := leases["192.168.1.11"]; !l.Infinite || l.Hostname != "" {
+		t.Errorf("expected an infinite lease without hostname, got %+v", l)
+	}
+	if l := leases["fd00::10"]; l.MAC != nil || l.Hostname != "laptop" {
+		t.Errorf("expected IPv6 lease without MAC, got %+v", l)
+	}
+}
+
+func TestParseISC(t *testing.T) {
+	leases := activeLeases(readFixture(t, "dhcpd.leases"))
+	if len(leases) != 2 {
+		t.Fatalf("expected 2 active leases, got %d: %v", len(leases), leases)
+	}
+
+	printer := leases["192.168.1.20"]
+	if printer.Hostname != "printer" || printer.MAC.String() != "aa:bb:cc:dd:ee:20" {
+		t.Errorf("unexpected lease %+v", printer)
+	}
+	// the renewal later in the file replaces the first lease
+	if want := time.Date(2030, 1, 4, 10, 0, 0, 0, time.UTC); !printer.Start.Equal(want) {
+		t.Errorf("expected start %v, got %v", want, printer.Start)
+	}
+	if want := time.Date(2030, 1, 4, 22, 0, 0, 0, time.UTC); !printer.Expiry.Equal(want) {
+		t.Errorf("expected expiry %v, got %v", want, printer.Expiry)
+	}
+
+	if l := leases["192.168.1.21"]; !l.Infinite || !l.Start.Equal(time.Unix(1893456000, 0)) {
+		t.Errorf("expected an infinite lease with epoch start, got %+v", l)
+	}
+	// the lease was freed after it was active
+	if l, ok := leases["192.168.1.23"]; ok {
+		t.Errorf("expected the freed lease to replace the active one, got %+v", l)
+	}
+}
+
+func TestParseISCUnterminated(t *testing.T) {
+	if _, err := ParseLeases([]byte("lease 192.168.1.20 {\n  binding state active;\n")); err == nil {
+		t.Fatal("expected an error for an unterminated lease block")
+	}
+}
+
+func TestParseKea(t *testing.T) {
+	leases := activeLeases(readFixture(t, "kea-leases4.csv"))
+	if len(leases) != 2 {
+		t.Fatalf("expected 2 active leases, got %d: %v", len(leases), leases)
+	}
+
+	nas := leases["192.168.1.30"]
+	if nas.Hostname != "nas.lan" || nas.MAC.String() != "aa:bb:cc:dd:ee:30" || nas.Format != FormatKea {
+		t.Errorf("unexpected lease %+v", nas)
+	}
+	if !nas.Start.Equal(time.Unix(1893456000, 0)) {
+		t.Errorf("expected start at expire - valid_lifetime, got %v", nas.Start)
+	}
+	if tv := leases["192.168.1.32"]; !tv.Expiry.Equal(time.Unix(1893459600, 0)) {
+		t.Errorf("expected the last row of an address to win, got %+v", tv)
+	}
+}
@@ -0,0 +1,51 @@
+package dhcpleases
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+// DefaultPaths are the usual lease file locations of dnsmasq, ISC dhcpd and Kea.
+var DefaultPaths = []string{
+	"/var/lib/misc/dnsmasq.leases",
+	"/var/lib/dhcp/dhcpd.leases",
+	"/var/lib/kea/kea-leases4.csv",
+	"/var/lib/kea/kea-leases6.csv",
+}
+
+// Config is the `scanners.dhcp-leases` config block.
+type Config struct {
+	config.ScannerToggle `yaml:",inline"`
+	// Paths are the lease files to read, the format is detected from the content.
+	Paths []string `yaml:"paths"`
+}
+
+// Validate resets an empty path list to the default lease file locations.
+func (c *Config) Validate() error {
+	if len(c.Paths) == 0 {
+		c.Paths = append([]string(nil), DefaultPaths...)
+		return errors.New("paths must not be empty")
+	}
+	return nil
+}
+
+func init() {
+	discovery.Register(discovery.Registration{
+		Name:           Name,
+		Description:    "DHCP lease files of dnsmasq, ISC dhcpd and Kea",
+		DefaultEnabled: false,
+		NewConfig: func() config.ScannerSettings {
+			return &Config{Paths: append([]string(nil), DefaultPaths...)}
+		},
+		New: func(iface *discovery.InterfaceInfo, settings config.ScannerSettings) (discovery.Scanner, error) {
+			cfg, ok := settings.(*Config)
+			if !ok {
+				return nil, fmt.Errorf("unexpected config type %T", settings)
+			}
+			return NewScanner(iface, cfg.Paths), nil
+		},
+	})
+}
@@ -2,7 +2,9 @@ package discovery
 
 import (
 	"context"
-	"sync"
+	"errors"
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
@@ -16,11 +18,19 @@ type Scanner interface {
 	Scan(ctx context.Context, out chan<- Device) error
 }
 
+// InterfaceScanner is implemented by scanners bound to a single network interface. An engine
+// can run one scanner set per interface, devices are tagged with the interface they were seen on.
+type InterfaceScanner interface {
+	Scanner
+	Interface() *InterfaceInfo
+}
+
 // Engine coordinates multiple scanners and merges device results.
 type Engine struct {
 	Scanners    []Scanner
 	Timeout     time.Duration
 	OUIRegistry *oui.Registry
+	MergePolicy *MergePolicy
 }
 
 type EngineOption func(*Engine)
@@ -33,6 +43,10 @@ func WithOUIRegistry(r *oui.Registry) EngineOption {
 	return func(e *Engine) { e.OUIRegistry = r }
 }
 
+func WithMergePolicy(p *MergePolicy) EngineOption {
+	return func(e *Engine) { e.MergePolicy = p }
+}
+
 func NewEngine(scanners []Scanner, opts ...EngineOption) *Engine {
 	e := &Engine{
 		Scanners: scanners,
@@ -44,6 +58,20 @@ func NewEngine(scanners []Scanner, opts ...EngineOption) *Engine {
 	return e
 }
 
+// Close releases scanners that keep running across scans (e.g. the passive mDNS listener).
+// Scanners opt in by implementing io.Closer.
+func (e *Engine) Close() error {
+	var errs []error
+	for _, s := range e.Scanners {
+		if c, ok := s.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // fillManufacturerIfEmpty fills the Manufacturer field of the device using OUI lookup if it's empty.
 func (e *Engine) fillManufacturerIfEmpty(d *Device) {
 	if d == nil {
@@ -66,75 +94,74 @@ func (e *Engine) fillManufacturerIfEmpty(d *Device) {
 	}
 }
 
-// Stream runs scanners and invokes onDevice for each incremental merged device observed.
-func (e *Engine) Stream(ctx context.Context, onDevice func(*Device)) ([]Device, error) {
+// Stream runs all scanners concurrently and invokes onDevice for each incremental merged device observed.
+// Every scanner writes to its own channel, a fan-in stage merges those channels into a single stream.
+// The returned ScanReport describes per scanner how long it ran, how many devices it emitted and whether
+// it failed or hit the deadline. An error is only returned when every scanner failed.
+func (e *Engine) Stream(ctx context.Context, onDevice func(*Device)) ([]Device, ScanReport, error) {
 	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
 	defer cancel()
 
-	out := make(chan Device, 128)
-	var wg sync.WaitGroup
-
-	// TODO(ramon): currently all scanners share the same channel, might be worth to have a channel per scanner
-	// and launch a separate goroutine to merge results from all channels into 'out'.
-	// tip: look into the "fan-in" concurrency pattern.
-	for _, s := range e.Scanners {
-		wg.Add(1)
-		go func(sc Scanner) {
-			defer wg.Done()
-			_ = sc.Scan(ctx, out)
-		}(s)
+	report := ScanReport{StartedAt: time.Now()}
+	runs := make([]*scannerRun, len(e.Scanners))
+	for i, s := range e.Scanners {
+		runs[i] = newScannerRun(s)
+		go runs[i].run(ctx)
 	}
 
-	// Launched as goroutine to close out channel when all scanners are done
-	// So that it is non-blocking for the main loop and can start processing devices immediately
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
+	// done tells the fan-in stage to stop forwarding once Stream returns,
+	// it keeps draining the scanner channels so scanners never block on send.
+	done := make(chan struct{})
+	defer close(done)
+	out := fanIn(done, runs)
 
-	devices := map[string]*Device{}
+	devices := NewDeviceIndex(e.MergePolicy)
+	deadline := ctx.Done()
+	var grace <-chan time.Time
+loop:
 	for {
 		select {
-		case <-ctx.Done():
-			return mapToSlice(devices), nil
+		case <-deadline:
+			// give scanners a moment to observe the cancellation so their report is complete
+			deadline = nil
+			grace = time.After(scannerGracePeriod)
+		case <-grace:
+			break loop
 		case d, ok := <-out:
 			if !ok {
-				// channel closed, all scanners are done
-				return mapToSlice(devices), nil
+				// all scanners are done and their channels are drained
+				break loop
 			}
 			e.handleDevice(&d, devices, onDevice)
 		}
 	}
+
+	report.Duration = time.Since(report.StartedAt)
+	report.Devices = devices.Len()
+	for _, r := range runs {
+		report.Scanners = append(report.Scanners, r.snapshot(report.StartedAt))
+	}
+	logReport(report)
+
+	return indexToSlice(devices), report, report.allFailed()
 }
 
-// handleDevice processes a discovered device, merging with existing or adding new.
-func (e *Engine) handleDevice(d *Device, devices map[string]*Device, onDevice func(*Device)) {
-	if d.IP == nil || d.IP.String() == "" {
+// handleDevice merges a discovered device into the index, correlating it with
+// devices seen earlier in this scan on MAC, hostname or IP address.
+func (e *Engine) handleDevice(d *Device, devices *DeviceIndex, onDevice func(*Device)) {
+	dev := devices.Upsert(d)
+	if dev == nil {
 		return
 	}
-	key := d.IP.String()
-	if existing, found := devices[key]; found {
-		existing.Merge(d)
-		e.fillManufacturerIfEmpty(existing)
-		if onDevice != nil {
-			onDevice(existing)
-		}
-	} else {
-		dev := d
-		if dev.FirstSeen.IsZero() {
-			dev.FirstSeen = time.Now()
-		}
-		e.fillManufacturerIfEmpty(dev)
-		devices[key] = dev
-		if onDevice != nil {
-			onDevice(dev)
-		}
+	e.fillManufacturerIfEmpty(dev)
+	if onDevice != nil {
+		onDevice(dev)
 	}
 }
 
-func mapToSlice(m map[string]*Device) []Device {
-	res := make([]Device, 0, len(m))
-	for _, v := range m {
+func indexToSlice(x *DeviceIndex) []Device {
+	res := make([]Device, 0, x.Len())
+	for _, v := range x.Devices() {
 		res = append(res, *v)
 	}
 	return res
@@ -3,6 +3,7 @@ package discovery
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"testing"
 	"time"
@@ -12,6 +13,7 @@ type fakeScanner struct {
 	name    string
 	devices []Device
 	delay   time.Duration
+	err     error
 }
 
 func (f *fakeScanner) Name() string { return f.name }
@@ -31,7 +33,7 @@ func (f *fakeScanner) Scan(ctx context.Context, out chan<- Device) error {
 		case out <- d:
 		}
 	}
-	return nil
+	return f.err
 }
 
 func TestEngineStreamMergeAndDedup(t *testing.T) {
@@ -42,7 +44,7 @@ func TestEngineStreamMergeAndDedup(t *testing.T) {
 	first := Device{
 		IP:           net.ParseIP("10.0.0.1"),
 		DisplayName:  "one",
-		Services:     map[string]int{"svc": 1},
+		Services:     []ServiceInstance{{Type: "svc", Port: 1}},
 		Sources:      map[string]struct{}{"mdns": {}},
 		ExtraData:    map[string]string{"a": "1"},
 		Manufacturer: "",
@@ -54,7 +56,7 @@ func TestEngineStreamMergeAndDedup(t *testing.T) {
 		IP:           net.ParseIP("10.0.0.1"),
 		MAC:          "aa:bb",
 		Manufacturer: "manu",
-		Services:     map[string]int{"svc": 0, "svc2": 2},
+		Services:     []ServiceInstance{{Type: "svc"}, {Type: "svc2", Port: 2}},
 		Sources:      map[string]struct{}{"ssdp": {}},
 		ExtraData:    map[string]string{"b": "2"},
 		FirstSeen:    t0,
@@ -70,7 +72,7 @@ func TestEngineStreamMergeAndDedup(t *testing.T) {
 
 	var got []Device
 	ctx := context.Background()
-	devices, err := eng.Stream(ctx, func(d *Device) { got = append(got, *d) })
+	devices, _, err := eng.Stream(ctx, func(d *Device) { got = append(got, *d) })
 	if err != nil {
 		t.Fatalf("Stream returned error: %v", err)
 	}
@@ -98,7 +100,7 @@ func TestEngineStreamMergeAndDedup(t *testing.T) {
 	if d.LastSeen != time.Unix(400, 0) {
 		t.Fatalf("LastSeen not latest: %v", d.LastSeen)
 	}
-	if d.Services["svc"] != 1 || d.Services["svc2"] != 2 {
+	if svc, _ := d.Service("svc"); svc.Port != 1 || len(d.Services) != 2 || d.Services[1].Port != 2 {
 		t.Fatalf("services not merged: %+v", d.Services)
 	}
 	if _, ok := d.Sources["mdns"]; !ok {
@@ -122,7 +124,7 @@ func TestEngineTimeoutCancelsScanners(t *testing.T) {
 
 	start := time.Now()
 	ctx := context.Background()
-	devices, err := eng.Stream(ctx, nil)
+	devices, report, err := eng.Stream(ctx, nil)
 	elapsed := time.Since(start)
 
 	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
@@ -134,4 +136,138 @@ func TestEngineTimeoutCancelsScanners(t *testing.T) {
 	if elapsed > 300*time.Millisecond {
 		t.Fatalf("timeout test took too long: %v", elapsed)
 	}
+	if len(report.Scanners) != 1 || !report.Scanners[0].DeadlineExceeded {
+		t.Fatalf("expected slow scanner to be reported as deadline exceeded: %+v", report.Scanners)
+	}
+	if report.Scanners[0].Err != nil {
+		t.Fatalf("deadline should not be reported as scanner error: %v", report.Scanners[0].Err)
+	}
+}
+
+func TestEngineStreamReport(t *testing.T) {
+	joinErr := errors.New("join multicast group: no such device")
+	scanners := []Scanner{
+		&fakeScanner{name: "ok", devices: []Device{{IP: net.ParseIP("10.0.0.1")}, {IP: net.ParseIP("10.0.0.2")}}},
+		&fakeScanner{name: "broken", devices: []Device{{IP: net.ParseIP("10.0.0.1")}}, err: joinErr},
+	}
+	eng := NewEngine(scanners, WithTimeout(time.Second))
+
+	_, report, err := eng.Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("expected no error when only one scanner fails, got %v", err)
+	}
+	if report.Devices != 2 {
+		t.Fatalf("expected 2 merged devices, got %d", report.Devices)
+	}
+	if len(report.Scanners) != 2 {
+		t.Fatalf("expected 2 scanner reports, got %d", len(report.Scanners))
+	}
+
+	ok, broken := report.Scanners[0], report.Scanners[1]
+	if ok.Name != "ok" || ok.DevicesEmitted != 2 || ok.Err != nil || ok.DeadlineExceeded {
+		t.Errorf("unexpected report for ok scanner: %+v", ok)
+	}
+	if broken.Name != "broken" || broken.DevicesEmitted != 1 || !errors.Is(broken.Err, joinErr) || broken.Error == "" {
+		t.Errorf("unexpected report for broken scanner: %+v", broken)
+	}
+	if failed := report.Failed(); len(failed) != 1 || failed[0].Name != "broken" {
+		t.Errorf("expected broken scanner in Failed(), got %+v", failed)
+	}
+}
+
+func TestEngineStreamReportDisabled(t *testing.T) {
+	scanners := []Scanner{
+		&fakeScanner{name: "ok", devices: []Device{{IP: net.ParseIP("10.0.0.1")}}},
+		&fakeScanner{name: "icmp", err: fmt.Errorf("listen: %w", &DisabledError{Reason: "ICMP sockets not permitted"})},
+	}
+	eng := NewEngine(scanners, WithTimeout(time.Second))
+
+	_, report, err := eng.Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabled := report.Scanners[1]
+	if disabled.Err != nil || disabled.Disabled != "ICMP sockets not permitted" {
+		t.Errorf("expected disabled status instead of failure, got %+v", disabled)
+	}
+	if failed := report.Failed(); len(failed) != 0 {
+		t.Errorf("expected no failed scanners, got %+v", failed)
+	}
+}
+
+func TestEngineStreamAllScannersFailed(t *testing.T) {
+	scanners := []Scanner{
+		&fakeScanner{name: "a", err: errors.New("boom")},
+		&fakeScanner{name: "b", err: errors.New("bang")},
+	}
+	eng := NewEngine(scanners, WithTimeout(time.Second))
+
+	_, _, err := eng.Stream(context.Background(), nil)
+	if err == nil {
+		t.Fatalf("expected error when all scanners fail")
+	}
+}
+
+type fakeInterfaceScanner struct {
+	fakeScanner
+	iface *InterfaceInfo
+}
+
+func (f *fakeInterfaceScanner) Interface() *InterfaceInfo { return f.iface }
+
+func TestEngineStreamTagsInterface(t *testing.T) {
+	_, lan, _ := net.ParseCIDR("192.168.1.0/24")
+	lan.IP = net.ParseIP("192.168.1.2").To4()
+	_, lab, _ := net.ParseCIDR("10.20.0.0/16")
+	lab.IP = net.ParseIP("10.20.0.2").To4()
+	eth0 := &InterfaceInfo{Interface: &net.Interface{Name: "eth0"}, IPv4Net: lan}
+	vlan := &InterfaceInfo{Interface: &net.Interface{Name: "eth0.20"}, IPv4Net: lab}
+
+	scanners := []Scanner{
+		&fakeInterfaceScanner{fakeScanner{name: "arp", devices: []Device{NewDevice(net.ParseIP("192.168.1.10"))}}, eth0},
+		&fakeInterfaceScanner{fakeScanner{name: "arp", devices: []Device{NewDevice(net.ParseIP("10.20.3.4"))}}, vlan},
+	}
+	e := NewEngine(scanners, WithTimeout(time.Second))
+
+	devices, report, err := e.Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 devices, got %d", len(devices))
+	}
+	want := map[string][2]string{
+		"192.168.1.10": {"eth0", "192.168.1.0/24"},
+		"10.20.3.4":    {"eth0.20", "10.20.0.0/16"},
+	}
+	for _, d := range devices {
+		w := want[d.IP.String()]
+		if d.Interface != w[0] || d.Subnet != w[1] {
+			t.Errorf("%s: expected %s %s, got %s %s", d.IP, w[0], w[1], d.Interface, d.Subnet)
+		}
+	}
+	if report.Scanners[1].Label() != "arp@eth0.20" {
+		t.Errorf("expected interface in scanner label, got %q", report.Scanners[1].Label())
+	}
+}
+
+type closingScanner struct {
+	fakeScanner
+	closed bool
+}
+
+func (c *closingScanner) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestEngineClose(t *testing.T) {
+	closer := &closingScanner{fakeScanner: fakeScanner{name: "listener"}}
+	e := NewEngine([]Scanner{&fakeScanner{name: "plain"}, closer})
+	if err := e.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !closer.closed {
+		t.Errorf("expected scanner implementing io.Closer to be closed")
+	}
 }
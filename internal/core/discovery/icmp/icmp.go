@@ -0,0 +1,295 @@
+package icmp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"go.uber.org/zap"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Name is the scanner name and the source of devices that answered an echo request.
+const Name = "icmp"
+
+const (
+	// maxSweepHosts caps the size of the subnet and of target prefixes, larger ones are not swept.
+	maxSweepHosts = 4096
+	// sweepBatch hosts are pinged before pausing for sweepPause.
+	sweepBatch = 32
+	sweepPause = 10 * time.Millisecond
+	// maxPacketSize is the read buffer size, echo replies fit in one Ethernet frame.
+	maxPacketSize = 1500
+
+	protocolICMP   = 1  // IANA protocol number of ICMP, see icmp.ParseMessage
+	protocolICMPv6 = 58 // IANA protocol number of ICMPv6
+)
+
+// payloadMagic follows the send timestamp in the echo payload to recognize our own replies.
+var payloadMagic = []byte("whosthere")
+
+var _ discovery.InterfaceScanner = (*Scanner)(nil)
+
+// Scanner checks which hosts are alive with ICMP echo requests and records their round-trip
+// time. It uses unprivileged datagram ICMP sockets, which Linux only permits to the groups
+// in net.ipv4.ping_group_range. When the OS refuses the socket the scanner reports itself
+// as disabled instead of failing every scan.
+type Scanner struct {
+	iface   *discovery.InterfaceInfo
+	targets []string
+
+	warnOnce sync.Once
+}
+
+// NewScanner returns a scanner pinging targets (IP addresses and IPv4 prefixes), or the
+// IPv4 subnet of the interface when there are none.
+func NewScanner(iface *discovery.InterfaceInfo, targets []string) *Scanner {
+	return &Scanner{iface: iface, targets: targets}
+}
+
+func (s *Scanner) Name() string { return Name }
+
+// Interface returns the network interface the scanner is bound to.
+func (s *Scanner) Interface() *discovery.InterfaceInfo { return s.iface }
+
+// family holds the socket parameters of an address family.
+type family struct {
+	network   string // network of icmp.ListenPacket
+	address   string
+	protocol  int
+	echo      icmp.Type
+	echoReply icmp.Type
+}
+
+var (
+	familyIPv4 = family{network: "udp4", address: "0.0.0.0", protocol: protocolICMP, echo: ipv4.ICMPTypeEcho, echoReply: ipv4.ICMPTypeEchoReply}
+	familyIPv6 = family{network: "udp6", address: "::", protocol: protocolICMPv6, echo: ipv6.ICMPTypeEchoRequest, echoReply: ipv6.ICMPTypeEchoReply}
+)
+
+// Scan pings every host and emits a device for every echo reply until the deadline of ctx.
+func (s *Scanner) Scan(ctx context.Context, out chan<- discovery.Device) error {
+	log := zap.L().With(zap.String("scanner", Name))
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return errors.New("icmp scan requires context with deadline")
+	}
+	v4, v6 := s.hosts(log)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, job := range []struct {
+		f     family
+		hosts []net.IP
+	}{{familyIPv4, v4}, {familyIPv6, v6}} {
+		if len(job.hosts) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.ping(ctx, deadline, job.f, job.hosts, out, log); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// hosts returns the IPv4 and IPv6 hosts to ping. Targets the interface does not
+// route to are left to the scanner of the interface that does.
+func (s *Scanner) hosts(log *zap.Logger) (v4, v6 []net.IP) {
+	if len(s.targets) == 0 {
+		if s.iface == nil || s.iface.IPv4Net == nil {
+			log.Debug("interface has no IPv4 subnet, skipping")
+			return nil, nil
+		}
+		for _, ip := range discovery.SubnetHosts(s.iface.IPv4Net, maxSweepHosts) {
+			if s.iface.IPv4Addr == nil || !ip.Equal(*s.iface.IPv4Addr) {
+				v4 = append(v4, ip)
+			}
+		}
+		if v4 == nil {
+			log.Debug("subnet too large to sweep", zap.Stringer("subnet", s.iface.IPv4Net))
+		}
+		return v4, nil
+	}
+
+	for _, target := range s.targets {
+		ips, err := expandTarget(target)
+		if err != nil {
+			log.Debug("skipping target", zap.String("target", target), zap.Error(err))
+			continue
+		}
+		if !s.iface.RoutesTo(ips[0].String()) {
+			continue
+		}
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				v4 = append(v4, ip)
+			} else {
+				v6 = append(v6, ip)
+			}
+		}
+	}
+	return v4, v6
+}
+
+// expandTarget returns the address of an IP target or the hosts of an IPv4 prefix.
+func expandTarget(target string) ([]net.IP, error) {
+	if ip := net.ParseIP(target); ip != nil {
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+		return []net.IP{ip}, nil
+	}
+	_, prefix, err := net.ParseCIDR(target)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither an IP address nor a prefix", target)
+	}
+	hosts := discovery.SubnetHosts(prefix, maxSweepHosts)
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("prefix %s is not IPv4 or has more than %d hosts", prefix, maxSweepHosts)
+	}
+	return hosts, nil
+}
+
+// ping sends an echo request to every host and reads the replies.
+func (s *Scanner) ping(ctx context.Context, deadline time.Time, f family, hosts []net.IP, out chan<- discovery.Device, log *zap.Logger) error {
+	conn, err := icmp.ListenPacket(f.network, f.address)
+	if err != nil {
+		return s.disabled(err, log)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return fmt.Errorf("set read deadline: %w", err)
+	}
+
+	sendCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sweep(sendCtx, conn, f, hosts)
+	}()
+
+	err = read(ctx, conn, f, out)
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// disabled turns the error of opening the ICMP socket into a discovery.DisabledError,
+// logging the reason once.
+func (s *Scanner) disabled(err error, log *zap.Logger) error {
+	reason := fmt.Sprintf("cannot open an unprivileged ICMP socket: %v", err)
+	if errors.Is(err, os.ErrPermission) && runtime.GOOS == "linux" {
+		reason = `unprivileged ICMP sockets are not permitted, allow them with sysctl -w net.ipv4.ping_group_range="0 2147483647"`
+	}
+	s.warnOnce.Do(func() {
+		log.Warn("icmp scanner disabled", zap.String("reason", reason))
+	})
+	return &discovery.DisabledError{Reason: reason}
+}
+
+// sweep sends the echo requests, the send time is carried in the payload.
+func sweep(ctx context.Context, conn *icmp.PacketConn, f family, hosts []net.IP) {
+	for i, ip := range hosts {
+		if i > 0 && i%sweepBatch == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(sweepPause):
+			}
+		}
+		// the kernel sets the identifier of datagram ICMP sockets to the local port
+		msg := icmp.Message{Type: f.echo, Body: &icmp.Echo{Seq: i & 0xffff, Data: payload(time.Now())}}
+		b, err := msg.Marshal(nil)
+		if err != nil {
+			continue
+		}
+		_, _ = conn.WriteTo(b, &net.UDPAddr{IP: ip})
+	}
+}
+
+// read emits a device for the first echo reply of every host.
+func read(ctx context.Context, conn *icmp.PacketConn, f family, out chan<- discovery.Device) error {
+	seen := map[string]bool{}
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("read: %w", err)
+		}
+		addr, ok := peer.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		rtt, ok := parseReply(f, buf[:n], time.Now())
+		if !ok || seen[addr.IP.String()] {
+			continue
+		}
+		seen[addr.IP.String()] = true
+
+		ip := addr.IP
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+		d := discovery.NewDevice(ip)
+		d.Sources[Name] = struct{}{}
+		d.Latency = rtt
+		select {
+		case out <- d:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// payload returns the echo payload: the send time in nanoseconds followed by payloadMagic.
+func payload(sent time.Time) []byte {
+	b := binary.BigEndian.AppendUint64(nil, uint64(sent.UnixNano()))
+	return append(b, payloadMagic...)
+}
+
+// parseReply returns the round-trip time of an echo reply to one of our requests.
+func parseReply(f family, b []byte, received time.Time) (time.Duration, bool) {
+	msg, err := icmp.ParseMessage(f.protocol, b)
+	if err != nil || msg.Type != f.echoReply {
+		return 0, false
+	}
+	echo, ok := msg.Body.(*icmp.Echo)
+	if !ok || len(echo.Data) != 8+len(payloadMagic) || !bytes.Equal(echo.Data[8:], payloadMagic) {
+		return 0, false
+	}
+	sent := time.Unix(0, int64(binary.BigEndian.Uint64(echo.Data)))
+	rtt := received.Sub(sent)
+	if rtt < 0 {
+		return 0, false
+	}
+	return rtt, true
+}
@@ -0,0 +1,99 @@
+package icmp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestParseReply(t *testing.T) {
+	sent := time.Unix(1700000000, 0)
+	reply := func(typ icmp.Type, data []byte) []byte {
+		b, err := (&icmp.Message{Type: typ, Body: &icmp.Echo{ID: 7, Seq: 1, Data: data}}).Marshal(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	rtt, ok := parseReply(familyIPv4, reply(ipv4.ICMPTypeEchoReply, payload(sent)), sent.Add(3*time.Millisecond))
+	if !ok || rtt != 3*time.Millisecond {
+		t.Errorf("expected 3ms round trip, got %s (%t)", rtt, ok)
+	}
+	if _, ok := parseReply(familyIPv4, reply(ipv4.ICMPTypeEcho, payload(sent)), sent); ok {
+		t.Error("expected echo request to be ignored")
+	}
+	if _, ok := parseReply(familyIPv4, reply(ipv4.ICMPTypeEchoReply, []byte("abcdefgh-ping")), sent); ok {
+		t.Error("expected reply to a foreign request to be ignored")
+	}
+	if _, ok := parseReply(familyIPv4, reply(ipv4.ICMPTypeEchoReply, payload(sent)), sent.Add(-time.Second)); ok {
+		t.Error("expected reply from the future to be ignored")
+	}
+}
+
+func TestExpandTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		hosts  int
+		err    bool
+	}{
+		{"10.1.2.3", 1, false},
+		{"2001:db8::1", 1, false},
+		{"10.1.2.0/29", 6, false},
+		{"10.1.2.3/32", 1, false},
+		{"10.0.0.0/8", 0, true},
+		{"2001:db8::/64", 0, true},
+		{"printer.lan", 0, true},
+	}
+	for _, tt := range tests {
+		hosts, err := expandTarget(tt.target)
+		if (err != nil) != tt.err || len(hosts) != tt.hosts {
+			t.Errorf("expandTarget(%q) = %d hosts, %v", tt.target, len(hosts), err)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	c := &Config{Targets: []string{"10.1.2.3", "10.0.0.0/8", "10.1.2.0/24"}}
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for the /8 target")
+	}
+	if len(c.Targets) != 2 || c.Targets[1] != "10.1.2.0/24" {
+		t.Errorf("expected the /8 target dropped, got %v", c.Targets)
+	}
+}
+
+func TestScanLoopback(t *testing.T) {
+	loopback := net.IPv4(127, 0, 0, 1)
+	s := NewScanner(&discovery.InterfaceInfo{IPv4Addr: &loopback}, []string{"127.0.0.1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out := make(chan discovery.Device, 4)
+	err := s.Scan(ctx, out)
+	var disabled *discovery.DisabledError
+	if errors.As(err, &disabled) {
+		// net.ipv4.ping_group_range does not include our group
+		if disabled.Reason == "" {
+			t.Error("expected a reason for the disabled scanner")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+	d, ok := <-out
+	if !ok || !d.IP.Equal(loopback) || d.Latency <= 0 {
+		t.Fatalf("expected echo reply from loopback, got %+v", d)
+	}
+	if _, ok := d.Sources[Name]; !ok {
+		t.Errorf("expected source %q, got %v", Name, d.Sources)
+	}
+}
@@ -0,0 +1,53 @@
+package icmp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+// Config is the `scanners.icmp` config block.
+type Config struct {
+	config.ScannerToggle `yaml:",inline"`
+	// Targets are the IP addresses and IPv4 prefixes to ping, the subnet of the interface when empty.
+	Targets []string `yaml:"targets"`
+}
+
+// Validate drops targets that are not an IP address or a sweepable IPv4 prefix.
+func (c *Config) Validate() error {
+	var errs []string
+	targets := c.Targets[:0]
+	for _, target := range c.Targets {
+		if _, err := expandTarget(target); err != nil {
+			errs = append(errs, "targets: "+err.Error())
+			continue
+		}
+		targets = append(targets, target)
+	}
+	c.Targets = targets
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func init() {
+	discovery.Register(discovery.Registration{
+		Name:           Name,
+		Description:    "ICMP echo sweep with unprivileged sockets, measures latency",
+		DefaultEnabled: true,
+		NewConfig: func() config.ScannerSettings {
+			return &Config{}
+		},
+		New: func(iface *discovery.InterfaceInfo, settings config.ScannerSettings) (discovery.Scanner, error) {
+			cfg, ok := settings.(*Config)
+			if !ok {
+				return nil, fmt.Errorf("unexpected config type %T", settings)
+			}
+			return NewScanner(iface, cfg.Targets), nil
+		},
+	})
+}
@@ -0,0 +1,353 @@
+package discovery
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// primaryAddressHold is how long a device keeps its primary address after it was last
+	// seen there while it is reported on another address (e.g. after a DHCP lease change).
+	primaryAddressHold = time.Minute
+	// addressStaleAfter marks an address historical when the device was not seen on it for this long.
+	addressStaleAfter = 10 * time.Minute
+	// heldEnrichmentTTL is how long an enrichment of an unknown device waits for the device to be seen.
+	heldEnrichmentTTL = 10 * time.Minute
+)
+
+// AddressRecord is an IP address a device has been seen with.
+type AddressRecord struct {
+	IP        net.IP    `json:"ip"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Current   bool      `json:"current"` // false once the address went stale or moved to another device
+}
+
+// NormalizeMAC returns mac in lower-case colon notation, or "" when it is not a valid MAC.
+func NormalizeMAC(mac string) string {
+	hw, err := net.ParseMAC(strings.TrimSpace(mac))
+	if err != nil || len(hw) == 0 {
+		return ""
+	}
+	return hw.String()
+}
+
+// normalizeHostname lower-cases a hostname and strips the trailing dot.
+func normalizeHostname(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// CurrentAddresses returns the addresses the device is currently reachable on.
+func (d *Device) CurrentAddresses() []net.IP {
+	var out []net.IP
+	for _, a := range d.addressRecords() {
+		if a.Current {
+			out = append(out, a.IP)
+		}
+	}
+	return out
+}
+
+// HasAddress reports whether ip is a current address of the device.
+func (d *Device) HasAddress(ip net.IP) bool {
+	for _, a := range d.addressRecords() {
+		if a.Current && a.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressRecords returns the address list, devices built without NewDevice get one for IP.
+func (d *Device) addressRecords() []AddressRecord {
+	if len(d.Addresses) == 0 && d.IP != nil {
+		return []AddressRecord{{IP: d.IP, FirstSeen: d.FirstSeen, LastSeen: d.LastSeen, Current: true}}
+	}
+	return d.Addresses
+}
+
+// mergeAddresses adds the addresses of other and re-evaluates which ones are current.
+// The list is copied first, snapshots handed out by AppState share the old one.
+func (d *Device) mergeAddresses(other *Device) {
+	d.Addresses = append([]AddressRecord(nil), d.addressRecords()...)
+	for _, a := range other.addressRecords() {
+		d.observeAddress(a)
+	}
+	d.refreshAddresses()
+}
+
+func (d *Device) observeAddress(a AddressRecord) {
+	for i := range d.Addresses {
+		r := &d.Addresses[i]
+		if !r.IP.Equal(a.IP) {
+			continue
+		}
+		if !a.FirstSeen.IsZero() && (r.FirstSeen.IsZero() || a.FirstSeen.Before(r.FirstSeen)) {
+			r.FirstSeen = a.FirstSeen
+		}
+		if a.LastSeen.After(r.LastSeen) {
+			r.LastSeen = a.LastSeen
+			r.Current = a.Current
+		}
+		return
+	}
+	d.Addresses = append(d.Addresses, a)
+}
+
+// retireAddress marks ip historical, used when another device took over the address.
+func (d *Device) retireAddress(ip net.IP) {
+	d.Addresses = append([]AddressRecord(nil), d.addressRecords()...)
+	for i := range d.Addresses {
+		if d.Addresses[i].IP.Equal(ip) {
+			d.Addresses[i].Current = false
+		}
+	}
+	d.refreshAddresses()
+}
+
+// refreshAddresses marks stale addresses historical and picks the primary IP. The primary
+// only moves when it is no longer current or when the device has been seen elsewhere for
+// longer than primaryAddressHold, so hosts with multiple addresses do not flip between them.
+// IPv4 addresses are preferred as primary, dual-stack hosts keep the address users know.
+func (d *Device) refreshAddresses() {
+	var primary, newest *AddressRecord
+	for i := range d.Addresses {
+		a := &d.Addresses[i]
+		if a.Current && d.LastSeen.Sub(a.LastSeen) > addressStaleAfter {
+			a.Current = false
+		}
+		if a.IP.Equal(d.IP) {
+			primary = a
+		}
+		if a.Current && (newest == nil || preferAddress(a, newest)) {
+			newest = a
+		}
+	}
+	switch {
+	case newest == nil:
+		// no current address left, keep the last known one
+	case primary == nil || !primary.Current:
+		d.IP = newest.IP
+	case isIPv4(newest.IP) && !isIPv4(primary.IP):
+		d.IP = newest.IP
+	case newest.LastSeen.Sub(primary.LastSeen) > primaryAddressHold:
+		d.IP = newest.IP
+	}
+
+	sort.SliceStable(d.Addresses, func(i, j int) bool {
+		if d.Addresses[i].Current != d.Addresses[j].Current {
+			return d.Addresses[i].Current
+		}
+		return d.Addresses[i].FirstSeen.Before(d.Addresses[j].FirstSeen)
+	})
+}
+
+// preferAddress reports whether a is a better primary candidate than b: IPv4 first, then the most recent.
+func preferAddress(a, b *AddressRecord) bool {
+	if isIPv4(a.IP) != isIPv4(b.IP) {
+		return isIPv4(a.IP)
+	}
+	return a.LastSeen.After(b.LastSeen)
+}
+
+func isIPv4(ip net.IP) bool { return ip.To4() != nil }
+
+// DeviceIndex correlates observations into devices with a stable identity. Devices are
+// matched on MAC first, then on mDNS hostname and finally on IP address. The ID of a
+// device is its MAC address when known, its IP address otherwise.
+// DeviceIndex is not safe for concurrent use.
+type DeviceIndex struct {
+	policy  *MergePolicy
+	devices map[string]*Device        // ID -> device
+	aliases map[string]string         // "mac:", "host:" and "ip:" keys -> ID
+	held    map[string]heldEnrichment // "ip:" key -> latest EnrichOnly observation of an unknown device
+	now     func() time.Time
+}
+
+// heldEnrichment is an EnrichOnly observation waiting for its device, dropped after heldEnrichmentTTL.
+type heldEnrichment struct {
+	obs *Device
+	at  time.Time
+}
+
+// NewDeviceIndex returns an empty index that merges devices with policy (DefaultMergePolicy when nil).
+func NewDeviceIndex(policy *MergePolicy) *DeviceIndex {
+	return &DeviceIndex{policy: policy, devices: map[string]*Device{}, aliases: map[string]string{}, held: map[string]heldEnrichment{}, now: time.Now}
+}
+
+// Len returns the number of devices.
+func (x *DeviceIndex) Len() int { return len(x.devices) }
+
+// Devices returns all devices in no particular order.
+func (x *DeviceIndex) Devices() []*Device {
+	out := make([]*Device, 0, len(x.devices))
+	for _, d := range x.devices {
+		out = append(out, d)
+	}
+	return out
+}
+
+// Lookup finds a device by ID, MAC address, IP address (current addresses only) or hostname.
+func (x *DeviceIndex) Lookup(id string) (*Device, bool) {
+	id = strings.TrimSpace(id)
+	if d, ok := x.devices[id]; ok {
+		return d, true
+	}
+	var key string
+	switch {
+	case NormalizeMAC(id) != "":
+		key = "mac:" + NormalizeMAC(id)
+	case net.ParseIP(id) != nil:
+		key = "ip:" + net.ParseIP(id).String()
+	default:
+		key = "host:" + normalizeHostname(id)
+	}
+	d, ok := x.devices[x.aliases[key]]
+	return d, ok
+}
+
+// Upsert merges an observation into the index and returns the device it was merged into,
+// nil when the observation has no IP address, is a goodbye of an unknown device or
+// only enriches an unknown device. The latest such enrichment of an address is merged once
+// the device is seen within heldEnrichmentTTL.
+func (x *DeviceIndex) Upsert(obs *Device) *Device {
+	if obs == nil || obs.IP == nil || obs.IP.IsUnspecified() {
+		return nil
+	}
+	mac := NormalizeMAC(obs.MAC)
+	host := normalizeHostname(obs.Hostname)
+	ipKey := "ip:" + obs.IP.String()
+
+	var target *Device
+	if mac != "" {
+		target = x.devices[x.aliases["mac:"+mac]]
+	}
+	if target == nil && host != "" {
+		if d := x.devices[x.aliases["host:"+host]]; d != nil && x.compatible(d, mac) {
+			target = d
+		}
+	}
+
+	// resolve the device that currently holds the address
+	if holder := x.devices[x.aliases[ipKey]]; holder != nil && holder != target {
+		switch {
+		case !x.compatible(holder, mac):
+			// the address moved to another device (e.g. DHCP handed it out again)
+			holder.retireAddress(obs.IP)
+			x.reindex(holder)
+		case target == nil:
+			target = holder
+		case !x.compatible(holder, NormalizeMAC(target.MAC)):
+			// the hostname points elsewhere but the address is owned by another MAC, trust the address
+			target = holder
+		default:
+			// an IP-only device turned out to be the same host as target
+			target.MergeWith(holder, x.policy)
+			x.remove(holder)
+		}
+	}
+
+	if target == nil {
+		if obs.Departed() || len(obs.RemovedServices) > 0 && len(obs.Services) == 0 {
+			// a goodbye of a device that was never seen
+			return nil
+		}
+		if obs.EnrichOnly {
+			now := x.now()
+			x.pruneHeld(now)
+			held := *obs
+			x.held[ipKey] = heldEnrichment{obs: &held, at: now}
+			return nil
+		}
+		dev := *obs
+		dev.Addresses = dev.addressRecords()
+		if dev.FirstSeen.IsZero() {
+			dev.FirstSeen = time.Now()
+		}
+		target = &dev
+		if held, ok := x.held[ipKey]; ok {
+			if x.now().Sub(held.at) <= heldEnrichmentTTL {
+				target.MergeWith(held.obs, x.policy)
+			}
+			delete(x.held, ipKey)
+		}
+	} else {
+		target.MergeWith(obs, x.policy)
+	}
+	x.reindex(target)
+	return target
+}
+
+// pruneHeld drops the enrichments that waited longer than heldEnrichmentTTL.
+func (x *DeviceIndex) pruneHeld(now time.Time) {
+	for key, held := range x.held {
+		if now.Sub(held.at) > heldEnrichmentTTL {
+			delete(x.held, key)
+		}
+	}
+}
+
+// RemoveWhere drops every device for which fn returns true and returns how many were removed.
+func (x *DeviceIndex) RemoveWhere(fn func(*Device) bool) int {
+	var n int
+	for _, d := range x.Devices() {
+		if fn(d) {
+			x.remove(d)
+			n++
+		}
+	}
+	return n
+}
+
+// compatible reports whether d may be the device with the given MAC.
+func (x *DeviceIndex) compatible(d *Device, mac string) bool {
+	known := NormalizeMAC(d.MAC)
+	return mac == "" || known == "" || known == mac
+}
+
+// remove drops d and its aliases from the index.
+func (x *DeviceIndex) remove(d *Device) {
+	for k, id := range x.aliases {
+		if id == d.ID {
+			delete(x.aliases, k)
+		}
+	}
+	delete(x.devices, d.ID)
+}
+
+// reindex (re)assigns the ID of d and points its aliases at it.
+func (x *DeviceIndex) reindex(d *Device) {
+	id := NormalizeMAC(d.MAC)
+	if id == "" {
+		id = d.IP.String()
+	}
+	if d.ID != id {
+		if d.ID != "" && x.devices[d.ID] == d {
+			x.remove(d)
+		}
+		d.ID = id
+	}
+	if other, ok := x.devices[id]; ok && other != d {
+		// another device already claimed the ID, it describes the same host
+		d.MergeWith(other, x.policy)
+		x.remove(other)
+	}
+	x.devices[id] = d
+
+	for k, v := range x.aliases {
+		if v == id && strings.HasPrefix(k, "ip:") {
+			delete(x.aliases, k)
+		}
+	}
+	if mac := NormalizeMAC(d.MAC); mac != "" {
+		x.aliases["mac:"+mac] = id
+	}
+	if host := normalizeHostname(d.Hostname); host != "" {
+		x.aliases["host:"+host] = id
+	}
+	for _, ip := range d.CurrentAddresses() {
+		x.aliases["ip:"+ip.String()] = id
+	}
+}
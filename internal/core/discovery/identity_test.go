@@ -0,0 +1,240 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func observation(ip, mac, host, source string, seen time.Time) *Device {
+	d := NewDevice(net.ParseIP(ip))
+	d.MAC = mac
+	d.Hostname = host
+	d.Sources[source] = struct{}{}
+	d.FirstSeen, d.LastSeen = seen, seen
+	d.Addresses[0].FirstSeen, d.Addresses[0].LastSeen = seen, seen
+	return &d
+}
+
+func TestDeviceIndexLeaseChange(t *testing.T) {
+	x := NewDeviceIndex(nil)
+	t0 := time.Unix(1000, 0)
+
+	x.Upsert(observation("10.0.0.10", "AA:BB:CC:DD:EE:01", "", "arp", t0))
+	dev := x.Upsert(observation("10.0.0.20", "aa:bb:cc:dd:ee:01", "", "arp", t0.Add(2*time.Minute)))
+
+	if x.Len() != 1 {
+		t.Fatalf("expected 1 device, got %d", x.Len())
+	}
+	if dev.ID != "aa:bb:cc:dd:ee:01" {
+		t.Errorf("expected MAC as ID, got %q", dev.ID)
+	}
+	if !dev.IP.Equal(net.ParseIP("10.0.0.20")) {
+		t.Errorf("expected primary IP to move to new lease, got %s", dev.IP)
+	}
+	if len(dev.Addresses) != 2 {
+		t.Fatalf("expected 2 addresses, got %+v", dev.Addresses)
+	}
+	for _, id := range []string{"10.0.0.20", "10.0.0.10", "AA-BB-CC-DD-EE-01"} {
+		if got, ok := x.Lookup(id); !ok || got != dev {
+			t.Errorf("lookup %q: expected device", id)
+		}
+	}
+}
+
+func TestDeviceIndexAddressReassigned(t *testing.T) {
+	x := NewDeviceIndex(nil)
+	t0 := time.Unix(1000, 0)
+
+	old := x.Upsert(observation("10.0.0.10", "aa:bb:cc:dd:ee:01", "", "arp", t0))
+	x.Upsert(observation("10.0.0.10", "aa:bb:cc:dd:ee:02", "", "arp", t0.Add(time.Hour)))
+
+	if x.Len() != 2 {
+		t.Fatalf("expected 2 devices, got %d", x.Len())
+	}
+	if old.HasAddress(net.ParseIP("10.0.0.10")) {
+		t.Errorf("expected address to be historical on the previous owner")
+	}
+	got, ok := x.Lookup("10.0.0.10")
+	if !ok || got.ID != "aa:bb:cc:dd:ee:02" {
+		t.Errorf("expected IP lookup to return the new owner, got %+v", got)
+	}
+}
+
+func TestDeviceIndexCorrelatesIPOnlyDevice(t *testing.T) {
+	x := NewDeviceIndex(nil)
+	t0 := time.Unix(1000, 0)
+
+	x.Upsert(observation("10.0.0.10", "", "tv.local", "mdns", t0))
+	dev := x.Upsert(observation("10.0.0.10", "aa:bb:cc:dd:ee:01", "", "arp", t0))
+
+	if x.Len() != 1 {
+		t.Fatalf("expected 1 device, got %d", x.Len())
+	}
+	if dev.ID != "aa:bb:cc:dd:ee:01" {
+		t.Errorf("expected ID to become the MAC, got %q", dev.ID)
+	}
+	if _, ok := dev.Sources["mdns"]; !ok {
+		t.Errorf("expected mdns source to be kept, got %v", dev.Sources)
+	}
+	if got, ok := x.Lookup("tv.local."); !ok || got != dev {
+		t.Errorf("expected hostname lookup to return the device")
+	}
+	if _, ok := x.Lookup("10.0.0.10"); !ok {
+		t.Errorf("expected old IP based ID to resolve through the address")
+	}
+}
+
+func TestDeviceIndexHostnameMultipleAddresses(t *testing.T) {
+	x := NewDeviceIndex(nil)
+	t0 := time.Unix(1000, 0)
+
+	x.Upsert(observation("10.0.0.10", "", "nas.local", "mdns", t0))
+	dev := x.Upsert(observation("10.0.0.11", "", "nas.local", "mdns", t0.Add(time.Second)))
+
+	if x.Len() != 1 {
+		t.Fatalf("expected 1 device, got %d", x.Len())
+	}
+	if !dev.IP.Equal(net.ParseIP("10.0.0.10")) {
+		t.Errorf("expected primary IP to stay, got %s", dev.IP)
+	}
+	if got := dev.CurrentAddresses(); len(got) != 2 {
+		t.Errorf("expected 2 current addresses, got %v", got)
+	}
+}
+
+func TestDeviceAddressGoesStale(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	dev := observation("10.0.0.10", "aa:bb:cc:dd:ee:01", "", "arp", t0)
+	dev.Merge(observation("10.0.0.20", "aa:bb:cc:dd:ee:01", "", "arp", t0.Add(addressStaleAfter+time.Minute)))
+
+	if dev.HasAddress(net.ParseIP("10.0.0.10")) {
+		t.Errorf("expected old address to be historical")
+	}
+	if !dev.IP.Equal(net.ParseIP("10.0.0.20")) {
+		t.Errorf("expected primary IP 10.0.0.20, got %s", dev.IP)
+	}
+	if dev.Addresses[0].IP.String() != "10.0.0.20" {
+		t.Errorf("expected current addresses first, got %+v", dev.Addresses)
+	}
+}
+
+func TestDevicePrefersIPv4Primary(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	dev := observation("fe80::1", "", "printer.local", "mdns", t0)
+	dev.Merge(observation("10.0.0.10", "", "printer.local", "mdns", t0))
+	dev.Merge(observation("fe80::1", "", "printer.local", "mdns", t0.Add(5*time.Minute)))
+
+	if !dev.IP.Equal(net.ParseIP("10.0.0.10")) {
+		t.Errorf("expected IPv4 primary, got %s", dev.IP)
+	}
+	if got := dev.CurrentAddresses(); len(got) != 2 {
+		t.Errorf("expected both addresses to be current, got %v", got)
+	}
+}
+
+func TestNormalizeMAC(t *testing.T) {
+	tests := map[string]string{
+		"AA:BB:CC:DD:EE:FF": "aa:bb:cc:dd:ee:ff",
+		"aa-bb-cc-dd-ee-ff": "aa:bb:cc:dd:ee:ff",
+		"":                  "",
+		"not-a-mac":         "",
+	}
+	for in, want := range tests {
+		if got := NormalizeMAC(in); got != want {
+			t.Errorf("NormalizeMAC(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDeviceIndexDeparture(t *testing.T) {
+	x := NewDeviceIndex(nil)
+	t0 := time.Unix(1000, 0)
+
+	hello := observation("10.0.0.10", "", "printer.local", "mdns", t0)
+	hello.Services = []ServiceInstance{{Instance: "Printer", Type: "ipp", Protocol: "tcp", Port: 631}, {Instance: "Printer", Type: "http", Protocol: "tcp", Port: 80}}
+	x.Upsert(hello)
+
+	goodbye := observation("10.0.0.10", "", "", "mdns", t0.Add(time.Minute))
+	goodbye.RemovedServices = []ServiceInstance{{Instance: "Printer", Type: "ipp", Protocol: "tcp"}}
+	goodbye.DepartedAt = t0.Add(time.Minute)
+	dev := x.Upsert(goodbye)
+
+	if !dev.Departed() || !dev.DepartedAt.Equal(t0.Add(time.Minute)) {
+		t.Errorf("expected device to be departed, got %v", dev.DepartedAt)
+	}
+	if http, _ := dev.Service("http"); len(dev.Services) != 1 || http.Port != 80 {
+		t.Errorf("expected ipp to be removed, got %v", dev.Services)
+	}
+
+	dev = x.Upsert(observation("10.0.0.10", "", "", "arp", t0.Add(2*time.Minute)))
+	if dev.Departed() {
+		t.Errorf("expected a later sighting to clear the departure")
+	}
+}
+
+func TestDeviceIndexIgnoresUnknownDeparture(t *testing.T) {
+	x := NewDeviceIndex(nil)
+	goodbye := observation("10.0.0.10", "", "", "mdns", time.Unix(1000, 0))
+	goodbye.DepartedAt = goodbye.LastSeen
+	if dev := x.Upsert(goodbye); dev != nil || x.Len() != 0 {
+		t.Errorf("expected the goodbye of an unknown device to be ignored")
+	}
+
+	withdrawal := &Device{IP: net.ParseIP("10.0.0.10"), RemovedServices: []ServiceInstance{{Instance: "Printer", Type: "ipp", Protocol: "tcp"}}}
+	if dev := x.Upsert(withdrawal); dev != nil || x.Len() != 0 {
+		t.Errorf("expected the service goodbye of an unknown device to be ignored")
+	}
+}
+
+func TestDeviceIndexHoldsEnrichmentOfUnknownDevice(t *testing.T) {
+	x := NewDeviceIndex(nil)
+	t0 := time.Unix(1000, 0)
+	name := &Device{IP: net.ParseIP("10.0.0.10"), Sources: map[string]struct{}{"rdns": {}}, ReverseDNS: "nas.lan", EnrichOnly: true}
+	if dev := x.Upsert(name); dev != nil || x.Len() != 0 {
+		t.Fatalf("expected the enrichment of an unknown device to be held back")
+	}
+
+	dev := x.Upsert(observation("10.0.0.10", "aa:bb:cc:dd:ee:01", "", "arp", t0))
+	if dev.ReverseDNS != "nas.lan" {
+		t.Errorf("expected the held name to be merged once the device is seen, got %q", dev.ReverseDNS)
+	}
+	if _, ok := dev.Sources["rdns"]; !ok {
+		t.Errorf("expected rdns source, got %v", dev.Sources)
+	}
+
+	x.Upsert(name)
+	if !dev.LastSeen.Equal(t0) || !dev.FirstSeen.Equal(t0) {
+		t.Errorf("expected an enrichment to leave first and last seen alone, got %v and %v", dev.FirstSeen, dev.LastSeen)
+	}
+}
+
+func TestDeviceIndexBoundsHeldEnrichments(t *testing.T) {
+	x := NewDeviceIndex(nil)
+	now := time.Unix(1000, 0)
+	x.now = func() time.Time { return now }
+	enrich := func(ip, name string) *Device {
+		return &Device{IP: net.ParseIP(ip), Sources: map[string]struct{}{"rdns": {}}, ReverseDNS: name, EnrichOnly: true}
+	}
+
+	x.Upsert(enrich("10.0.0.10", "old.lan"))
+	x.Upsert(enrich("10.0.0.10", "nas.lan"))
+	if len(x.held) != 1 {
+		t.Fatalf("expected one held enrichment per address, got %d", len(x.held))
+	}
+	dev := x.Upsert(observation("10.0.0.10", "", "", "arp", now))
+	if dev.ReverseDNS != "nas.lan" {
+		t.Errorf("expected the latest enrichment to be merged, got %q", dev.ReverseDNS)
+	}
+
+	x.Upsert(enrich("10.0.0.11", "tv.lan"))
+	now = now.Add(heldEnrichmentTTL + time.Second)
+	x.Upsert(enrich("10.0.0.12", "phone.lan"))
+	if _, ok := x.held["ip:10.0.0.11"]; ok || len(x.held) != 1 {
+		t.Errorf("expected expired enrichments to be dropped, got %v", x.held)
+	}
+	now = now.Add(heldEnrichmentTTL + time.Second)
+	if dev := x.Upsert(observation("10.0.0.12", "", "", "arp", now)); dev.ReverseDNS != "" {
+		t.Errorf("expected an expired enrichment not to be merged, got %q", dev.ReverseDNS)
+	}
+}
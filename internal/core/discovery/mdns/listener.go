@@ -0,0 +1,223 @@
+package mdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"go.uber.org/zap"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxPendingObservations bounds the observations queued between scans, the oldest are dropped.
+const maxPendingObservations = 1024
+
+// Listener receives the mDNS traffic on port 5353 of one interface for as long as it runs.
+// Unlike a scan session it also sees unsolicited announcements, answers to queries of other
+// hosts and goodbye packets (TTL 0, RFC 6762 section 10.1). Observations are queued until a
+// scan streams them to the engine, so nothing announced between scan cycles is lost.
+type Listener struct {
+	iface *discovery.InterfaceInfo
+	log   *zap.Logger
+
+	backlog *discovery.Backlog
+
+	mu       sync.Mutex
+	started  bool
+	closed   bool
+	conns    []*discovery.MulticastConn
+	services map[string]map[string]struct{} // sender IP -> keys of the service instances it announced
+	wg       sync.WaitGroup
+}
+
+func NewListener(iface *discovery.InterfaceInfo) *Listener {
+	return &Listener{
+		iface:    iface,
+		log:      zap.L().Named("mdns").With(zap.String("component", "listener")),
+		backlog:  discovery.NewBacklog(maxPendingObservations),
+		services: map[string]map[string]struct{}{},
+	}
+}
+
+// Start binds the mDNS port for every address family of the interface and starts reading.
+// It is a no-op when the listener is already running.
+func (l *Listener) Start() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return net.ErrClosed
+	}
+	if l.started {
+		return nil
+	}
+	if l.iface == nil || l.iface.Interface == nil {
+		return errors.New("no network interface")
+	}
+	l.log = l.log.With(zap.String("interface", l.iface.Interface.Name))
+
+	var errs []error
+	for _, family := range l.iface.Families() {
+		group := &net.UDPAddr{IP: net.ParseIP(mdnsMulticastAddress), Port: mdnsPort}
+		if family == discovery.IPv6 {
+			group = &net.UDPAddr{IP: net.ParseIP(mdnsMulticastAddressV6), Port: mdnsPort}
+		}
+		pc, err := discovery.ListenMulticast(l.iface, family, group)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", family, err))
+			continue
+		}
+		l.conns = append(l.conns, pc)
+		l.wg.Add(1)
+		go l.read(pc, family)
+	}
+	if len(l.conns) == 0 {
+		if len(errs) == 0 {
+			return errors.New("interface has no usable address")
+		}
+		return errors.Join(errs...)
+	}
+	for _, err := range errs {
+		l.log.Warn("listening on one address family failed", zap.Error(err))
+	}
+	l.started = true
+	return nil
+}
+
+// Close stops reading and releases the sockets. A closed listener cannot be started again.
+func (l *Listener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	conns := l.conns
+	l.conns = nil
+	l.mu.Unlock()
+
+	var errs []error
+	for _, c := range conns {
+		errs = append(errs, c.Close())
+	}
+	l.wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Stream sends the queued observations and every new one to out until ctx is done.
+func (l *Listener) Stream(ctx context.Context, out chan<- discovery.Device) error {
+	return l.backlog.Stream(ctx, out)
+}
+
+func (l *Listener) read(pc *discovery.MulticastConn, family discovery.IPFamily) {
+	defer l.wg.Done()
+	buf := make([]byte, maxBufferSize)
+	for {
+		n, src, err := pc.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			l.log.Debug("read failed", zap.Stringer("family", family), zap.Error(err))
+			continue
+		}
+		msg, err := parseDNSMessage(buf[:n])
+		if err != nil || !msg.Response {
+			continue
+		}
+		l.handle(msg, src, time.Now())
+	}
+}
+
+// handle turns one mDNS response into observations. Records with a TTL become a device,
+// goodbye records withdraw services (PTR) or the whole device (A/AAAA). A device that
+// withdrew every service it announced is considered departed as well.
+func (l *Listener) handle(msg *dnsmessage.Message, sender net.IP, now time.Time) {
+	var live []dnsmessage.Resource
+	var announced, removed []discovery.ServiceInstance
+	var instance string
+	departed := false
+
+	records := append(append([]dnsmessage.Resource(nil), msg.Answers...), msg.Additionals...)
+	for _, r := range records {
+		name := r.Header.Name.String()
+		ptr, isPTR := r.Body.(*dnsmessage.PTRResource)
+		if isPTR && name == serviceDiscoveryQuery {
+			continue
+		}
+		if r.Header.TTL > 0 {
+			if !isPTR {
+				live = append(live, r)
+				continue
+			}
+			if service, ok := serviceInstance(ptr.PTR.String(), now); ok {
+				announced = append(announced, service)
+			}
+			if instance == "" {
+				instance = cleanDisplayName(ptr.PTR.String())
+			}
+			continue
+		}
+		switch r.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if service, ok := serviceInstance(ptr.PTR.String(), now); ok {
+				removed = append(removed, service)
+			}
+		case *dnsmessage.AResource, *dnsmessage.AAAAResource:
+			departed = true
+		}
+	}
+
+	device, ok := deviceFromRecords(live, sender)
+	if !ok && len(announced) > 0 {
+		device, ok = discovery.NewDevice(sender), true
+		device.Sources["mdns"] = struct{}{}
+	}
+	if ok {
+		for _, service := range announced {
+			device.AddService(service)
+		}
+		if device.DisplayName == "" {
+			device.DisplayName = instance
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := sender.String()
+	services := l.services[key]
+	if services == nil {
+		services = map[string]struct{}{}
+		l.services[key] = services
+	}
+	for _, service := range announced {
+		services[service.Key()] = struct{}{}
+	}
+	hadServices := len(services) > 0
+	for _, service := range removed {
+		delete(services, service.Key())
+	}
+	if hadServices && len(services) == 0 && len(removed) > 0 {
+		departed = true
+	}
+	if departed {
+		delete(l.services, key)
+	}
+
+	if ok {
+		l.backlog.Add(device)
+	}
+	if len(removed) > 0 || departed {
+		// not built with NewDevice, a goodbye must not refresh LastSeen or the address
+		goodbye := discovery.Device{IP: sender, Sources: map[string]struct{}{"mdns": {}}, RemovedServices: removed}
+		if departed {
+			goodbye.DepartedAt = now
+		}
+		l.log.Debug("goodbye", zap.Stringer("ip", sender), zap.Int("services", len(removed)), zap.Bool("departed", departed))
+		l.backlog.Add(goodbye)
+	}
+}
@@ -0,0 +1,166 @@
+package mdns
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// observations returns the queued observations of the listener.
+func observations(l *Listener) []discovery.Device {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	out := make(chan discovery.Device, 16)
+	_ = l.Stream(ctx, out)
+	close(out)
+	var devices []discovery.Device
+	for d := range out {
+		devices = append(devices, d)
+	}
+	return devices
+}
+
+var printerIP = net.ParseIP("192.168.1.20")
+
+func resource(name string, ttl uint32, body dnsmessage.ResourceBody) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   body,
+	}
+}
+
+// announcement is an unsolicited response of a printer announcing ipp and http.
+func announcement(ttl uint32) *dnsmessage.Message {
+	return &dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true, Authoritative: true},
+		Answers: []dnsmessage.Resource{
+			resource("_ipp._tcp.local.", ttl, &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("Printer._ipp._tcp.local.")}),
+			resource("_http._tcp.local.", ttl, &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("Printer._http._tcp.local.")}),
+			resource("Printer._ipp._tcp.local.", ttl, &dnsmessage.SRVResource{Target: dnsmessage.MustNewName("printer.local."), Port: 631}),
+			resource("printer.local.", ttl, &dnsmessage.AResource{A: [4]byte{192, 168, 1, 20}}),
+		},
+	}
+}
+
+func serviceGoodbye(service string) *dnsmessage.Message {
+	return &dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true, Authoritative: true},
+		Answers: []dnsmessage.Resource{
+			resource("_"+service+"._tcp.local.", 0, &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("Printer._" + service + "._tcp.local.")}),
+		},
+	}
+}
+
+func TestListenerAnnouncement(t *testing.T) {
+	l := NewListener(nil)
+	l.handle(announcement(120), printerIP, time.Now())
+
+	pending := observations(l)
+	if len(pending) != 1 {
+		t.Fatalf("expected 1 observation, got %d", len(pending))
+	}
+	d := pending[0]
+	if d.Hostname != "printer.local" || !d.IP.Equal(printerIP) {
+		t.Errorf("unexpected device %+v", d)
+	}
+	ipp, ok := d.Service("ipp")
+	if !ok || ipp.Instance != "Printer" || ipp.Protocol != "tcp" || ipp.Port != 631 || ipp.Target != "printer.local" {
+		t.Errorf("expected ipp instance with the SRV port and target, got %+v", d.Services)
+	}
+	if _, ok := d.Service("http"); !ok || len(d.Services) != 2 {
+		t.Errorf("expected ipp and http instances, got %+v", d.Services)
+	}
+	if d.Departed() {
+		t.Errorf("announcement must not mark the device departed")
+	}
+}
+
+func TestListenerServiceGoodbye(t *testing.T) {
+	l := NewListener(nil)
+	l.handle(announcement(120), printerIP, time.Now())
+	observations(l)
+
+	l.handle(serviceGoodbye("ipp"), printerIP, time.Now())
+	pending := observations(l)
+	if len(pending) != 1 {
+		t.Fatalf("expected 1 observation, got %d", len(pending))
+	}
+	if got := pending[0].RemovedServices; len(got) != 1 || got[0].Type != "ipp" || got[0].Instance != "Printer" {
+		t.Errorf("expected ipp to be removed, got %v", got)
+	}
+	if pending[0].Departed() {
+		t.Errorf("device still announces http, it has not departed")
+	}
+
+	now := time.Now()
+	l.handle(serviceGoodbye("http"), printerIP, now)
+	pending = observations(l)
+	if len(pending) != 1 || !pending[0].DepartedAt.Equal(now) {
+		t.Fatalf("expected the device to depart once every service is withdrawn, got %+v", pending)
+	}
+}
+
+func TestListenerHostGoodbye(t *testing.T) {
+	l := NewListener(nil)
+	now := time.Now()
+	l.handle(announcement(0), printerIP, now)
+
+	pending := observations(l)
+	if len(pending) != 1 {
+		t.Fatalf("expected only a goodbye observation, got %d", len(pending))
+	}
+	d := pending[0]
+	if !d.DepartedAt.Equal(now) {
+		t.Errorf("expected device to be departed")
+	}
+	if len(d.RemovedServices) != 2 {
+		t.Errorf("expected ipp and http to be removed, got %v", d.RemovedServices)
+	}
+}
+
+func TestListenerGoodbyeKeepsLastSeen(t *testing.T) {
+	l := NewListener(nil)
+	l.handle(announcement(120), printerIP, time.Now())
+	index := discovery.NewDeviceIndex(nil)
+	var seen time.Time
+	var addresses []discovery.AddressRecord
+	for _, d := range observations(l) {
+		dev := index.Upsert(&d)
+		seen, addresses = dev.LastSeen, dev.Addresses
+	}
+	time.Sleep(time.Millisecond)
+
+	l.handle(announcement(0), printerIP, time.Now())
+	pending := observations(l)
+	if len(pending) != 1 || !pending[0].LastSeen.IsZero() || !pending[0].FirstSeen.IsZero() {
+		t.Fatalf("expected a goodbye without sighting times, got %+v", pending)
+	}
+	d := index.Upsert(&pending[0])
+	if d == nil || !d.Departed() {
+		t.Fatalf("expected the device to depart, got %+v", d)
+	}
+	if !d.LastSeen.Equal(seen) {
+		t.Errorf("expected LastSeen %v to be kept, got %v", seen, d.LastSeen)
+	}
+	if !reflect.DeepEqual(d.Addresses, addresses) {
+		t.Errorf("expected the address record to be unchanged, got %+v", d.Addresses)
+	}
+}
+
+func TestScannerModes(t *testing.T) {
+	if s := NewScanner(nil, ""); s.mode != config.ScanModeActive || s.listener != nil {
+		t.Errorf("expected active mode without listener by default")
+	}
+	if s := NewScanner(nil, config.ScanModePassive); s.listener == nil {
+		t.Errorf("expected a listener in passive mode")
+	}
+	if err := NewScanner(nil, config.ScanModeBoth).Close(); err != nil {
+		t.Errorf("unexpected error closing an idle scanner: %v", err)
+	}
+}
@@ -4,43 +4,102 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"strings"
 	"time"
 
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
 	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
 	"go.uber.org/zap"
 	"golang.org/x/net/dns/dnsmessage"
 	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
-var _ discovery.Scanner = (*Scanner)(nil)
+var (
+	_ discovery.InterfaceScanner = (*Scanner)(nil)
+	_ io.Closer                  = (*Scanner)(nil)
+)
 
 const (
-	serviceDiscoveryQuery = "_services._dns-sd._udp.local."
-	mdnsMulticastAddress  = "224.0.0.251"
-	mdnsPort              = 5353
-	maxBufferSize         = 16384
+	serviceDiscoveryQuery  = "_services._dns-sd._udp.local."
+	mdnsMulticastAddress   = "224.0.0.251"
+	mdnsMulticastAddressV6 = "ff02::fb"
+	mdnsPort               = 5353
+	maxBufferSize          = 16384
+	// resolveTick is how often a scan session checks for instances whose resolution timed out.
+	resolveTick = 100 * time.Millisecond
 )
 
 type Scanner struct {
-	iface *discovery.InterfaceInfo
+	iface    *discovery.InterfaceInfo
+	mode     config.ScanMode
+	listener *Listener // nil in active mode
 }
 
-func NewScanner(iface *discovery.InterfaceInfo) *Scanner {
-	return &Scanner{iface: iface}
+// NewScanner returns an mDNS scanner for iface, an empty mode means config.ScanModeActive.
+func NewScanner(iface *discovery.InterfaceInfo, mode config.ScanMode) *Scanner {
+	if mode == "" {
+		mode = config.ScanModeActive
+	}
+	s := &Scanner{iface: iface, mode: mode}
+	if mode.Listens() {
+		s.listener = NewListener(iface)
+	}
+	return s
 }
 
 func (s *Scanner) Name() string {
 	return "mdns"
 }
 
+// Interface returns the network interface the scanner is bound to.
+func (s *Scanner) Interface() *discovery.InterfaceInfo { return s.iface }
+
+// Scan queries and/or listens depending on the mode of the scanner. In passive mode the
+// listener is started on the first scan and keeps running until Close.
 func (s *Scanner) Scan(ctx context.Context, out chan<- discovery.Device) error {
-	session := &scanSession{
-		log:   zap.L().Named("mdns"),
-		iface: s.iface,
+	if s.listener == nil {
+		return s.query(ctx, out)
+	}
+	if err := s.listener.Start(); err != nil {
+		return fmt.Errorf("start listener: %w", err)
+	}
+	if !s.mode.Queries() {
+		return s.listener.Stream(ctx, out)
 	}
-	return session.run(ctx, out)
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- s.listener.Stream(ctx, out)
+	}()
+	err := s.query(ctx, out)
+	if lerr := <-listenErr; err == nil {
+		err = lerr
+	}
+	return err
+}
+
+// Close stops the passive listener, if any.
+func (s *Scanner) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// query sends a service discovery query over IPv4 (224.0.0.251) and IPv6 (ff02::fb)
+// concurrently, depending on the addresses of the interface, and reads the responses.
+func (s *Scanner) query(ctx context.Context, out chan<- discovery.Device) error {
+	return discovery.ScanFamilies(s.iface, s.Name(), func(family discovery.IPFamily) error {
+		session := &scanSession{
+			log:    zap.L().Named("mdns").With(zap.Stringer("family", family)),
+			iface:  s.iface,
+			family: family,
+		}
+		return session.run(ctx, out)
+	})
 }
 
 // scanSession manages state for one mDNS scan
@@ -49,24 +108,34 @@ type scanSession struct {
 	conn                *net.UDPConn
 	multicastAddr       *net.UDPAddr
 	iface               *discovery.InterfaceInfo
+	family              discovery.IPFamily
 	queriedServiceTypes map[string]bool
-	reportedDevices     map[string]bool
+	resolver            *resolver
 }
 
 func (ss *scanSession) setupConnection() error {
-	addr, err := net.ResolveUDPAddr("udp4",
-		fmt.Sprintf("%s:%d", mdnsMulticastAddress, mdnsPort))
-	if err != nil {
-		return fmt.Errorf("resolve multicast address: %w", err)
+	network := "udp4"
+	addr := &net.UDPAddr{IP: net.ParseIP(mdnsMulticastAddress), Port: mdnsPort}
+	if ss.family == discovery.IPv6 {
+		network = "udp6"
+		addr = &net.UDPAddr{IP: net.ParseIP(mdnsMulticastAddressV6), Port: mdnsPort, Zone: ss.iface.Interface.Name}
 	}
 
-	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: *ss.iface.IPv4Addr, Port: 0})
+	conn, err := net.ListenUDP(network, ss.iface.LocalUDPAddr(ss.family))
 	if err != nil {
 		return fmt.Errorf("create UDP socket: %w", err)
 	}
 
-	p := ipv4.NewPacketConn(conn)
-	if err := p.JoinGroup(ss.iface.Interface, addr); err != nil {
+	if ss.family == discovery.IPv6 {
+		p := ipv6.NewPacketConn(conn)
+		err = p.JoinGroup(ss.iface.Interface, addr)
+		if err == nil {
+			err = p.SetMulticastInterface(ss.iface.Interface)
+		}
+	} else {
+		err = ipv4.NewPacketConn(conn).JoinGroup(ss.iface.Interface, addr)
+	}
+	if err != nil {
 		_ = conn.Close()
 		return fmt.Errorf("join multicast group: %w", err)
 	}
@@ -76,23 +145,26 @@ func (ss *scanSession) setupConnection() error {
 	return nil
 }
 
+// queryService asks for the instances of a service type, or for the service types
+// themselves with the DNS-SD meta query.
 func (ss *scanSession) queryService(serviceName string) error {
-	msg := dnsmessage.Message{
-		Header: dnsmessage.Header{ID: 0, RecursionDesired: false},
-		Questions: []dnsmessage.Question{{
-			Name:  dnsmessage.MustNewName(serviceName),
-			Type:  dnsmessage.TypePTR,
-			Class: dnsmessage.ClassINET,
-		}},
-	}
+	return ss.send([]question{{serviceName, dnsmessage.TypePTR}})
+}
 
-	packet, err := msg.Pack()
-	if err != nil {
-		return fmt.Errorf("pack DNS query: %w", err)
+// send multicasts the questions, at most maxQuestions per packet.
+func (ss *scanSession) send(questions []question) error {
+	for len(questions) > 0 {
+		n := min(len(questions), maxQuestions)
+		packet, err := buildQuery(questions[:n])
+		if err != nil {
+			return fmt.Errorf("pack DNS query: %w", err)
+		}
+		if _, err := ss.conn.WriteToUDP(packet, ss.multicastAddr); err != nil {
+			return err
+		}
+		questions = questions[n:]
 	}
-
-	_, err = ss.conn.WriteToUDP(packet, ss.multicastAddr)
-	return err
+	return nil
 }
 
 func (ss *scanSession) run(ctx context.Context, out chan<- discovery.Device) error {
@@ -104,7 +176,7 @@ func (ss *scanSession) run(ctx context.Context, out chan<- discovery.Device) err
 	}()
 
 	ss.queriedServiceTypes = make(map[string]bool)
-	ss.reportedDevices = make(map[string]bool)
+	ss.resolver = newResolver()
 
 	// Sends the initial service discovery query (multicast)
 	// See https://datatracker.ietf.org/doc/html/rfc6763#section-9
@@ -121,27 +193,37 @@ func (ss *scanSession) listenForResponses(ctx context.Context, out chan<- discov
 	if !ok {
 		return fmt.Errorf("context has no deadline")
 	}
-	timeout := time.Until(deadline)
-	if err := ss.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
-		return fmt.Errorf("set read deadline: %w", err)
-	}
 
 	buffer := make([]byte, maxBufferSize)
 
 	for {
 		select {
 		case <-ctx.Done():
+			ss.flushPending(out)
 			return ctx.Err()
 		default:
-			shouldExit, err := ss.readAndProcessPacket(buffer, out)
-			if err != nil || shouldExit {
-				return err
-			}
+		}
+		// wake up regularly to report instances whose resolution timed out
+		readDeadline := time.Now().Add(resolveTick)
+		if deadline.Before(readDeadline) {
+			readDeadline = deadline
+		}
+		if err := ss.conn.SetReadDeadline(readDeadline); err != nil {
+			return fmt.Errorf("set read deadline: %w", err)
+		}
+		timedOut, err := ss.readAndProcessPacket(buffer, out)
+		if err != nil {
+			return err
+		}
+		ss.flush(time.Now(), out)
+		if timedOut && !time.Now().Before(deadline) {
+			ss.flushPending(out)
+			return nil
 		}
 	}
 }
 
-// readAndProcessPacket handles ONE complete mDNS response packet
+// readAndProcessPacket handles ONE mDNS response packet, it returns true when the read timed out
 func (ss *scanSession) readAndProcessPacket(buffer []byte, out chan<- discovery.Device) (bool, error) {
 	packetSize, sender, err := ss.conn.ReadFromUDP(buffer)
 	if err != nil {
@@ -165,28 +247,42 @@ func (ss *scanSession) readAndProcessPacket(buffer []byte, out chan<- discovery.
 	return false, nil
 }
 
-// processDNSResponse handles all records in one DNS message
+// processDNSResponse caches the records of one DNS message and asks for the records
+// that are still missing to resolve the announced instances. A response may be
+// truncated or spread over several packets, the follow-up questions cover both.
 func (ss *scanSession) processDNSResponse(msg *dnsmessage.Message, sender *net.UDPAddr, out chan<- discovery.Device) {
-	for _, answer := range msg.Answers {
-		if ptr, ok := answer.Body.(*dnsmessage.PTRResource); ok {
-			serviceName := answer.Header.Name.String()
-			ptrValue := ptr.PTR.String()
-
-			ss.log.Debug("Got PTR record",
-				zap.String("question", serviceName),
-				zap.String("points_to", ptrValue))
-
-			if serviceName == serviceDiscoveryQuery {
-				// This is a service type announcement (e.g., "_http._tcp.local")
-				ss.handleDiscoveredServiceType(ptrValue)
-			} else {
-				// This is a device announcement (e.g., "My Device._http._tcp.local")
-				ss.handleDiscoveredDevice(&answer, ptrValue, sender, out)
-			}
+	records := append(append([]dnsmessage.Resource(nil), msg.Answers...), msg.Additionals...)
+	for _, record := range records {
+		if ptr, ok := record.Body.(*dnsmessage.PTRResource); ok && record.Header.Name.String() == serviceDiscoveryQuery {
+			// This is a service type announcement (e.g., "_http._tcp.local")
+			ss.handleDiscoveredServiceType(ptr.PTR.String())
+		}
+	}
+	if msg.Truncated {
+		ss.log.Debug("Truncated response", zap.Stringer("sender", sender.IP))
+	}
+
+	if questions := ss.resolver.add(records, sender.IP, time.Now()); len(questions) > 0 {
+		if err := ss.send(questions); err != nil {
+			ss.log.Debug("Failed to send follow-up query", zap.Int("questions", len(questions)), zap.Error(err))
 		}
 	}
+	ss.flush(time.Now(), out)
+}
+
+// flush reports the devices of the instances that are resolved or timed out.
+func (ss *scanSession) flush(now time.Time, out chan<- discovery.Device) {
+	for _, device := range ss.resolver.ready(now) {
+		out <- device
+	}
+}
 
-	ss.extractDeviceDetails(msg.Additionals, sender, out)
+// flushPending reports every instance that was not reported yet with what is known, the
+// scan ends before their resolveTimeout passed.
+func (ss *scanSession) flushPending(out chan<- discovery.Device) {
+	for _, device := range ss.resolver.pending() {
+		out <- device
+	}
 }
 
 func (ss *scanSession) handleDiscoveredServiceType(serviceType string) {
@@ -204,107 +300,141 @@ func (ss *scanSession) handleDiscoveredServiceType(serviceType string) {
 	}
 }
 
-func (ss *scanSession) handleDiscoveredDevice(
-	answer *dnsmessage.Resource,
-	ptrValue string,
-	sender *net.UDPAddr,
-	out chan<- discovery.Device,
-) {
-	deviceID := fmt.Sprintf("%s-%s", sender.IP.String(), ptrValue)
-
-	if ss.reportedDevices[deviceID] {
-		return
-	}
-
-	now := time.Now()
-	device := &discovery.Device{
-		IP:          sender.IP,
-		DisplayName: cleanDisplayName(ptrValue),
-		Services:    make(map[string]int),
-		Sources:     map[string]struct{}{"mdns": {}},
-		ExtraData:   make(map[string]string),
-		FirstSeen:   now,
-		LastSeen:    now,
-	}
-
-	if service := extractServiceName(answer.Header.Name.String()); service != "" {
-		device.Services[service] = 0
-	}
-
-	out <- *device
-	ss.reportedDevices[deviceID] = true
-}
-
-func (ss *scanSession) extractDeviceDetails(
-	records []dnsmessage.Resource,
-	sender *net.UDPAddr,
-	out chan<- discovery.Device,
-) {
+// deviceFromRecords builds a device from the SRV, TXT, A and AAAA records of a response.
+// The device gets the address of the announced host, the sender is only used when the
+// records carry none. It returns false when the records carry no services and no name.
+func deviceFromRecords(records []dnsmessage.Resource, sender net.IP) (discovery.Device, bool) {
 	if len(records) == 0 {
-		return
+		return discovery.Device{}, false
 	}
 
-	device := discovery.NewDevice(sender.IP)
+	device := discovery.NewDevice(nil)
 	device.Sources["mdns"] = struct{}{}
 
+	var hostAddrs []hostAddress
 	for _, record := range records {
 		switch r := record.Body.(type) {
 		case *dnsmessage.SRVResource:
+			device.Hostname = strings.TrimSuffix(r.Target.String(), ".")
 			device.DisplayName = cleanDisplayName(r.Target.String())
-			if service := extractServiceNameFromTarget(r.Target.String()); service != "" {
-				device.Services[service] = int(r.Port)
+			if service, ok := serviceInstance(record.Header.Name.String(), device.LastSeen); ok {
+				service.Port = int(r.Port)
+				service.Target = device.Hostname
+				device.AddService(service)
 			}
 		case *dnsmessage.TXTResource:
-			ss.parseTXTRecords(r, &device)
+			parseTXTRecords(record.Header.Name.String(), r, &device)
+		case *dnsmessage.AResource:
+			hostAddrs = append(hostAddrs, hostAddress{name: record.Header.Name.String(), ip: net.IP(r.A[:])})
+		case *dnsmessage.AAAAResource:
+			hostAddrs = append(hostAddrs, hostAddress{name: record.Header.Name.String(), ip: net.IP(r.AAAA[:])})
 		}
 	}
+	addHostAddresses(&device, hostAddrs)
 
-	if len(device.Services) > 0 || device.DisplayName != "" {
-		out <- device
+	device.IP = primaryAddress(device.Addresses, sender)
+	if !device.HasAddress(device.IP) && device.IP != nil {
+		device.Addresses = append([]discovery.AddressRecord{{IP: device.IP, FirstSeen: device.FirstSeen, LastSeen: device.LastSeen, Current: true}}, device.Addresses...)
 	}
+
+	return device, len(device.Services) > 0 || device.DisplayName != ""
+}
+
+// primaryAddress picks the address a device is reported at: the sender when the host
+// announced it, otherwise the first IPv4 and then IPv6 address of the host, the sender
+// when the host has none.
+func primaryAddress(addrs []discovery.AddressRecord, sender net.IP) net.IP {
+	var v4, v6 net.IP
+	for _, a := range addrs {
+		switch {
+		case a.IP.Equal(sender):
+			return sender
+		case a.IP.To4() != nil && v4 == nil:
+			v4 = a.IP
+		case a.IP.To4() == nil && v6 == nil:
+			v6 = a.IP
+		}
+	}
+	switch {
+	case v4 != nil:
+		return v4
+	case v6 != nil:
+		return v6
+	}
+	return sender
+}
+
+// hostAddress is an A or AAAA record found in the additional section.
+type hostAddress struct {
+	name string
+	ip   net.IP
 }
 
-// parseTXTRecords extracts device details from TXT records
+// addHostAddresses attaches the A/AAAA records of the announced host to the device,
+// so its IPv4 and IPv6 addresses end up on the same Device.
+func addHostAddresses(device *discovery.Device, addrs []hostAddress) {
+	now := time.Now()
+	for _, a := range addrs {
+		if device.Hostname != "" && !strings.EqualFold(strings.TrimSuffix(a.name, "."), device.Hostname) {
+			continue
+		}
+		if a.ip.IsUnspecified() || device.HasAddress(a.ip) {
+			continue
+		}
+		device.Addresses = append(device.Addresses, discovery.AddressRecord{IP: a.ip, FirstSeen: now, LastSeen: now, Current: true})
+	}
+}
+
+// parseTXTRecords extracts device details from the TXT records of name
 // see https://datatracker.ietf.org/doc/html/rfc6763#section-6.3
-// it implements common keys used by various devices
-func (ss *scanSession) parseTXTRecords(txt *dnsmessage.TXTResource, device *discovery.Device) {
+// it implements common keys used by various devices, all pairs are kept on the
+// service instance the records belong to (ExtraData for names that are no instance)
+func parseTXTRecords(name string, txt *dnsmessage.TXTResource, device *discovery.Device) {
+	pairs := make(map[string]string, len(txt.TXT))
 	for _, text := range txt.TXT {
 		// Split key=value
 		if idx := strings.IndexByte(text, '='); idx > 0 {
 			key := strings.ToLower(text[:idx])
 			value := text[idx+1:]
+			pairs[key] = value
 
 			switch key {
 			case "manufacturer":
 				device.Manufacturer = value
 			case "mac":
 				device.MAC = value
-			// todo(ramon): think about device merge strategy, often `md` is a better display name, however at this point often other scanners have already set a name
+			// `md` is often a better display name than what other scanners report,
+			// the display name merge strategy decides which one wins
 			case "md":
 				device.DisplayName = value
-			default:
-				if device.ExtraData == nil {
-					device.ExtraData = make(map[string]string)
-				}
-				device.ExtraData[key] = value
 			}
-		} else {
-			if device.ExtraData == nil {
-				device.ExtraData = make(map[string]string)
-			}
-			device.ExtraData[text] = "true"
+		} else if text != "" {
+			pairs[strings.ToLower(text)] = "true"
+		}
+	}
+	if len(pairs) == 0 {
+		return
+	}
+
+	if service, ok := serviceInstance(name, device.LastSeen); ok {
+		service.TXT = pairs
+		device.AddService(service)
+		return
+	}
+	if device.ExtraData == nil {
+		device.ExtraData = make(map[string]string)
+	}
+	for key, value := range pairs {
+		switch key {
+		case "manufacturer", "mac", "md":
+		default:
+			device.ExtraData[key] = value
 		}
 	}
 }
 
 // utils
 // todo(ramon): after multiple scanner implementations look for overlap and move to common package
-func parseDNSMessage(data []byte) (*dnsmessage.Message, error) {
-	var msg dnsmessage.Message
-	err := msg.Unpack(data)
-	return &msg, err
-}
-
 func isTimeout(err error) bool {
 	var netErr net.Error
 	return errors.As(err, &netErr) && netErr.Timeout()
@@ -315,18 +445,39 @@ func cleanDisplayName(name string) string {
 	return strings.TrimSuffix(name, ".")
 }
 
-func extractServiceName(dnsName string) string {
-	parts := strings.Split(dnsName, ".")
-	if len(parts) == 0 {
-		return ""
+// parseServiceName splits a DNS-SD name into its instance, service type and protocol,
+// e.g. "Printer._ipp._tcp.local." or "_ipp._tcp.local." (no instance).
+// see https://datatracker.ietf.org/doc/html/rfc6763#section-4.1
+func parseServiceName(name string) (instance, service, protocol string, ok bool) {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	for i := len(labels) - 1; i > 0; i-- {
+		if labels[i] != "_tcp" && labels[i] != "_udp" {
+			continue
+		}
+		if !strings.HasPrefix(labels[i-1], "_") || len(labels[i-1]) < 2 {
+			return "", "", "", false
+		}
+		instance = strings.Join(labels[:i-1], ".")
+		if instance == "_sub" || strings.HasSuffix(instance, "._sub") {
+			// subtype PTR name, e.g. "_printer._sub._http._tcp.local."
+			instance = ""
+		}
+		return instance, labels[i-1][1:], labels[i][1:], true
 	}
-	return strings.TrimPrefix(parts[0], "_")
+	return "", "", "", false
 }
 
-func extractServiceNameFromTarget(target string) string {
-	parts := strings.Split(target, ".")
-	if len(parts) < 2 {
-		return ""
+// serviceInstance returns the service instance named by a DNS-SD name, seen at now.
+func serviceInstance(name string, now time.Time) (discovery.ServiceInstance, bool) {
+	instance, service, protocol, ok := parseServiceName(name)
+	if !ok {
+		return discovery.ServiceInstance{}, false
 	}
-	return strings.TrimPrefix(parts[0], "_")
+	return discovery.ServiceInstance{
+		Instance: instance,
+		Type:     service,
+		Protocol: protocol,
+		Source:   "mdns",
+		LastSeen: now,
+	}, true
 }
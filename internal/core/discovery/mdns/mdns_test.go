@@ -1,22 +1,98 @@
 package mdns
 
 import (
+	"net"
 	"testing"
 
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
 	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"golang.org/x/net/dns/dnsmessage"
 )
 
 func TestNewScanner(t *testing.T) {
 	iface := &discovery.InterfaceInfo{}
-	scanner := NewScanner(iface)
+	scanner := NewScanner(iface, config.ScanModeActive)
 	if scanner.iface != iface {
 		t.Errorf("expected iface to be set")
 	}
 }
 
 func TestName(t *testing.T) {
-	scanner := NewScanner(nil)
+	scanner := NewScanner(nil, config.ScanModeActive)
 	if scanner.Name() != "mdns" {
 		t.Errorf("expected name mdns, got %s", scanner.Name())
 	}
 }
+
+func TestAddHostAddresses(t *testing.T) {
+	device := discovery.NewDevice(net.ParseIP("192.168.1.20"))
+	device.Hostname = "printer.local"
+
+	addHostAddresses(&device, []hostAddress{
+		{name: "printer.local.", ip: net.ParseIP("192.168.1.20")},
+		{name: "printer.local.", ip: net.ParseIP("fe80::20")},
+		{name: "printer.local.", ip: net.ParseIP("fe80::20")},
+		{name: "other.local.", ip: net.ParseIP("192.168.1.30")},
+	})
+
+	if len(device.Addresses) != 2 {
+		t.Fatalf("expected IPv4 and IPv6 address, got %+v", device.Addresses)
+	}
+	if !device.HasAddress(net.ParseIP("fe80::20")) {
+		t.Errorf("expected AAAA record to be added")
+	}
+}
+
+func TestParseServiceName(t *testing.T) {
+	tests := []struct {
+		name                        string
+		instance, service, protocol string
+		ok                          bool
+	}{
+		{"Printer._ipp._tcp.local.", "Printer", "ipp", "tcp", true},
+		{"Living Room.Speaker._raop._tcp.local.", "Living Room.Speaker", "raop", "tcp", true},
+		{"_http._tcp.local.", "", "http", "tcp", true},
+		{"_printer._sub._http._tcp.local.", "", "http", "tcp", true},
+		{"_sleep-proxy._udp.local.", "", "sleep-proxy", "udp", true},
+		{"printer.local.", "", "", "", false},
+		{"x._tcp.local.", "", "", "", false},
+	}
+	for _, tt := range tests {
+		instance, service, protocol, ok := parseServiceName(tt.name)
+		if instance != tt.instance || service != tt.service || protocol != tt.protocol || ok != tt.ok {
+			t.Errorf("parseServiceName(%q) = %q, %q, %q, %v", tt.name, instance, service, protocol, ok)
+		}
+	}
+}
+
+func TestDeviceFromRecordsKeepsInstancesApart(t *testing.T) {
+	records := []dnsmessage.Resource{
+		resource("Admin._http._tcp.local.", 120, &dnsmessage.SRVResource{Target: dnsmessage.MustNewName("nas.local."), Port: 5000}),
+		resource("Files._http._tcp.local.", 120, &dnsmessage.SRVResource{Target: dnsmessage.MustNewName("nas.local."), Port: 8080}),
+		resource("Files._http._tcp.local.", 120, &dnsmessage.TXTResource{TXT: []string{"path=/files", "md=DiskStation", "secure"}}),
+	}
+
+	device, ok := deviceFromRecords(records, net.ParseIP("192.168.1.40"))
+	if !ok {
+		t.Fatal("expected a device")
+	}
+	if len(device.Services) != 2 {
+		t.Fatalf("expected two http instances, got %+v", device.Services)
+	}
+	admin, files := device.Services[0], device.Services[1]
+	if admin.Instance != "Admin" || admin.Port != 5000 || len(admin.TXT) != 0 {
+		t.Errorf("unexpected admin instance %+v", admin)
+	}
+	if files.Instance != "Files" || files.Port != 8080 || files.Target != "nas.local" || files.Source != "mdns" {
+		t.Errorf("unexpected files instance %+v", files)
+	}
+	if files.TXT["path"] != "/files" || files.TXT["secure"] != "true" {
+		t.Errorf("expected TXT pairs on the instance, got %v", files.TXT)
+	}
+	if device.DisplayName != "DiskStation" {
+		t.Errorf("expected md to set the display name, got %q", device.DisplayName)
+	}
+	if _, ok := device.ExtraData["path"]; ok {
+		t.Errorf("TXT pairs of an instance must not end up in ExtraData")
+	}
+}
@@ -0,0 +1,36 @@
+package mdns
+
+import (
+	"fmt"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+// Config is the `scanners.mdns` config block.
+type Config struct {
+	config.ScannerToggle `yaml:",inline"`
+	// Mode is active (query every scan), passive (listen on 5353 across scans) or both.
+	Mode config.ScanMode `yaml:"mode"`
+}
+
+// Validate resets an unknown mode to active.
+func (c *Config) Validate() error { return c.Mode.Validate() }
+
+func init() {
+	discovery.Register(discovery.Registration{
+		Name:           "mdns",
+		Description:    "Multicast DNS service discovery (Bonjour/Avahi)",
+		DefaultEnabled: true,
+		NewConfig: func() config.ScannerSettings {
+			return &Config{Mode: config.ScanModeActive}
+		},
+		New: func(iface *discovery.InterfaceInfo, settings config.ScannerSettings) (discovery.Scanner, error) {
+			cfg, ok := settings.(*Config)
+			if !ok {
+				return nil, fmt.Errorf("unexpected config type %T", settings)
+			}
+			return NewScanner(iface, cfg.Mode), nil
+		},
+	})
+}
@@ -0,0 +1,280 @@
+package mdns
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// resolveTimeout is how long an instance may stay incomplete before it is reported
+	// with what is known, attributed to the host that announced it.
+	resolveTimeout = time.Second
+	// maxQuestions caps the questions sent in one follow-up query packet.
+	maxQuestions = 16
+	// classQU is the unicast-response bit of the question class (RFC 6762 section 5.4).
+	classQU = 1 << 15
+)
+
+// resolver caches the records of one scan session and resolves every announced service
+// instance PTR -> SRV -> TXT -> A/AAAA, asking follow-up questions for the records a
+// responder left out (RFC 6763 section 12). Records may be spread over several packets,
+// they are collected until the instance is complete or resolveTimeout passed.
+type resolver struct {
+	instances map[string]*instanceState // lower-cased instance name -> state
+	hosts     map[string][]hostAddress  // lower-cased host name -> A/AAAA records
+	asked     map[question]bool
+}
+
+type question struct {
+	name  string
+	qtype dnsmessage.Type
+}
+
+// instanceState is what is known about one service instance, e.g. "Printer._ipp._tcp.local.".
+type instanceState struct {
+	name    string
+	sender  net.IP // host that announced the instance, used when its SRV target does not resolve
+	seen    time.Time
+	srv     *dnsmessage.Resource
+	txt     *dnsmessage.Resource
+	emitted int // progress of the last reported device, -1 when not reported yet
+}
+
+func newResolver() *resolver {
+	return &resolver{
+		instances: map[string]*instanceState{},
+		hosts:     map[string][]hostAddress{},
+		asked:     map[question]bool{},
+	}
+}
+
+// add caches the records of one response and returns the follow-up questions to ask.
+func (r *resolver) add(records []dnsmessage.Resource, sender net.IP, now time.Time) []question {
+	for _, record := range records {
+		name := record.Header.Name.String()
+		switch b := record.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if name != serviceDiscoveryQuery {
+				r.instance(b.PTR.String(), sender, now)
+			}
+		case *dnsmessage.SRVResource:
+			if inst := r.instance(name, sender, now); inst != nil {
+				inst.srv = &record
+			}
+		case *dnsmessage.TXTResource:
+			if inst := r.instance(name, sender, now); inst != nil {
+				inst.txt = &record
+			}
+		case *dnsmessage.AResource:
+			r.addHost(name, net.IP(b.A[:]))
+		case *dnsmessage.AAAAResource:
+			r.addHost(name, net.IP(b.AAAA[:]))
+		}
+	}
+
+	var questions []question
+	ask := func(q question) {
+		if !r.asked[q] {
+			r.asked[q] = true
+			questions = append(questions, q)
+		}
+	}
+	for _, inst := range r.instances {
+		if inst.srv == nil {
+			ask(question{inst.name, dnsmessage.TypeSRV})
+		}
+		if inst.txt == nil {
+			ask(question{inst.name, dnsmessage.TypeTXT})
+		}
+		if target := inst.target(); target != "" && len(r.hosts[strings.ToLower(target)]) == 0 {
+			ask(question{target, dnsmessage.TypeA})
+			ask(question{target, dnsmessage.TypeAAAA})
+		}
+	}
+	return questions
+}
+
+// instance returns the state of a service instance, creating it when it is new.
+// It returns nil for names that are no service instance.
+func (r *resolver) instance(name string, sender net.IP, now time.Time) *instanceState {
+	if instance, _, _, ok := parseServiceName(name); !ok || instance == "" {
+		return nil
+	}
+	key := strings.ToLower(name)
+	inst, ok := r.instances[key]
+	if !ok {
+		inst = &instanceState{name: name, sender: sender, seen: now, emitted: -1}
+		r.instances[key] = inst
+	}
+	return inst
+}
+
+func (r *resolver) addHost(name string, ip net.IP) {
+	key := strings.ToLower(name)
+	for _, a := range r.hosts[key] {
+		if a.ip.Equal(ip) {
+			return
+		}
+	}
+	r.hosts[key] = append(r.hosts[key], hostAddress{name: name, ip: ip})
+}
+
+// target returns the host name of the SRV record, empty while it is unknown.
+func (inst *instanceState) target() string {
+	if inst.srv == nil {
+		return ""
+	}
+	return inst.srv.Body.(*dnsmessage.SRVResource).Target.String()
+}
+
+// progress counts the resolved steps of the instance, 3 means complete.
+func (r *resolver) progress(inst *instanceState) int {
+	n := 0
+	if inst.srv != nil {
+		n++
+		if len(r.hosts[strings.ToLower(inst.target())]) > 0 {
+			n++
+		}
+	}
+	if inst.txt != nil {
+		n++
+	}
+	return n
+}
+
+// ready returns the devices of instances that resolved, or timed out, since the last call.
+// An instance is reported again when later records complete it.
+func (r *resolver) ready(now time.Time) []discovery.Device {
+	return r.report(func(inst *instanceState, progress int) bool {
+		return progress == 3 || now.Sub(inst.seen) >= resolveTimeout
+	})
+}
+
+// pending returns the devices of every instance that progressed since it was last
+// reported, resolved or not, for the end of a scan.
+func (r *resolver) pending() []discovery.Device {
+	return r.report(func(*instanceState, int) bool { return true })
+}
+
+// report returns the devices of the instances that progressed since they were last
+// reported and are due.
+func (r *resolver) report(due func(inst *instanceState, progress int) bool) []discovery.Device {
+	var devices []discovery.Device
+	for _, inst := range r.instances {
+		progress := r.progress(inst)
+		if progress <= inst.emitted || !due(inst, progress) {
+			continue
+		}
+		inst.emitted = progress
+		devices = append(devices, r.device(inst))
+	}
+	return devices
+}
+
+// device builds the device of an instance from the cached records. The device is
+// attributed to the A/AAAA records of the SRV target, not to the host that sent them,
+// so records relayed by a sleep proxy end up on the sleeping device.
+func (r *resolver) device(inst *instanceState) discovery.Device {
+	var records []dnsmessage.Resource
+	if inst.srv != nil {
+		records = append(records, *inst.srv)
+		for _, a := range r.hosts[strings.ToLower(inst.target())] {
+			records = append(records, a.resource())
+		}
+	}
+	if inst.txt != nil {
+		records = append(records, *inst.txt)
+	}
+
+	device, ok := deviceFromRecords(records, inst.sender)
+	if !ok {
+		device = discovery.NewDevice(inst.sender)
+		device.Sources["mdns"] = struct{}{}
+	}
+	if service, ok := serviceInstance(inst.name, device.LastSeen); ok {
+		device.AddService(service)
+	}
+	if device.DisplayName == "" {
+		device.DisplayName = cleanDisplayName(inst.name)
+	}
+	return device
+}
+
+// resource converts the address back to an A or AAAA record.
+func (a hostAddress) resource() dnsmessage.Resource {
+	header := dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(ensureDot(a.name)), Class: dnsmessage.ClassINET}
+	if ip4 := a.ip.To4(); ip4 != nil {
+		return dnsmessage.Resource{Header: header, Body: &dnsmessage.AResource{A: [4]byte(ip4)}}
+	}
+	return dnsmessage.Resource{Header: header, Body: &dnsmessage.AAAAResource{AAAA: [16]byte(a.ip.To16())}}
+}
+
+func ensureDot(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// buildQuery packs the questions into one query, every question asks for a unicast
+// response (QU) so the answers reach the ephemeral port of the scan session.
+func buildQuery(questions []question) ([]byte, error) {
+	msg := dnsmessage.Message{Header: dnsmessage.Header{ID: 0, RecursionDesired: false}}
+	for _, q := range questions {
+		name, err := dnsmessage.NewName(ensureDot(q.name))
+		if err != nil {
+			continue
+		}
+		msg.Questions = append(msg.Questions, dnsmessage.Question{
+			Name:  name,
+			Type:  q.qtype,
+			Class: dnsmessage.ClassINET | classQU,
+		})
+	}
+	if len(msg.Questions) == 0 {
+		return nil, errors.New("no valid question")
+	}
+	return msg.Pack()
+}
+
+// parseDNSMessage unpacks an mDNS message. Records after a malformed or truncated one
+// are dropped instead of the whole packet, the resolver asks for what is missing.
+func parseDNSMessage(data []byte) (*dnsmessage.Message, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(data)
+	if err != nil {
+		return nil, err
+	}
+	msg := &dnsmessage.Message{Header: header}
+	if msg.Questions, err = p.AllQuestions(); err != nil {
+		return nil, err
+	}
+	if msg.Answers, err = readSection(p.Answer); err != nil {
+		return msg, nil
+	}
+	if err = p.SkipAllAuthorities(); err != nil {
+		return msg, nil
+	}
+	msg.Additionals, _ = readSection(p.Additional)
+	return msg, nil
+}
+
+// readSection reads the records of one section up to the first error.
+func readSection(next func() (dnsmessage.Resource, error)) ([]dnsmessage.Resource, error) {
+	var records []dnsmessage.Resource
+	for {
+		r, err := next()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, r)
+	}
+}
@@ -0,0 +1,200 @@
+package mdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"go.uber.org/zap"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var proxyIP = net.ParseIP("192.168.1.2")
+
+func TestResolverChasesRecords(t *testing.T) {
+	r := newResolver()
+	now := time.Now()
+
+	questions := r.add([]dnsmessage.Resource{
+		resource("_ipp._tcp.local.", 120, &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("Printer._ipp._tcp.local.")}),
+	}, proxyIP, now)
+	if len(questions) != 2 || questions[0] != (question{"Printer._ipp._tcp.local.", dnsmessage.TypeSRV}) || questions[1].qtype != dnsmessage.TypeTXT {
+		t.Fatalf("expected SRV and TXT questions, got %v", questions)
+	}
+
+	questions = r.add([]dnsmessage.Resource{
+		resource("Printer._ipp._tcp.local.", 120, &dnsmessage.SRVResource{Target: dnsmessage.MustNewName("printer.local."), Port: 631}),
+		resource("Printer._ipp._tcp.local.", 120, &dnsmessage.TXTResource{TXT: []string{"ty=Laser"}}),
+	}, proxyIP, now)
+	if len(questions) != 2 || questions[0] != (question{"printer.local.", dnsmessage.TypeA}) || questions[1].qtype != dnsmessage.TypeAAAA {
+		t.Fatalf("expected A and AAAA questions for the SRV target, got %v", questions)
+	}
+	if devices := r.ready(now); len(devices) != 0 {
+		t.Fatalf("incomplete instance must wait for its address, got %+v", devices)
+	}
+
+	// the address arrives in a separate packet, sent by a sleep proxy
+	if questions = r.add([]dnsmessage.Resource{
+		resource("printer.local.", 120, &dnsmessage.AResource{A: [4]byte{192, 168, 1, 20}}),
+	}, proxyIP, now); len(questions) != 0 {
+		t.Errorf("expected no further questions, got %v", questions)
+	}
+
+	devices := r.ready(now)
+	if len(devices) != 1 {
+		t.Fatalf("expected the resolved instance, got %d devices", len(devices))
+	}
+	d := devices[0]
+	if !d.IP.Equal(printerIP) || d.HasAddress(proxyIP) {
+		t.Errorf("expected the device at its A record, not at the sender, got %v %+v", d.IP, d.Addresses)
+	}
+	svc, ok := d.Service("ipp")
+	if !ok || svc.Instance != "Printer" || svc.Port != 631 || svc.TXT["ty"] != "Laser" {
+		t.Errorf("unexpected service %+v", d.Services)
+	}
+	if devices := r.ready(now.Add(time.Minute)); len(devices) != 0 {
+		t.Errorf("a reported instance must not be reported again, got %+v", devices)
+	}
+}
+
+func TestResolverTimeout(t *testing.T) {
+	r := newResolver()
+	now := time.Now()
+	r.add([]dnsmessage.Resource{
+		resource("_http._tcp.local.", 120, &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("Camera._http._tcp.local.")}),
+	}, printerIP, now)
+
+	if devices := r.ready(now.Add(resolveTimeout / 2)); len(devices) != 0 {
+		t.Fatalf("expected the instance to wait for its records, got %+v", devices)
+	}
+	devices := r.ready(now.Add(resolveTimeout))
+	if len(devices) != 1 {
+		t.Fatalf("expected the unresolved instance after the timeout, got %d devices", len(devices))
+	}
+	if d := devices[0]; !d.IP.Equal(printerIP) || d.DisplayName != "Camera._http._tcp" {
+		t.Errorf("expected the instance at its sender, got %+v", d)
+	}
+	if svc, ok := devices[0].Service("http"); !ok || svc.Instance != "Camera" {
+		t.Errorf("expected the announced instance, got %+v", devices[0].Services)
+	}
+}
+
+func TestResolverPending(t *testing.T) {
+	r := newResolver()
+	now := time.Now()
+	r.add([]dnsmessage.Resource{
+		resource("_http._tcp.local.", 120, &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("Camera._http._tcp.local.")}),
+	}, printerIP, now)
+
+	if devices := r.ready(now); len(devices) != 0 {
+		t.Fatalf("expected the instance to wait for its records, got %+v", devices)
+	}
+	devices := r.pending()
+	if len(devices) != 1 || !devices[0].IP.Equal(printerIP) {
+		t.Fatalf("expected the unresolved instance at the end of the scan, got %+v", devices)
+	}
+	if devices := r.pending(); len(devices) != 0 {
+		t.Errorf("a reported instance must not be reported again, got %+v", devices)
+	}
+}
+
+// TestSessionFlushesLateAnnouncement sends a PTR just before the end of the scan, the
+// instance cannot resolve in time and must be reported from the PTR alone.
+func TestSessionFlushesLateAnnouncement(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	// follow-up queries go to a socket nobody answers on
+	sink, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	ss := &scanSession{
+		log:                 zap.NewNop(),
+		conn:                conn,
+		multicastAddr:       sink.LocalAddr().(*net.UDPAddr),
+		queriedServiceTypes: map[string]bool{},
+		resolver:            newResolver(),
+	}
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true},
+		Answers: []dnsmessage.Resource{
+			resource("_http._tcp.local.", 120, &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("Camera._http._tcp.local.")}),
+		},
+	}
+	packet, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_, _ = sink.WriteToUDP(packet, conn.LocalAddr().(*net.UDPAddr))
+	}()
+
+	out := make(chan discovery.Device, 4)
+	if err := ss.listenForResponses(ctx, out); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("listen: %v", err)
+	}
+	close(out)
+
+	var devices []discovery.Device
+	for d := range out {
+		devices = append(devices, d)
+	}
+	if len(devices) != 1 {
+		t.Fatalf("expected the late instance to be reported, got %d devices", len(devices))
+	}
+	if svc, ok := devices[0].Service("http"); !ok || svc.Instance != "Camera" {
+		t.Errorf("expected the announced instance, got %+v", devices[0].Services)
+	}
+}
+
+func TestBuildQueryRequestsUnicast(t *testing.T) {
+	packet, err := buildQuery([]question{{"printer.local", dnsmessage.TypeA}, {"printer.local.", dnsmessage.TypeAAAA}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(packet); err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Questions) != 2 {
+		t.Fatalf("expected 2 questions, got %d", len(msg.Questions))
+	}
+	for _, q := range msg.Questions {
+		if q.Class&classQU == 0 || q.Class&^classQU != dnsmessage.ClassINET {
+			t.Errorf("expected an IN question with the QU bit, got class %#x", uint16(q.Class))
+		}
+	}
+}
+
+func TestParseDNSMessageTruncated(t *testing.T) {
+	packet, err := announcement(120).Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// cut into the last record, the ones before it are kept
+	msg, err := parseDNSMessage(packet[:len(packet)-2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Response || len(msg.Answers) != 3 {
+		t.Errorf("expected the 3 complete answers, got %d", len(msg.Answers))
+	}
+
+	if _, err := parseDNSMessage(packet[:5]); err == nil {
+		t.Errorf("expected an error for a packet without header")
+	}
+}
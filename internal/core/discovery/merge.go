@@ -0,0 +1,257 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+)
+
+// MergeStrategy decides which value wins when two scanners report a different
+// value for the same Device field.
+type MergeStrategy string
+
+const (
+	MergeFirstWins      MergeStrategy = "first-wins"      // first non-empty value is kept
+	MergeMostSpecific   MergeStrategy = "most-specific"   // descriptive value beats generic one (e.g. model name over "Linux/3.14 UPnP/1.0")
+	MergeLongest        MergeStrategy = "longest"         // longer value (usually more complete) wins
+	MergeMostRecent     MergeStrategy = "most-recent"     // value of the most recently seen observation wins
+	MergeUnion          MergeStrategy = "union"           // combine map entries, existing keys are kept
+	MergeSourcePriority MergeStrategy = "source-priority" // value from the highest ranked source wins
+)
+
+// Mergeable field names, used as keys for per-field policies and Device.FieldSources.
+const (
+	FieldMAC          = "mac"
+	FieldHostname     = "hostname"
+	FieldInterface    = "interface"
+	FieldSubnet       = "subnet"
+	FieldDisplayName  = "display_name"
+	FieldManufacturer = "manufacturer"
+	FieldDeviceType   = "device_type"
+	FieldOS           = "os"
+	FieldNetBIOSName  = "netbios_name"
+	FieldReverseDNS   = "reverse_dns"
+	FieldHTTPTitle    = "http_title"
+	FieldHTTPServer   = "http_server"
+	FieldExtraData    = "extra_data"
+)
+
+// scalarFields lists the string fields that accept every strategy except union.
+var scalarFields = map[string]struct{}{
+	FieldMAC:          {},
+	FieldHostname:     {},
+	FieldInterface:    {},
+	FieldSubnet:       {},
+	FieldDisplayName:  {},
+	FieldManufacturer: {},
+	FieldDeviceType:   {},
+	FieldOS:           {},
+	FieldNetBIOSName:  {},
+	FieldReverseDNS:   {},
+	FieldHTTPTitle:    {},
+	FieldHTTPServer:   {},
+}
+
+// mapFields lists the map fields, these only accept union and most-recent.
+var mapFields = map[string]struct{}{
+	FieldExtraData: {},
+}
+
+// DefaultSourcePriority ranks scanner sources from most to least trusted. It is used by
+// MergeSourcePriority when neither the field nor the config define their own ranking.
+// Sources announcing their own name and model come first, address lookups last.
+var DefaultSourcePriority = []string{
+	config.SourceLocal, "mdns", "ssdp", "arp", "wsd", "snmp", "netbios", "llmnr", "dhcp-leases", "rdns", "icmp", "reachability",
+}
+
+// FieldPolicy is the merge strategy for a single field.
+type FieldPolicy struct {
+	Strategy       MergeStrategy
+	SourcePriority []string // optional, falls back to MergePolicy.SourcePriority
+}
+
+// MergePolicy selects a merge strategy per Device field.
+type MergePolicy struct {
+	Default        MergeStrategy
+	SourcePriority []string
+	Fields         map[string]FieldPolicy
+}
+
+// defaultMergePolicy is used by Device.Merge and whenever no policy is configured.
+var defaultMergePolicy = DefaultMergePolicy()
+
+// DefaultMergePolicy keeps the first value for every field, except for the display name
+// where a descriptive name (e.g. the mDNS `md=` model) beats a generic SSDP server string.
+func DefaultMergePolicy() *MergePolicy {
+	return &MergePolicy{
+		Default:        MergeFirstWins,
+		SourcePriority: DefaultSourcePriority,
+		Fields: map[string]FieldPolicy{
+			FieldDisplayName: {Strategy: MergeMostSpecific},
+			FieldExtraData:   {Strategy: MergeUnion},
+		},
+	}
+}
+
+// ParseMergeStrategy converts a config value into a MergeStrategy.
+func ParseMergeStrategy(name string) (MergeStrategy, error) {
+	s := MergeStrategy(strings.ToLower(strings.TrimSpace(name)))
+	switch s {
+	case MergeFirstWins, MergeMostSpecific, MergeLongest, MergeMostRecent, MergeUnion, MergeSourcePriority:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown merge strategy %q", name)
+	}
+}
+
+// NewMergePolicy builds a MergePolicy from the user config, on top of DefaultMergePolicy.
+func NewMergePolicy(cfg config.MergeConfig) (*MergePolicy, error) {
+	p := DefaultMergePolicy()
+
+	if cfg.Default != "" {
+		s, err := ParseMergeStrategy(cfg.Default)
+		if err != nil {
+			return nil, fmt.Errorf("merge.default: %w", err)
+		}
+		if s == MergeUnion {
+			return nil, fmt.Errorf("merge.default: %q is only valid for map fields", s)
+		}
+		p.Default = s
+	}
+	if len(cfg.SourcePriority) > 0 {
+		p.SourcePriority = normalizeSources(cfg.SourcePriority)
+	}
+
+	for field, fc := range cfg.Fields {
+		s, err := ParseMergeStrategy(fc.Strategy)
+		if err != nil {
+			return nil, fmt.Errorf("merge.fields.%s: %w", field, err)
+		}
+		if err := validateFieldStrategy(field, s); err != nil {
+			return nil, fmt.Errorf("merge.fields.%s: %w", field, err)
+		}
+		p.Fields[field] = FieldPolicy{Strategy: s, SourcePriority: normalizeSources(fc.SourcePriority)}
+	}
+
+	return p, nil
+}
+
+// MergePolicyFromConfig builds the merge policy for cfg, the DefaultMergePolicy when the
+// merge section is invalid. Loading the config reports and resets an invalid section.
+func MergePolicyFromConfig(cfg *config.Config) *MergePolicy {
+	if cfg == nil {
+		return DefaultMergePolicy()
+	}
+	p, err := NewMergePolicy(cfg.Merge)
+	if err != nil {
+		return DefaultMergePolicy()
+	}
+	return p
+}
+
+func validateFieldStrategy(field string, s MergeStrategy) error {
+	if _, ok := scalarFields[field]; ok {
+		if s == MergeUnion {
+			return fmt.Errorf("%q is only valid for map fields", s)
+		}
+		return nil
+	}
+	if _, ok := mapFields[field]; ok {
+		if s != MergeUnion && s != MergeMostRecent {
+			return fmt.Errorf("map fields only support %q and %q", MergeUnion, MergeMostRecent)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown field")
+}
+
+func normalizeSources(sources []string) []string {
+	if len(sources) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(sources))
+	for _, s := range sources {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// strategyFor returns the strategy and source ranking that apply to a field.
+func (p *MergePolicy) strategyFor(field string) (MergeStrategy, []string) {
+	strategy, priority := p.Default, p.SourcePriority
+	if fp, ok := p.Fields[field]; ok {
+		strategy = fp.Strategy
+		if len(fp.SourcePriority) > 0 {
+			priority = fp.SourcePriority
+		}
+	}
+	return strategy, priority
+}
+
+// mergeCandidate is one side of a scalar field merge.
+type mergeCandidate struct {
+	value  string
+	source string
+	newer  bool
+}
+
+// pick reports whether the incoming candidate should replace the current value.
+func (p *MergePolicy) pick(field string, current, incoming mergeCandidate) bool {
+	if incoming.value == "" || incoming.value == current.value {
+		return false
+	}
+	if current.value == "" {
+		return true
+	}
+
+	strategy, priority := p.strategyFor(field)
+	switch strategy {
+	case MergeMostSpecific:
+		return specificity(incoming.value) > specificity(current.value)
+	case MergeLongest:
+		return len(incoming.value) > len(current.value)
+	case MergeMostRecent:
+		return incoming.newer
+	case MergeSourcePriority:
+		return sourceRank(priority, incoming.source) < sourceRank(priority, current.source)
+	default:
+		return false
+	}
+}
+
+// sourceRank returns the position of source in priority, unknown sources rank last.
+func sourceRank(priority []string, source string) int {
+	for i, s := range priority {
+		if s == source {
+			return i
+		}
+	}
+	return len(priority)
+}
+
+// productTokenRe matches HTTP style product tokens such as "UPnP/1.0" or "libupnp/1.6.19".
+var productTokenRe = regexp.MustCompile(`[A-Za-z][\w.-]*/v?\d`)
+
+// specificity scores how descriptive a value is. IP/MAC literals and values that mostly
+// consist of product tokens (typical SSDP Server headers) are considered generic.
+func specificity(value string) int {
+	v := strings.TrimSpace(value)
+	switch {
+	case v == "":
+		return 0
+	case net.ParseIP(v) != nil:
+		return 1
+	}
+	if _, err := net.ParseMAC(v); err == nil {
+		return 1
+	}
+	if tokens := productTokenRe.FindAllString(v, -1); len(tokens) > 0 && 2*len(tokens) >= len(strings.Fields(v)) {
+		return 2
+	}
+	return 3
+}
@@ -0,0 +1,222 @@
+package discovery
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+)
+
+func newSourcedDevice(source, name string, lastSeen time.Time) Device {
+	d := NewDevice(net.ParseIP("10.0.0.1"))
+	d.Sources[source] = struct{}{}
+	d.DisplayName = name
+	d.LastSeen = lastSeen
+	return d
+}
+
+func TestMergeDisplayNameMostSpecificByDefault(t *testing.T) {
+	ssdp := newSourcedDevice("ssdp", "Linux/3.14 UPnP/1.0 libupnp/1.6.19", time.Unix(100, 0))
+	mdns := newSourcedDevice("mdns", "Living Room TV", time.Unix(100, 0))
+
+	ssdp.Merge(&mdns)
+
+	if ssdp.DisplayName != "Living Room TV" {
+		t.Fatalf("expected mDNS name to win over SSDP server string, got %q", ssdp.DisplayName)
+	}
+	if got := ssdp.FieldSource(FieldDisplayName); got != "mdns" {
+		t.Fatalf("expected display name source mdns, got %q", got)
+	}
+}
+
+func TestMergeStrategies(t *testing.T) {
+	tests := []struct {
+		name       string
+		field      FieldPolicy
+		existing   Device
+		incoming   Device
+		wantName   string
+		wantSource string
+	}{
+		{
+			name:       "first wins",
+			field:      FieldPolicy{Strategy: MergeFirstWins},
+			existing:   newSourcedDevice("ssdp", "short", time.Unix(100, 0)),
+			incoming:   newSourcedDevice("mdns", "much longer name", time.Unix(200, 0)),
+			wantName:   "short",
+			wantSource: "ssdp",
+		},
+		{
+			name:       "longest",
+			field:      FieldPolicy{Strategy: MergeLongest},
+			existing:   newSourcedDevice("ssdp", "short", time.Unix(100, 0)),
+			incoming:   newSourcedDevice("mdns", "much longer name", time.Unix(100, 0)),
+			wantName:   "much longer name",
+			wantSource: "mdns",
+		},
+		{
+			name:       "most recent newer wins",
+			field:      FieldPolicy{Strategy: MergeMostRecent},
+			existing:   newSourcedDevice("ssdp", "old", time.Unix(100, 0)),
+			incoming:   newSourcedDevice("mdns", "new", time.Unix(200, 0)),
+			wantName:   "new",
+			wantSource: "mdns",
+		},
+		{
+			name:       "most recent older loses",
+			field:      FieldPolicy{Strategy: MergeMostRecent},
+			existing:   newSourcedDevice("ssdp", "new", time.Unix(200, 0)),
+			incoming:   newSourcedDevice("mdns", "old", time.Unix(100, 0)),
+			wantName:   "new",
+			wantSource: "ssdp",
+		},
+		{
+			name:       "source priority",
+			field:      FieldPolicy{Strategy: MergeSourcePriority, SourcePriority: []string{"mdns", "ssdp"}},
+			existing:   newSourcedDevice("ssdp", "from ssdp", time.Unix(100, 0)),
+			incoming:   newSourcedDevice("mdns", "from mdns", time.Unix(100, 0)),
+			wantName:   "from mdns",
+			wantSource: "mdns",
+		},
+		{
+			name:       "source priority unknown source loses",
+			field:      FieldPolicy{Strategy: MergeSourcePriority, SourcePriority: []string{"mdns", "ssdp"}},
+			existing:   newSourcedDevice("ssdp", "from ssdp", time.Unix(100, 0)),
+			incoming:   newSourcedDevice("custom", "from custom", time.Unix(100, 0)),
+			wantName:   "from ssdp",
+			wantSource: "ssdp",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := DefaultMergePolicy()
+			policy.Fields[FieldDisplayName] = tt.field
+
+			tt.existing.MergeWith(&tt.incoming, policy)
+
+			if tt.existing.DisplayName != tt.wantName {
+				t.Errorf("DisplayName = %q, want %q", tt.existing.DisplayName, tt.wantName)
+			}
+			if got := tt.existing.FieldSource(FieldDisplayName); got != tt.wantSource {
+				t.Errorf("FieldSource = %q, want %q", got, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestMergeExtraDataMostRecent(t *testing.T) {
+	policy := DefaultMergePolicy()
+	policy.Fields[FieldExtraData] = FieldPolicy{Strategy: MergeMostRecent}
+
+	existing := newSourcedDevice("mdns", "", time.Unix(100, 0))
+	existing.ExtraData["fw"] = "1.0"
+	incoming := newSourcedDevice("mdns", "", time.Unix(200, 0))
+	incoming.ExtraData["fw"] = "2.0"
+
+	existing.MergeWith(&incoming, policy)
+
+	if existing.ExtraData["fw"] != "2.0" {
+		t.Fatalf("expected newer extra data to win, got %q", existing.ExtraData["fw"])
+	}
+}
+
+func TestNewMergePolicy(t *testing.T) {
+	p, err := NewMergePolicy(config.MergeConfig{
+		Default:        "Longest",
+		SourcePriority: []string{" MDNS ", "arp"},
+		Fields: map[string]config.FieldMergeConfig{
+			"manufacturer": {Strategy: "source-priority", SourcePriority: []string{"arp"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Default != MergeLongest {
+		t.Errorf("expected default longest, got %q", p.Default)
+	}
+	if len(p.SourcePriority) != 2 || p.SourcePriority[0] != "mdns" {
+		t.Errorf("expected normalized source priority, got %v", p.SourcePriority)
+	}
+	if s, prio := p.strategyFor(FieldManufacturer); s != MergeSourcePriority || prio[0] != "arp" {
+		t.Errorf("unexpected manufacturer policy: %q %v", s, prio)
+	}
+	if s, _ := p.strategyFor(FieldDisplayName); s != MergeMostSpecific {
+		t.Errorf("expected display name to keep default most-specific, got %q", s)
+	}
+}
+
+func TestNewMergePolicyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.MergeConfig
+	}{
+		{"unknown strategy", config.MergeConfig{Default: "best"}},
+		{"union as default", config.MergeConfig{Default: "union"}},
+		{"unknown field", config.MergeConfig{Fields: map[string]config.FieldMergeConfig{"color": {Strategy: "longest"}}}},
+		{"union on scalar", config.MergeConfig{Fields: map[string]config.FieldMergeConfig{"os": {Strategy: "union"}}}},
+		{"longest on map", config.MergeConfig{Fields: map[string]config.FieldMergeConfig{"extra_data": {Strategy: "longest"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMergePolicy(tt.cfg); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestLoadReportsInvalidMergeConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	raw := "merge:\n  default: union\n"
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(path)
+	if err == nil || !strings.Contains(err.Error(), "merge.default") {
+		t.Fatalf("expected merge.default error, got %v", err)
+	}
+	if cfg.Merge.Default != "" {
+		t.Errorf("expected merge section reset, got %+v", cfg.Merge)
+	}
+}
+
+func TestConfigKnowsMergeFields(t *testing.T) {
+	for field := range scalarFields {
+		cfg := config.MergeConfig{Fields: map[string]config.FieldMergeConfig{field: {Strategy: string(MergeLongest)}}}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("field %s: %v", field, err)
+		}
+	}
+	for field := range mapFields {
+		cfg := config.MergeConfig{Fields: map[string]config.FieldMergeConfig{field: {Strategy: string(MergeUnion)}}}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("field %s: %v", field, err)
+		}
+	}
+}
+
+func TestSpecificity(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 0},
+		{"192.168.1.10", 1},
+		{"aa:bb:cc:dd:ee:ff", 1},
+		{"Linux/3.14 UPnP/1.0 libupnp/1.6.19", 2},
+		{"Sonos Play:5", 3},
+	}
+
+	for _, tt := range tests {
+		if got := specificity(tt.value); got != tt.want {
+			t.Errorf("specificity(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// MulticastConn is a socket bound to a well-known multicast port (mDNS 5353, SSDP 1900) and
+// joined to a group on one interface. The port is bound with address reuse so it can be
+// shared with the system responders (Avahi, mDNSResponder, UPnP stacks) and other listeners.
+type MulticastConn struct {
+	iface *InterfaceInfo
+	v4    *ipv4.PacketConn
+	v6    *ipv6.PacketConn
+}
+
+// ListenMulticast binds the port of group and joins group on the interface.
+func ListenMulticast(iface *InterfaceInfo, family IPFamily, group *net.UDPAddr) (*MulticastConn, error) {
+	if iface == nil || iface.Interface == nil {
+		return nil, errors.New("no network interface")
+	}
+	network := "udp4"
+	if family == IPv6 {
+		network = "udp6"
+		group = &net.UDPAddr{IP: group.IP, Port: group.Port, Zone: iface.Interface.Name}
+	}
+
+	lc := net.ListenConfig{Control: reuseAddr}
+	c, err := lc.ListenPacket(context.Background(), network, multicastListenAddress(group))
+	if err != nil {
+		return nil, fmt.Errorf("bind %s: %w", group, err)
+	}
+
+	mc := &MulticastConn{iface: iface}
+	// control messages are not supported everywhere (Windows), packets are not filtered by interface then
+	if family == IPv6 {
+		mc.v6 = ipv6.NewPacketConn(c)
+		err = mc.v6.JoinGroup(iface.Interface, group)
+		_ = mc.v6.SetControlMessage(ipv6.FlagInterface, true)
+	} else {
+		mc.v4 = ipv4.NewPacketConn(c)
+		err = mc.v4.JoinGroup(iface.Interface, group)
+		_ = mc.v4.SetControlMessage(ipv4.FlagInterface, true)
+	}
+	if err != nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("join multicast group %s: %w", group, err)
+	}
+	return mc, nil
+}
+
+// ReadFrom reads the next packet that arrived on the interface and returns its sender.
+// The group is shared by every interface, packets of other interfaces are skipped.
+func (c *MulticastConn) ReadFrom(b []byte) (int, net.IP, error) {
+	for {
+		var n, ifIndex int
+		var src net.Addr
+		var err error
+		if c.v6 != nil {
+			var cm *ipv6.ControlMessage
+			n, cm, src, err = c.v6.ReadFrom(b)
+			if cm != nil {
+				ifIndex = cm.IfIndex
+			}
+		} else {
+			var cm *ipv4.ControlMessage
+			n, cm, src, err = c.v4.ReadFrom(b)
+			if cm != nil {
+				ifIndex = cm.IfIndex
+			}
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		if ifIndex != 0 && ifIndex != c.iface.Interface.Index {
+			continue
+		}
+		var ip net.IP
+		if ua, ok := src.(*net.UDPAddr); ok {
+			ip = ua.IP
+		}
+		return n, ip, nil
+	}
+}
+
+// Close closes the socket, a blocked ReadFrom returns net.ErrClosed.
+func (c *MulticastConn) Close() error {
+	if c.v6 != nil {
+		return c.v6.Close()
+	}
+	return c.v4.Close()
+}
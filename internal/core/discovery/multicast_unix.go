@@ -0,0 +1,31 @@
+//go:build !windows
+
+package discovery
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reuseAddr sets SO_REUSEADDR and SO_REUSEPORT, BSDs (macOS) need the latter to share
+// a multicast port with the system responders.
+func reuseAddr(_, _ string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		if sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); sockErr != nil {
+			return
+		}
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// multicastListenAddress binds the group address itself, so only traffic of the group is received.
+func multicastListenAddress(group *net.UDPAddr) string {
+	return group.String()
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+package discovery
+
+import (
+	"net"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// reuseAddr sets SO_REUSEADDR so the port can be shared with the system responders.
+func reuseAddr(_, _ string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = windows.SetsockoptInt(windows.Handle(fd), windows.SOL_SOCKET, windows.SO_REUSEADDR, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// multicastListenAddress binds the wildcard address, Windows cannot bind a multicast address.
+func multicastListenAddress(group *net.UDPAddr) string {
+	if group.IP.To4() != nil {
+		return net.JoinHostPort("0.0.0.0", strconv.Itoa(group.Port))
+	}
+	return net.JoinHostPort("::", strconv.Itoa(group.Port))
+}
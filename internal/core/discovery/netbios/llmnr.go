@@ -0,0 +1,79 @@
+package netbios
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	llmnrMulticastAddress = "224.0.0.252"
+	llmnrPort             = 5355
+)
+
+// reverseName returns the in-addr.arpa name of an IPv4 address, e.g. "20.1.168.192.in-addr.arpa.".
+func reverseName(ip net.IP) (string, bool) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return "", false
+	}
+	return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", ip4[3], ip4[2], ip4[1], ip4[0]), true
+}
+
+// parseReverseName is the inverse of reverseName.
+func parseReverseName(name string) net.IP {
+	labels := strings.Split(strings.TrimSuffix(strings.ToLower(name), "."), ".")
+	if len(labels) != 6 || labels[4] != "in-addr" || labels[5] != "arpa" {
+		return nil
+	}
+	ip := make(net.IP, net.IPv4len)
+	for i := 0; i < 4; i++ {
+		n, err := strconv.ParseUint(labels[3-i], 10, 8)
+		if err != nil {
+			return nil
+		}
+		ip[i] = byte(n)
+	}
+	return ip
+}
+
+// reverseQuery builds an LLMNR PTR query for the address (RFC 4795 section 2.1).
+func reverseQuery(id uint16, ip net.IP) ([]byte, error) {
+	rev, ok := reverseName(ip)
+	if !ok {
+		return nil, fmt.Errorf("llmnr: %v is no IPv4 address", ip)
+	}
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName(rev),
+			Type:  dnsmessage.TypePTR,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	return msg.Pack()
+}
+
+// parseReverseResponse returns the address and host name of an LLMNR PTR response.
+// The address is taken from the record name, not from the sender of the packet.
+func parseReverseResponse(b []byte) (net.IP, string, bool) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(b); err != nil || !msg.Response || msg.RCode != dnsmessage.RCodeSuccess {
+		return nil, "", false
+	}
+	for _, answer := range msg.Answers {
+		ptr, ok := answer.Body.(*dnsmessage.PTRResource)
+		if !ok {
+			continue
+		}
+		ip := parseReverseName(answer.Header.Name.String())
+		host := strings.TrimSuffix(ptr.PTR.String(), ".")
+		if ip != nil && host != "" {
+			return ip, host, true
+		}
+	}
+	return nil, "", false
+}
@@ -0,0 +1,52 @@
+package netbios
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestReverseName(t *testing.T) {
+	name, ok := reverseName(net.ParseIP("192.168.1.20"))
+	if !ok || name != "20.1.168.192.in-addr.arpa." {
+		t.Fatalf("unexpected reverse name %q", name)
+	}
+	if ip := parseReverseName(name); !ip.Equal(net.ParseIP("192.168.1.20")) {
+		t.Errorf("expected the address back, got %v", ip)
+	}
+	if _, ok := reverseName(net.ParseIP("fe80::1")); ok {
+		t.Errorf("expected no reverse name for IPv6")
+	}
+	if ip := parseReverseName("1.2.3.in-addr.arpa."); ip != nil {
+		t.Errorf("expected nil for an incomplete name, got %v", ip)
+	}
+}
+
+func TestParseReverseResponse(t *testing.T) {
+	query, err := reverseQuery(7, net.ParseIP("192.168.1.20"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil {
+		t.Fatal(err)
+	}
+	msg.Response = true
+	msg.Answers = []dnsmessage.Resource{{
+		Header: dnsmessage.ResourceHeader{Name: msg.Questions[0].Name, Class: dnsmessage.ClassINET, TTL: 30},
+		Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("DESKTOP-7Q1.")},
+	}}
+	resp, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, ok := deviceFromReverse(resp)
+	if !ok || !d.IP.Equal(net.ParseIP("192.168.1.20")) || d.Hostname != "DESKTOP-7Q1" {
+		t.Errorf("unexpected device %+v", d)
+	}
+	if _, ok := deviceFromReverse(query); ok {
+		t.Errorf("a query must not produce a device")
+	}
+}
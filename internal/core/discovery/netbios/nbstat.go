@@ -0,0 +1,174 @@
+package netbios
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NBNS constants, see RFC 1002 section 4.2.
+const (
+	nbnsPort       = 137
+	typeNBSTAT     = 0x0021
+	classIN        = 0x0001
+	nameEntrySize  = 18 // 15 byte name, 1 byte suffix, 2 byte flags
+	groupNameFlag  = 0x8000
+	suffixMachine  = 0x00
+	browserElector = "__MSBROWSE__"
+)
+
+var errShortPacket = errors.New("nbstat: short packet")
+
+// NameEntry is one name of the name table of a node status response.
+type NameEntry struct {
+	Name   string
+	Suffix byte // NetBIOS suffix, 0x00 workstation, 0x20 file server, ...
+	Group  bool
+}
+
+// NodeStatus is a decoded node status (NBSTAT) response.
+type NodeStatus struct {
+	Names []NameEntry
+	MAC   net.HardwareAddr // unit ID of the adapter, nil when the host reports zeros (e.g. Samba)
+}
+
+// Workstation returns the computer name, the first unique name with suffix 0x00.
+func (s NodeStatus) Workstation() string {
+	for _, n := range s.Names {
+		if !n.Group && n.Suffix == suffixMachine {
+			return n.Name
+		}
+	}
+	return ""
+}
+
+// Workgroup returns the workgroup or domain, the first group name with suffix 0x00.
+func (s NodeStatus) Workgroup() string {
+	for _, n := range s.Names {
+		if n.Group && n.Suffix == suffixMachine && n.Name != browserElector {
+			return n.Name
+		}
+	}
+	return ""
+}
+
+// NodeStatusRequest builds a node status request for the wildcard name "*", which
+// returns all names registered on the target host (RFC 1002 section 4.2.17).
+func NodeStatusRequest(id uint16) []byte {
+	packet := make([]byte, 50)
+	binary.BigEndian.PutUint16(packet[0:2], id)
+	binary.BigEndian.PutUint16(packet[4:6], 1) // one question
+
+	// first-level encoded name, every nibble of the 16 byte name is added to 'A':
+	// '*' = 0x2A -> "CK", the space padding 0x20 -> "CA"
+	packet[12] = 0x20
+	packet[13], packet[14] = 'C', 'K'
+	for i := 0; i < 15; i++ {
+		packet[15+i*2], packet[16+i*2] = 'C', 'A'
+	}
+	// packet[45] terminates the name
+	binary.BigEndian.PutUint16(packet[46:48], typeNBSTAT)
+	binary.BigEndian.PutUint16(packet[48:50], classIN)
+	return packet
+}
+
+// ParseNodeStatus decodes a node status response (RFC 1002 section 4.2.18).
+func ParseNodeStatus(b []byte) (NodeStatus, error) {
+	if len(b) < 12 {
+		return NodeStatus{}, errShortPacket
+	}
+	if b[2]&0x80 == 0 {
+		return NodeStatus{}, errors.New("nbstat: not a response")
+	}
+	questions := int(binary.BigEndian.Uint16(b[4:6]))
+	if binary.BigEndian.Uint16(b[6:8]) == 0 {
+		return NodeStatus{}, errors.New("nbstat: no answer")
+	}
+
+	pos := 12
+	var err error
+	for i := 0; i < questions; i++ {
+		if pos, err = skipName(b, pos); err != nil {
+			return NodeStatus{}, err
+		}
+		pos += 4 // type and class
+	}
+	if pos, err = skipName(b, pos); err != nil {
+		return NodeStatus{}, err
+	}
+	if pos+10 > len(b) {
+		return NodeStatus{}, errShortPacket
+	}
+	if rrType := binary.BigEndian.Uint16(b[pos : pos+2]); rrType != typeNBSTAT {
+		return NodeStatus{}, fmt.Errorf("nbstat: unexpected record type %#04x", rrType)
+	}
+	rdata := b[pos+10:]
+	if rdLen := int(binary.BigEndian.Uint16(b[pos+8 : pos+10])); rdLen < len(rdata) {
+		rdata = rdata[:rdLen]
+	}
+	if len(rdata) < 1 {
+		return NodeStatus{}, errShortPacket
+	}
+
+	count := int(rdata[0])
+	rdata = rdata[1:]
+	if len(rdata) < count*nameEntrySize {
+		return NodeStatus{}, errShortPacket
+	}
+	var status NodeStatus
+	for i := 0; i < count; i++ {
+		entry := rdata[i*nameEntrySize : (i+1)*nameEntrySize]
+		name := strings.TrimRight(string(entry[:15]), " \x00")
+		if name == "" || !printable(name) {
+			continue
+		}
+		status.Names = append(status.Names, NameEntry{
+			Name:   name,
+			Suffix: entry[15],
+			Group:  binary.BigEndian.Uint16(entry[16:18])&groupNameFlag != 0,
+		})
+	}
+	// the statistics after the name table start with the unit ID
+	if stats := rdata[count*nameEntrySize:]; len(stats) >= 6 {
+		mac := net.HardwareAddr(append([]byte(nil), stats[:6]...))
+		if !isZero(mac) {
+			status.MAC = mac
+		}
+	}
+	return status, nil
+}
+
+// skipName returns the offset after the (possibly compressed) name at pos.
+func skipName(b []byte, pos int) (int, error) {
+	for pos < len(b) {
+		l := int(b[pos])
+		switch {
+		case l == 0:
+			return pos + 1, nil
+		case l&0xC0 == 0xC0:
+			return pos + 2, nil
+		}
+		pos += l + 1
+	}
+	return 0, errShortPacket
+}
+
+func printable(s string) bool {
+	for _, r := range s {
+		if r < 32 || r > 126 {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package netbios
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// nodeStatusResponse builds an NBSTAT response with the given name table and unit ID.
+func nodeStatusResponse(names []NameEntry, mac []byte) []byte {
+	b := []byte{0x00, 0x01, 0x84, 0x00, 0, 0, 0, 1, 0, 0, 0, 0}
+	b = append(b, NodeStatusRequest(1)[12:46]...) // RR_NAME, the encoded wildcard
+	b = binary.BigEndian.AppendUint16(b, typeNBSTAT)
+	b = binary.BigEndian.AppendUint16(b, classIN)
+	b = append(b, 0, 0, 0, 0) // TTL
+
+	rdata := []byte{byte(len(names))}
+	for _, n := range names {
+		entry := make([]byte, nameEntrySize)
+		copy(entry, n.Name+"               ")
+		entry[15] = n.Suffix
+		if n.Group {
+			binary.BigEndian.PutUint16(entry[16:], groupNameFlag)
+		}
+		rdata = append(rdata, entry...)
+	}
+	rdata = append(rdata, mac...)
+	rdata = append(rdata, make([]byte, 40)...) // remaining statistics
+
+	b = binary.BigEndian.AppendUint16(b, uint16(len(rdata)))
+	return append(b, rdata...)
+}
+
+func TestNodeStatusRequest(t *testing.T) {
+	b := NodeStatusRequest(0x1234)
+	if len(b) != 50 || binary.BigEndian.Uint16(b[0:2]) != 0x1234 {
+		t.Fatalf("unexpected request header % x", b[:12])
+	}
+	if string(b[13:15]) != "CK" || string(b[15:45]) != "CACACACACACACACACACACACACACACA" {
+		t.Errorf("expected the encoded wildcard name, got %q", b[13:45])
+	}
+	if binary.BigEndian.Uint16(b[46:48]) != typeNBSTAT {
+		t.Errorf("expected an NBSTAT question")
+	}
+}
+
+func TestParseNodeStatus(t *testing.T) {
+	mac := []byte{0x00, 0x15, 0x5d, 0x01, 0x02, 0x03}
+	b := nodeStatusResponse([]NameEntry{
+		{Name: "WORKGROUP", Suffix: 0x00, Group: true},
+		{Name: "DESKTOP-7Q1", Suffix: 0x20},
+		{Name: "DESKTOP-7Q1", Suffix: 0x00},
+		{Name: "__MSBROWSE__", Suffix: 0x01, Group: true},
+	}, mac)
+
+	status, err := ParseNodeStatus(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Names) != 4 {
+		t.Fatalf("expected 4 names, got %+v", status.Names)
+	}
+	if status.Workstation() != "DESKTOP-7Q1" || status.Workgroup() != "WORKGROUP" {
+		t.Errorf("unexpected workstation %q or workgroup %q", status.Workstation(), status.Workgroup())
+	}
+	if status.MAC.String() != "00:15:5d:01:02:03" {
+		t.Errorf("unexpected MAC %v", status.MAC)
+	}
+
+	d, ok := deviceFromNodeStatus(net.ParseIP("192.168.1.50"), b)
+	if !ok || d.NetBIOSName != "DESKTOP-7Q1" || d.MAC != "00:15:5d:01:02:03" || d.ExtraData[ExtraWorkgroup] != "WORKGROUP" {
+		t.Errorf("unexpected device %+v", d)
+	}
+	if _, ok := d.Sources[Name]; !ok {
+		t.Errorf("expected source %s, got %v", Name, d.Sources)
+	}
+}
+
+func TestParseNodeStatusSambaZeroMAC(t *testing.T) {
+	status, err := ParseNodeStatus(nodeStatusResponse([]NameEntry{{Name: "NAS", Suffix: 0x00}}, make([]byte, 6)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.MAC != nil {
+		t.Errorf("expected no MAC for a zero unit ID, got %v", status.MAC)
+	}
+}
+
+func TestParseNodeStatusInvalid(t *testing.T) {
+	valid := nodeStatusResponse([]NameEntry{{Name: "PC", Suffix: 0x00}}, nil)
+	tests := map[string][]byte{
+		"short":     valid[:8],
+		"query":     NodeStatusRequest(1),
+		"truncated": valid[:60],
+	}
+	for name, b := range tests {
+		if _, err := ParseNodeStatus(b); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
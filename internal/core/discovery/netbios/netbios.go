@@ -0,0 +1,216 @@
+package netbios
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"go.uber.org/zap"
+	"golang.org/x/net/ipv4"
+)
+
+// Name is the scanner name and the source of devices found through NBNS.
+const Name = "netbios"
+
+// SourceLLMNR is the source of devices named through an LLMNR reverse lookup.
+const SourceLLMNR = "llmnr"
+
+// ExtraWorkgroup is the ExtraData key of the NetBIOS workgroup or domain.
+const ExtraWorkgroup = "netbios_workgroup"
+
+const (
+	// maxSweepHosts caps the subnet size queried host by host, larger subnets
+	// only get the broadcast node status query.
+	maxSweepHosts = 1024
+	// sweepBatch hosts are queried before pausing for sweepPause.
+	sweepBatch = 32
+	sweepPause = 10 * time.Millisecond
+	// maxPacketSize is the read buffer size, NBNS and LLMNR answers fit in one Ethernet frame.
+	maxPacketSize = 1500
+)
+
+var _ discovery.InterfaceScanner = (*Scanner)(nil)
+
+// Scanner names Windows hosts that do not speak mDNS. It broadcasts a wildcard NBNS
+// node status query on the subnet and sends it to every host of the subnet, as not
+// every host answers broadcasts, together with an LLMNR reverse lookup on 224.0.0.252.
+// Node status responses carry the computer name, workgroup and MAC address.
+type Scanner struct {
+	iface *discovery.InterfaceInfo
+	llmnr bool
+}
+
+func NewScanner(iface *discovery.InterfaceInfo, llmnr bool) *Scanner {
+	return &Scanner{iface: iface, llmnr: llmnr}
+}
+
+func (s *Scanner) Name() string { return Name }
+
+// Interface returns the network interface the scanner is bound to.
+func (s *Scanner) Interface() *discovery.InterfaceInfo { return s.iface }
+
+// Scan sends the queries and emits a device for every answer until the deadline of ctx.
+// NetBIOS and LLMNR reverse lookups are IPv4 only, IPv6-only interfaces are skipped.
+func (s *Scanner) Scan(ctx context.Context, out chan<- discovery.Device) error {
+	log := zap.L().With(zap.String("scanner", Name))
+	if s.iface == nil || s.iface.IPv4Addr == nil || s.iface.IPv4Net == nil {
+		log.Debug("interface has no IPv4 subnet, skipping")
+		return nil
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return errors.New("netbios scan requires context with deadline")
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: *s.iface.IPv4Addr})
+	if err != nil {
+		return fmt.Errorf("create UDP socket: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return fmt.Errorf("set read deadline: %w", err)
+	}
+
+	llmnr := s.llmnr
+	if llmnr && s.iface.Interface != nil {
+		if err := ipv4.NewPacketConn(conn).SetMulticastInterface(s.iface.Interface); err != nil {
+			log.Debug("llmnr disabled, cannot select multicast interface", zap.Error(err))
+			llmnr = false
+		}
+	}
+
+	sendCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.sweep(sendCtx, conn, llmnr, log)
+	}()
+
+	err = s.read(ctx, conn, out)
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// sweep sends the broadcast node status query and the per host queries.
+func (s *Scanner) sweep(ctx context.Context, conn *net.UDPConn, llmnr bool, log *zap.Logger) {
+	subnet := s.iface.IPv4Net
+	nbstat := NodeStatusRequest(uint16(rand.N(1 << 16)))
+	if bcast := discovery.BroadcastAddr(subnet); bcast != nil {
+		if _, err := conn.WriteToUDP(nbstat, &net.UDPAddr{IP: bcast, Port: nbnsPort}); err != nil {
+			log.Debug("broadcast node status query failed", zap.Error(err))
+		}
+	}
+
+	hosts := discovery.SubnetHosts(subnet, maxSweepHosts)
+	if hosts == nil {
+		log.Debug("subnet too large to query every host", zap.Stringer("subnet", subnet))
+		return
+	}
+	group := &net.UDPAddr{IP: net.ParseIP(llmnrMulticastAddress), Port: llmnrPort}
+	for i, ip := range hosts {
+		if ip.Equal(*s.iface.IPv4Addr) {
+			continue
+		}
+		if i > 0 && i%sweepBatch == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(sweepPause):
+			}
+		}
+		_, _ = conn.WriteToUDP(nbstat, &net.UDPAddr{IP: ip, Port: nbnsPort})
+		if !llmnr {
+			continue
+		}
+		if query, err := reverseQuery(uint16(rand.N(1<<16)), ip); err == nil {
+			_, _ = conn.WriteToUDP(query, group)
+		}
+	}
+}
+
+// read emits a device for the first NBNS and LLMNR answer of every host.
+func (s *Scanner) read(ctx context.Context, conn *net.UDPConn, out chan<- discovery.Device) error {
+	seen := map[string]bool{}
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("read: %w", err)
+		}
+
+		var d discovery.Device
+		var ok bool
+		switch src.Port {
+		case nbnsPort:
+			d, ok = deviceFromNodeStatus(src.IP, buf[:n])
+		case llmnrPort:
+			d, ok = deviceFromReverse(buf[:n])
+		}
+		if !ok || s.iface.SubnetOf(d.IP) == "" {
+			continue
+		}
+		key := fmt.Sprintf("%d/%s", src.Port, d.IP)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		select {
+		case out <- d:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// deviceFromNodeStatus builds the device of a node status response sent by ip.
+func deviceFromNodeStatus(ip net.IP, b []byte) (discovery.Device, bool) {
+	status, err := ParseNodeStatus(b)
+	if err != nil {
+		return discovery.Device{}, false
+	}
+	name := status.Workstation()
+	if name == "" {
+		return discovery.Device{}, false
+	}
+	d := discovery.NewDevice(ip.To4())
+	d.Sources[Name] = struct{}{}
+	d.NetBIOSName = name
+	d.DisplayName = name
+	if status.MAC != nil {
+		d.MAC = status.MAC.String()
+	}
+	if wg := status.Workgroup(); wg != "" {
+		d.ExtraData[ExtraWorkgroup] = wg
+	}
+	return d, true
+}
+
+// deviceFromReverse builds the device of an LLMNR PTR response.
+func deviceFromReverse(b []byte) (discovery.Device, bool) {
+	ip, host, ok := parseReverseResponse(b)
+	if !ok {
+		return discovery.Device{}, false
+	}
+	d := discovery.NewDevice(ip)
+	d.Sources[SourceLLMNR] = struct{}{}
+	d.Hostname = host
+	d.DisplayName = host
+	return d, true
+}
@@ -0,0 +1,34 @@
+package netbios
+
+import (
+	"fmt"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+// Config is the `scanners.netbios` config block.
+type Config struct {
+	config.ScannerToggle `yaml:",inline"`
+	// LLMNR also sends an LLMNR reverse lookup (224.0.0.252:5355) for every host of the subnet.
+	LLMNR bool `yaml:"llmnr"`
+}
+
+func init() {
+	discovery.Register(discovery.Registration{
+		Name:           Name,
+		Description:    "NetBIOS node status and LLMNR reverse lookups, names Windows hosts",
+		DefaultEnabled: true,
+		Sources:        []string{SourceLLMNR},
+		NewConfig: func() config.ScannerSettings {
+			return &Config{LLMNR: true}
+		},
+		New: func(iface *discovery.InterfaceInfo, settings config.ScannerSettings) (discovery.Scanner, error) {
+			cfg, ok := settings.(*Config)
+			if !ok {
+				return nil, fmt.Errorf("unexpected config type %T", settings)
+			}
+			return NewScanner(iface, cfg.LLMNR), nil
+		},
+	})
+}
@@ -1,21 +1,42 @@
 package discovery
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
+	"sync"
 
 	"go.uber.org/zap"
 )
 
 // InterfaceInfo holds the essential network interface information for scanning
 type InterfaceInfo struct {
-	Interface *net.Interface // Network interface
-	IPv4Addr  *net.IP        // IPv4 host address used by the interface
-	IPv4Net   *net.IPNet     // Subnet (e.g., 192.168.1.0/24)
+	Interface     *net.Interface // Network interface
+	IPv4Addr      *net.IP        // IPv4 host address used by the interface, nil on IPv6-only links
+	IPv4Net       *net.IPNet     // Subnet (e.g., 192.168.1.0/24)
+	IPv6LinkLocal *net.IP        // fe80::/10 address, used as source for link-local multicast
+	IPv6Addrs     []*net.IPNet   // global and unique local IPv6 addresses with their prefix
+}
+
+// IPFamily is an IP address family supported by an interface.
+type IPFamily int
+
+const (
+	IPv4 IPFamily = 4
+	IPv6 IPFamily = 6
+)
+
+func (f IPFamily) String() string {
+	if f == IPv6 {
+		return "ipv6"
+	}
+	return "ipv4"
 }
 
 // NewInterfaceInfo creates InterfaceInfo from a net.Interface
-// It returns an error if the interface has no IPv4 address.
+// It returns an error if the interface has neither an IPv4 nor an IPv6 address.
 // This makes sure that every scanner has the necessary information to perform network scans.
 // And makes interface handling consistent and swappable.
 func NewInterfaceInfo(interfaceName string) (*InterfaceInfo, error) {
@@ -31,20 +52,260 @@ func NewInterfaceInfo(interfaceName string) (*InterfaceInfo, error) {
 	}
 
 	for _, addr := range addresses {
-		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
-			info.IPv4Addr = &ipnet.IP
-			info.IPv4Net = ipnet
-			break
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		switch {
+		case ipnet.IP.To4() != nil:
+			if info.IPv4Addr == nil {
+				info.IPv4Addr = &ipnet.IP
+				info.IPv4Net = ipnet
+			}
+		case ipnet.IP.IsLinkLocalUnicast():
+			if info.IPv6LinkLocal == nil {
+				info.IPv6LinkLocal = &ipnet.IP
+			}
+		case ipnet.IP.IsGlobalUnicast():
+			info.IPv6Addrs = append(info.IPv6Addrs, ipnet)
 		}
 	}
 
-	if info.IPv4Addr == nil {
-		return nil, fmt.Errorf("interface %s has no IPv4 address", iface.Name)
+	if info.IPv4Addr == nil && info.IPv6LinkLocal == nil && len(info.IPv6Addrs) == 0 {
+		return nil, fmt.Errorf("interface %s has no IPv4 or IPv6 address", iface.Name)
 	}
 
 	return info, nil
 }
 
+// NewInterfaceInfos resolves every named interface, the OS default interface when names is empty.
+func NewInterfaceInfos(names []string) ([]*InterfaceInfo, error) {
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	infos := make([]*InterfaceInfo, 0, len(names))
+	for _, name := range names {
+		info, err := NewInterfaceInfo(name)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Families returns the address families that can be scanned on the interface.
+// IPv6 link-local multicast needs a link-local source address.
+func (i *InterfaceInfo) Families() []IPFamily {
+	var fams []IPFamily
+	if i.IPv4Addr != nil {
+		fams = append(fams, IPv4)
+	}
+	if i.IPv6LinkLocal != nil {
+		fams = append(fams, IPv6)
+	}
+	return fams
+}
+
+// LocalUDPAddr returns the address to bind multicast sockets of family to, port 0.
+func (i *InterfaceInfo) LocalUDPAddr(f IPFamily) *net.UDPAddr {
+	if f == IPv6 {
+		if i.IPv6LinkLocal == nil {
+			return nil
+		}
+		return &net.UDPAddr{IP: *i.IPv6LinkLocal, Zone: i.Interface.Name}
+	}
+	if i.IPv4Addr == nil {
+		return nil
+	}
+	return &net.UDPAddr{IP: *i.IPv4Addr}
+}
+
+// LocalIP returns the address the interface uses to reach ip, nil when the
+// interface has no address of the same family.
+func (i *InterfaceInfo) LocalIP(ip net.IP) net.IP {
+	if ip.To4() != nil {
+		if i.IPv4Addr == nil {
+			return nil
+		}
+		return *i.IPv4Addr
+	}
+	if !ip.IsLinkLocalUnicast() {
+		for _, n := range i.IPv6Addrs {
+			if n.Contains(ip) {
+				return n.IP
+			}
+		}
+		if len(i.IPv6Addrs) > 0 {
+			return i.IPv6Addrs[0].IP
+		}
+	}
+	if i.IPv6LinkLocal != nil {
+		return *i.IPv6LinkLocal
+	}
+	return nil
+}
+
+// PointToPoint reports whether the interface is a point-to-point link (e.g. a VPN
+// tunnel), which has no ARP and usually no broadcast or multicast.
+func (i *InterfaceInfo) PointToPoint() bool {
+	return i.Interface != nil && i.Interface.Flags&net.FlagPointToPoint != 0
+}
+
+// RoutesTo reports whether the route to host leaves through the interface. It connects
+// a UDP socket to host, which selects the source address without sending a packet.
+func (i *InterfaceInfo) RoutesTo(host string) bool {
+	conn, err := net.Dial("udp", net.JoinHostPort(host, "9"))
+	if err != nil {
+		return false
+	}
+	defer func() { _ = conn.Close() }()
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	for _, ip := range i.Addresses() {
+		if ip.Equal(local) {
+			return true
+		}
+	}
+	return false
+}
+
+// HostWithZone appends the interface zone to IPv6 link-local addresses ("fe80::1%eth0"),
+// other addresses are returned unchanged.
+func (i *InterfaceInfo) HostWithZone(host string) string {
+	ip := net.ParseIP(host)
+	if ip == nil || ip.To4() != nil || !ip.IsLinkLocalUnicast() || i.Interface == nil {
+		return host
+	}
+	return host + "%" + i.Interface.Name
+}
+
+// SubnetOf returns the on-link prefix of the interface containing ip (e.g. "192.168.1.0/24"),
+// "" when ip is not on a directly connected subnet.
+func (i *InterfaceInfo) SubnetOf(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	nets := i.IPv6Addrs
+	if i.IPv4Net != nil {
+		nets = append([]*net.IPNet{i.IPv4Net}, nets...)
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return (&net.IPNet{IP: n.IP.Mask(n.Mask), Mask: n.Mask}).String()
+		}
+	}
+	if ip.To4() == nil && ip.IsLinkLocalUnicast() && i.IPv6LinkLocal != nil {
+		return "fe80::/64"
+	}
+	return ""
+}
+
+// PrimaryIP returns the IPv4 address, or the first IPv6 address on IPv6-only links.
+func (i *InterfaceInfo) PrimaryIP() net.IP {
+	switch {
+	case i.IPv4Addr != nil:
+		return *i.IPv4Addr
+	case len(i.IPv6Addrs) > 0:
+		return i.IPv6Addrs[0].IP
+	case i.IPv6LinkLocal != nil:
+		return *i.IPv6LinkLocal
+	}
+	return nil
+}
+
+// Addresses returns every address configured on the interface.
+func (i *InterfaceInfo) Addresses() []net.IP {
+	var out []net.IP
+	if i.IPv4Addr != nil {
+		out = append(out, *i.IPv4Addr)
+	}
+	for _, n := range i.IPv6Addrs {
+		out = append(out, n.IP)
+	}
+	if i.IPv6LinkLocal != nil {
+		out = append(out, *i.IPv6LinkLocal)
+	}
+	return out
+}
+
+// BroadcastAddr returns the directed broadcast address of an IPv4 subnet, nil for IPv6.
+func BroadcastAddr(subnet *net.IPNet) net.IP {
+	ip := subnet.IP.To4()
+	if ip == nil || len(subnet.Mask) != net.IPv4len {
+		return nil
+	}
+	out := make(net.IP, net.IPv4len)
+	for i := range ip {
+		out[i] = ip[i] | ^subnet.Mask[i]
+	}
+	return out
+}
+
+// SubnetHosts returns the host addresses of an IPv4 subnet, without the network and
+// broadcast address. It returns nil for IPv6 and for subnets with more than limit hosts.
+func SubnetHosts(subnet *net.IPNet, limit int) []net.IP {
+	ip := subnet.IP.To4()
+	ones, bits := subnet.Mask.Size()
+	if ip == nil || bits != 8*net.IPv4len {
+		return nil
+	}
+	size := uint64(1) << (bits - ones)
+	first, last := uint64(1), size-2
+	if size <= 2 {
+		// /31 and /32 have no network and broadcast address (RFC 3021)
+		first, last = 0, size-1
+	}
+	if last+1-first > uint64(limit) {
+		return nil
+	}
+	base := uint64(binary.BigEndian.Uint32(ip.Mask(subnet.Mask)))
+	hosts := make([]net.IP, 0, last+1-first)
+	for n := first; n <= last; n++ {
+		host := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(host, uint32(base+n))
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// ScanFamilies runs fn concurrently for every family of the interface. A failing
+// family is logged, an error is only returned when every family failed.
+func ScanFamilies(iface *InterfaceInfo, scanner string, fn func(IPFamily) error) error {
+	fams := iface.Families()
+	if len(fams) == 0 {
+		return fmt.Errorf("interface %s has no usable address", iface.Interface.Name)
+	}
+
+	errs := make([]error, len(fams))
+	var wg sync.WaitGroup
+	for i, f := range fams {
+		wg.Add(1)
+		go func(i int, f IPFamily) {
+			defer wg.Done()
+			errs[i] = fn(f)
+		}(i, f)
+	}
+	wg.Wait()
+
+	var failed []error
+	for i, err := range errs {
+		if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			continue
+		}
+		zap.L().Debug("scan failed for address family", zap.String("scanner", scanner), zap.Stringer("family", fams[i]), zap.Error(err))
+		failed = append(failed, fmt.Errorf("%s: %w", fams[i], err))
+	}
+	if len(failed) == len(fams) {
+		return errors.Join(failed...)
+	}
+	for _, err := range errs {
+		if err != nil && (errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)) {
+			return err
+		}
+	}
+	return nil
+}
+
 // getNetworkInterface returns the network interface by name.
 // If interfaceName is empty, it attempts to return the OS default network interface.
 func getNetworkInterface(interfaceName string) (*net.Interface, error) {
@@ -69,7 +330,7 @@ func getNetworkInterface(interfaceName string) (*net.Interface, error) {
 
 // isLANSuitable returns true if the interface is suitable for LAN discovery.
 // It filters out loopback, down, point-to-point (VPN/TUN), and interfaces
-// without a usable IPv4 subnet (e.g. /32).
+// without a usable IPv4 subnet (e.g. /32) or IPv6 link-local address.
 func isLANSuitable(iface net.Interface) bool {
 	if iface.Flags&net.FlagUp == 0 {
 		return false
@@ -87,14 +348,21 @@ func isLANSuitable(iface net.Interface) bool {
 	if iface.Flags&net.FlagBroadcast == 0 {
 		return false
 	}
-	// Must have at least one IPv4 address with a subnet larger than /31
+	// Must have at least one IPv4 address with a subnet larger than /31,
+	// or an IPv6 link-local address on IPv6-only links
 	addrs, err := iface.Addrs()
 	if err != nil {
 		return false
 	}
 	for _, addr := range addrs {
 		ipnet, ok := addr.(*net.IPNet)
-		if !ok || ipnet.IP.To4() == nil {
+		if !ok {
+			continue
+		}
+		if ipnet.IP.To4() == nil {
+			if ipnet.IP.IsLinkLocalUnicast() {
+				return true
+			}
 			continue
 		}
 		ones, _ := ipnet.Mask.Size()
@@ -110,12 +378,13 @@ type InterfaceEntry struct {
 	Name   string
 	IPv4   string
 	Subnet string
+	IPv6   string
 	MAC    string
 	Flags  string
 	IsVPN  bool
 }
 
-// ListAllInterfaces returns all non-loopback, up interfaces with IPv4 addresses,
+// ListAllInterfaces returns all non-loopback, up interfaces with IPv4 or IPv6 addresses,
 // marking VPN/TUN interfaces so the UI can display them distinctly.
 func ListAllInterfaces() []InterfaceEntry {
 	interfaces, err := net.Interfaces()
@@ -131,36 +400,54 @@ func ListAllInterfaces() []InterfaceEntry {
 		if err != nil {
 			continue
 		}
+		entry := InterfaceEntry{
+			Name:  iface.Name,
+			MAC:   iface.HardwareAddr.String(),
+			IsVPN: iface.Flags&net.FlagPointToPoint != 0 || iface.Flags&net.FlagBroadcast == 0,
+		}
+		var linkLocal string
 		for _, addr := range addrs {
 			ipnet, ok := addr.(*net.IPNet)
-			if !ok || ipnet.IP.To4() == nil {
+			if !ok {
 				continue
 			}
-			isVPN := iface.Flags&net.FlagPointToPoint != 0 || iface.Flags&net.FlagBroadcast == 0
-			entry := InterfaceEntry{
-				Name:   iface.Name,
-				IPv4:   ipnet.IP.String(),
-				Subnet: ipnet.String(),
-				MAC:    iface.HardwareAddr.String(),
-				IsVPN:  isVPN,
-			}
-			// Build flags description
-			var flags []string
-			if iface.Flags&net.FlagBroadcast != 0 {
-				flags = append(flags, "broadcast")
-			}
-			if iface.Flags&net.FlagPointToPoint != 0 {
-				flags = append(flags, "point-to-point")
+			switch {
+			case ipnet.IP.To4() != nil:
+				if entry.IPv4 == "" {
+					entry.IPv4 = ipnet.IP.String()
+					entry.Subnet = ipnet.String()
+				}
+			case ipnet.IP.IsLinkLocalUnicast():
+				if linkLocal == "" {
+					linkLocal = ipnet.IP.String()
+				}
+			case ipnet.IP.IsGlobalUnicast():
+				if entry.IPv6 == "" {
+					entry.IPv6 = ipnet.String()
+				}
 			}
-			if iface.Flags&net.FlagMulticast != 0 {
-				flags = append(flags, "multicast")
-			}
-			if len(flags) > 0 {
-				entry.Flags = fmt.Sprintf("[%s]", joinStrings(flags, ", "))
-			}
-			result = append(result, entry)
-			break // one entry per interface
 		}
+		if entry.IPv6 == "" {
+			entry.IPv6 = linkLocal
+		}
+		if entry.IPv4 == "" && entry.IPv6 == "" {
+			continue
+		}
+		// Build flags description
+		var flags []string
+		if iface.Flags&net.FlagBroadcast != 0 {
+			flags = append(flags, "broadcast")
+		}
+		if iface.Flags&net.FlagPointToPoint != 0 {
+			flags = append(flags, "point-to-point")
+		}
+		if iface.Flags&net.FlagMulticast != 0 {
+			flags = append(flags, "multicast")
+		}
+		if len(flags) > 0 {
+			entry.Flags = fmt.Sprintf("[%s]", joinStrings(flags, ", "))
+		}
+		result = append(result, entry)
 	}
 	return result
 }
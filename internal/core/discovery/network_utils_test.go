@@ -0,0 +1,149 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+func dualStackInterface() *InterfaceInfo {
+	v4 := net.ParseIP("192.168.1.10").To4()
+	ll := net.ParseIP("fe80::1")
+	_, global, _ := net.ParseCIDR("2001:db8::/64")
+	global.IP = net.ParseIP("2001:db8::10")
+	return &InterfaceInfo{
+		Interface:     &net.Interface{Name: "eth0", Index: 2},
+		IPv4Addr:      &v4,
+		IPv6LinkLocal: &ll,
+		IPv6Addrs:     []*net.IPNet{global},
+	}
+}
+
+func TestInterfaceInfoLocalIP(t *testing.T) {
+	iface := dualStackInterface()
+	tests := map[string]string{
+		"192.168.1.20":   "192.168.1.10",
+		"fe80::2":        "fe80::1",
+		"2001:db8::20":   "2001:db8::10",
+		"2001:db9::20":   "2001:db8::10",
+		"::ffff:1.2.3.4": "192.168.1.10",
+	}
+	for target, want := range tests {
+		if got := iface.LocalIP(net.ParseIP(target)); !got.Equal(net.ParseIP(want)) {
+			t.Errorf("LocalIP(%s) = %s, want %s", target, got, want)
+		}
+	}
+
+	v6Only := &InterfaceInfo{Interface: iface.Interface, IPv6LinkLocal: iface.IPv6LinkLocal}
+	if got := v6Only.LocalIP(net.ParseIP("192.168.1.20")); got != nil {
+		t.Errorf("expected no IPv4 source on an IPv6-only link, got %s", got)
+	}
+	if got := v6Only.PrimaryIP(); !got.Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("expected link-local primary, got %s", got)
+	}
+}
+
+func TestInterfaceInfoFamilies(t *testing.T) {
+	iface := dualStackInterface()
+	if got := iface.Families(); len(got) != 2 || got[0] != IPv4 || got[1] != IPv6 {
+		t.Errorf("expected [ipv4 ipv6], got %v", got)
+	}
+	if addr := iface.LocalUDPAddr(IPv6); addr.Zone != "eth0" || !addr.IP.Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("unexpected IPv6 bind address %v", addr)
+	}
+	if got := iface.HostWithZone("fe80::2"); got != "fe80::2%eth0" {
+		t.Errorf("expected zone on link-local host, got %s", got)
+	}
+	if got := iface.HostWithZone("192.168.1.20"); got != "192.168.1.20" {
+		t.Errorf("expected IPv4 host unchanged, got %s", got)
+	}
+	if got := len(iface.Addresses()); got != 3 {
+		t.Errorf("expected 3 addresses, got %d", got)
+	}
+}
+
+func TestScanFamilies(t *testing.T) {
+	iface := dualStackInterface()
+
+	var calls atomic.Int32
+	err := ScanFamilies(iface, "test", func(f IPFamily) error {
+		calls.Add(1)
+		if f == IPv6 {
+			return errors.New("no route")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("expected partial failure to be tolerated, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected both families to be scanned, got %d", calls.Load())
+	}
+
+	err = ScanFamilies(iface, "test", func(IPFamily) error { return errors.New("boom") })
+	if err == nil {
+		t.Errorf("expected an error when every family fails")
+	}
+
+	err = ScanFamilies(iface, "test", func(IPFamily) error { return context.DeadlineExceeded })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context error to be returned, got %v", err)
+	}
+}
+
+func TestInterfaceInfoSubnetOf(t *testing.T) {
+	iface := dualStackInterface()
+	_, v4, _ := net.ParseCIDR("192.168.1.0/24")
+	v4.IP = *iface.IPv4Addr
+	iface.IPv4Net = v4
+
+	tests := map[string]string{
+		"192.168.1.20": "192.168.1.0/24",
+		"2001:db8::20": "2001:db8::/64",
+		"fe80::20":     "fe80::/64",
+		"10.0.0.1":     "",
+	}
+	for ip, want := range tests {
+		if got := iface.SubnetOf(net.ParseIP(ip)); got != want {
+			t.Errorf("SubnetOf(%s) = %q, want %q", ip, got, want)
+		}
+	}
+}
+
+func TestSubnetHosts(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("192.168.1.0/29")
+	hosts := SubnetHosts(subnet, 256)
+	if len(hosts) != 6 || !hosts[0].Equal(net.ParseIP("192.168.1.1")) || !hosts[5].Equal(net.ParseIP("192.168.1.6")) {
+		t.Errorf("expected .1 to .6, got %v", hosts)
+	}
+	if got := BroadcastAddr(subnet); !got.Equal(net.ParseIP("192.168.1.7")) {
+		t.Errorf("expected broadcast 192.168.1.7, got %v", got)
+	}
+
+	_, p2p, _ := net.ParseCIDR("10.0.0.0/31")
+	if hosts := SubnetHosts(p2p, 256); len(hosts) != 2 {
+		t.Errorf("expected both addresses of a /31, got %v", hosts)
+	}
+
+	_, large, _ := net.ParseCIDR("10.0.0.0/16")
+	if hosts := SubnetHosts(large, 1024); hosts != nil {
+		t.Errorf("expected nil above the limit, got %d hosts", len(hosts))
+	}
+
+	_, v6, _ := net.ParseCIDR("fd00::/120")
+	if hosts := SubnetHosts(v6, 1024); hosts != nil || BroadcastAddr(v6) != nil {
+		t.Errorf("expected nil for IPv6")
+	}
+}
+
+func TestInterfaceInfoRoutesTo(t *testing.T) {
+	loopback := net.IPv4(127, 0, 0, 1)
+	if !(&InterfaceInfo{IPv4Addr: &loopback}).RoutesTo("127.0.0.1") {
+		t.Error("expected loopback target to route through the loopback address")
+	}
+	if dualStackInterface().RoutesTo("127.0.0.1") {
+		t.Error("expected loopback target not to route through the LAN interface")
+	}
+}
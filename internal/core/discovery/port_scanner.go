@@ -2,9 +2,12 @@ package discovery
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"net"
-	"sync"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -15,15 +18,18 @@ type Dialer interface {
 
 // PortScanner scans ports on a given IP address.
 type PortScanner struct {
-	workers int
+	timing  Timing
+	limiter *rateLimiter // shared by every probe, nil is unlimited
 	dialer  Dialer
 	iface   *InterfaceInfo
 }
 
-// NewPortScanner creates a new PortScanner.
-func NewPortScanner(workers int, iface *InterfaceInfo) *PortScanner {
+// NewPortScanner creates a PortScanner probing with the given timing.
+func NewPortScanner(timing Timing, iface *InterfaceInfo) *PortScanner {
+	timing = timing.normalize()
 	return &PortScanner{
-		workers: workers,
+		timing:  timing,
+		limiter: newRateLimiter(timing.Rate),
 		dialer:  &netDialer{iface: iface},
 		iface:   iface,
 	}
@@ -36,81 +42,158 @@ type netDialer struct {
 
 func (d *netDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
 	var dialer net.Dialer
-	dialer.LocalAddr = &net.TCPAddr{IP: *d.iface.IPv4Addr}
+	udp := strings.HasPrefix(network, "udp")
+	if host, _, err := net.SplitHostPort(address); err == nil && d.iface != nil {
+		// bind to the interface address of the same family as the target
+		if ip, err := netip.ParseAddr(host); err == nil {
+			if local := d.iface.LocalIP(net.IP(ip.Unmap().AsSlice())); local != nil {
+				if udp {
+					dialer.LocalAddr = &net.UDPAddr{IP: local, Zone: ip.Zone()}
+				} else {
+					dialer.LocalAddr = &net.TCPAddr{IP: local, Zone: ip.Zone()}
+				}
+			}
+		}
+	}
+	if udp {
+		dialer.Control = udpControl
+	}
 	return dialer.DialContext(ctx, network, address)
 }
 
-// Stream scans the TCP ports on the given IP and calls the callback for each open port found.
-// It uses the provided context for cancellation.
-func (ps *PortScanner) Stream(ctx context.Context, ip string, ports []int, timeout time.Duration, callback func(int)) error {
-	if len(ports) == 0 {
-		return nil
-	}
+// PortState is the state of a scanned UDP port.
+type PortState int
 
-	var wg sync.WaitGroup
-	portChan := make(chan int, ps.workers)
-	errChan := make(chan error, 1)
-
-	// Worker pool
-	for i := 0; i < ps.workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			ps.streamWorker(ctx, ip, portChan, callback, timeout)
-		}()
+const (
+	PortClosed       PortState = iota // ICMP port unreachable
+	PortFiltered                      // another ICMP unreachable, e.g. administratively prohibited
+	PortOpenFiltered                  // no reply, the service ignored the probe or a firewall dropped it
+	PortOpen                          // the service answered the probe
+)
+
+// OpenPorts keys of the scanned protocols.
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+func (s PortState) String() string {
+	switch s {
+	case PortClosed:
+		return "closed"
+	case PortFiltered:
+		return "filtered"
+	case PortOpenFiltered:
+		return "open|filtered"
+	default:
+		return "open"
 	}
+}
 
-	// Feed ports to workers in background
-	go func() {
-		defer close(portChan)
-		for _, port := range ports {
-			select {
-			case portChan <- port:
-			case <-ctx.Done():
-				errChan <- ctx.Err()
-				return
-			}
+// isPortOpen checks if a TCP port is open using context-aware dialing. A port that does
+// not answer in time is dialed again, up to the retries of the timing.
+func (ps *PortScanner) isPortOpen(ctx context.Context, ip string, port int, rtt *rttEstimator) bool {
+	for attempt := 0; attempt <= ps.timing.Retries; attempt++ {
+		if ps.limiter.wait(ctx) != nil {
+			return false
+		}
+		open, answered := ps.dialTCP(ctx, ip, port, rtt.timeout(attempt), rtt)
+		if answered || ctx.Err() != nil {
+			return open
 		}
-	}()
+	}
+	return false
+}
 
-	// Wait for completion
-	go func() {
-		wg.Wait()
-		close(errChan)
-	}()
+// dialTCP connects to the port once, answered is false when it timed out. The round trip
+// of an answer, an accepted connection or a reset, is added to rtt.
+func (ps *PortScanner) dialTCP(ctx context.Context, ip string, port int, timeout time.Duration, rtt *rttEstimator) (open, answered bool) {
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	conn, err := ps.dialer.DialContext(dialCtx, "tcp", net.JoinHostPort(ps.host(ip), strconv.Itoa(port)))
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			rtt.observe(time.Since(start))
+		}
+		return false, !isTimeout(err)
+	}
+	rtt.observe(time.Since(start))
+	return conn.Close() == nil, true
+}
 
-	// Return first error if any
-	if err := <-errChan; err != nil {
-		return err
+// udpPortState probes the port until it answers, up to the retries of the timing. A port
+// that never answers is open|filtered.
+func (ps *PortScanner) udpPortState(ctx context.Context, ip string, port int, rtt *rttEstimator) PortState {
+	state := PortOpenFiltered
+	for attempt := 0; attempt <= ps.timing.Retries && state == PortOpenFiltered; attempt++ {
+		if ps.limiter.wait(ctx) != nil {
+			break
+		}
+		state = ps.probeUDP(ctx, ip, port, rtt.udpTimeout(attempt), rtt)
 	}
-	return ctx.Err()
+	return state
 }
 
-// streamWorker performs the actual port scanning for streaming.
-func (ps *PortScanner) streamWorker(ctx context.Context, ip string, ports <-chan int, callback func(int), timeout time.Duration) {
+// probeUDP sends the probe of the port and waits for the reply until timeout. The socket
+// is connected, so the OS reports an ICMP port unreachable as a refused read. The round
+// trip of a reply or port unreachable is added to rtt.
+func (ps *PortScanner) probeUDP(ctx context.Context, ip string, port int, timeout time.Duration, rtt *rttEstimator) PortState {
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := ps.dialer.DialContext(dialCtx, "udp", net.JoinHostPort(ps.host(ip), strconv.Itoa(port)))
+	if err != nil {
+		return PortFiltered
+	}
+	defer func() { _ = conn.Close() }()
+
+	deadline, _ := dialCtx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	probe := probeFor(port)
+	req := probe.payload()
+	start := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return writeState(err)
+	}
+
+	buf := make([]byte, 1500)
 	for {
-		select {
-		case <-ctx.Done():
-			return
-		case port, ok := <-ports:
-			if !ok {
-				return
+		n, err := conn.Read(buf)
+		if err != nil {
+			if isTimeout(err) {
+				return PortOpenFiltered
 			}
-			if ps.isPortOpen(ctx, ip, port, timeout) {
-				callback(port)
+			state := writeState(err)
+			if state == PortClosed {
+				rtt.observe(time.Since(start))
 			}
+			return state
+		}
+		if probe.valid == nil || probe.valid(req, buf[:n]) {
+			rtt.observe(time.Since(start))
+			return PortOpen
 		}
 	}
 }
 
-// isPortOpen checks if a TCP port is open using context-aware dialing.
-func (ps *PortScanner) isPortOpen(ctx context.Context, ip string, port int, timeout time.Duration) bool {
-	dialCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-	conn, err := ps.dialer.DialContext(dialCtx, "tcp", fmt.Sprintf("%s:%d", ip, port))
-	if err != nil {
-		return false
+// isTimeout reports whether err is a timeout, the probe or its answer may have been dropped.
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &ne) && ne.Timeout()
+}
+
+// writeState maps a socket error to the port state, refused means port unreachable.
+func writeState(err error) PortState {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return PortClosed
+	}
+	return PortFiltered
+}
+
+// host adds the interface zone to IPv6 link-local targets, they are not routable without it.
+func (ps *PortScanner) host(ip string) string {
+	if ps.iface == nil || ps.iface.Interface == nil {
+		return ip
 	}
-	err = conn.Close()
-	return err == nil
+	return ps.iface.HostWithZone(ip)
 }
@@ -0,0 +1,24 @@
+package discovery
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// udpControl enables IP_RECVERR on UDP port scan sockets, so every ICMP error for the
+// connected socket, not only port unreachable, is reported on the next read.
+func udpControl(network, _ string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		if network == "udp6" {
+			sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_RECVERR, 1)
+			return
+		}
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_RECVERR, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package discovery
+
+import "syscall"
+
+// udpControl is nil outside Linux, connected UDP sockets report port unreachable without options.
+var udpControl func(network, address string, c syscall.RawConn) error
@@ -3,7 +3,6 @@ package discovery
 import (
 	"context"
 	"net"
-	"sync"
 	"testing"
 	"time"
 )
@@ -32,7 +31,7 @@ func (m *mockDialer) DialContext(ctx context.Context, network, address string) (
 	return nil, net.ErrClosed // simulate closed
 }
 
-func TestPortScanner_Stream(t *testing.T) {
+func TestPortScanner_IsPortOpen(t *testing.T) {
 	mock := &mockDialer{
 		openPorts: map[string]bool{
 			"127.0.0.1:80":  true,
@@ -40,53 +39,62 @@ func TestPortScanner_Stream(t *testing.T) {
 		},
 	}
 	ps := &PortScanner{
-		workers: 2,
-		dialer:  mock,
+		timing: Timing{Workers: 2, MaxTimeout: 100 * time.Millisecond},
+		dialer: mock,
 	}
 
-	ports := []int{22, 80, 443, 8080}
-	var openPorts []int
-	var mu sync.Mutex
-	err := ps.Stream(context.Background(), "127.0.0.1", ports, 100*time.Millisecond, func(port int) {
-		mu.Lock()
-		openPorts = append(openPorts, port)
-		mu.Unlock()
-	})
-	if err != nil {
-		t.Fatalf("Stream failed: %v", err)
+	rtt := newRTTEstimator(0, ps.timing.normalize())
+	for port, want := range map[int]bool{22: false, 80: true, 443: true, 8080: false} {
+		if got := ps.isPortOpen(context.Background(), "127.0.0.1", port, rtt); got != want {
+			t.Errorf("port %d: expected open %v, got %v", port, want, got)
+		}
 	}
+}
 
-	expected := []int{80, 443}
-	if len(openPorts) != len(expected) {
-		t.Errorf("expected %d open ports, got %d", len(expected), len(openPorts))
+// udpListener starts a UDP listener on loopback answering every datagram with reply, none
+// when reply is nil or returns nil.
+func udpListener(t *testing.T, reply func(req []byte) []byte) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
 	}
-	for _, p := range expected {
-		found := false
-		for _, op := range openPorts {
-			if op == p {
-				found = true
-				break
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if reply == nil {
+				continue
+			}
+			if resp := reply(buf[:n]); resp != nil {
+				_, _ = conn.WriteTo(resp, addr)
 			}
 		}
-		if !found {
-			t.Errorf("expected port %d to be open", p)
-		}
-	}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
 }
 
-func TestPortScanner_Stream_EmptyPorts(t *testing.T) {
-	ps := NewPortScanner(1, nil)
-	var openPorts []int
-	var mu sync.Mutex
-	err := ps.Stream(context.Background(), "127.0.0.1", []int{}, 100*time.Millisecond, func(port int) {
-		mu.Lock()
-		openPorts = append(openPorts, port)
-		mu.Unlock()
-	})
+func TestPortScanner_UDPPortState(t *testing.T) {
+	open := udpListener(t, func(req []byte) []byte { return []byte("pong") })
+	silent := udpListener(t, nil)
+
+	// a port that was just released is closed and answers with port unreachable
+	released, err := net.ListenPacket("udp4", "127.0.0.1:0")
 	if err != nil {
-		t.Fatalf("Stream failed: %v", err)
+		t.Fatal(err)
 	}
-	if len(openPorts) != 0 {
-		t.Errorf("expected no open ports, got %d", len(openPorts))
+	closed := released.LocalAddr().(*net.UDPAddr).Port
+	_ = released.Close()
+
+	ps := NewPortScanner(Timing{Workers: 3, MaxTimeout: 200 * time.Millisecond}, nil)
+	rtt := newRTTEstimator(0, ps.timing)
+	for port, want := range map[int]PortState{open: PortOpen, silent: PortOpenFiltered, closed: PortClosed} {
+		if got := ps.udpPortState(context.Background(), "127.0.0.1", port, rtt); got != want {
+			t.Errorf("port %d: expected %v, got %v", port, want, got)
+		}
 	}
 }
@@ -0,0 +1,170 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+)
+
+// Timing controls how fast and how patiently a port scan probes.
+type Timing struct {
+	Workers    int           // concurrent probes
+	Rate       int           // probes sent per second, retries included, 0 is unlimited
+	MinTimeout time.Duration // lower bound of the adaptive timeout, the timeout is fixed at MaxTimeout when 0
+	MaxTimeout time.Duration // upper bound of the adaptive timeout, the timeout until a round trip was measured
+	Retries    int           // extra probes of a port that did not answer
+}
+
+// udpMinTimeout is the shortest timeout of a UDP probe. UDP services answer from user space,
+// often after work of their own (e.g. a DNS lookup), much later than the round trip of the
+// host, so a host that answers TCP fast does not make its UDP ports time out early.
+const udpMinTimeout = time.Second
+
+// timingProfiles are the timings of the config profiles. Polite keeps fragile IoT devices
+// responsive, aggressive trades accuracy on slow or filtered hosts for speed.
+var timingProfiles = map[config.TimingProfile]Timing{
+	config.TimingPolite:     {Workers: 10, Rate: 50, MinTimeout: 500 * time.Millisecond, MaxTimeout: 10 * time.Second, Retries: 2},
+	config.TimingNormal:     {Workers: 100, MinTimeout: 100 * time.Millisecond, MaxTimeout: 5 * time.Second, Retries: 1},
+	config.TimingAggressive: {Workers: 500, MinTimeout: 50 * time.Millisecond, MaxTimeout: time.Second},
+}
+
+// TimingFromConfig returns the timing of the configured profile, with the workers, rate
+// and longest timeout of cfg when set.
+func TimingFromConfig(cfg config.PortScannerConfig) Timing {
+	t, ok := timingProfiles[cfg.Timing]
+	if !ok {
+		t = timingProfiles[config.DefaultPortScanTiming]
+	}
+	if cfg.Workers > 0 {
+		t.Workers = cfg.Workers
+	}
+	if cfg.Rate > 0 {
+		t.Rate = cfg.Rate
+	}
+	if cfg.Timeout > 0 {
+		t.MaxTimeout = cfg.Timeout
+	}
+	return t.normalize()
+}
+
+// normalize fills in the zero values of a timing.
+func (t Timing) normalize() Timing {
+	t.Workers = max(t.Workers, 1)
+	t.Rate = max(t.Rate, 0)
+	t.Retries = max(t.Retries, 0)
+	if t.MaxTimeout <= 0 {
+		t.MaxTimeout = config.DefaultPortScanTimeout
+	}
+	if t.MinTimeout <= 0 || t.MinTimeout > t.MaxTimeout {
+		t.MinTimeout = t.MaxTimeout
+	}
+	return t
+}
+
+// rttEstimator estimates the round trip time of a host from the answers of its ports,
+// in the way of TCP retransmission timers (RFC 6298), to time out the next probes.
+type rttEstimator struct {
+	mu     sync.Mutex
+	srtt   time.Duration // smoothed round trip time, 0 until measured
+	rttvar time.Duration // round trip time variation
+	min    time.Duration
+	max    time.Duration
+}
+
+// newRTTEstimator returns an estimator bounded by the timing, seeded with the latency of
+// the host when known (e.g. from an ICMP echo).
+func newRTTEstimator(latency time.Duration, t Timing) *rttEstimator {
+	e := &rttEstimator{min: t.MinTimeout, max: t.MaxTimeout}
+	if latency > 0 {
+		e.srtt = latency
+		e.rttvar = latency / 2
+	}
+	return e
+}
+
+// observe adds a measured round trip.
+func (e *rttEstimator) observe(rtt time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.srtt == 0 {
+		e.srtt = rtt
+		e.rttvar = rtt / 2
+		return
+	}
+	diff := e.srtt - rtt
+	if diff < 0 {
+		diff = -diff
+	}
+	e.rttvar = (3*e.rttvar + diff) / 4
+	e.srtt = (7*e.srtt + rtt) / 8
+}
+
+// timeout returns how long to wait for the answer of a TCP port on the given attempt, the
+// timeout doubles with every retry.
+func (e *rttEstimator) timeout(attempt int) time.Duration {
+	return e.timeoutAtLeast(attempt, e.min)
+}
+
+// udpTimeout returns how long to wait for the reply of a UDP port on the given attempt, at
+// least udpMinTimeout.
+func (e *rttEstimator) udpTimeout(attempt int) time.Duration {
+	return e.timeoutAtLeast(attempt, max(e.min, udpMinTimeout))
+}
+
+// timeoutAtLeast returns the timeout of the attempt, starting from no less than floor.
+func (e *rttEstimator) timeoutAtLeast(attempt int, floor time.Duration) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	timeout := e.max
+	if e.srtt > 0 {
+		timeout = e.srtt + 4*e.rttvar
+	}
+	timeout = max(timeout, floor)
+	for i := 0; i < attempt && timeout < e.max; i++ {
+		timeout *= 2
+	}
+	return min(timeout, e.max)
+}
+
+// rateLimiter spaces probes evenly to at most rate per second, a nil limiter is unlimited.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rate int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Second / time.Duration(rate)}
+}
+
+// wait blocks until the next probe may be sent or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	slot := now
+	if l.next.After(now) {
+		slot = l.next
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	if slot.Equal(now) {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(slot.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
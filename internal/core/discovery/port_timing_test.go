@@ -0,0 +1,189 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+)
+
+func TestTimingFromConfig(t *testing.T) {
+	polite := TimingFromConfig(config.PortScannerConfig{Timing: config.TimingPolite})
+	normal := TimingFromConfig(config.PortScannerConfig{Timing: config.TimingNormal})
+	aggressive := TimingFromConfig(config.PortScannerConfig{Timing: config.TimingAggressive})
+
+	if polite.Rate == 0 || polite.Workers >= normal.Workers || polite.Retries <= normal.Retries {
+		t.Errorf("expected polite to be slower than normal, got %+v and %+v", polite, normal)
+	}
+	if aggressive.Workers <= normal.Workers || aggressive.MaxTimeout >= normal.MaxTimeout {
+		t.Errorf("expected aggressive to be faster than normal, got %+v and %+v", aggressive, normal)
+	}
+	if polite.MaxTimeout != 10*time.Second || normal.MaxTimeout != 5*time.Second {
+		t.Errorf("expected the profile timeouts without a configured one, got %v and %v", polite.MaxTimeout, normal.MaxTimeout)
+	}
+	if longer := TimingFromConfig(config.PortScannerConfig{Timing: config.TimingAggressive, Timeout: 3 * time.Second}); longer.MaxTimeout != 3*time.Second {
+		t.Errorf("expected the configured timeout to raise the longest timeout, got %v", longer.MaxTimeout)
+	}
+
+	custom := TimingFromConfig(config.PortScannerConfig{Timing: config.TimingPolite, Workers: 3, Rate: 7, Timeout: 2 * time.Second})
+	if custom.Workers != 3 || custom.Rate != 7 || custom.MaxTimeout != 2*time.Second {
+		t.Errorf("expected configured workers, rate and timeout, got %+v", custom)
+	}
+
+	if unknown := TimingFromConfig(config.PortScannerConfig{Timing: "bogus"}); unknown != normal {
+		t.Errorf("expected normal timing for unknown profile, got %+v", unknown)
+	}
+}
+
+func TestRTTEstimator(t *testing.T) {
+	timing := Timing{MinTimeout: 10 * time.Millisecond, MaxTimeout: time.Second}
+
+	e := newRTTEstimator(0, timing)
+	if got := e.timeout(0); got != time.Second {
+		t.Errorf("expected max timeout before a round trip, got %v", got)
+	}
+
+	e = newRTTEstimator(20*time.Millisecond, timing)
+	if got := e.timeout(0); got != 60*time.Millisecond {
+		t.Errorf("expected timeout of seeded latency 60ms, got %v", got)
+	}
+	if got := e.timeout(1); got != 120*time.Millisecond {
+		t.Errorf("expected doubled timeout on retry 120ms, got %v", got)
+	}
+	if got := e.timeout(10); got != time.Second {
+		t.Errorf("expected retries capped at max timeout, got %v", got)
+	}
+
+	for i := 0; i < 50; i++ {
+		e.observe(time.Millisecond)
+	}
+	if got := e.timeout(0); got != 10*time.Millisecond {
+		t.Errorf("expected fast host clamped to min timeout, got %v", got)
+	}
+
+	if got := e.udpTimeout(0); got != time.Second {
+		t.Errorf("expected UDP timeout of at least %v, got %v", udpMinTimeout, got)
+	}
+
+	fixed := newRTTEstimator(time.Millisecond, Timing{MaxTimeout: 300 * time.Millisecond}.normalize())
+	if got := fixed.timeout(0); got != 300*time.Millisecond {
+		t.Errorf("expected fixed timeout without min timeout, got %v", got)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	if newRateLimiter(0) != nil {
+		t.Errorf("expected no limiter for rate 0")
+	}
+	var unlimited *rateLimiter
+	if err := unlimited.wait(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	l := newRateLimiter(100)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = l.wait(context.Background())
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected 6 probes at 100/s to take at least 50ms, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx); err == nil {
+		t.Errorf("expected error on cancelled context")
+	}
+}
+
+// dropDialer times out the first drops dials of every address, as if the SYN was lost.
+type dropDialer struct {
+	mu    sync.Mutex
+	drops int
+	dials map[string]int
+	open  bool
+}
+
+func (d *dropDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	d.dials[address]++
+	n := d.dials[address]
+	d.mu.Unlock()
+	if n <= d.drops {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if d.open {
+		return &mockConn{}, nil
+	}
+	return nil, net.ErrClosed
+}
+
+func TestPortScanner_Retries(t *testing.T) {
+	tests := []struct {
+		name      string
+		retries   int
+		open      bool
+		wantOpen  bool
+		wantDials int
+	}{
+		{"retry finds open port", 1, true, true, 2},
+		{"no retries", 0, true, false, 1},
+		{"answer is not retried", 2, false, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := &dropDialer{drops: 1, dials: map[string]int{}, open: tt.open}
+			ps := &PortScanner{
+				timing: Timing{Workers: 1, MaxTimeout: 20 * time.Millisecond, Retries: tt.retries},
+				dialer: dialer,
+			}
+			open := ps.isPortOpen(context.Background(), "127.0.0.1", 80, newRTTEstimator(0, ps.timing.normalize()))
+			if open != tt.wantOpen {
+				t.Errorf("expected open %v, got %v", tt.wantOpen, open)
+			}
+			if got := dialer.dials["127.0.0.1:80"]; got != tt.wantDials {
+				t.Errorf("expected %d dials, got %d", tt.wantDials, got)
+			}
+		})
+	}
+}
+
+func TestPortScanner_RetriesUDP(t *testing.T) {
+	// the listener ignores the first probe, as if it was lost
+	var probes atomic.Int32
+	port := udpListener(t, func(req []byte) []byte {
+		if probes.Add(1) == 1 {
+			return nil
+		}
+		return []byte("pong")
+	})
+
+	ps := NewPortScanner(Timing{Workers: 1, MaxTimeout: 200 * time.Millisecond, Retries: 1}, nil)
+	if state := ps.udpPortState(context.Background(), "127.0.0.1", port, newRTTEstimator(0, ps.timing)); state != PortOpen {
+		t.Errorf("expected port open after retry, got %v", state)
+	}
+}
+
+func TestPortScanner_SlowUDPService(t *testing.T) {
+	// the service answers long after the round trip of the host, like a recursive DNS lookup
+	port := udpListener(t, func(req []byte) []byte {
+		time.Sleep(300 * time.Millisecond)
+		return []byte("pong")
+	})
+
+	ps := NewPortScanner(Timing{Workers: 1, MinTimeout: 10 * time.Millisecond, MaxTimeout: 2 * time.Second}, nil)
+	if state := ps.udpPortState(context.Background(), "127.0.0.1", port, newRTTEstimator(time.Millisecond, ps.timing)); state != PortOpen {
+		t.Errorf("expected a slow service of a fast host open, got %v", state)
+	}
+}
@@ -0,0 +1,216 @@
+package rdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"go.uber.org/zap"
+)
+
+// Name is the scanner name and the source of devices named by their PTR record.
+const Name = "rdns"
+
+const (
+	// maxSweepHosts caps the subnet size, larger subnets are not swept.
+	maxSweepHosts = 4096
+	// maxConcurrentLookups is the number of PTR queries in flight.
+	maxConcurrentLookups = 32
+	// dnsPort is the port of configured resolvers given without one.
+	dnsPort = "53"
+)
+
+var _ discovery.InterfaceScanner = (*Scanner)(nil)
+
+// Scanner names the hosts of the subnet by their PTR record. It looks up every address
+// of the IPv4 subnet against the system resolver, or the configured servers, at most
+// rate queries per second. Answers, including the absence of a PTR record, are cached
+// for cacheTTL so a scan only queries the addresses that are not cached yet; the
+// sweep resumes where the previous scan ran out of time.
+type Scanner struct {
+	iface    *discovery.InterfaceInfo
+	rate     int
+	cacheTTL time.Duration
+	lookup   func(ctx context.Context, addr string) ([]string, error)
+
+	mu    sync.Mutex
+	cache map[string]entry
+}
+
+// entry is a cached lookup, name is empty for addresses without a PTR record.
+type entry struct {
+	name    string
+	expires time.Time
+}
+
+// NewScanner returns a scanner querying servers ("ip" or "ip:port", the system resolver
+// when empty) at most rate times per second and caching the answers for cacheTTL.
+func NewScanner(iface *discovery.InterfaceInfo, servers []string, rate int, cacheTTL time.Duration) *Scanner {
+	return &Scanner{
+		iface:    iface,
+		rate:     rate,
+		cacheTTL: cacheTTL,
+		lookup:   newResolver(servers).LookupAddr,
+		cache:    map[string]entry{},
+	}
+}
+
+func (s *Scanner) Name() string { return Name }
+
+// Interface returns the network interface the scanner is bound to.
+func (s *Scanner) Interface() *discovery.InterfaceInfo { return s.iface }
+
+// Scan emits the cached names and looks up the remaining addresses until the deadline of ctx.
+// PTR sweeps are IPv4 only, IPv6 subnets are too large and IPv6-only interfaces are skipped.
+func (s *Scanner) Scan(ctx context.Context, out chan<- discovery.Device) error {
+	log := zap.L().With(zap.String("scanner", Name))
+	if s.iface == nil || s.iface.IPv4Net == nil {
+		log.Debug("interface has no IPv4 subnet, skipping")
+		return nil
+	}
+	hosts := discovery.SubnetHosts(s.iface.IPv4Net, maxSweepHosts)
+	if hosts == nil {
+		log.Debug("subnet too large to sweep", zap.Stringer("subnet", s.iface.IPv4Net))
+		return nil
+	}
+
+	var pending []net.IP
+	for _, ip := range hosts {
+		name, ok := s.cached(ip)
+		switch {
+		case !ok:
+			pending = append(pending, ip)
+		case name != "":
+			if !emit(ctx, out, ip, name) {
+				return ctx.Err()
+			}
+		}
+	}
+	log.Debug("sweeping subnet", zap.Int("cached", len(hosts)-len(pending)), zap.Int("pending", len(pending)))
+
+	jobs := make(chan net.IP)
+	var wg sync.WaitGroup
+	for range min(maxConcurrentLookups, len(pending)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range jobs {
+				if name, ok := s.resolve(ctx, ip, log); ok && name != "" {
+					emit(ctx, out, ip, name)
+				}
+			}
+		}()
+	}
+	s.dispatch(ctx, pending, jobs)
+	close(jobs)
+	wg.Wait()
+	return nil
+}
+
+// dispatch hands the addresses to the lookup workers, at most rate per second.
+func (s *Scanner) dispatch(ctx context.Context, ips []net.IP, jobs chan<- net.IP) {
+	if len(ips) == 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(max(s.rate, 1)))
+	defer ticker.Stop()
+	for i, ip := range ips {
+		if i > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case jobs <- ip:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// resolve looks up the PTR record of ip. Names and missing records are cached,
+// failed lookups (timeouts, server failures) are retried in the next scan.
+func (s *Scanner) resolve(ctx context.Context, ip net.IP, log *zap.Logger) (string, bool) {
+	names, err := s.lookup(ctx, ip.String())
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil && len(names) > 0:
+		name := strings.TrimSuffix(names[0], ".")
+		s.store(ip, name)
+		return name, true
+	case err == nil, errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		s.store(ip, "")
+		return "", true
+	default:
+		if ctx.Err() == nil {
+			log.Debug("PTR lookup failed", zap.Stringer("ip", ip), zap.Error(err))
+		}
+		return "", false
+	}
+}
+
+func (s *Scanner) cached(ip net.IP) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.cache[ip.String()]
+	if !ok || time.Now().After(e.expires) {
+		return "", false
+	}
+	return e.name, true
+}
+
+func (s *Scanner) store(ip net.IP, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache[ip.String()] = entry{name: name, expires: time.Now().Add(s.cacheTTL)}
+}
+
+// emit sends the name of ip, it reports false when ctx is done first. A PTR record does
+// not mean the host is up, the name only enriches a device seen by another scanner.
+func emit(ctx context.Context, out chan<- discovery.Device, ip net.IP, name string) bool {
+	d := discovery.Device{IP: ip, Sources: map[string]struct{}{Name: {}}, EnrichOnly: true}
+	d.ReverseDNS = name
+	d.DisplayName = name
+	select {
+	case out <- d:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// newResolver returns the system resolver, or a resolver sending its queries to the
+// servers in turn.
+func newResolver(servers []string) *net.Resolver {
+	if len(servers) == 0 {
+		return net.DefaultResolver
+	}
+	addrs := make([]string, len(servers))
+	for i, server := range servers {
+		addrs[i] = serverAddr(server)
+	}
+	var next atomic.Uint32
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			addr := addrs[int(next.Add(1)-1)%len(addrs)]
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// serverAddr adds the DNS port to a server given without one.
+func serverAddr(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), dnsPort)
+}
@@ -0,0 +1,144 @@
+package rdns
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// startServer runs a DNS server answering PTR queries from records and NXDOMAIN
+// otherwise. It returns its address and the number of queries it received.
+func startServer(t *testing.T, records map[string]string) (string, *atomic.Int32) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	var queries atomic.Int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, src, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			var req dnsmessage.Message
+			if err := req.Unpack(buf[:n]); err != nil || len(req.Questions) != 1 {
+				continue
+			}
+			queries.Add(1)
+			q := req.Questions[0]
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: req.ID, Response: true, RecursionDesired: req.RecursionDesired, RecursionAvailable: true},
+				Questions: req.Questions,
+			}
+			if name, ok := records[q.Name.String()]; ok && q.Type == dnsmessage.TypePTR {
+				resp.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET, TTL: 300},
+					Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(name)},
+				}}
+			} else {
+				resp.RCode = dnsmessage.RCodeNameError
+			}
+			b, err := resp.Pack()
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteToUDP(b, src)
+		}
+	}()
+	return conn.LocalAddr().String(), &queries
+}
+
+func scan(t *testing.T, s *Scanner) map[string]discovery.Device {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	out := make(chan discovery.Device, 16)
+	if err := s.Scan(ctx, out); err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+	got := map[string]discovery.Device{}
+	for d := range out {
+		got[d.IP.String()] = d
+	}
+	return got
+}
+
+func TestScan(t *testing.T) {
+	server, queries := startServer(t, map[string]string{
+		"1.0.0.10.in-addr.arpa.": "gw.office.example.",
+		"5.0.0.10.in-addr.arpa.": "printer-2f.office.example.",
+	})
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/29")
+	s := NewScanner(&discovery.InterfaceInfo{IPv4Net: subnet}, []string{server}, 1000, time.Minute)
+
+	got := scan(t, s)
+	if len(got) != 2 {
+		t.Fatalf("expected two named hosts, got %v", got)
+	}
+	printer := got["10.0.0.5"]
+	if printer.ReverseDNS != "printer-2f.office.example" || printer.DisplayName != "printer-2f.office.example" {
+		t.Errorf("unexpected printer %+v", printer)
+	}
+	if _, ok := printer.Sources[Name]; !ok {
+		t.Errorf("expected source %q, got %v", Name, printer.Sources)
+	}
+	if !printer.EnrichOnly || !printer.LastSeen.IsZero() {
+		t.Errorf("expected an enrichment that does not mark the host seen, got %+v", printer)
+	}
+	if n := queries.Load(); n != 6 {
+		t.Errorf("expected a query for each of the 6 hosts, got %d", n)
+	}
+
+	// the second scan is answered from the cache, including the missing records
+	if got := scan(t, s); len(got) != 2 || got["10.0.0.1"].ReverseDNS != "gw.office.example" {
+		t.Errorf("unexpected cached scan %v", got)
+	}
+	if n := queries.Load(); n != 6 {
+		t.Errorf("expected no queries for cached addresses, got %d", n-6)
+	}
+}
+
+func TestScanRateLimit(t *testing.T) {
+	server, queries := startServer(t, nil)
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+	s := NewScanner(&discovery.InterfaceInfo{IPv4Net: subnet}, []string{server}, 20, time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := s.Scan(ctx, make(chan discovery.Device)); err != nil {
+		t.Fatal(err)
+	}
+	if n := queries.Load(); n < 3 || n > 12 {
+		t.Errorf("expected about 10 queries in 500ms at 20/s, got %d", n)
+	}
+}
+
+func TestScanSkipsIPv6Only(t *testing.T) {
+	s := NewScanner(&discovery.InterfaceInfo{}, nil, DefaultRate, DefaultCacheTTL)
+	if got := scan(t, s); len(got) != 0 {
+		t.Errorf("expected no devices, got %v", got)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	c := &Config{Servers: []string{"10.0.0.53", "10.0.0.54:5353", "[2001:db8::53]:53", "dns.example"}}
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for zero rate, cache TTL and a host name server")
+	}
+	if c.Rate != DefaultRate || c.CacheTTL != DefaultCacheTTL {
+		t.Errorf("expected defaults, got rate %d, cache_ttl %s", c.Rate, c.CacheTTL)
+	}
+	if len(c.Servers) != 3 {
+		t.Errorf("expected the host name server dropped, got %v", c.Servers)
+	}
+}
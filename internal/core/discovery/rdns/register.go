@@ -0,0 +1,76 @@
+package rdns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+const (
+	DefaultRate     = 100
+	DefaultCacheTTL = 10 * time.Minute
+)
+
+// Config is the `scanners.rdns` config block.
+type Config struct {
+	config.ScannerToggle `yaml:",inline"`
+	// Servers are the DNS servers queried in turn ("ip" or "ip:port"), the system resolver when empty.
+	Servers []string `yaml:"servers"`
+	// Rate is the maximum number of PTR queries per second.
+	Rate int `yaml:"rate"`
+	// CacheTTL is how long names and missing PTR records are remembered.
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// Validate resets an invalid rate or cache TTL to its default and drops servers that are not IP addresses.
+func (c *Config) Validate() error {
+	var errs []string
+	if c.Rate <= 0 {
+		c.Rate = DefaultRate
+		errs = append(errs, "rate must be > 0")
+	}
+	if c.CacheTTL <= 0 {
+		c.CacheTTL = DefaultCacheTTL
+		errs = append(errs, "cache_ttl must be > 0")
+	}
+	servers := c.Servers[:0]
+	for _, server := range c.Servers {
+		host := server
+		if h, _, err := net.SplitHostPort(server); err == nil {
+			host = h
+		}
+		if net.ParseIP(strings.Trim(host, "[]")) == nil {
+			errs = append(errs, fmt.Sprintf("servers: %q is not an IP address", server))
+			continue
+		}
+		servers = append(servers, server)
+	}
+	c.Servers = servers
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func init() {
+	discovery.Register(discovery.Registration{
+		Name:           Name,
+		Description:    "Reverse DNS (PTR) lookups for every address of the subnet",
+		DefaultEnabled: false,
+		NewConfig: func() config.ScannerSettings {
+			return &Config{Rate: DefaultRate, CacheTTL: DefaultCacheTTL}
+		},
+		New: func(iface *discovery.InterfaceInfo, settings config.ScannerSettings) (discovery.Scanner, error) {
+			cfg, ok := settings.(*Config)
+			if !ok {
+				return nil, fmt.Errorf("unexpected config type %T", settings)
+			}
+			return NewScanner(iface, cfg.Servers, cfg.Rate, cfg.CacheTTL), nil
+		},
+	})
+}
@@ -0,0 +1,91 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+)
+
+// ScannerFactory creates a scanner for an interface from its typed config block.
+type ScannerFactory func(iface *InterfaceInfo, cfg config.ScannerSettings) (Scanner, error)
+
+// Registration describes a scanner implementation. Scanner packages register
+// themselves from init, so importing a package is enough to make it available
+// to the CLI, the config file and the engine builder.
+type Registration struct {
+	Name           string
+	Description    string
+	DefaultEnabled bool
+	// Sources are the device sources the scanner reports besides its name, known to the merge config.
+	Sources []string
+	// NewConfig returns the typed config block with defaults, nil means enabled-only.
+	NewConfig func() config.ScannerSettings
+	New       ScannerFactory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Registration{}
+)
+
+// Register adds a scanner to the registry, it panics when the name is taken.
+func Register(r Registration) {
+	if r.Name == "" || r.New == nil {
+		panic("discovery: Register needs a name and a factory")
+	}
+
+	registryMu.Lock()
+	if _, dup := registry[r.Name]; dup {
+		registryMu.Unlock()
+		panic("discovery: Register called twice for scanner " + r.Name)
+	}
+	registry[r.Name] = r
+	registryMu.Unlock()
+
+	config.RegisterScanner(config.ScannerSpec{
+		Name:           r.Name,
+		Description:    r.Description,
+		DefaultEnabled: r.DefaultEnabled,
+		Sources:        r.Sources,
+		NewConfig:      r.NewConfig,
+	})
+}
+
+// Registrations returns all registered scanners sorted by name.
+func Registrations() []Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	out := make([]Registration, 0, len(registry))
+	for _, r := range registry {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// LookupScanner returns the registration for name.
+func LookupScanner(name string) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := registry[name]
+	return r, ok
+}
+
+// NewScanner creates the registered scanner name with the given config block,
+// defaults are used when cfg is nil.
+func NewScanner(name string, iface *InterfaceInfo, cfg config.ScannerSettings) (Scanner, error) {
+	r, ok := LookupScanner(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown scanner: %s", name)
+	}
+	if cfg == nil {
+		cfg = config.ScannersConfig{}.Settings(name)
+	}
+	s, err := r.New(iface, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,217 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// scannerBufferSize is the buffer size of each per-scanner channel.
+	scannerBufferSize = 32
+	// scannerGracePeriod is how long Stream waits for scanners to return after the deadline.
+	scannerGracePeriod = 500 * time.Millisecond
+)
+
+// ScannerReport holds the statistics of a single scanner for one scan cycle.
+type ScannerReport struct {
+	Name             string        `json:"name"`
+	Interface        string        `json:"interface,omitempty"` // interface the scanner ran on, see InterfaceScanner
+	Duration         time.Duration `json:"duration"`            // time until Scan returned (or until the report was taken)
+	DevicesEmitted   int           `json:"devicesEmitted"`      // devices sent by the scanner, before merging
+	Err              error         `json:"-"`                   // error returned by Scan, context and DisabledError excluded
+	Error            string        `json:"error,omitempty"`     // Err as string, for the API
+	Disabled         string        `json:"disabled,omitempty"`  // reason the scanner cannot run on this host, see DisabledError
+	DeadlineExceeded bool          `json:"deadlineExceeded"`    // scanner was still running when the scan deadline passed
+	Running          bool          `json:"running"`             // scanner did not return within the grace period
+}
+
+// DisabledError is returned by Scan when the scanner cannot run on this host, e.g. because
+// the OS does not permit the sockets it needs. It is reported as the status of the scanner
+// rather than as a failure.
+type DisabledError struct {
+	Reason string
+}
+
+func (e *DisabledError) Error() string { return "disabled: " + e.Reason }
+
+// Label identifies the scanner in messages, "arp@eth0" when it is bound to an interface.
+func (r ScannerReport) Label() string {
+	if r.Interface == "" {
+		return r.Name
+	}
+	return r.Name + "@" + r.Interface
+}
+
+// ScanReport summarizes one Engine.Stream cycle.
+type ScanReport struct {
+	StartedAt time.Time       `json:"startedAt"`
+	Duration  time.Duration   `json:"duration"`
+	Devices   int             `json:"devices"` // unique devices after merging
+	Scanners  []ScannerReport `json:"scanners"`
+}
+
+// Failed returns the reports of scanners that returned an error.
+func (r *ScanReport) Failed() []ScannerReport {
+	var failed []ScannerReport
+	for _, s := range r.Scanners {
+		if s.Err != nil {
+			failed = append(failed, s)
+		}
+	}
+	return failed
+}
+
+// allFailed returns an error wrapping all scanner errors when every scanner failed.
+func (r *ScanReport) allFailed() error {
+	failed := r.Failed()
+	if len(failed) == 0 || len(failed) != len(r.Scanners) {
+		return nil
+	}
+	errs := make([]error, 0, len(failed))
+	for _, s := range failed {
+		errs = append(errs, fmt.Errorf("%s: %w", s.Label(), s.Err))
+	}
+	return fmt.Errorf("all scanners failed: %w", errors.Join(errs...))
+}
+
+// scannerRun tracks a single scanner during one Stream cycle.
+type scannerRun struct {
+	scanner Scanner
+	iface   *InterfaceInfo // nil for scanners that are not an InterfaceScanner
+	ch      chan Device
+	emitted atomic.Int64
+
+	mu     sync.Mutex
+	report *ScannerReport // nil while the scanner is running
+}
+
+func newScannerRun(s Scanner) *scannerRun {
+	r := &scannerRun{scanner: s, ch: make(chan Device, scannerBufferSize)}
+	if is, ok := s.(InterfaceScanner); ok {
+		r.iface = is.Interface()
+	}
+	return r
+}
+
+// ifaceName returns the name of the interface the scanner is bound to, if any.
+func (r *scannerRun) ifaceName() string {
+	if r.iface == nil || r.iface.Interface == nil {
+		return ""
+	}
+	return r.iface.Interface.Name
+}
+
+// tag records the interface and subnet the device was seen on.
+func (r *scannerRun) tag(d *Device) {
+	if r.iface == nil || d.Interface != "" {
+		return
+	}
+	d.Interface = r.ifaceName()
+	if d.Subnet == "" {
+		d.Subnet = r.iface.SubnetOf(d.IP)
+	}
+}
+
+// run executes the scanner and closes its channel when Scan returns.
+func (r *scannerRun) run(ctx context.Context) {
+	defer close(r.ch)
+	start := time.Now()
+	err := r.scanner.Scan(ctx, r.ch)
+
+	rep := &ScannerReport{
+		Name:             r.scanner.Name(),
+		Interface:        r.ifaceName(),
+		Duration:         time.Since(start),
+		DeadlineExceeded: errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded),
+	}
+	// Scanners return ctx.Err() when they are stopped, that is expected behavior and not a failure.
+	var disabled *DisabledError
+	switch {
+	case errors.As(err, &disabled):
+		rep.Disabled = disabled.Reason
+	case err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled):
+		rep.Err = err
+		rep.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	r.report = rep
+	r.mu.Unlock()
+}
+
+// snapshot returns the report of the scanner, or a running report when it has not returned yet.
+func (r *scannerRun) snapshot(scanStart time.Time) ScannerReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rep := ScannerReport{
+		Name:             r.scanner.Name(),
+		Interface:        r.ifaceName(),
+		Duration:         time.Since(scanStart),
+		DeadlineExceeded: true,
+		Running:          true,
+	}
+	if r.report != nil {
+		rep = *r.report
+	}
+	rep.DevicesEmitted = int(r.emitted.Load())
+	return rep
+}
+
+// fanIn merges the per-scanner channels into a single channel which is closed once
+// every scanner channel is closed. After done is closed devices are counted and dropped.
+func fanIn(done <-chan struct{}, runs []*scannerRun) <-chan Device {
+	out := make(chan Device)
+	var wg sync.WaitGroup
+
+	for _, r := range runs {
+		wg.Add(1)
+		go func(r *scannerRun) {
+			defer wg.Done()
+			for d := range r.ch {
+				r.emitted.Add(1)
+				r.tag(&d)
+				select {
+				case out <- d:
+				case <-done:
+				}
+			}
+		}(r)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// logReport writes the per-scanner statistics of a scan to the log.
+func logReport(r ScanReport) {
+	log := zap.L().Named("engine")
+	for _, s := range r.Scanners {
+		fields := []zap.Field{
+			zap.String("scanner", s.Name),
+			zap.String("interface", s.Interface),
+			zap.Duration("duration", s.Duration),
+			zap.Int("devices", s.DevicesEmitted),
+			zap.Bool("deadline_exceeded", s.DeadlineExceeded),
+		}
+		if s.Err != nil {
+			log.Warn("scanner failed", append(fields, zap.Error(s.Err))...)
+			continue
+		}
+		if s.Disabled != "" {
+			log.Debug("scanner disabled", append(fields, zap.String("reason", s.Disabled))...)
+			continue
+		}
+		log.Debug("scanner finished", fields...)
+	}
+}
@@ -0,0 +1,132 @@
+package discovery
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ServiceInstance is one service announced by a device, e.g. a DNS-SD instance
+// ("Printer._ipp._tcp.local.") or a UPnP service of a device description.
+type ServiceInstance struct {
+	Instance string            `json:"instance,omitempty"` // instance name, e.g. "Printer", empty when the protocol has none
+	Type     string            `json:"type"`               // service type without leading underscore, e.g. "ipp" or "upnp"
+	Protocol string            `json:"protocol,omitempty"` // transport protocol, "tcp" or "udp"
+	Port     int               `json:"port,omitempty"`     // 0 when unknown
+	Target   string            `json:"target,omitempty"`   // host the instance runs on (e.g. the SRV target)
+	TXT      map[string]string `json:"txt,omitempty"`      // key/value metadata of the instance (e.g. DNS-SD TXT records)
+	Source   string            `json:"source"`             // scanner that discovered the instance
+	LastSeen time.Time         `json:"lastSeen"`           // last time the instance was announced
+}
+
+// Key identifies the instance on a device. DNS names are case-insensitive, so is the key.
+func (s ServiceInstance) Key() string {
+	return strings.ToLower(s.Type + "." + s.Protocol + "/" + s.Instance)
+}
+
+// Name returns the DNS-SD style name of the instance, e.g. "Printer._ipp._tcp".
+func (s ServiceInstance) Name() string {
+	name := "_" + s.Type
+	if s.Protocol != "" {
+		name += "._" + s.Protocol
+	}
+	if s.Instance != "" {
+		name = s.Instance + "." + name
+	}
+	return name
+}
+
+// withdrawnBy reports whether the removal r covers s. An empty protocol or instance
+// in r matches any, so {Type: "upnp"} withdraws every UPnP instance.
+func (s ServiceInstance) withdrawnBy(r ServiceInstance) bool {
+	return strings.EqualFold(s.Type, r.Type) &&
+		(r.Protocol == "" || strings.EqualFold(s.Protocol, r.Protocol)) &&
+		(r.Instance == "" || strings.EqualFold(s.Instance, r.Instance))
+}
+
+// merge returns s updated with other. Known values are kept unless other is at least
+// as recent and carries a value itself, TXT keys are merged the same way.
+func (s ServiceInstance) merge(other ServiceInstance) ServiceInstance {
+	newer := !other.LastSeen.Before(s.LastSeen)
+	if other.Port != 0 && (s.Port == 0 || newer) {
+		s.Port = other.Port
+	}
+	if other.Target != "" && (s.Target == "" || newer) {
+		s.Target = other.Target
+	}
+	if other.Source != "" && (s.Source == "" || newer) {
+		s.Source = other.Source
+	}
+	if len(other.TXT) > 0 {
+		// copy first, the map may be shared with a snapshot
+		txt := maps.Clone(s.TXT)
+		if txt == nil {
+			txt = make(map[string]string, len(other.TXT))
+		}
+		for k, v := range other.TXT {
+			if _, ok := txt[k]; !ok || newer {
+				txt[k] = v
+			}
+		}
+		s.TXT = txt
+	}
+	if other.LastSeen.After(s.LastSeen) {
+		s.LastSeen = other.LastSeen
+	}
+	return s
+}
+
+// AddService adds s to the services of the device, or merges it into the instance with the same key.
+func (d *Device) AddService(s ServiceInstance) {
+	if i := slices.IndexFunc(d.Services, func(cur ServiceInstance) bool { return cur.Key() == s.Key() }); i >= 0 {
+		d.Services[i] = d.Services[i].merge(s)
+		return
+	}
+	s.TXT = maps.Clone(s.TXT)
+	d.Services = append(d.Services, s)
+}
+
+// Service returns the first instance of the given service type.
+func (d *Device) Service(serviceType string) (ServiceInstance, bool) {
+	for _, s := range d.Services {
+		if strings.EqualFold(s.Type, serviceType) {
+			return s, true
+		}
+	}
+	return ServiceInstance{}, false
+}
+
+// mergeServices merges the services of other into d and drops the ones other withdrew.
+func (d *Device) mergeServices(other *Device) {
+	if len(other.Services) == 0 && len(other.RemovedServices) == 0 {
+		return
+	}
+	// copy first, snapshots handed out by AppState share the slice
+	d.Services = slices.Clone(d.Services)
+	for _, s := range other.Services {
+		d.AddService(s)
+	}
+	if len(other.RemovedServices) > 0 {
+		d.Services = slices.DeleteFunc(d.Services, func(s ServiceInstance) bool {
+			return slices.ContainsFunc(other.RemovedServices, s.withdrawnBy)
+		})
+	}
+}
+
+// FingerprintData returns ExtraData together with the announced services, keyed by
+// their DNS-SD name with the TXT pairs as value, for the probe heuristics.
+func (d *Device) FingerprintData() map[string]string {
+	data := maps.Clone(d.ExtraData)
+	if data == nil {
+		data = make(map[string]string, len(d.Services))
+	}
+	for _, s := range d.Services {
+		pairs := make([]string, 0, len(s.TXT))
+		for _, k := range slices.Sorted(maps.Keys(s.TXT)) {
+			pairs = append(pairs, k+"="+s.TXT[k])
+		}
+		data[s.Name()] = strings.Join(pairs, " ")
+	}
+	return data
+}
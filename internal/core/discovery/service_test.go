@@ -0,0 +1,74 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAddServiceKeepsInstancesApart(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	d := NewDevice(net.ParseIP("10.0.0.5"))
+	d.AddService(ServiceInstance{Instance: "Admin", Type: "http", Protocol: "tcp", Port: 5000, LastSeen: t0})
+	d.AddService(ServiceInstance{Instance: "Files", Type: "http", Protocol: "tcp", Port: 8080, LastSeen: t0})
+	d.AddService(ServiceInstance{Instance: "files", Type: "http", Protocol: "tcp", TXT: map[string]string{"path": "/"}, LastSeen: t0.Add(time.Second)})
+
+	if len(d.Services) != 2 {
+		t.Fatalf("expected two http instances, got %+v", d.Services)
+	}
+	files := d.Services[1]
+	if files.Port != 8080 || files.TXT["path"] != "/" || !files.LastSeen.Equal(t0.Add(time.Second)) {
+		t.Errorf("expected the instances to be merged case-insensitively, got %+v", files)
+	}
+}
+
+func TestServiceInstanceMerge(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	cur := ServiceInstance{Type: "ipp", Protocol: "tcp", Port: 631, TXT: map[string]string{"rp": "ipp/print", "ty": "Old"}, Source: "mdns", LastSeen: t0}
+
+	older := cur.merge(ServiceInstance{Type: "ipp", Protocol: "tcp", Port: 8631, TXT: map[string]string{"ty": "Older", "note": "x"}, LastSeen: t0.Add(-time.Minute)})
+	if older.Port != 631 || older.TXT["ty"] != "Old" || older.TXT["note"] != "x" || !older.LastSeen.Equal(t0) {
+		t.Errorf("older values must only fill gaps, got %+v", older)
+	}
+
+	newer := cur.merge(ServiceInstance{Type: "ipp", Protocol: "tcp", TXT: map[string]string{"ty": "New"}, LastSeen: t0.Add(time.Minute)})
+	if newer.Port != 631 || newer.TXT["ty"] != "New" || newer.TXT["rp"] != "ipp/print" {
+		t.Errorf("newer values must win without dropping known ones, got %+v", newer)
+	}
+	if cur.TXT["ty"] != "Old" {
+		t.Errorf("merge must not modify the TXT map of the original")
+	}
+}
+
+func TestMergeRemovedServices(t *testing.T) {
+	d := NewDevice(net.ParseIP("10.0.0.5"))
+	d.Services = []ServiceInstance{
+		{Type: "upnp", Protocol: "tcp"},
+		{Instance: "AVTransport", Type: "upnp", Protocol: "tcp"},
+		{Instance: "TV", Type: "airplay", Protocol: "tcp"},
+	}
+	snapshot := d.Services
+
+	d.Merge(&Device{RemovedServices: []ServiceInstance{{Type: "upnp"}}})
+
+	if len(d.Services) != 1 || d.Services[0].Type != "airplay" {
+		t.Errorf("expected every upnp instance to be withdrawn, got %+v", d.Services)
+	}
+	if len(snapshot) != 3 || snapshot[0].Type != "upnp" {
+		t.Errorf("merge must not modify a shared services slice, got %+v", snapshot)
+	}
+}
+
+func TestFingerprintData(t *testing.T) {
+	d := NewDevice(net.ParseIP("10.0.0.5"))
+	d.ExtraData["server"] = "Linux"
+	d.AddService(ServiceInstance{Instance: "Printer", Type: "ipp", Protocol: "tcp", TXT: map[string]string{"ty": "Laser", "rp": "print"}})
+
+	data := d.FingerprintData()
+	if data["server"] != "Linux" || data["Printer._ipp._tcp"] != "rp=print ty=Laser" {
+		t.Errorf("unexpected fingerprint data %v", data)
+	}
+	if _, ok := d.ExtraData["Printer._ipp._tcp"]; ok {
+		t.Errorf("FingerprintData must not modify ExtraData")
+	}
+}
@@ -0,0 +1,250 @@
+package snmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Type is the BER tag of a variable value, see RFC 2578 section 7.1 and RFC 3416 section 3.
+type Type byte
+
+const (
+	TypeInteger        Type = 0x02
+	TypeOctetString    Type = 0x04
+	TypeNull           Type = 0x05
+	TypeOID            Type = 0x06
+	TypeIPAddress      Type = 0x40
+	TypeCounter32      Type = 0x41
+	TypeGauge32        Type = 0x42
+	TypeTimeTicks      Type = 0x43
+	TypeOpaque         Type = 0x44
+	TypeCounter64      Type = 0x46
+	TypeNoSuchObject   Type = 0x80
+	TypeNoSuchInstance Type = 0x81
+	TypeEndOfMibView   Type = 0x82
+)
+
+const tagSequence = 0x30
+
+var errTruncated = errors.New("snmp: truncated BER encoding")
+
+// OID is an object identifier, e.g. 1.3.6.1.2.1.1.5.0 for sysName.0.
+type OID []uint32
+
+// ParseOID parses the dotted form of an OID, a leading dot is allowed.
+func ParseOID(s string) (OID, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("snmp: invalid OID %q", s)
+	}
+	oid := make(OID, len(parts))
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("snmp: invalid OID %q", s)
+		}
+		oid[i] = uint32(n)
+	}
+	return oid, nil
+}
+
+// MustParseOID is ParseOID for constants, it panics on an invalid OID.
+func MustParseOID(s string) OID {
+	oid, err := ParseOID(s)
+	if err != nil {
+		panic(err)
+	}
+	return oid
+}
+
+func (o OID) String() string {
+	parts := make([]string, len(o))
+	for i, n := range o {
+		parts[i] = strconv.FormatUint(uint64(n), 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+// HasPrefix reports whether o lies in the subtree of prefix (or equals it).
+func (o OID) HasPrefix(prefix OID) bool {
+	return len(o) >= len(prefix) && o[:len(prefix)].Compare(prefix) == 0
+}
+
+// Compare orders OIDs lexicographically, the order of a MIB walk.
+func (o OID) Compare(other OID) int {
+	for i := 0; i < len(o) && i < len(other); i++ {
+		switch {
+		case o[i] < other[i]:
+			return -1
+		case o[i] > other[i]:
+			return 1
+		}
+	}
+	return len(o) - len(other)
+}
+
+func appendTLV(b []byte, tag byte, value []byte) []byte {
+	b = append(b, tag)
+	b = appendLength(b, len(value))
+	return append(b, value...)
+}
+
+func appendLength(b []byte, n int) []byte {
+	if n < 0x80 {
+		return append(b, byte(n))
+	}
+	var tmp [4]byte
+	i := len(tmp)
+	for n > 0 {
+		i--
+		tmp[i] = byte(n)
+		n >>= 8
+	}
+	b = append(b, 0x80|byte(len(tmp)-i))
+	return append(b, tmp[i:]...)
+}
+
+// appendInteger appends the minimal two's complement encoding of v with the given tag.
+func appendInteger(b []byte, tag byte, v int64) []byte {
+	var tmp [8]byte
+	binary.BigEndian.PutUint64(tmp[:], uint64(v))
+	i := 0
+	for i < 7 && ((tmp[i] == 0 && tmp[i+1]&0x80 == 0) || (tmp[i] == 0xff && tmp[i+1]&0x80 != 0)) {
+		i++
+	}
+	return appendTLV(b, tag, tmp[i:])
+}
+
+// appendUnsigned appends an unsigned application type (Counter32, Gauge32, TimeTicks, Counter64).
+func appendUnsigned(b []byte, tag byte, v uint64) []byte {
+	var tmp [9]byte
+	binary.BigEndian.PutUint64(tmp[1:], v)
+	i := 0
+	for i < 8 && tmp[i] == 0 && tmp[i+1]&0x80 == 0 {
+		i++
+	}
+	return appendTLV(b, tag, tmp[i:])
+}
+
+func appendOID(b []byte, oid OID) []byte {
+	if len(oid) < 2 {
+		return appendTLV(b, byte(TypeOID), []byte{0})
+	}
+	value := appendBase128(nil, oid[0]*40+oid[1])
+	for _, n := range oid[2:] {
+		value = appendBase128(value, n)
+	}
+	return appendTLV(b, byte(TypeOID), value)
+}
+
+func appendBase128(b []byte, n uint32) []byte {
+	var tmp [5]byte
+	i := len(tmp) - 1
+	tmp[i] = byte(n & 0x7f)
+	for n >>= 7; n > 0; n >>= 7 {
+		i--
+		tmp[i] = byte(n&0x7f) | 0x80
+	}
+	return append(b, tmp[i:]...)
+}
+
+// readTLV splits the first element off b and returns its tag, value and the remainder.
+// The value shares the memory of b.
+func readTLV(b []byte) (tag byte, value, rest []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, nil, errTruncated
+	}
+	tag = b[0]
+	n := int(b[1])
+	pos := 2
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 4 || len(b) < pos+size {
+			return 0, nil, nil, errTruncated
+		}
+		n = 0
+		for _, c := range b[pos : pos+size] {
+			n = n<<8 | int(c)
+		}
+		pos += size
+	}
+	if n < 0 || len(b)-pos < n {
+		return 0, nil, nil, errTruncated
+	}
+	return tag, b[pos : pos+n], b[pos+n:], nil
+}
+
+// readExpected reads an element and checks its tag.
+func readExpected(b []byte, want byte) (value, rest []byte, err error) {
+	tag, value, rest, err := readTLV(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tag != want {
+		return nil, nil, fmt.Errorf("snmp: unexpected tag %#02x, want %#02x", tag, want)
+	}
+	return value, rest, nil
+}
+
+func readInteger(b []byte) (int64, []byte, error) {
+	value, rest, err := readExpected(b, byte(TypeInteger))
+	if err != nil {
+		return 0, nil, err
+	}
+	n, err := parseInteger(value)
+	return n, rest, err
+}
+
+func parseInteger(b []byte) (int64, error) {
+	if len(b) == 0 || len(b) > 8 {
+		return 0, fmt.Errorf("snmp: invalid integer of %d bytes", len(b))
+	}
+	n := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		n = n<<8 | int64(c)
+	}
+	return n, nil
+}
+
+func parseUnsigned(b []byte) (uint64, error) {
+	if len(b) > 9 || (len(b) == 9 && b[0] != 0) {
+		return 0, fmt.Errorf("snmp: invalid unsigned of %d bytes", len(b))
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+func parseOID(b []byte) (OID, error) {
+	if len(b) == 0 {
+		return nil, errTruncated
+	}
+	var oid OID
+	var n uint32
+	for i, c := range b {
+		if n > 1<<25 {
+			return nil, errors.New("snmp: OID component overflow")
+		}
+		n = n<<7 | uint32(c&0x7f)
+		if c&0x80 != 0 {
+			if i == len(b)-1 {
+				return nil, errTruncated
+			}
+			continue
+		}
+		if oid == nil {
+			// the first component encodes the first two arcs
+			first := min(n/40, 2)
+			oid = OID{first, n - first*40}
+		} else {
+			oid = append(oid, n)
+		}
+		n = 0
+	}
+	return oid, nil
+}
@@ -0,0 +1,78 @@
+package snmp
+
+import (
+	"math"
+	"net"
+	"testing"
+)
+
+func TestOIDRoundTrip(t *testing.T) {
+	for _, s := range []string{"1.3.6.1.2.1.1.5.0", "1.3.6.1.4.1.2636.1.1.1.2.29", "2.999.1", "1.3.6.1.2.1.4.22.1.2.5.4294967295"} {
+		oid := MustParseOID(s)
+		value, _, err := readExpected(appendOID(nil, oid), byte(TypeOID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseOID(value)
+		if err != nil || got.String() != s {
+			t.Errorf("%s: got %v (%v)", s, got, err)
+		}
+	}
+	if _, err := ParseOID("1.3.six"); err == nil {
+		t.Errorf("expected an error for an invalid OID")
+	}
+}
+
+func TestIntegerRoundTrip(t *testing.T) {
+	for _, n := range []int64{0, 1, 127, 128, 255, 256, -1, -128, -129, math.MaxInt32, math.MinInt32} {
+		value, _, err := readExpected(appendInteger(nil, byte(TypeInteger), n), byte(TypeInteger))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := parseInteger(value); err != nil || got != n {
+			t.Errorf("%d: got %d (%v)", n, got, err)
+		}
+	}
+	for _, n := range []uint64{0, 127, 128, math.MaxUint32, math.MaxUint64} {
+		value, _, err := readExpected(appendUnsigned(nil, byte(TypeCounter64), n), byte(TypeCounter64))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := parseUnsigned(value); err != nil || got != n {
+			t.Errorf("%d: got %d (%v)", n, got, err)
+		}
+	}
+}
+
+func TestLongLength(t *testing.T) {
+	long := make([]byte, 300)
+	tag, value, rest, err := readTLV(append(appendTLV(nil, byte(TypeOctetString), long), 0x05, 0x00))
+	if err != nil || tag != byte(TypeOctetString) || len(value) != 300 || len(rest) != 2 {
+		t.Errorf("unexpected decode: tag %#x, %d bytes, rest %d, %v", tag, len(value), len(rest), err)
+	}
+	if _, _, _, err := readTLV([]byte{0x04, 0x82, 0x01}); err == nil {
+		t.Errorf("expected an error for a truncated length")
+	}
+}
+
+func TestParseMessageV2c(t *testing.T) {
+	m := message{version: Version2c, community: "public", pdu: pdu{kind: pduResponse, requestID: -7, vars: []Variable{
+		{OID: OIDSysName, Type: TypeOctetString, Value: []byte("switch-1")},
+		{OID: MustParseOID("1.3.6.1.2.1.4.20.1.1.10.0.0.1"), Type: TypeIPAddress, Value: net.IPv4(10, 0, 0, 1)},
+	}}}
+	b, err := m.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.community != "public" || got.pdu.requestID != -7 || len(got.pdu.vars) != 2 ||
+		got.pdu.vars[0].String() != "switch-1" || got.pdu.vars[1].String() != "10.0.0.1" {
+		t.Errorf("unexpected message %+v", got)
+	}
+	if _, err := parseMessage(b[:len(b)-3]); err == nil {
+		t.Errorf("expected an error for a truncated message")
+	}
+}
@@ -0,0 +1,362 @@
+// Package snmp implements a small SNMP manager: GET and WALK requests over SNMPv1,
+// SNMPv2c and SNMPv3 with the user based security model (noAuthNoPriv and authNoPriv).
+// The prober reads the MIB-2 system group of a device with it.
+package snmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+const (
+	DefaultPort    = 161
+	defaultTimeout = 2 * time.Second
+	// bulkRepetitions is the max-repetitions of the GetBulk requests of a walk.
+	bulkRepetitions = 16
+)
+
+// MIB-2 system group, see RFC 3418.
+var (
+	OIDSysDescr    = MustParseOID("1.3.6.1.2.1.1.1.0")
+	OIDSysObjectID = MustParseOID("1.3.6.1.2.1.1.2.0")
+	OIDSysUpTime   = MustParseOID("1.3.6.1.2.1.1.3.0")
+	OIDSysContact  = MustParseOID("1.3.6.1.2.1.1.4.0")
+	OIDSysName     = MustParseOID("1.3.6.1.2.1.1.5.0")
+	OIDSysLocation = MustParseOID("1.3.6.1.2.1.1.6.0")
+)
+
+var (
+	// ErrTimeout is returned when the agent does not answer, e.g. because of a wrong community.
+	ErrTimeout = errors.New("snmp: request timed out")
+	// ErrAuth is returned when an SNMPv3 agent rejects the user or its credentials.
+	ErrAuth = errors.New("snmp: authentication failed")
+)
+
+// StatusError is the error status of a response PDU, e.g. noSuchName (2) from an SNMPv1 agent.
+type StatusError struct {
+	Status int
+	Index  int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("snmp: error status %d at index %d", e.Status, e.Index)
+}
+
+// Params selects the version and credentials of the requests of a Client.
+type Params struct {
+	Version        Version
+	Community      string // v1 and v2c
+	Username       string // v3
+	AuthProtocol   AuthProtocol
+	AuthPassphrase string
+	Port           int           // DefaultPort when zero
+	Timeout        time.Duration // per attempt
+	Retries        int
+}
+
+// ParamsFromConfig returns the params to try in order, one per community for v1 and v2c.
+func ParamsFromConfig(cfg config.SNMPCredentials) []Params {
+	version, err := ParseVersion(cfg.Version)
+	if err != nil {
+		return nil
+	}
+	base := Params{Version: version, Port: cfg.Port, Timeout: cfg.Timeout, Retries: cfg.Retries}
+	if version == Version3 {
+		auth, err := ParseAuthProtocol(cfg.V3.AuthProtocol)
+		if err != nil {
+			return nil
+		}
+		base.Username, base.AuthProtocol, base.AuthPassphrase = cfg.V3.Username, auth, cfg.V3.AuthPassphrase
+		return []Params{base}
+	}
+	out := make([]Params, 0, len(cfg.Communities))
+	for _, community := range cfg.Communities {
+		p := base
+		p.Community = community
+		out = append(out, p)
+	}
+	return out
+}
+
+// Client sends requests to one agent over a connected UDP socket. It is not safe for concurrent use.
+type Client struct {
+	conn   net.Conn
+	params Params
+	buf    []byte
+
+	// SNMPv3 state learned through engine discovery
+	engineID   []byte
+	engineBoot int32
+	engineTime int32
+	timeBase   time.Time // local time at which engineTime was reported
+	authKey    []byte
+}
+
+// Dial returns a client for the agent at host.
+func Dial(ctx context.Context, host string, params Params) (*Client, error) {
+	if params.Port == 0 {
+		params.Port = DefaultPort
+	}
+	if params.Timeout <= 0 {
+		params.Timeout = defaultTimeout
+	}
+	if params.Version == Version3 && params.Username == "" {
+		return nil, errors.New("snmp: v3 requires a username")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(host, strconv.Itoa(params.Port)))
+	if err != nil {
+		return nil, fmt.Errorf("dial snmp: %w", err)
+	}
+	return &Client{conn: conn, params: params, buf: make([]byte, maxMessageSize)}, nil
+}
+
+func (c *Client) Close() error { return c.conn.Close() }
+
+// Get reads the given OIDs. Missing objects are returned as exception variables
+// by v2c and v3 agents, v1 agents fail the request with a StatusError.
+func (c *Client) Get(ctx context.Context, oids ...OID) ([]Variable, error) {
+	vars := make([]Variable, len(oids))
+	for i, oid := range oids {
+		vars[i] = Variable{OID: oid, Type: TypeNull}
+	}
+	resp, err := c.request(ctx, pdu{kind: pduGet, vars: vars})
+	if err != nil {
+		return nil, err
+	}
+	return resp.vars, nil
+}
+
+// Walk calls fn for every variable in the subtree of root, in MIB order. It uses
+// GetBulk with v2c and v3 and GetNext with v1. An error returned by fn stops the walk.
+func (c *Client) Walk(ctx context.Context, root OID, fn func(Variable) error) error {
+	last := root
+	for {
+		req := pdu{kind: pduGetNext, vars: []Variable{{OID: last, Type: TypeNull}}}
+		if c.params.Version != Version1 {
+			req.kind, req.errorIndex = pduGetBulk, bulkRepetitions
+		}
+		resp, err := c.request(ctx, req)
+		if err != nil {
+			var se *StatusError
+			if errors.As(err, &se) && se.Status == errorNoSuchName {
+				return nil // v1 end of MIB
+			}
+			return err
+		}
+		if len(resp.vars) == 0 {
+			return nil
+		}
+		for _, v := range resp.vars {
+			if v.Type == TypeEndOfMibView || !v.OID.HasPrefix(root) {
+				return nil
+			}
+			if v.OID.Compare(last) <= 0 {
+				return fmt.Errorf("snmp: agent returned %s after %s", v.OID, last)
+			}
+			if err := fn(v); err != nil {
+				return err
+			}
+			last = v.OID
+		}
+	}
+}
+
+// System reads the MIB-2 system group.
+func (c *Client) System(ctx context.Context) (discovery.SNMPSystem, error) {
+	vars, err := c.Get(ctx, OIDSysDescr, OIDSysObjectID, OIDSysUpTime, OIDSysContact, OIDSysName, OIDSysLocation)
+	if err != nil {
+		return discovery.SNMPSystem{}, err
+	}
+	var sys discovery.SNMPSystem
+	for _, v := range vars {
+		if v.Exception() {
+			continue
+		}
+		switch {
+		case v.OID.Compare(OIDSysDescr) == 0:
+			sys.Descr = v.String()
+		case v.OID.Compare(OIDSysObjectID) == 0:
+			sys.ObjectID = v.String()
+		case v.OID.Compare(OIDSysUpTime) == 0:
+			// TimeTicks are hundredths of a second
+			sys.UpTime = time.Duration(v.Uint()) * 10 * time.Millisecond
+		case v.OID.Compare(OIDSysContact) == 0:
+			sys.Contact = v.String()
+		case v.OID.Compare(OIDSysName) == 0:
+			sys.Name = v.String()
+		case v.OID.Compare(OIDSysLocation) == 0:
+			sys.Location = v.String()
+		}
+	}
+	return sys, nil
+}
+
+// request sends req and returns the response PDU, retrying on timeouts.
+func (c *Client) request(ctx context.Context, req pdu) (pdu, error) {
+	if c.params.Version == Version3 && c.engineID == nil {
+		if err := c.discoverEngine(ctx); err != nil {
+			return pdu{}, err
+		}
+	}
+	resynced := false
+	for {
+		resp, err := c.exchange(ctx, req)
+		if err != nil {
+			return pdu{}, err
+		}
+		if resp.pdu.kind == pduReport {
+			// an agent outside the time window reports its current boots and time, retry once with those
+			if !resynced && reportIs(resp.pdu, usmStatsNotInTimeWindows) {
+				c.syncTime(resp.usm)
+				resynced = true
+				continue
+			}
+			return pdu{}, reportError(resp.pdu)
+		}
+		if resp.pdu.errorStatus != 0 {
+			return pdu{}, &StatusError{Status: resp.pdu.errorStatus, Index: resp.pdu.errorIndex}
+		}
+		return resp.pdu, nil
+	}
+}
+
+// discoverEngine learns the engine ID, boots and time of an SNMPv3 agent, see RFC 3414 section 4.
+func (c *Client) discoverEngine(ctx context.Context) error {
+	resp, err := c.exchange(ctx, pdu{kind: pduGet})
+	if err != nil {
+		return err
+	}
+	if len(resp.usm.engineID) == 0 {
+		return errors.New("snmp: agent did not report its engine ID")
+	}
+	c.engineID = append([]byte(nil), resp.usm.engineID...)
+	c.syncTime(resp.usm)
+	c.authKey = localizedKey(c.params.AuthProtocol, c.params.AuthPassphrase, c.engineID)
+	return nil
+}
+
+func (c *Client) syncTime(usm usmParams) {
+	c.engineBoot, c.engineTime, c.timeBase = usm.engineBoot, usm.engineTime, time.Now()
+}
+
+// exchange sends req once per attempt and waits for the matching response.
+func (c *Client) exchange(ctx context.Context, req pdu) (message, error) {
+	for attempt := 0; attempt <= c.params.Retries; attempt++ {
+		req.requestID = rand.Int32()
+		msg := c.message(req)
+		b, err := msg.marshal()
+		if err != nil {
+			return message{}, err
+		}
+		if msg.flags&flagAuth != 0 {
+			if err := sign(b, msg.authOffset, c.params.AuthProtocol, c.authKey); err != nil {
+				return message{}, err
+			}
+		}
+		if _, err := c.conn.Write(b); err != nil {
+			return message{}, fmt.Errorf("send snmp request: %w", err)
+		}
+		resp, err := c.await(ctx, msg)
+		if errors.Is(err, ErrTimeout) && ctx.Err() == nil {
+			continue
+		}
+		return resp, err
+	}
+	return message{}, ErrTimeout
+}
+
+// message wraps req in a message of the configured version.
+func (c *Client) message(req pdu) message {
+	if c.params.Version != Version3 {
+		return message{version: c.params.Version, community: c.params.Community, pdu: req}
+	}
+	m := message{version: Version3, msgID: req.requestID, flags: flagReportable, pdu: req}
+	if c.engineID == nil {
+		// engine discovery, an unauthenticated request without user
+		return m
+	}
+	m.usm = usmParams{
+		engineID:   c.engineID,
+		engineBoot: c.engineBoot,
+		engineTime: c.engineTime + int32(time.Since(c.timeBase)/time.Second),
+		user:       c.params.Username,
+	}
+	m.contextEngineID = c.engineID
+	if c.authKey != nil {
+		m.flags |= flagAuth
+	}
+	return m
+}
+
+// await reads until the response to sent arrives or the attempt times out.
+func (c *Client) await(ctx context.Context, sent message) (message, error) {
+	deadline := time.Now().Add(c.params.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		return message{}, err
+	}
+	for {
+		n, err := c.conn.Read(c.buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				if ctx.Err() != nil {
+					return message{}, ctx.Err()
+				}
+				return message{}, ErrTimeout
+			}
+			return message{}, fmt.Errorf("read snmp response: %w", err)
+		}
+		// the response outlives the read buffer, e.g. the values returned by Get
+		b := append([]byte(nil), c.buf[:n]...)
+		resp, err := parseMessage(b)
+		if err != nil || resp.version != sent.version {
+			continue
+		}
+		if sent.version != Version3 {
+			if resp.pdu.requestID == sent.pdu.requestID && resp.community == sent.community {
+				return resp, nil
+			}
+			continue
+		}
+		if resp.msgID != sent.msgID {
+			continue
+		}
+		if resp.flags&flagAuth != 0 && !verify(b, resp.authOffset, c.params.AuthProtocol, c.authKey) {
+			continue
+		}
+		if sent.flags&flagAuth != 0 && resp.pdu.kind == pduResponse && resp.flags&flagAuth == 0 {
+			continue // unauthenticated answer to an authenticated request
+		}
+		return resp, nil
+	}
+}
+
+func reportIs(p pdu, oid OID) bool {
+	return len(p.vars) > 0 && p.vars[0].OID.Compare(oid) == 0
+}
+
+// reportError maps a USM report to an error.
+func reportError(p pdu) error {
+	switch {
+	case reportIs(p, usmStatsUnknownUserNames), reportIs(p, usmStatsWrongDigests), reportIs(p, usmStatsUnsupportedSecLevels):
+		return fmt.Errorf("%w: report %s", ErrAuth, p.vars[0].OID)
+	case reportIs(p, usmStatsUnknownEngineIDs), reportIs(p, usmStatsNotInTimeWindows):
+		return fmt.Errorf("snmp: agent rejected the engine parameters, report %s", p.vars[0].OID)
+	case len(p.vars) > 0:
+		return fmt.Errorf("snmp: unexpected report %s", p.vars[0].OID)
+	default:
+		return errors.New("snmp: unexpected empty report")
+	}
+}
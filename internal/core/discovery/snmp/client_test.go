@@ -0,0 +1,289 @@
+package snmp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testAgent is an in-process SNMP agent answering from a static MIB.
+type testAgent struct {
+	conn      *net.UDPConn
+	community string
+	user      string
+	auth      AuthProtocol
+	engineID  []byte
+	authKey   []byte
+	mib       []Variable // sorted by OID
+}
+
+// newTestAgent starts an agent serving the system group, ifDescr and the extra objects.
+func newTestAgent(t *testing.T, community string, extra ...Variable) *testAgent {
+	t.Helper()
+	return startTestAgent(t, community, func(*testAgent) {}, extra...)
+}
+
+// newV3TestAgent starts an agent that answers SNMPv3 requests of the given USM user.
+func newV3TestAgent(t *testing.T, user string, auth AuthProtocol, passphrase string) *testAgent {
+	t.Helper()
+	return startTestAgent(t, "", func(a *testAgent) {
+		a.user, a.auth = user, auth
+		a.authKey = localizedKey(auth, passphrase, a.engineID)
+	})
+}
+
+// startTestAgent builds the agent, lets setup adjust it and only then starts serving.
+func startTestAgent(t *testing.T, community string, setup func(*testAgent), extra ...Variable) *testAgent {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &testAgent{
+		conn:      conn,
+		community: community,
+		engineID:  []byte{0x80, 0x00, 0x1f, 0x88, 0x04, 't', 'e', 's', 't'},
+		mib: []Variable{
+			{OID: OIDSysDescr, Type: TypeOctetString, Value: []byte("Linux nas 4.4.302+ #69057 SMP x86_64")},
+			{OID: OIDSysObjectID, Type: TypeOID, Value: MustParseOID("1.3.6.1.4.1.6574.1")},
+			{OID: OIDSysUpTime, Type: TypeTimeTicks, Value: uint64(123456)},
+			{OID: OIDSysContact, Type: TypeOctetString, Value: []byte("admin@example.com")},
+			{OID: OIDSysName, Type: TypeOctetString, Value: []byte("nas")},
+			{OID: OIDSysLocation, Type: TypeOctetString, Value: []byte("rack 2")},
+		},
+	}
+	for i := 1; i <= 40; i++ {
+		a.mib = append(a.mib, Variable{
+			OID:  MustParseOID("1.3.6.1.2.1.2.2.1.2." + strconv.Itoa(i)),
+			Type: TypeOctetString, Value: []byte("eth" + strconv.Itoa(i)),
+		})
+	}
+	a.mib = append(a.mib, Variable{OID: MustParseOID("1.3.6.1.2.1.4.1.0"), Type: TypeInteger, Value: int64(1)})
+	a.mib = append(a.mib, extra...)
+	slices.SortFunc(a.mib, func(x, y Variable) int { return x.OID.Compare(y.OID) })
+	setup(a)
+	go a.serve()
+	t.Cleanup(func() { _ = conn.Close() })
+	return a
+}
+
+func (a *testAgent) params(version Version) Params {
+	return Params{Version: version, Port: a.conn.LocalAddr().(*net.UDPAddr).Port, Timeout: 200 * time.Millisecond}
+}
+
+func (a *testAgent) serve() {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, src, err := a.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		b := append([]byte(nil), buf[:n]...)
+		req, err := parseMessage(b)
+		if err != nil {
+			continue
+		}
+		resp, ok := a.handle(b, req)
+		if !ok {
+			continue
+		}
+		out, err := resp.marshal()
+		if err != nil {
+			continue
+		}
+		if resp.flags&flagAuth != 0 {
+			_ = sign(out, resp.authOffset, a.auth, a.authKey)
+		}
+		_, _ = a.conn.WriteToUDP(out, src)
+	}
+}
+
+func (a *testAgent) handle(raw []byte, req message) (message, bool) {
+	if req.version != Version3 {
+		if req.community != a.community {
+			return message{}, false // wrong communities are ignored
+		}
+		resp := req
+		resp.pdu = a.respond(req.version, req.pdu)
+		return resp, true
+	}
+
+	resp := message{
+		version: Version3,
+		msgID:   req.msgID,
+		usm:     usmParams{engineID: a.engineID, engineBoot: 1, engineTime: 100, user: req.usm.user},
+	}
+	report := func(oid OID) (message, bool) {
+		resp.pdu = pdu{kind: pduReport, requestID: req.pdu.requestID, vars: []Variable{{OID: oid, Type: TypeCounter32, Value: uint64(1)}}}
+		return resp, true
+	}
+	switch {
+	case len(req.usm.engineID) == 0:
+		return report(usmStatsUnknownEngineIDs)
+	case req.usm.user != a.user:
+		return report(usmStatsUnknownUserNames)
+	case (req.flags&flagAuth != 0) != (a.auth != AuthNone):
+		return report(usmStatsUnsupportedSecLevels)
+	case a.auth != AuthNone && !verify(raw, req.authOffset, a.auth, a.authKey):
+		return report(usmStatsWrongDigests)
+	}
+	resp.flags = req.flags &^ flagReportable
+	resp.contextEngineID = a.engineID
+	resp.pdu = a.respond(Version3, req.pdu)
+	return resp, true
+}
+
+func (a *testAgent) respond(version Version, req pdu) pdu {
+	resp := pdu{kind: pduResponse, requestID: req.requestID}
+	switch req.kind {
+	case pduGet:
+		for i, v := range req.vars {
+			idx := slices.IndexFunc(a.mib, func(m Variable) bool { return m.OID.Compare(v.OID) == 0 })
+			switch {
+			case idx >= 0:
+				resp.vars = append(resp.vars, a.mib[idx])
+			case version == Version1:
+				return pdu{kind: pduResponse, requestID: req.requestID, errorStatus: errorNoSuchName, errorIndex: i + 1, vars: req.vars}
+			default:
+				resp.vars = append(resp.vars, Variable{OID: v.OID, Type: TypeNoSuchObject})
+			}
+		}
+	case pduGetNext, pduGetBulk:
+		repetitions := 1
+		if req.kind == pduGetBulk {
+			repetitions = req.errorIndex
+		}
+		last := req.vars[0].OID
+		for range repetitions {
+			idx := slices.IndexFunc(a.mib, func(m Variable) bool { return m.OID.Compare(last) > 0 })
+			if idx < 0 {
+				if version == Version1 {
+					return pdu{kind: pduResponse, requestID: req.requestID, errorStatus: errorNoSuchName, errorIndex: 1, vars: req.vars}
+				}
+				resp.vars = append(resp.vars, Variable{OID: last, Type: TypeEndOfMibView})
+				break
+			}
+			resp.vars = append(resp.vars, a.mib[idx])
+			last = a.mib[idx].OID
+		}
+	}
+	return resp
+}
+
+func dialAgent(t *testing.T, params Params) *Client {
+	t.Helper()
+	c, err := Dial(context.Background(), "127.0.0.1", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestSystem(t *testing.T) {
+	agent := newTestAgent(t, "public")
+	for _, version := range []Version{Version1, Version2c} {
+		params := agent.params(version)
+		params.Community = "public"
+		sys, err := dialAgent(t, params).System(context.Background())
+		if err != nil {
+			t.Fatalf("v%s: %v", version, err)
+		}
+		if sys.Name != "nas" || sys.Location != "rack 2" || sys.Contact != "admin@example.com" ||
+			sys.ObjectID != "1.3.6.1.4.1.6574.1" || sys.UpTime != 1234560*time.Millisecond ||
+			sys.Descr != "Linux nas 4.4.302+ #69057 SMP x86_64" {
+			t.Errorf("v%s: unexpected system group %+v", version, sys)
+		}
+	}
+}
+
+func TestGetMissingObject(t *testing.T) {
+	agent := newTestAgent(t, "public")
+	missing := MustParseOID("1.3.6.1.2.1.1.9.0")
+
+	params := agent.params(Version2c)
+	params.Community = "public"
+	vars, err := dialAgent(t, params).Get(context.Background(), OIDSysName, missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 2 || vars[0].String() != "nas" || vars[1].Type != TypeNoSuchObject {
+		t.Errorf("unexpected variables %+v", vars)
+	}
+
+	params.Version = Version1
+	_, err = dialAgent(t, params).Get(context.Background(), OIDSysName, missing)
+	var se *StatusError
+	if !errors.As(err, &se) || se.Status != errorNoSuchName || se.Index != 2 {
+		t.Errorf("expected noSuchName at index 2, got %v", err)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	agent := newTestAgent(t, "public")
+	root := MustParseOID("1.3.6.1.2.1.2.2.1.2")
+	for _, version := range []Version{Version1, Version2c} {
+		params := agent.params(version)
+		params.Community = "public"
+		var names []string
+		err := dialAgent(t, params).Walk(context.Background(), root, func(v Variable) error {
+			names = append(names, v.String())
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("v%s: %v", version, err)
+		}
+		if len(names) != 40 || names[0] != "eth1" || names[39] != "eth40" {
+			t.Errorf("v%s: unexpected walk %v", version, names)
+		}
+	}
+
+	// walking the last subtree ends at the end of the MIB
+	params := agent.params(Version2c)
+	params.Community = "public"
+	count := 0
+	err := dialAgent(t, params).Walk(context.Background(), MustParseOID("1.3.6.1.2.1.4"), func(Variable) error {
+		count++
+		return nil
+	})
+	if err != nil || count != 1 {
+		t.Errorf("expected one variable, got %d (%v)", count, err)
+	}
+}
+
+func TestWrongCommunityTimesOut(t *testing.T) {
+	agent := newTestAgent(t, "public")
+	params := agent.params(Version2c)
+	params.Community = "private"
+	params.Timeout = 50 * time.Millisecond
+	if _, err := dialAgent(t, params).System(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected timeout, got %v", err)
+	}
+}
+
+func TestSystemV3(t *testing.T) {
+	for _, auth := range []AuthProtocol{AuthNone, AuthMD5, AuthSHA} {
+		agent := newV3TestAgent(t, "monitor", auth, "maplesyrup")
+		params := agent.params(Version3)
+		params.Username, params.AuthProtocol, params.AuthPassphrase = "monitor", auth, "maplesyrup"
+		sys, err := dialAgent(t, params).System(context.Background())
+		if err != nil {
+			t.Fatalf("auth %q: %v", auth, err)
+		}
+		if sys.Name != "nas" {
+			t.Errorf("auth %q: unexpected system group %+v", auth, sys)
+		}
+
+		if auth == AuthNone {
+			continue
+		}
+		params.AuthPassphrase = "pancakesyrup"
+		if _, err := dialAgent(t, params).System(context.Background()); !errors.Is(err, ErrAuth) {
+			t.Errorf("auth %q: expected authentication error for a wrong passphrase, got %v", auth, err)
+		}
+	}
+}
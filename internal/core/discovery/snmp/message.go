@@ -0,0 +1,424 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Version is the SNMP message version as encoded on the wire.
+type Version int
+
+const (
+	Version1  Version = 0
+	Version2c Version = 1
+	Version3  Version = 3
+)
+
+// ParseVersion parses the config form of a version: 1, 2c or 3.
+func ParseVersion(s string) (Version, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "v1":
+		return Version1, nil
+	case "2c", "v2c", "2":
+		return Version2c, nil
+	case "3", "v3":
+		return Version3, nil
+	default:
+		return 0, fmt.Errorf("snmp: unknown version %q", s)
+	}
+}
+
+func (v Version) String() string {
+	switch v {
+	case Version1:
+		return "1"
+	case Version2c:
+		return "2c"
+	case Version3:
+		return "3"
+	default:
+		return "unknown(" + strconv.Itoa(int(v)) + ")"
+	}
+}
+
+// PDU types, see RFC 3416 section 3.
+const (
+	pduGet      = 0xa0
+	pduGetNext  = 0xa1
+	pduResponse = 0xa2
+	pduGetBulk  = 0xa5
+	pduReport   = 0xa8
+)
+
+// errorNoSuchName is the SNMPv1 error status of a Get for an unknown OID.
+const errorNoSuchName = 2
+
+// Variable is one variable binding of a PDU.
+type Variable struct {
+	OID   OID
+	Type  Type
+	Value any // int64, uint64, []byte, OID, net.IP or nil for Null and the exceptions
+}
+
+// Exception reports whether the variable carries noSuchObject, noSuchInstance or endOfMibView.
+func (v Variable) Exception() bool {
+	return v.Type == TypeNoSuchObject || v.Type == TypeNoSuchInstance || v.Type == TypeEndOfMibView
+}
+
+// Bytes returns the value of an OCTET STRING or Opaque variable.
+func (v Variable) Bytes() []byte {
+	b, _ := v.Value.([]byte)
+	return b
+}
+
+// Uint returns the value of a numeric variable.
+func (v Variable) Uint() uint64 {
+	switch n := v.Value.(type) {
+	case uint64:
+		return n
+	case int64:
+		if n >= 0 {
+			return uint64(n)
+		}
+	}
+	return 0
+}
+
+// String renders the value, octet strings that are not printable are shown as hex.
+func (v Variable) String() string {
+	switch val := v.Value.(type) {
+	case []byte:
+		s := strings.TrimRight(string(val), "\x00")
+		if utf8.ValidString(s) && !strings.ContainsFunc(s, func(r rune) bool { return r < 0x20 && r != '\n' && r != '\r' && r != '\t' }) {
+			return s
+		}
+		return fmt.Sprintf("%x", val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case uint64:
+		return strconv.FormatUint(val, 10)
+	case OID:
+		return val.String()
+	case net.IP:
+		return val.String()
+	}
+	return ""
+}
+
+func (v Variable) append(b []byte) []byte {
+	value := appendOID(nil, v.OID)
+	switch val := v.Value.(type) {
+	case int64:
+		value = appendInteger(value, byte(v.Type), val)
+	case uint64:
+		value = appendUnsigned(value, byte(v.Type), val)
+	case []byte:
+		value = appendTLV(value, byte(v.Type), val)
+	case OID:
+		value = appendOID(value, val)
+	case net.IP:
+		value = appendTLV(value, byte(TypeIPAddress), val.To4())
+	default:
+		typ := v.Type
+		if typ == 0 {
+			typ = TypeNull
+		}
+		value = appendTLV(value, byte(typ), nil)
+	}
+	return appendTLV(b, tagSequence, value)
+}
+
+func parseVariable(b []byte) (Variable, error) {
+	oidBytes, rest, err := readExpected(b, byte(TypeOID))
+	if err != nil {
+		return Variable{}, err
+	}
+	oid, err := parseOID(oidBytes)
+	if err != nil {
+		return Variable{}, err
+	}
+	tag, value, _, err := readTLV(rest)
+	if err != nil {
+		return Variable{}, err
+	}
+	v := Variable{OID: oid, Type: Type(tag)}
+	switch v.Type {
+	case TypeInteger:
+		v.Value, err = parseInteger(value)
+	case TypeOctetString, TypeOpaque:
+		v.Value = value
+	case TypeOID:
+		v.Value, err = parseOID(value)
+	case TypeIPAddress:
+		if len(value) != net.IPv4len {
+			return Variable{}, fmt.Errorf("snmp: invalid IpAddress of %d bytes", len(value))
+		}
+		v.Value = net.IP(value)
+	case TypeCounter32, TypeGauge32, TypeTimeTicks, TypeCounter64:
+		v.Value, err = parseUnsigned(value)
+	case TypeNull, TypeNoSuchObject, TypeNoSuchInstance, TypeEndOfMibView:
+	default:
+		v.Value = value
+	}
+	return v, err
+}
+
+// pdu is a request, response or report PDU. For GetBulk the error status and index
+// fields carry non-repeaters and max-repetitions.
+type pdu struct {
+	kind        byte
+	requestID   int32
+	errorStatus int
+	errorIndex  int
+	vars        []Variable
+}
+
+func (p pdu) append(b []byte) []byte {
+	value := appendInteger(nil, byte(TypeInteger), int64(p.requestID))
+	value = appendInteger(value, byte(TypeInteger), int64(p.errorStatus))
+	value = appendInteger(value, byte(TypeInteger), int64(p.errorIndex))
+	var list []byte
+	for _, v := range p.vars {
+		list = v.append(list)
+	}
+	value = appendTLV(value, tagSequence, list)
+	return appendTLV(b, p.kind, value)
+}
+
+func parsePDU(b []byte) (pdu, error) {
+	tag, value, _, err := readTLV(b)
+	if err != nil {
+		return pdu{}, err
+	}
+	if tag < pduGet || tag > pduReport {
+		return pdu{}, fmt.Errorf("snmp: unexpected PDU type %#02x", tag)
+	}
+	p := pdu{kind: tag}
+	var id, status, index int64
+	if id, value, err = readInteger(value); err != nil {
+		return pdu{}, err
+	}
+	if status, value, err = readInteger(value); err != nil {
+		return pdu{}, err
+	}
+	if index, value, err = readInteger(value); err != nil {
+		return pdu{}, err
+	}
+	p.requestID, p.errorStatus, p.errorIndex = int32(id), int(status), int(index)
+
+	list, _, err := readExpected(value, tagSequence)
+	if err != nil {
+		return pdu{}, err
+	}
+	for len(list) > 0 {
+		var binding []byte
+		if binding, list, err = readExpected(list, tagSequence); err != nil {
+			return pdu{}, err
+		}
+		v, err := parseVariable(binding)
+		if err != nil {
+			return pdu{}, err
+		}
+		p.vars = append(p.vars, v)
+	}
+	return p, nil
+}
+
+// msgFlags of an SNMPv3 message, see RFC 3412 section 6.4.
+const (
+	flagAuth       = 0x01
+	flagPriv       = 0x02
+	flagReportable = 0x04
+)
+
+// securityModelUSM is the user based security model of RFC 3414.
+const securityModelUSM = 3
+
+// maxMessageSize is the largest message we accept, it is announced in SNMPv3 requests.
+const maxMessageSize = 65507
+
+// authParamsLen is the length of the truncated HMAC of the authentication protocols.
+const authParamsLen = 12
+
+// message is an SNMP message of any version. community is used by v1 and v2c,
+// the remaining fields by v3.
+type message struct {
+	version   Version
+	community string
+
+	msgID           int32
+	flags           byte
+	usm             usmParams
+	contextEngineID []byte
+	contextName     string
+
+	pdu pdu
+
+	// authOffset is the offset of the authentication parameters in the parsed
+	// or marshaled message, -1 when there are none.
+	authOffset int
+}
+
+// usmParams are the msgSecurityParameters of the user based security model.
+type usmParams struct {
+	engineID   []byte
+	engineBoot int32
+	engineTime int32
+	user       string
+	authParams []byte
+	privParams []byte
+}
+
+// marshal encodes the message. An authenticated v3 message gets zeroed
+// authentication parameters, they are filled in by sign.
+func (m *message) marshal() ([]byte, error) {
+	value := appendInteger(nil, byte(TypeInteger), int64(m.version))
+	if m.version != Version3 {
+		value = appendTLV(value, byte(TypeOctetString), []byte(m.community))
+		value = m.pdu.append(value)
+		m.authOffset = -1
+		return appendTLV(nil, tagSequence, value), nil
+	}
+
+	global := appendInteger(nil, byte(TypeInteger), int64(m.msgID))
+	global = appendInteger(global, byte(TypeInteger), maxMessageSize)
+	global = appendTLV(global, byte(TypeOctetString), []byte{m.flags})
+	global = appendInteger(global, byte(TypeInteger), securityModelUSM)
+	value = appendTLV(value, tagSequence, global)
+
+	authParams := m.usm.authParams
+	if m.flags&flagAuth != 0 {
+		authParams = make([]byte, authParamsLen)
+	}
+	sec := appendTLV(nil, byte(TypeOctetString), m.usm.engineID)
+	sec = appendInteger(sec, byte(TypeInteger), int64(m.usm.engineBoot))
+	sec = appendInteger(sec, byte(TypeInteger), int64(m.usm.engineTime))
+	sec = appendTLV(sec, byte(TypeOctetString), []byte(m.usm.user))
+	sec = appendTLV(sec, byte(TypeOctetString), authParams)
+	sec = appendTLV(sec, byte(TypeOctetString), m.usm.privParams)
+	value = appendTLV(value, byte(TypeOctetString), appendTLV(nil, tagSequence, sec))
+
+	scoped := appendTLV(nil, byte(TypeOctetString), m.contextEngineID)
+	scoped = appendTLV(scoped, byte(TypeOctetString), []byte(m.contextName))
+	scoped = m.pdu.append(scoped)
+	value = appendTLV(value, tagSequence, scoped)
+
+	b := appendTLV(nil, tagSequence, value)
+	// parse our own encoding to locate the authentication parameters
+	parsed, err := parseMessage(b)
+	if err != nil {
+		return nil, err
+	}
+	m.authOffset = parsed.authOffset
+	return b, nil
+}
+
+// parseMessage decodes an SNMP message of any version.
+func parseMessage(b []byte) (message, error) {
+	body, _, err := readExpected(b, tagSequence)
+	if err != nil {
+		return message{}, err
+	}
+	version, body, err := readInteger(body)
+	if err != nil {
+		return message{}, err
+	}
+	m := message{version: Version(version), authOffset: -1}
+
+	switch m.version {
+	case Version1, Version2c:
+		community, rest, err := readExpected(body, byte(TypeOctetString))
+		if err != nil {
+			return message{}, err
+		}
+		m.community = string(community)
+		m.pdu, err = parsePDU(rest)
+		return m, err
+	case Version3:
+	default:
+		return message{}, fmt.Errorf("snmp: unsupported version %d", version)
+	}
+
+	global, body, err := readExpected(body, tagSequence)
+	if err != nil {
+		return message{}, err
+	}
+	msgID, global, err := readInteger(global)
+	if err != nil {
+		return message{}, err
+	}
+	m.msgID = int32(msgID)
+	if _, global, err = readInteger(global); err != nil {
+		return message{}, err
+	}
+	flags, global, err := readExpected(global, byte(TypeOctetString))
+	if err != nil || len(flags) != 1 {
+		return message{}, errors.New("snmp: invalid msgFlags")
+	}
+	m.flags = flags[0]
+	model, _, err := readInteger(global)
+	if err != nil {
+		return message{}, err
+	}
+	if model != securityModelUSM {
+		return message{}, fmt.Errorf("snmp: unsupported security model %d", model)
+	}
+
+	secOctets, body, err := readExpected(body, byte(TypeOctetString))
+	if err != nil {
+		return message{}, err
+	}
+	sec, _, err := readExpected(secOctets, tagSequence)
+	if err != nil {
+		return message{}, err
+	}
+	var n int64
+	var user []byte
+	if m.usm.engineID, sec, err = readExpected(sec, byte(TypeOctetString)); err != nil {
+		return message{}, err
+	}
+	if n, sec, err = readInteger(sec); err != nil {
+		return message{}, err
+	}
+	m.usm.engineBoot = int32(n)
+	if n, sec, err = readInteger(sec); err != nil {
+		return message{}, err
+	}
+	m.usm.engineTime = int32(n)
+	if user, sec, err = readExpected(sec, byte(TypeOctetString)); err != nil {
+		return message{}, err
+	}
+	m.usm.user = string(user)
+	if m.usm.authParams, sec, err = readExpected(sec, byte(TypeOctetString)); err != nil {
+		return message{}, err
+	}
+	if len(m.usm.authParams) > 0 {
+		// the value shares the memory of b, its capacity reveals its position
+		m.authOffset = cap(b) - cap(m.usm.authParams)
+	}
+	if m.usm.privParams, _, err = readExpected(sec, byte(TypeOctetString)); err != nil {
+		return message{}, err
+	}
+	if m.flags&flagPriv != 0 {
+		return message{}, errors.New("snmp: encrypted messages are not supported")
+	}
+
+	scoped, _, err := readExpected(body, tagSequence)
+	if err != nil {
+		return message{}, err
+	}
+	var contextName []byte
+	if m.contextEngineID, scoped, err = readExpected(scoped, byte(TypeOctetString)); err != nil {
+		return message{}, err
+	}
+	if contextName, scoped, err = readExpected(scoped, byte(TypeOctetString)); err != nil {
+		return message{}, err
+	}
+	m.contextName = string(contextName)
+	m.pdu, err = parsePDU(scoped)
+	return m, err
+}
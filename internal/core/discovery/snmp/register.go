@@ -0,0 +1,50 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+// Config is the `scanners.snmp` config block.
+type Config struct {
+	config.ScannerToggle   `yaml:",inline"`
+	config.SNMPCredentials `yaml:",inline"`
+	// Targets are the routers and L3 switches to query, "host" or "host:port".
+	Targets []string `yaml:"targets"`
+	// Bridge also walks the forwarding tables of the targets to find the switch port of every host.
+	Bridge bool `yaml:"bridge"`
+}
+
+// Validate checks the credentials and requires targets when the scanner is enabled.
+func (c *Config) Validate() error {
+	errs := c.Normalize()
+	if c.Enabled && len(c.Targets) == 0 {
+		errs = append(errs, "targets must not be empty")
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func init() {
+	discovery.Register(discovery.Registration{
+		Name:           Name,
+		Description:    "ARP, neighbor and bridge forwarding tables of routers and switches over SNMP",
+		DefaultEnabled: false,
+		NewConfig: func() config.ScannerSettings {
+			return &Config{SNMPCredentials: config.DefaultSNMPCredentials(), Bridge: true}
+		},
+		New: func(iface *discovery.InterfaceInfo, settings config.ScannerSettings) (discovery.Scanner, error) {
+			cfg, ok := settings.(*Config)
+			if !ok {
+				return nil, fmt.Errorf("unexpected config type %T", settings)
+			}
+			return NewScanner(iface, cfg.Targets, ParamsFromConfig(cfg.SNMPCredentials), cfg.Bridge), nil
+		},
+	})
+}
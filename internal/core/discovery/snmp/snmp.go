@@ -0,0 +1,233 @@
+package snmp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"go.uber.org/zap"
+)
+
+// Name is the scanner name and the source of devices found in the tables of routers and switches.
+const Name = "snmp"
+
+// ExtraData keys of the devices found by the scanner.
+const (
+	ExtraRouter          = "snmp_router"           // sysName (or address) of the router the ARP entry was read from
+	ExtraRouterInterface = "snmp_router_interface" // router interface the entry was learned on
+	ExtraSwitch          = "snmp_switch"           // sysName (or address) of the switch the MAC was learned by
+	ExtraSwitchPort      = "snmp_switch_port"      // switch port the MAC was learned on
+)
+
+// emitMargin is the part of the scan window reserved to emit the devices after the walks.
+const emitMargin = 500 * time.Millisecond
+
+var _ discovery.InterfaceScanner = (*Scanner)(nil)
+
+// Scanner inventories hosts beyond the local segment by walking the ARP and IPv6 neighbor
+// tables of routers and L3 switches over SNMP. With bridge set it also walks the forwarding
+// tables of the targets to report the switch port every MAC address was learned on.
+// Every scanner only queries the targets it reaches through its own interface, so scanning
+// several interfaces does not query a router twice.
+type Scanner struct {
+	iface   *discovery.InterfaceInfo
+	targets []string
+	params  []Params
+	bridge  bool
+}
+
+// NewScanner returns a scanner querying targets ("host" or "host:port") with the params tried in order.
+func NewScanner(iface *discovery.InterfaceInfo, targets []string, params []Params, bridge bool) *Scanner {
+	return &Scanner{iface: iface, targets: targets, params: params, bridge: bridge}
+}
+
+func (s *Scanner) Name() string { return Name }
+
+// Interface returns the network interface the scanner is bound to.
+func (s *Scanner) Interface() *discovery.InterfaceInfo { return s.iface }
+
+// tables holds what was read from one target.
+type tables struct {
+	name      string // sysName, or the target when it has none
+	neighbors []Neighbor
+	fdb       []FDBEntry
+	ifNames   map[int]string
+}
+
+// Scan walks the tables of every target concurrently and emits a device per neighbor entry.
+func (s *Scanner) Scan(ctx context.Context, out chan<- discovery.Device) error {
+	log := zap.L().With(zap.String("scanner", Name))
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return errors.New("snmp scan requires context with deadline")
+	}
+	walkCtx, cancel := context.WithDeadline(ctx, deadline.Add(-emitMargin))
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		results []tables
+		errs    []error
+		wg      sync.WaitGroup
+	)
+	for _, target := range s.targets {
+		host, port := splitTarget(target)
+		if !s.iface.RoutesTo(host) {
+			log.Debug("target not reached through interface, skipping", zap.String("target", target))
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t, err := s.walk(walkCtx, host, port)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Debug("walk failed", zap.String("target", target), zap.Error(err))
+				errs = append(errs, err)
+			}
+			if t.name != "" {
+				results = append(results, t)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, d := range devices(results) {
+		select {
+		case out <- d:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if len(results) == 0 && len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// walk reads the tables of one target with the first params it answers. The tables read
+// before an error are returned with it, t.name is empty when the target did not answer.
+func (s *Scanner) walk(ctx context.Context, host string, port int) (tables, error) {
+	var lastErr error
+	for _, p := range s.params {
+		if port != 0 {
+			p.Port = port
+		}
+		client, err := Dial(ctx, host, p)
+		if err != nil {
+			return tables{}, err
+		}
+		t, err := s.walkClient(ctx, client, host)
+		_ = client.Close()
+		if t.name != "" {
+			return t, err
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return tables{}, lastErr
+}
+
+func (s *Scanner) walkClient(ctx context.Context, client *Client, host string) (tables, error) {
+	// sysName selects the working credentials and names the target
+	vars, err := client.Get(ctx, OIDSysName)
+	if err != nil {
+		return tables{}, err
+	}
+	t := tables{name: host}
+	if len(vars) == 1 && !vars[0].Exception() && vars[0].String() != "" {
+		t.name = vars[0].String()
+	}
+	if t.ifNames, err = client.InterfaceNames(ctx); err != nil {
+		return t, err
+	}
+	if t.neighbors, err = client.Neighbors(ctx); err != nil {
+		return t, err
+	}
+	if s.bridge {
+		t.fdb, err = client.ForwardingTable(ctx)
+	}
+	return t, err
+}
+
+// splitTarget splits an optional port off a target, port is 0 when there is none.
+func splitTarget(target string) (string, int) {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return strings.Trim(target, "[]"), 0
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return host, 0
+	}
+	return host, port
+}
+
+// devices joins the neighbor entries of all targets with the forwarding tables. A MAC
+// address is learned on the uplinks of every switch it passes, it is attributed to the
+// port that learned the fewest addresses, which is the access port the host is attached to.
+func devices(results []tables) []discovery.Device {
+	type location struct {
+		device string
+		port   string
+		macs   int // addresses learned on the port
+	}
+	edge := map[string]location{}
+	for _, t := range results {
+		perPort := map[int]int{}
+		for _, e := range t.fdb {
+			perPort[e.Port]++
+		}
+		for _, e := range t.fdb {
+			loc := location{device: t.name, port: portName(t.ifNames, e.IfIndex, e.Port), macs: perPort[e.Port]}
+			key := e.MAC.String()
+			if cur, ok := edge[key]; !ok || loc.macs < cur.macs {
+				edge[key] = loc
+			}
+		}
+	}
+
+	var out []discovery.Device
+	seen := map[string]bool{}
+	for _, t := range results {
+		for _, n := range t.neighbors {
+			if n.IP.IsLinkLocalUnicast() || n.IP.IsUnspecified() {
+				continue
+			}
+			key := n.MAC.String() + "/" + n.IP.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			d := discovery.NewDevice(n.IP)
+			d.MAC = n.MAC.String()
+			d.Sources[Name] = struct{}{}
+			d.ExtraData[ExtraRouter] = t.name
+			if name := t.ifNames[n.IfIndex]; name != "" {
+				d.ExtraData[ExtraRouterInterface] = name
+			}
+			if loc, ok := edge[d.MAC]; ok {
+				d.ExtraData[ExtraSwitch] = loc.device
+				d.ExtraData[ExtraSwitchPort] = loc.port
+			}
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func portName(ifNames map[int]string, ifIndex, port int) string {
+	if name := ifNames[ifIndex]; name != "" {
+		return name
+	}
+	return "port " + strconv.Itoa(port)
+}
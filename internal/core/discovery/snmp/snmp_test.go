@@ -0,0 +1,218 @@
+package snmp
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+var (
+	macCamera  = net.HardwareAddr{0x00, 0x11, 0x32, 0xaa, 0xbb, 0x01}
+	macPrinter = net.HardwareAddr{0x00, 0x11, 0x32, 0xaa, 0xbb, 0x02}
+	macUplink  = net.HardwareAddr{0x00, 0x11, 0x32, 0xaa, 0xbb, 0x03}
+)
+
+func macIndex(mac net.HardwareAddr) string {
+	s := ""
+	for _, b := range mac {
+		s += "." + strconv.Itoa(int(b))
+	}
+	return s
+}
+
+func integer(oid string, n int64) Variable {
+	return Variable{OID: MustParseOID(oid), Type: TypeInteger, Value: n}
+}
+
+func octetString(oid string, b []byte) Variable {
+	return Variable{OID: MustParseOID(oid), Type: TypeOctetString, Value: b}
+}
+
+// routerTables is a router with a built-in switch: interface 1 is an access
+// port with the camera, interface 24 the uplink the printer is learned on.
+func routerTables() []Variable {
+	return []Variable{
+		octetString("1.3.6.1.2.1.31.1.1.1.1.1", []byte("ge-0/0/1")),
+		octetString("1.3.6.1.2.1.31.1.1.1.1.24", []byte("ge-0/0/24")),
+		octetString("1.3.6.1.2.1.31.1.1.1.1.100", []byte("vlan10")),
+
+		// ipNetToPhysicalPhysAddress and ipNetToPhysicalType: dynamic, local and invalid entries
+		octetString("1.3.6.1.2.1.4.35.1.4.100.1.4.10.0.10.21", macCamera),
+		octetString("1.3.6.1.2.1.4.35.1.4.100.1.4.10.0.10.22", macPrinter),
+		octetString("1.3.6.1.2.1.4.35.1.4.100.1.4.10.0.10.1", macUplink),
+		octetString("1.3.6.1.2.1.4.35.1.4.100.1.4.10.0.10.99", macUplink),
+		octetString("1.3.6.1.2.1.4.35.1.4.100.2.16.254.128.0.0.0.0.0.0.2.17.50.255.254.170.187.1", macCamera),
+		integer("1.3.6.1.2.1.4.35.1.6.100.1.4.10.0.10.21", 3),
+		integer("1.3.6.1.2.1.4.35.1.6.100.1.4.10.0.10.22", 3),
+		integer("1.3.6.1.2.1.4.35.1.6.100.1.4.10.0.10.1", neighborLocal),
+		integer("1.3.6.1.2.1.4.35.1.6.100.1.4.10.0.10.99", neighborInvalid),
+		integer("1.3.6.1.2.1.4.35.1.6.100.2.16.254.128.0.0.0.0.0.0.2.17.50.255.254.170.187.1", 3),
+
+		// dot1dBasePortIfIndex
+		integer("1.3.6.1.2.1.17.1.4.1.2.1", 1),
+		integer("1.3.6.1.2.1.17.1.4.1.2.24", 24),
+
+		// dot1qTpFdbPort and dot1qTpFdbStatus in VLAN 10, the camera is also seen on the uplink of another VLAN
+		integer("1.3.6.1.2.1.17.7.1.2.2.1.2.10"+macIndex(macCamera), 1),
+		integer("1.3.6.1.2.1.17.7.1.2.2.1.2.10"+macIndex(macPrinter), 24),
+		integer("1.3.6.1.2.1.17.7.1.2.2.1.2.10"+macIndex(macUplink), 24),
+		integer("1.3.6.1.2.1.17.7.1.2.2.1.2.20"+macIndex(macCamera), 24),
+		integer("1.3.6.1.2.1.17.7.1.2.2.1.3.10"+macIndex(macCamera), fdbLearned),
+		integer("1.3.6.1.2.1.17.7.1.2.2.1.3.10"+macIndex(macPrinter), fdbLearned),
+		integer("1.3.6.1.2.1.17.7.1.2.2.1.3.10"+macIndex(macUplink), 4),
+		integer("1.3.6.1.2.1.17.7.1.2.2.1.3.20"+macIndex(macCamera), fdbLearned),
+	}
+}
+
+func routerParams(agent *testAgent) Params {
+	params := agent.params(Version2c)
+	params.Community = "public"
+	return params
+}
+
+func TestNeighbors(t *testing.T) {
+	agent := newTestAgent(t, "public", routerTables()...)
+	neighbors, err := dialAgent(t, routerParams(agent)).Neighbors(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]Neighbor{}
+	for _, n := range neighbors {
+		got[n.IP.String()] = n
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected camera, printer and link-local camera, got %+v", neighbors)
+	}
+	if n := got["10.0.10.21"]; n.MAC.String() != macCamera.String() || n.IfIndex != 100 {
+		t.Errorf("unexpected camera entry %+v", n)
+	}
+	if _, ok := got["fe80::211:32ff:feaa:bb01"]; !ok {
+		t.Errorf("expected IPv6 neighbor, got %+v", neighbors)
+	}
+}
+
+func TestNeighborsMediaTable(t *testing.T) {
+	agent := newTestAgent(t, "public",
+		octetString("1.3.6.1.2.1.4.22.1.2.3.192.168.5.7", macPrinter),
+		integer("1.3.6.1.2.1.4.22.1.4.3.192.168.5.7", 3),
+	)
+	neighbors, err := dialAgent(t, routerParams(agent)).Neighbors(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(neighbors) != 1 || neighbors[0].IP.String() != "192.168.5.7" || neighbors[0].IfIndex != 3 {
+		t.Errorf("unexpected neighbors %+v", neighbors)
+	}
+}
+
+func TestForwardingTable(t *testing.T) {
+	agent := newTestAgent(t, "public", routerTables()...)
+	fdb, err := dialAgent(t, routerParams(agent)).ForwardingTable(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fdb) != 3 {
+		t.Fatalf("expected the learned entries only, got %+v", fdb)
+	}
+	for _, e := range fdb {
+		if e.IfIndex != e.Port {
+			t.Errorf("expected port %d mapped to its interface, got %+v", e.Port, e)
+		}
+	}
+}
+
+func TestForwardingTableDot1d(t *testing.T) {
+	agent := newTestAgent(t, "public",
+		integer("1.3.6.1.2.1.17.4.3.1.2"+macIndex(macPrinter), 7),
+		integer("1.3.6.1.2.1.17.4.3.1.3"+macIndex(macPrinter), fdbLearned),
+	)
+	fdb, err := dialAgent(t, routerParams(agent)).ForwardingTable(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fdb) != 1 || fdb[0].MAC.String() != macPrinter.String() || fdb[0].Port != 7 || fdb[0].IfIndex != 0 {
+		t.Errorf("unexpected forwarding table %+v", fdb)
+	}
+}
+
+func TestScan(t *testing.T) {
+	agent := newTestAgent(t, "public", routerTables()...)
+	loopback := net.IPv4(127, 0, 0, 1)
+	iface := &discovery.InterfaceInfo{IPv4Addr: &loopback}
+	target := "127.0.0.1:" + strconv.Itoa(agent.conn.LocalAddr().(*net.UDPAddr).Port)
+
+	params := routerParams(agent)
+	wrong := params
+	wrong.Community, wrong.Timeout = "private", 50*time.Millisecond
+	s := NewScanner(iface, []string{target}, []Params{wrong, params}, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	out := make(chan discovery.Device, 10)
+	if err := s.Scan(ctx, out); err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+
+	got := map[string]discovery.Device{}
+	for d := range out {
+		got[d.IP.String()] = d
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected camera and printer, got %v", got)
+	}
+	camera := got["10.0.10.21"]
+	if camera.MAC != macCamera.String() || camera.ExtraData[ExtraRouter] != "nas" ||
+		camera.ExtraData[ExtraRouterInterface] != "vlan10" ||
+		camera.ExtraData[ExtraSwitchPort] != "ge-0/0/1" {
+		t.Errorf("unexpected camera %+v", camera)
+	}
+	if _, ok := camera.Sources[Name]; !ok {
+		t.Errorf("expected source %q, got %v", Name, camera.Sources)
+	}
+	if printer := got["10.0.10.22"]; printer.ExtraData[ExtraSwitchPort] != "ge-0/0/24" {
+		t.Errorf("unexpected printer %+v", printer)
+	}
+}
+
+func TestScanSkipsTargetsOfOtherInterfaces(t *testing.T) {
+	agent := newTestAgent(t, "public", routerTables()...)
+	other := net.IPv4(192, 0, 2, 1)
+	iface := &discovery.InterfaceInfo{IPv4Addr: &other}
+	target := "127.0.0.1:" + strconv.Itoa(agent.conn.LocalAddr().(*net.UDPAddr).Port)
+	s := NewScanner(iface, []string{target}, []Params{routerParams(agent)}, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out := make(chan discovery.Device, 10)
+	if err := s.Scan(ctx, out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Errorf("expected no devices, got %d", len(out))
+	}
+}
+
+func TestSplitTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		host   string
+		port   int
+	}{
+		{"10.0.0.1", "10.0.0.1", 0},
+		{"10.0.0.1:1161", "10.0.0.1", 1161},
+		{"core-sw.lan", "core-sw.lan", 0},
+		{"[2001:db8::1]:161", "2001:db8::1", 161},
+		{"2001:db8::1", "2001:db8::1", 0},
+	}
+	for _, tt := range tests {
+		host, port := splitTarget(tt.target)
+		if host != tt.host || port != tt.port {
+			t.Errorf("splitTarget(%q) = %q, %d, want %q, %d", tt.target, host, port, tt.host, tt.port)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package snmp
+
+import (
+	"context"
+	"net"
+)
+
+// Columns of the MIB tables read by the scanner.
+var (
+	// IF-MIB (RFC 2863)
+	oidIfDescr = MustParseOID("1.3.6.1.2.1.2.2.1.2")
+	oidIfName  = MustParseOID("1.3.6.1.2.1.31.1.1.1.1")
+	// IP-MIB (RFC 4293), ipNetToPhysicalTable and the deprecated ipNetToMediaTable
+	oidIPNetToPhysicalPhysAddress = MustParseOID("1.3.6.1.2.1.4.35.1.4")
+	oidIPNetToPhysicalType        = MustParseOID("1.3.6.1.2.1.4.35.1.6")
+	oidIPNetToMediaPhysAddress    = MustParseOID("1.3.6.1.2.1.4.22.1.2")
+	oidIPNetToMediaType           = MustParseOID("1.3.6.1.2.1.4.22.1.4")
+	// BRIDGE-MIB (RFC 4188) and Q-BRIDGE-MIB (RFC 4363)
+	oidDot1dBasePortIfIndex = MustParseOID("1.3.6.1.2.1.17.1.4.1.2")
+	oidDot1dTpFdbPort       = MustParseOID("1.3.6.1.2.1.17.4.3.1.2")
+	oidDot1dTpFdbStatus     = MustParseOID("1.3.6.1.2.1.17.4.3.1.3")
+	oidDot1qTpFdbPort       = MustParseOID("1.3.6.1.2.1.17.7.1.2.2.1.2")
+	oidDot1qTpFdbStatus     = MustParseOID("1.3.6.1.2.1.17.7.1.2.2.1.3")
+)
+
+// Values of the type and status columns.
+const (
+	neighborInvalid = 2 // ipNetToPhysicalType / ipNetToMediaType invalid(2)
+	neighborLocal   = 5 // ipNetToPhysicalType local(5), an address of the router itself
+	fdbLearned      = 3 // dot1dTpFdbStatus / dot1qTpFdbStatus learned(3)
+)
+
+// Neighbor is an entry of the IP-to-MAC (ARP and IPv6 neighbor) table of a router.
+type Neighbor struct {
+	IP      net.IP
+	MAC     net.HardwareAddr
+	IfIndex int // interface the entry was learned on
+}
+
+// FDBEntry is a MAC address learned on a port of a bridge.
+type FDBEntry struct {
+	MAC     net.HardwareAddr
+	Port    int // bridge port number
+	IfIndex int // interface of the bridge port, 0 when unknown
+}
+
+// column walks a table column and returns the values by row index.
+func (c *Client) column(ctx context.Context, col OID) (map[string]Variable, error) {
+	rows := map[string]Variable{}
+	err := c.Walk(ctx, col, func(v Variable) error {
+		rows[v.OID[len(col):].String()] = v
+		return nil
+	})
+	return rows, err
+}
+
+// Neighbors reads ipNetToPhysicalTable, or ipNetToMediaTable from agents that
+// only implement the older IPv4 table. Invalid and local entries are skipped.
+func (c *Client) Neighbors(ctx context.Context) ([]Neighbor, error) {
+	out, err := c.neighbors(ctx, oidIPNetToPhysicalPhysAddress, oidIPNetToPhysicalType, physicalIndex)
+	if err != nil || len(out) > 0 {
+		return out, err
+	}
+	return c.neighbors(ctx, oidIPNetToMediaPhysAddress, oidIPNetToMediaType, mediaIndex)
+}
+
+func (c *Client) neighbors(ctx context.Context, addrCol, typeCol OID, parseIndex func(OID) (int, net.IP, bool)) ([]Neighbor, error) {
+	addrs, err := c.column(ctx, addrCol)
+	if err != nil || len(addrs) == 0 {
+		return nil, err
+	}
+	types, err := c.column(ctx, typeCol)
+	if err != nil {
+		return nil, err
+	}
+	var out []Neighbor
+	for _, v := range addrs {
+		index := v.OID[len(addrCol):]
+		ifIndex, ip, ok := parseIndex(index)
+		mac := net.HardwareAddr(v.Bytes())
+		if !ok || !validMAC(mac) {
+			continue
+		}
+		if t, ok := types[index.String()]; ok && (t.Uint() == neighborInvalid || t.Uint() == neighborLocal) {
+			continue
+		}
+		out = append(out, Neighbor{IP: ip, MAC: append(net.HardwareAddr(nil), mac...), IfIndex: ifIndex})
+	}
+	return out, nil
+}
+
+// physicalIndex parses the ipNetToPhysicalTable index: ifIndex, address type, length and address.
+func physicalIndex(index OID) (int, net.IP, bool) {
+	if len(index) < 3 {
+		return 0, nil, false
+	}
+	ifIndex, addrType, n := int(index[0]), index[1], int(index[2])
+	addr := index[3:]
+	if n != len(addr) {
+		return 0, nil, false
+	}
+	// ipv4(1), ipv6(2) and their zoned variants ipv4z(3), ipv6z(4) with a trailing zone index
+	var size int
+	switch addrType {
+	case 1, 3:
+		size = net.IPv4len
+	case 2, 4:
+		size = net.IPv6len
+	default:
+		return 0, nil, false
+	}
+	if len(addr) < size {
+		return 0, nil, false
+	}
+	ip, ok := octets(addr[:size])
+	return ifIndex, net.IP(ip), ok
+}
+
+// mediaIndex parses the ipNetToMediaTable index: ifIndex and IPv4 address.
+func mediaIndex(index OID) (int, net.IP, bool) {
+	if len(index) != 1+net.IPv4len {
+		return 0, nil, false
+	}
+	ip, ok := octets(index[1:])
+	return int(index[0]), net.IP(ip), ok
+}
+
+// ForwardingTable reads the learned entries of the Q-BRIDGE forwarding table, or the
+// BRIDGE-MIB table from bridges without VLAN support, and maps ports to interfaces.
+func (c *Client) ForwardingTable(ctx context.Context) ([]FDBEntry, error) {
+	out, err := c.forwardingTable(ctx, oidDot1qTpFdbPort, oidDot1qTpFdbStatus, 1)
+	if err == nil && len(out) == 0 {
+		out, err = c.forwardingTable(ctx, oidDot1dTpFdbPort, oidDot1dTpFdbStatus, 0)
+	}
+	if err != nil || len(out) == 0 {
+		return out, err
+	}
+	ports, err := c.column(ctx, oidDot1dBasePortIfIndex)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		if v, ok := ports[OID{uint32(out[i].Port)}.String()]; ok {
+			out[i].IfIndex = int(v.Uint())
+		}
+	}
+	return out, nil
+}
+
+// forwardingTable reads a forwarding table whose index is skip components (the
+// Q-BRIDGE filtering database ID) followed by the MAC address.
+func (c *Client) forwardingTable(ctx context.Context, portCol, statusCol OID, skip int) ([]FDBEntry, error) {
+	ports, err := c.column(ctx, portCol)
+	if err != nil || len(ports) == 0 {
+		return nil, err
+	}
+	statuses, err := c.column(ctx, statusCol)
+	if err != nil {
+		return nil, err
+	}
+	var out []FDBEntry
+	for key, v := range ports {
+		index := v.OID[len(portCol):]
+		if len(index) != skip+6 || v.Uint() == 0 {
+			continue
+		}
+		if s, ok := statuses[key]; ok && s.Uint() != fdbLearned {
+			continue
+		}
+		mac, ok := octets(index[skip:])
+		if !ok || !validMAC(net.HardwareAddr(mac)) {
+			continue
+		}
+		out = append(out, FDBEntry{MAC: net.HardwareAddr(mac), Port: int(v.Uint())})
+	}
+	return out, nil
+}
+
+// InterfaceNames returns the ifName of every interface by ifIndex, ifDescr
+// for agents without the ifXTable.
+func (c *Client) InterfaceNames(ctx context.Context) (map[int]string, error) {
+	names := map[int]string{}
+	for _, col := range []OID{oidIfName, oidIfDescr} {
+		rows, err := c.column(ctx, col)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range rows {
+			index := v.OID[len(col):]
+			if len(index) == 1 && v.String() != "" {
+				names[int(index[0])] = v.String()
+			}
+		}
+		if len(names) > 0 {
+			break
+		}
+	}
+	return names, nil
+}
+
+// octets converts OID components that encode bytes, e.g. an address in a table index.
+func octets(components OID) ([]byte, bool) {
+	b := make([]byte, len(components))
+	for i, n := range components {
+		if n > 0xff {
+			return nil, false
+		}
+		b[i] = byte(n)
+	}
+	return b, true
+}
+
+// validMAC reports whether mac is a unicast Ethernet address that is not all zeros.
+func validMAC(mac net.HardwareAddr) bool {
+	if len(mac) != 6 || mac[0]&0x01 != 0 {
+		return false
+	}
+	for _, b := range mac {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
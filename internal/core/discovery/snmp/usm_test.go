@@ -0,0 +1,51 @@
+package snmp
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// TestLocalizedKey uses the test vectors of RFC 3414 appendix A.3.
+func TestLocalizedKey(t *testing.T) {
+	engineID, _ := hex.DecodeString("000000000000000000000002")
+	for auth, want := range map[AuthProtocol]string{
+		AuthMD5: "526f5eed9fcce26f8964c2930787d82b",
+		AuthSHA: "6695febc9288e36282235fc7151f128497b38f3f",
+	} {
+		if got := hex.EncodeToString(localizedKey(auth, "maplesyrup", engineID)); got != want {
+			t.Errorf("%s: got %s, want %s", auth, got, want)
+		}
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	m := message{
+		version: Version3,
+		msgID:   42,
+		flags:   flagAuth | flagReportable,
+		usm:     usmParams{engineID: []byte{1, 2, 3}, engineBoot: 1, engineTime: 2, user: "monitor"},
+		pdu:     pdu{kind: pduGet, requestID: 42, vars: []Variable{{OID: OIDSysName, Type: TypeNull}}},
+	}
+	b, err := m.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := localizedKey(AuthSHA, "maplesyrup", m.usm.engineID)
+	if err := sign(b, m.authOffset, AuthSHA, key); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parseMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.authOffset != m.authOffset || parsed.usm.user != "monitor" || parsed.pdu.vars[0].OID.Compare(OIDSysName) != 0 {
+		t.Errorf("unexpected parsed message %+v", parsed)
+	}
+	if !verify(b, parsed.authOffset, AuthSHA, key) {
+		t.Errorf("expected a valid signature")
+	}
+	b[len(b)-1] ^= 0xff
+	if verify(b, parsed.authOffset, AuthSHA, key) {
+		t.Errorf("expected a modified message to fail verification")
+	}
+}
@@ -0,0 +1,221 @@
+package ssdp
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+const (
+	// maxDescriptionSize caps the size of a device description document.
+	maxDescriptionSize = 256 << 10
+	// descriptionTimeout bounds a single description fetch.
+	descriptionTimeout = 3 * time.Second
+	// descriptionCacheTTL is how long a fetched description (or the failure) is reused.
+	descriptionCacheTTL = 10 * time.Minute
+)
+
+// ExtraData keys set from the UPnP device description.
+const (
+	ExtraDeviceType   = "upnp_device_type"
+	ExtraModelName    = "upnp_model_name"
+	ExtraModelNumber  = "upnp_model_number"
+	ExtraSerialNumber = "upnp_serial_number"
+	ExtraUDN          = "upnp_udn"
+)
+
+// Description is the UPnP device description served at the LOCATION URL of a device,
+// see UPnP Device Architecture 2.0 section 2.3.
+type Description struct {
+	DeviceType   string
+	FriendlyName string
+	Manufacturer string
+	ModelName    string
+	ModelNumber  string
+	SerialNumber string
+	UDN          string
+	// Services are the service types of the device and its embedded devices,
+	// e.g. urn:schemas-upnp-org:service:AVTransport:1.
+	Services []string
+}
+
+type xmlDevice struct {
+	DeviceType   string `xml:"deviceType"`
+	FriendlyName string `xml:"friendlyName"`
+	Manufacturer string `xml:"manufacturer"`
+	ModelName    string `xml:"modelName"`
+	ModelNumber  string `xml:"modelNumber"`
+	SerialNumber string `xml:"serialNumber"`
+	UDN          string `xml:"UDN"`
+	Services     []struct {
+		ServiceType string `xml:"serviceType"`
+	} `xml:"serviceList>service"`
+	Devices []xmlDevice `xml:"deviceList>device"`
+}
+
+// ParseDescription parses a device description document.
+func ParseDescription(b []byte) (*Description, error) {
+	var root struct {
+		Device xmlDevice `xml:"device"`
+	}
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	// devices declare all kinds of encodings, the fields we read are ASCII in practice
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := dec.Decode(&root); err != nil {
+		return nil, fmt.Errorf("parse description: %w", err)
+	}
+
+	dev := root.Device
+	desc := &Description{
+		DeviceType:   strings.TrimSpace(dev.DeviceType),
+		FriendlyName: strings.TrimSpace(dev.FriendlyName),
+		Manufacturer: strings.TrimSpace(dev.Manufacturer),
+		ModelName:    strings.TrimSpace(dev.ModelName),
+		ModelNumber:  strings.TrimSpace(dev.ModelNumber),
+		SerialNumber: strings.TrimSpace(dev.SerialNumber),
+		UDN:          strings.TrimSpace(dev.UDN),
+	}
+	if desc.DeviceType == "" && desc.UDN == "" {
+		return nil, fmt.Errorf("parse description: no device element")
+	}
+	seen := map[string]bool{}
+	var collect func(d xmlDevice)
+	collect = func(d xmlDevice) {
+		for _, s := range d.Services {
+			if st := strings.TrimSpace(s.ServiceType); st != "" && !seen[st] {
+				seen[st] = true
+				desc.Services = append(desc.Services, st)
+			}
+		}
+		for _, embedded := range d.Devices {
+			collect(embedded)
+		}
+	}
+	collect(dev)
+	return desc, nil
+}
+
+// apply copies the description into the device, every UPnP service becomes a service instance.
+func (desc *Description) apply(d *discovery.Device) {
+	if desc.FriendlyName != "" {
+		d.DisplayName = desc.FriendlyName
+	}
+	if desc.Manufacturer != "" {
+		d.Manufacturer = desc.Manufacturer
+	}
+	for key, value := range map[string]string{
+		ExtraDeviceType:   desc.DeviceType,
+		ExtraModelName:    desc.ModelName,
+		ExtraModelNumber:  desc.ModelNumber,
+		ExtraSerialNumber: desc.SerialNumber,
+		ExtraUDN:          desc.UDN,
+	} {
+		if value != "" {
+			d.ExtraData[key] = value
+		}
+	}
+	for _, st := range desc.Services {
+		service := upnpService(d.ExtraData["location"], d.LastSeen)
+		service.Instance = shortType(st)
+		service.TXT = map[string]string{"type": st}
+		d.AddService(service)
+	}
+}
+
+// shortType returns the name of a UPnP type URN, urn:schemas-upnp-org:service:AVTransport:1 -> AVTransport.
+func shortType(urn string) string {
+	parts := strings.Split(urn, ":")
+	if len(parts) >= 5 {
+		return parts[len(parts)-2]
+	}
+	return urn
+}
+
+// describable reports whether the description at location may be fetched for a device
+// seen at ip. Only plain HTTP(S) URLs pointing at the device itself are fetched, so a
+// spoofed LOCATION cannot make whosthere connect to arbitrary hosts.
+func describable(location string, ip net.IP) bool {
+	u, err := url.Parse(location)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	host, _, _ := strings.Cut(u.Hostname(), "%")
+	hostIP := net.ParseIP(host)
+	return hostIP != nil && hostIP.Equal(ip)
+}
+
+// describer fetches device descriptions and caches them by LOCATION URL, devices repeat
+// the same location in every response and NOTIFY.
+type describer struct {
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]describeResult
+}
+
+type describeResult struct {
+	desc *Description
+	err  error
+	at   time.Time
+}
+
+func newDescriber() *describer {
+	return &describer{
+		client: &http.Client{Timeout: descriptionTimeout},
+		cache:  map[string]describeResult{},
+	}
+}
+
+// describe returns the description at location, fetching it when it is not cached.
+func (d *describer) describe(ctx context.Context, location string) (*Description, error) {
+	d.mu.Lock()
+	if r, ok := d.cache[location]; ok && time.Since(r.at) < descriptionCacheTTL {
+		d.mu.Unlock()
+		return r.desc, r.err
+	}
+	d.mu.Unlock()
+
+	desc, err := fetchDescription(ctx, d.client, location)
+	if ctx.Err() != nil {
+		// the scan ended, this says nothing about the device
+		return nil, ctx.Err()
+	}
+	d.mu.Lock()
+	d.cache[location] = describeResult{desc: desc, err: err, at: time.Now()}
+	d.mu.Unlock()
+	return desc, err
+}
+
+func fetchDescription(ctx context.Context, client *http.Client, location string) (*Description, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch description: unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDescriptionSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch description: %w", err)
+	}
+	if len(body) > maxDescriptionSize {
+		return nil, fmt.Errorf("fetch description: larger than %d bytes", maxDescriptionSize)
+	}
+	return ParseDescription(body)
+}
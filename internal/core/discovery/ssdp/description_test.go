@@ -0,0 +1,172 @@
+package ssdp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"go.uber.org/zap"
+)
+
+func readDescription(t *testing.T) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "description.xml"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return b
+}
+
+func TestParseDescription(t *testing.T) {
+	desc, err := ParseDescription(readDescription(t))
+	if err != nil {
+		t.Fatalf("ParseDescription: %v", err)
+	}
+	if desc.FriendlyName != "Living Room TV" || desc.Manufacturer != "Samsung Electronics" || desc.ModelName != "UE55TU8000" {
+		t.Errorf("unexpected description %+v", desc)
+	}
+	if desc.ModelNumber != "AllShare1.0" || desc.SerialNumber != "0A1B2C3D" || desc.UDN != "uuid:1234-abcd" {
+		t.Errorf("unexpected description %+v", desc)
+	}
+	if desc.DeviceType != "urn:schemas-upnp-org:device:MediaRenderer:1" {
+		t.Errorf("unexpected device type %q", desc.DeviceType)
+	}
+	want := []string{
+		"urn:schemas-upnp-org:service:RenderingControl:1",
+		"urn:schemas-upnp-org:service:AVTransport:1",
+		"urn:schemas-upnp-org:service:ContentDirectory:1",
+	}
+	if strings.Join(desc.Services, " ") != strings.Join(want, " ") {
+		t.Errorf("expected services of the device and its embedded devices, got %v", desc.Services)
+	}
+}
+
+func TestParseDescriptionInvalid(t *testing.T) {
+	for _, doc := range []string{"", "<html><body>router login</body></html>", "<root><device>"} {
+		if _, err := ParseDescription([]byte(doc)); err == nil {
+			t.Errorf("expected an error for %q", doc)
+		}
+	}
+}
+
+func TestDescriptionApply(t *testing.T) {
+	desc, err := ParseDescription(readDescription(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newDevice(net.ParseIP("192.168.1.30"), "http://192.168.1.30:7676/dmr", "Linux/3.14 UPnP/1.0 libupnp")
+	desc.apply(&d)
+
+	if d.DisplayName != "Living Room TV" || d.Manufacturer != "Samsung Electronics" {
+		t.Errorf("unexpected device %+v", d)
+	}
+	if d.ExtraData[ExtraModelName] != "UE55TU8000" || d.ExtraData[ExtraUDN] != "uuid:1234-abcd" {
+		t.Errorf("unexpected extra data %v", d.ExtraData)
+	}
+	var names []string
+	for _, s := range d.Services {
+		if s.Type != "upnp" || s.Port != 7676 || s.Target != "192.168.1.30" {
+			t.Errorf("unexpected service %+v", s)
+		}
+		names = append(names, s.Instance)
+	}
+	if got := strings.Join(names, ","); got != ",RenderingControl,AVTransport,ContentDirectory" {
+		t.Errorf("expected the root device and its services, got %q", got)
+	}
+}
+
+func TestDescribable(t *testing.T) {
+	ip := net.ParseIP("192.168.1.30")
+	tests := []struct {
+		location string
+		want     bool
+	}{
+		{"http://192.168.1.30:49152/description.xml", true},
+		{"https://192.168.1.30/desc", true},
+		{"http://192.168.1.31:49152/description.xml", false},
+		{"http://router.lan/description.xml", false},
+		{"file:///etc/passwd", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := describable(tt.location, ip); got != tt.want {
+			t.Errorf("describable(%q) = %v, want %v", tt.location, got, tt.want)
+		}
+	}
+	if !describable("http://[fe80::1%25eth0]:1400/xml", net.ParseIP("fe80::1")) {
+		t.Errorf("expected link-local location with zone to be describable")
+	}
+}
+
+func TestDescriberFetchAndCache(t *testing.T) {
+	doc := readDescription(t)
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		_, _ = w.Write(doc)
+	}))
+	defer srv.Close()
+
+	d := newDescriber()
+	for range 2 {
+		desc, err := d.describe(context.Background(), srv.URL+"/description.xml")
+		if err != nil {
+			t.Fatalf("describe: %v", err)
+		}
+		if desc.FriendlyName != "Living Room TV" {
+			t.Errorf("unexpected description %+v", desc)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected the description to be cached, got %d requests", requests)
+	}
+}
+
+func TestFetchDescriptionLimits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			_, _ = w.Write([]byte(strings.Repeat("x", maxDescriptionSize+1)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	for _, path := range []string{"/large", "/missing"} {
+		if _, err := fetchDescription(context.Background(), srv.Client(), srv.URL+path); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+}
+
+func TestScannerDescribe(t *testing.T) {
+	doc := readDescription(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(doc)
+	}))
+	defer srv.Close()
+
+	s := NewScanner(nil, "", true)
+	d := newDevice(net.ParseIP("127.0.0.1"), srv.URL+"/description.xml", "libupnp")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out := make(chan discovery.Device, 1)
+	s.describe(ctx, d, out, zap.NewNop())
+
+	select {
+	case got := <-out:
+		if got.DisplayName != "Living Room TV" || got.ExtraData["location"] != d.ExtraData["location"] {
+			t.Errorf("unexpected device %+v", got)
+		}
+	default:
+		t.Fatal("expected the described device to be emitted")
+	}
+}
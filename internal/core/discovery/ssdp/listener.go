@@ -0,0 +1,318 @@
+package ssdp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultMaxAge is used for NOTIFY messages without a valid CACHE-CONTROL max-age,
+	// it is the minimum UPnP Device Architecture recommends.
+	defaultMaxAge = 1800 * time.Second
+	// reannounceInterval limits how often a device that keeps sending ssdp:alive is emitted.
+	reannounceInterval = time.Minute
+	// expiryCheckInterval is how often announcements are checked for an expired max-age.
+	expiryCheckInterval = 10 * time.Second
+	// maxPendingObservations bounds the observations queued between scans.
+	maxPendingObservations = 1024
+	ssdpPort               = 1900
+)
+
+// NTS values of NOTIFY messages.
+const (
+	ntsAlive  = "ssdp:alive"
+	ntsUpdate = "ssdp:update"
+	ntsByeBye = "ssdp:byebye"
+)
+
+// notify is a parsed NOTIFY message, see UPnP Device Architecture 2.0 section 1.2.
+type notify struct {
+	nts      string
+	usn      string
+	location string
+	server   string
+	maxAge   time.Duration
+}
+
+// announcement is the last ssdp:alive of a device.
+type announcement struct {
+	ip       net.IP
+	location string
+	expires  time.Time
+	emitted  time.Time
+}
+
+// Listener receives the NOTIFY messages UPnP devices multicast to 239.255.255.250:1900
+// (and ff02::c) for as long as it runs. Devices are emitted on ssdp:alive, marked departed
+// on ssdp:byebye and when they did not re-announce within the max-age of their last alive.
+type Listener struct {
+	iface     *discovery.InterfaceInfo
+	log       *zap.Logger
+	backlog   *discovery.Backlog
+	describer *describer // nil when device descriptions are not fetched
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	mu       sync.Mutex
+	started  bool
+	closed   bool
+	conns    []*discovery.MulticastConn
+	devices  map[string]*announcement // device UUID -> last alive
+	departed map[string]bool          // device UUIDs that said byebye without a tracked alive
+	wg       sync.WaitGroup
+}
+
+// NewListener returns a listener for iface, descriptions of announced devices are fetched
+// with d when it is not nil.
+func NewListener(iface *discovery.InterfaceInfo, d *describer) *Listener {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Listener{
+		iface:     iface,
+		log:       zap.L().Named("ssdp").With(zap.String("component", "listener")),
+		backlog:   discovery.NewBacklog(maxPendingObservations),
+		describer: d,
+		ctx:       ctx,
+		cancel:    cancel,
+		devices:   map[string]*announcement{},
+		departed:  map[string]bool{},
+	}
+}
+
+// Start joins the SSDP group for every address family of the interface and starts reading.
+// It is a no-op when the listener is already running.
+func (l *Listener) Start() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return net.ErrClosed
+	}
+	if l.started {
+		return nil
+	}
+	if l.iface == nil || l.iface.Interface == nil {
+		return errors.New("no network interface")
+	}
+	l.log = l.log.With(zap.String("interface", l.iface.Interface.Name))
+
+	var errs []error
+	for _, family := range l.iface.Families() {
+		group := &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: ssdpPort}
+		if family == discovery.IPv6 {
+			group = &net.UDPAddr{IP: net.ParseIP("ff02::c"), Port: ssdpPort}
+		}
+		pc, err := discovery.ListenMulticast(l.iface, family, group)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", family, err))
+			continue
+		}
+		l.conns = append(l.conns, pc)
+		l.wg.Add(1)
+		go l.read(pc, family)
+	}
+	if len(l.conns) == 0 {
+		if len(errs) == 0 {
+			return errors.New("interface has no usable address")
+		}
+		return errors.Join(errs...)
+	}
+	for _, err := range errs {
+		l.log.Warn("listening on one address family failed", zap.Error(err))
+	}
+
+	l.wg.Add(1)
+	go l.expireLoop()
+	l.started = true
+	return nil
+}
+
+// Close stops reading and releases the sockets. A closed listener cannot be started again.
+func (l *Listener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	conns := l.conns
+	l.conns = nil
+	l.cancel()
+	l.mu.Unlock()
+
+	var errs []error
+	for _, c := range conns {
+		errs = append(errs, c.Close())
+	}
+	l.wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Stream sends the queued observations and every new one to out until ctx is done.
+func (l *Listener) Stream(ctx context.Context, out chan<- discovery.Device) error {
+	return l.backlog.Stream(ctx, out)
+}
+
+func (l *Listener) read(pc *discovery.MulticastConn, family discovery.IPFamily) {
+	defer l.wg.Done()
+	buf := make([]byte, 8192)
+	for {
+		n, src, err := pc.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			l.log.Debug("read failed", zap.Stringer("family", family), zap.Error(err))
+			continue
+		}
+		// M-SEARCH requests of other control points arrive on the group as well
+		msg, ok := parseNotify(buf[:n])
+		if !ok {
+			continue
+		}
+		l.handle(msg, src, time.Now())
+	}
+}
+
+func (l *Listener) expireLoop() {
+	defer l.wg.Done()
+	ticker := time.NewTicker(expiryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case now := <-ticker.C:
+			l.expire(now)
+		}
+	}
+}
+
+// handle tracks the announcement of a NOTIFY message and queues the resulting observation.
+func (l *Listener) handle(n notify, sender net.IP, now time.Time) {
+	ip := sender
+	if ip == nil {
+		ip = ipFromLocation(n.location)
+	}
+	key := deviceUUID(n.usn)
+	if ip == nil || key == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch n.nts {
+	case ntsAlive, ntsUpdate:
+		maxAge := n.maxAge
+		if maxAge <= 0 {
+			maxAge = defaultMaxAge
+		}
+		a, known := l.devices[key]
+		if !known {
+			a = &announcement{}
+			l.devices[key] = a
+		}
+		// devices send an alive per device and service type, only emit new or changed devices
+		changed := !known || !a.ip.Equal(ip) || a.location != n.location || now.Sub(a.emitted) >= reannounceInterval
+		a.ip, a.location, a.expires = ip, n.location, now.Add(maxAge)
+		delete(l.departed, key)
+		if changed {
+			a.emitted = now
+			l.backlog.Add(newDevice(ip, n.location, n.server))
+			if l.describer != nil && describable(n.location, ip) {
+				l.wg.Add(1)
+				go l.describe(ip, n)
+			}
+		}
+	case ntsByeBye:
+		if a, known := l.devices[key]; known {
+			delete(l.devices, key)
+			ip = a.ip
+		} else if l.departed[key] {
+			return
+		}
+		l.departed[key] = true
+		l.log.Debug("byebye", zap.Stringer("ip", ip), zap.String("usn", n.usn))
+		l.backlog.Add(goodbye(ip, now))
+	}
+}
+
+// describe fetches the description of an announced device and queues it.
+func (l *Listener) describe(ip net.IP, n notify) {
+	defer l.wg.Done()
+	desc, err := l.describer.describe(l.ctx, n.location)
+	if err != nil {
+		l.log.Debug("description fetch failed", zap.String("location", n.location), zap.Error(err))
+		return
+	}
+	d := newDevice(ip, n.location, n.server)
+	desc.apply(&d)
+	l.backlog.Add(d)
+}
+
+// expire marks the devices whose last alive is older than its max-age as departed.
+func (l *Listener) expire(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, a := range l.devices {
+		if now.Before(a.expires) {
+			continue
+		}
+		delete(l.devices, key)
+		l.log.Debug("announcement expired", zap.Stringer("ip", a.ip), zap.String("uuid", key))
+		l.backlog.Add(goodbye(a.ip, a.expires))
+	}
+}
+
+// goodbye is the observation of a UPnP device that left the network.
+func goodbye(ip net.IP, at time.Time) discovery.Device {
+	d := discovery.NewDevice(ip)
+	d.Sources["ssdp"] = struct{}{}
+	d.RemovedServices = []discovery.ServiceInstance{{Type: "upnp"}}
+	d.DepartedAt = at
+	return d
+}
+
+// parseNotify parses a NOTIFY message, it returns false for other messages (e.g. M-SEARCH).
+func parseNotify(b []byte) (notify, bool) {
+	line, hdr, err := readMessage(b)
+	if err != nil || !strings.HasPrefix(strings.ToUpper(line), "NOTIFY ") {
+		return notify{}, false
+	}
+	n := notify{
+		nts:      strings.ToLower(strings.TrimSpace(hdr.Get("NTS"))),
+		usn:      strings.TrimSpace(hdr.Get("USN")),
+		location: strings.TrimSpace(hdr.Get("Location")),
+		server:   strings.TrimSpace(hdr.Get("Server")),
+		maxAge:   parseMaxAge(hdr.Get("Cache-Control")),
+	}
+	return n, n.nts != "" && n.usn != ""
+}
+
+// parseMaxAge reads the max-age directive of a CACHE-CONTROL header ("max-age=1800").
+func parseMaxAge(v string) time.Duration {
+	for _, directive := range strings.Split(v, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(directive), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "max-age") {
+			continue
+		}
+		if secs, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && secs > 0 {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	return 0
+}
+
+// deviceUUID returns the device part of a USN, "uuid:abc::upnp:rootdevice" -> "uuid:abc".
+func deviceUUID(usn string) string {
+	id, _, _ := strings.Cut(usn, "::")
+	return strings.ToLower(strings.TrimSpace(id))
+}
@@ -0,0 +1,147 @@
+package ssdp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+const aliveMessage = "NOTIFY * HTTP/1.1\r\n" +
+	"HOST: 239.255.255.250:1900\r\n" +
+	"CACHE-CONTROL: max-age=120\r\n" +
+	"LOCATION: http://192.168.1.30:49152/description.xml\r\n" +
+	"NT: upnp:rootdevice\r\n" +
+	"NTS: ssdp:alive\r\n" +
+	"SERVER: Linux/5.10 UPnP/1.0 MediaServer/1.0\r\n" +
+	"USN: uuid:1234-abcd::upnp:rootdevice\r\n\r\n"
+
+const byeByeMessage = "NOTIFY * HTTP/1.1\r\n" +
+	"HOST: 239.255.255.250:1900\r\n" +
+	"NT: urn:schemas-upnp-org:device:MediaServer:1\r\n" +
+	"NTS: ssdp:byebye\r\n" +
+	"USN: uuid:1234-abcd::urn:schemas-upnp-org:device:MediaServer:1\r\n\r\n"
+
+var serverIP = net.ParseIP("192.168.1.30")
+
+// observations returns the queued observations of the listener.
+func observations(l *Listener) []discovery.Device {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	out := make(chan discovery.Device, 16)
+	_ = l.Stream(ctx, out)
+	close(out)
+	var devices []discovery.Device
+	for d := range out {
+		devices = append(devices, d)
+	}
+	return devices
+}
+
+func mustParseNotify(t *testing.T, msg string) notify {
+	t.Helper()
+	n, ok := parseNotify([]byte(msg))
+	if !ok {
+		t.Fatalf("expected a NOTIFY message")
+	}
+	return n
+}
+
+func TestParseNotify(t *testing.T) {
+	n := mustParseNotify(t, aliveMessage)
+	if n.nts != ntsAlive || n.usn != "uuid:1234-abcd::upnp:rootdevice" || n.maxAge != 120*time.Second {
+		t.Errorf("unexpected notify %+v", n)
+	}
+	if n.location != "http://192.168.1.30:49152/description.xml" || n.server != "Linux/5.10 UPnP/1.0 MediaServer/1.0" {
+		t.Errorf("unexpected notify %+v", n)
+	}
+
+	search := "M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nMAN: \"ssdp:discover\"\r\nST: ssdp:all\r\n\r\n"
+	if _, ok := parseNotify([]byte(search)); ok {
+		t.Errorf("M-SEARCH must not parse as NOTIFY")
+	}
+}
+
+func TestParseMaxAge(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"max-age=1800", 1800 * time.Second},
+		{"no-cache=\"Ext\", max-age = 60", 60 * time.Second},
+		{"MAX-AGE=30", 30 * time.Second},
+		{"max-age=-1", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := parseMaxAge(tt.value); got != tt.want {
+			t.Errorf("parseMaxAge(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDeviceUUID(t *testing.T) {
+	if got := deviceUUID("uuid:1234-ABCD::upnp:rootdevice"); got != "uuid:1234-abcd" {
+		t.Errorf("unexpected uuid %q", got)
+	}
+	if got := deviceUUID("uuid:1234-abcd"); got != "uuid:1234-abcd" {
+		t.Errorf("unexpected uuid %q", got)
+	}
+}
+
+func TestListenerAliveAndByeBye(t *testing.T) {
+	l := NewListener(nil, nil)
+	now := time.Now()
+	alive := mustParseNotify(t, aliveMessage)
+
+	l.handle(alive, serverIP, now)
+	l.handle(alive, serverIP, now.Add(time.Second))
+	devices := observations(l)
+	if len(devices) != 1 {
+		t.Fatalf("expected repeated alives to be emitted once, got %d", len(devices))
+	}
+	d := devices[0]
+	if !d.IP.Equal(serverIP) || d.ExtraData["location"] != alive.location || d.Departed() {
+		t.Errorf("unexpected device %+v", d)
+	}
+
+	l.handle(mustParseNotify(t, byeByeMessage), serverIP, now.Add(2*time.Second))
+	l.handle(mustParseNotify(t, byeByeMessage), serverIP, now.Add(2*time.Second))
+	devices = observations(l)
+	if len(devices) != 1 {
+		t.Fatalf("expected one goodbye, got %d", len(devices))
+	}
+	if !devices[0].DepartedAt.Equal(now.Add(2*time.Second)) || devices[0].RemovedServices[0].Type != "upnp" {
+		t.Errorf("expected device to be departed, got %+v", devices[0])
+	}
+}
+
+func TestListenerExpire(t *testing.T) {
+	l := NewListener(nil, nil)
+	now := time.Now()
+	l.handle(mustParseNotify(t, aliveMessage), serverIP, now)
+	observations(l)
+
+	l.expire(now.Add(time.Minute))
+	if devices := observations(l); len(devices) != 0 {
+		t.Fatalf("expected no expiry within max-age, got %d", len(devices))
+	}
+
+	l.expire(now.Add(3 * time.Minute))
+	devices := observations(l)
+	if len(devices) != 1 || !devices[0].DepartedAt.Equal(now.Add(120*time.Second)) {
+		t.Fatalf("expected the device to depart at its max-age, got %+v", devices)
+	}
+}
+
+func TestScannerModes(t *testing.T) {
+	if s := NewScanner(nil, "", false); s.mode != config.ScanModeActive || s.listener != nil {
+		t.Errorf("expected active mode without listener by default")
+	}
+	if s := NewScanner(nil, config.ScanModeBoth, true); s.listener == nil {
+		t.Errorf("expected a listener in both mode")
+	}
+}
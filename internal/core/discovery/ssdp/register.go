@@ -0,0 +1,38 @@
+package ssdp
+
+import (
+	"fmt"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+// Config is the `scanners.ssdp` config block.
+type Config struct {
+	config.ScannerToggle `yaml:",inline"`
+	// Mode is active (M-SEARCH every scan), passive (listen for NOTIFY on 1900 across scans) or both.
+	Mode config.ScanMode `yaml:"mode"`
+	// Describe fetches the UPnP device description from the LOCATION of every device.
+	Describe bool `yaml:"describe"`
+}
+
+// Validate resets an unknown mode to active.
+func (c *Config) Validate() error { return c.Mode.Validate() }
+
+func init() {
+	discovery.Register(discovery.Registration{
+		Name:           "ssdp",
+		Description:    "SSDP/UPnP discovery via M-SEARCH",
+		DefaultEnabled: true,
+		NewConfig: func() config.ScannerSettings {
+			return &Config{Mode: config.ScanModeActive, Describe: true}
+		},
+		New: func(iface *discovery.InterfaceInfo, settings config.ScannerSettings) (discovery.Scanner, error) {
+			cfg, ok := settings.(*Config)
+			if !ok {
+				return nil, fmt.Errorf("unexpected config type %T", settings)
+			}
+			return NewScanner(iface, cfg.Mode, cfg.Describe), nil
+		},
+	})
+}
@@ -6,50 +6,130 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/textproto"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
 	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
 	"go.uber.org/zap"
 )
 
 const (
 	MulticastAddr = "239.255.255.250:1900"
-	HeaderMan     = `"ssdp:discover"`
-	HeaderST      = "ssdp:all"
-	HeaderMX      = 2
+	// MulticastAddrV6 is the IPv6 link-local SSDP group, see UPnP Device Architecture 2.0 section 1.1.2.
+	MulticastAddrV6 = "[ff02::c]:1900"
+	HeaderMan       = `"ssdp:discover"`
+	HeaderST        = "ssdp:all"
+	HeaderMX        = 2
 )
 
-var _ discovery.Scanner = (*Scanner)(nil)
+var (
+	_ discovery.InterfaceScanner = (*Scanner)(nil)
+	_ io.Closer                  = (*Scanner)(nil)
+)
 
 // Scanner implements SSDP discovery (UPnP) via manual M-SEARCH over UDP.
 // Implemented as described in the RFC: https://datatracker.ietf.org/doc/html/draft-cai-ssdp-v1-03#section-4.1
+// In passive mode a Listener picks up the NOTIFY messages devices multicast between scans.
 type Scanner struct {
-	iface *discovery.InterfaceInfo
+	iface     *discovery.InterfaceInfo
+	mode      config.ScanMode
+	listener  *Listener  // nil in active mode
+	describer *describer // nil when device descriptions are not fetched
 }
 
-func NewScanner(iface *discovery.InterfaceInfo) *Scanner {
-	return &Scanner{iface: iface}
+// NewScanner returns an SSDP scanner for iface, an empty mode means config.ScanModeActive.
+// With describe set the device description behind the LOCATION of every device is fetched.
+func NewScanner(iface *discovery.InterfaceInfo, mode config.ScanMode, describe bool) *Scanner {
+	if mode == "" {
+		mode = config.ScanModeActive
+	}
+	s := &Scanner{iface: iface, mode: mode}
+	if describe {
+		s.describer = newDescriber()
+	}
+	if mode.Listens() {
+		s.listener = NewListener(iface, s.describer)
+	}
+	return s
 }
 
 func (s *Scanner) Name() string { return "ssdp" }
 
-// Scan sends an SSDP M-SEARCH and streams responses incrementally until the ctx deadline.
+// Interface returns the network interface the scanner is bound to.
+func (s *Scanner) Interface() *discovery.InterfaceInfo { return s.iface }
+
+// Scan searches and/or listens depending on the mode of the scanner. In passive mode the
+// listener is started on the first scan and keeps running until Close.
 func (s *Scanner) Scan(ctx context.Context, out chan<- discovery.Device) error {
-	log := zap.L()
-	mAddr, err := net.ResolveUDPAddr("udp4", MulticastAddr)
+	if s.listener == nil {
+		return s.search(ctx, out)
+	}
+	if err := s.listener.Start(); err != nil {
+		return fmt.Errorf("start listener: %w", err)
+	}
+	if !s.mode.Queries() {
+		return s.listener.Stream(ctx, out)
+	}
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- s.listener.Stream(ctx, out)
+	}()
+	err := s.search(ctx, out)
+	if lerr := <-listenErr; err == nil {
+		err = lerr
+	}
+	return err
+}
+
+// Close stops the passive listener, if any.
+func (s *Scanner) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// search sends an SSDP M-SEARCH over IPv4 and IPv6 (depending on the addresses of the
+// interface) and streams responses incrementally until the ctx deadline.
+func (s *Scanner) search(ctx context.Context, out chan<- discovery.Device) error {
+	return discovery.ScanFamilies(s.iface, s.Name(), func(family discovery.IPFamily) error {
+		return s.scanFamily(ctx, family, out)
+	})
+}
+
+func (s *Scanner) scanFamily(ctx context.Context, family discovery.IPFamily, out chan<- discovery.Device) error {
+	log := zap.L().With(zap.Stringer("family", family))
+	network, host := "udp4", MulticastAddr
+	if family == discovery.IPv6 {
+		network, host = "udp6", MulticastAddrV6
+	}
+	mAddr, err := net.ResolveUDPAddr(network, host)
 	if err != nil {
 		return fmt.Errorf("resolve ssdp addr: %w", err)
 	}
-	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: *s.iface.IPv4Addr, Port: 0})
+	if family == discovery.IPv6 {
+		mAddr.Zone = s.iface.Interface.Name
+	}
+	conn, err := net.ListenUDP(network, s.iface.LocalUDPAddr(family))
 	if err != nil {
 		return fmt.Errorf("listen udp: %w", err)
 	}
 	defer func() { _ = conn.Close() }()
 
-	if err := sendSearch(conn, mAddr, log); err != nil {
+	// descriptions are fetched in the background, they have to be sent before Scan returns
+	var describing sync.WaitGroup
+	defer describing.Wait()
+	described := map[string]bool{}
+
+	if err := sendSearch(conn, mAddr, host, log); err != nil {
 		return err
 	}
 	if err := applyDeadlineFromContext(conn, ctx); err != nil {
@@ -69,12 +149,40 @@ func (s *Scanner) Scan(ctx context.Context, out chan<- discovery.Device) error {
 			}
 			return fmt.Errorf("read ssdp: %w", err)
 		}
-		handlePacket(out, src, buf[:n], log)
+		d, ok := deviceFromResponse(src, buf[:n], log)
+		if !ok {
+			continue
+		}
+		out <- d
+		if loc := d.ExtraData["location"]; s.describer != nil && !described[loc] && describable(loc, d.IP) {
+			described[loc] = true
+			describing.Add(1)
+			go func() {
+				defer describing.Done()
+				s.describe(ctx, d, out, log)
+			}()
+		}
+	}
+}
+
+// describe fetches the description of the device and emits the device again with it applied.
+func (s *Scanner) describe(ctx context.Context, d discovery.Device, out chan<- discovery.Device, log *zap.Logger) {
+	loc := d.ExtraData["location"]
+	desc, err := s.describer.describe(ctx, loc)
+	if err != nil {
+		log.Debug("ssdp description fetch failed", zap.String("location", loc), zap.Error(err))
+		return
+	}
+	described := newDevice(d.IP, loc, d.ExtraData["server"])
+	desc.apply(&described)
+	select {
+	case out <- described:
+	case <-ctx.Done():
 	}
 }
 
 // sendSearch builds and sends the SSDP M-SEARCH request.
-func sendSearch(conn *net.UDPConn, addr *net.UDPAddr, log *zap.Logger) error {
+func sendSearch(conn *net.UDPConn, addr *net.UDPAddr, host string, log *zap.Logger) error {
 	req := fmt.Sprintf(
 		"M-SEARCH * HTTP/1.1\r\n"+
 			"HOST: %s\r\n"+
@@ -82,9 +190,9 @@ func sendSearch(conn *net.UDPConn, addr *net.UDPAddr, log *zap.Logger) error {
 			"MX: %d\r\n"+
 			"ST: %s\r\n"+
 			"USER-AGENT: whosthere/0.1\r\n\r\n",
-		MulticastAddr, HeaderMan, HeaderMX, HeaderST,
+		host, HeaderMan, HeaderMX, HeaderST,
 	)
-	log.Debug("ssdp m-search send", zap.String("host", MulticastAddr), zap.Int("mx", HeaderMX), zap.String("st", HeaderST))
+	log.Debug("ssdp m-search send", zap.String("host", host), zap.Int("mx", HeaderMX), zap.String("st", HeaderST))
 	if _, err := conn.WriteToUDP([]byte(req), addr); err != nil {
 		return fmt.Errorf("send m-search: %w", err)
 	}
@@ -102,8 +210,8 @@ func applyDeadlineFromContext(conn *net.UDPConn, ctx context.Context) error {
 	return fmt.Errorf("ssdp scan requires context with deadline")
 }
 
-// handlePacket parses the packet and emits a Device if an IP can be resolved.
-func handlePacket(out chan<- discovery.Device, src *net.UDPAddr, payload []byte, log *zap.Logger) {
+// deviceFromResponse parses an M-SEARCH response, it returns false when no IP can be resolved.
+func deviceFromResponse(src *net.UDPAddr, payload []byte, log *zap.Logger) (discovery.Device, bool) {
 	loc, server := parseHeaders(payload)
 	ip := ipFromAddr(src)
 	if ip == nil && loc != "" {
@@ -111,26 +219,60 @@ func handlePacket(out chan<- discovery.Device, src *net.UDPAddr, payload []byte,
 	}
 	if ip == nil {
 		log.Debug("ssdp response skipped; no ip", zap.String("src", src.String()), zap.String("location", loc))
-		return
+		return discovery.Device{}, false
 	}
+	return newDevice(ip, loc, server), true
+}
+
+// newDevice builds the device of an M-SEARCH response or NOTIFY message.
+func newDevice(ip net.IP, location, server string) discovery.Device {
 	d := discovery.NewDevice(ip)
 	d.DisplayName = server
-	d.Services["upnp"] = 0
 	d.Sources["ssdp"] = struct{}{}
-	if d.ExtraData == nil {
-		d.ExtraData = make(map[string]string)
-	}
-	if loc != "" {
-		d.ExtraData["location"] = loc
+	if location != "" {
+		d.ExtraData["location"] = location
 	}
 	if server != "" {
 		d.ExtraData["server"] = server
 	}
-	out <- d
+	d.AddService(upnpService(location, d.LastSeen))
+	return d
+}
+
+// upnpService returns a UPnP service instance served at the LOCATION URL of a device,
+// the root device itself has no instance name.
+func upnpService(location string, now time.Time) discovery.ServiceInstance {
+	s := discovery.ServiceInstance{Type: "upnp", Protocol: "tcp", Source: "ssdp", LastSeen: now}
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" {
+		return s
+	}
+	s.Target = u.Hostname()
+	s.Port, _ = strconv.Atoi(u.Port())
+	if s.Port == 0 {
+		switch u.Scheme {
+		case "http":
+			s.Port = 80
+		case "https":
+			s.Port = 443
+		}
+	}
+	return s
 }
 
 // parseHeaders extracts LOCATION and SERVER using HTTP-like header parsing.
 func parseHeaders(b []byte) (location, server string) {
+	_, hdr, err := readMessage(b)
+	if err != nil {
+		return "", ""
+	}
+	location = strings.TrimSpace(hdr.Get("Location"))
+	server = strings.TrimSpace(hdr.Get("Server"))
+	return
+}
+
+// readMessage splits an SSDP message in its start line and headers.
+func readMessage(b []byte) (string, textproto.MIMEHeader, error) {
 	// Ensures the buffer ends with CRLFCRLF to satisfy textproto header reader
 	data := b
 	if !bytes.HasSuffix(data, []byte("\r\n\r\n")) {
@@ -139,14 +281,9 @@ func parseHeaders(b []byte) (location, server string) {
 	br := bufio.NewReader(bytes.NewReader(data))
 	tr := textproto.NewReader(br)
 	// Read the first status line and ignore errors (best-effort)
-	_, _ = tr.ReadLine()
+	line, _ := tr.ReadLine()
 	hdr, err := tr.ReadMIMEHeader()
-	if err != nil {
-		return "", ""
-	}
-	location = strings.TrimSpace(hdr.Get("Location"))
-	server = strings.TrimSpace(hdr.Get("Server"))
-	return
+	return line, hdr, err
 }
 
 // Helper: extract IP from net.Addr (UDP address)
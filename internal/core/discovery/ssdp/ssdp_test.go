@@ -3,19 +3,20 @@ package ssdp
 import (
 	"testing"
 
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
 	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
 )
 
 func TestNewScanner(t *testing.T) {
 	iface := &discovery.InterfaceInfo{}
-	scanner := NewScanner(iface)
+	scanner := NewScanner(iface, config.ScanModeActive, false)
 	if scanner.iface != iface {
 		t.Errorf("expected iface to be set")
 	}
 }
 
 func TestName(t *testing.T) {
-	scanner := NewScanner(nil)
+	scanner := NewScanner(nil, config.ScanModeActive, false)
 	if scanner.Name() != "ssdp" {
 		t.Errorf("expected name ssdp, got %s", scanner.Name())
 	}
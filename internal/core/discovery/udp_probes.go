@@ -0,0 +1,169 @@
+package discovery
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// udpProbe is the payload sent to a well-known UDP port. A port is only reported
+// open for a reply the service would send, other datagrams leave it open|filtered.
+type udpProbe struct {
+	// payload returns the datagram to send, it may embed a random transaction ID.
+	payload func() []byte
+	// valid reports whether resp answers req, nil accepts any reply.
+	valid func(req, resp []byte) bool
+}
+
+// udpProbes are the protocol-aware probes by port. Most services ignore datagrams they
+// cannot parse, an empty datagram would leave them open|filtered.
+var udpProbes = map[int]udpProbe{
+	53:   {payload: dnsQuery("version.bind.", dnsmessage.TypeTXT, dnsmessage.ClassCHAOS), valid: dnsReply},
+	69:   {payload: tftpReadRequest, valid: tftpReply},
+	123:  {payload: ntpRequest, valid: ntpReply},
+	137:  {payload: nbnsNodeStatus, valid: dnsReply},
+	161:  {payload: snmpGetRequest, valid: snmpReply},
+	500:  {payload: ikeMainMode, valid: ikeReply},
+	1900: {payload: ssdpSearch, valid: ssdpReply},
+	5353: {payload: dnsQuery("_services._dns-sd._udp.local.", dnsmessage.TypePTR, dnsmessage.ClassINET), valid: dnsReply},
+}
+
+// probeFor returns the probe of port, an empty datagram accepting any reply for unknown ports.
+func probeFor(port int) udpProbe {
+	if p, ok := udpProbes[port]; ok {
+		return p
+	}
+	return udpProbe{payload: func() []byte { return nil }}
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return b
+}
+
+// dnsQuery returns a probe asking one question, used for DNS and unicast mDNS (RFC 6762 section 6.7).
+func dnsQuery(name string, typ dnsmessage.Type, class dnsmessage.Class) func() []byte {
+	return func() []byte {
+		msg := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: binary.BigEndian.Uint16(randomBytes(2)), RecursionDesired: true},
+			Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName(name), Type: typ, Class: class}},
+		}
+		b, err := msg.Pack()
+		if err != nil {
+			return nil
+		}
+		return b
+	}
+}
+
+// dnsReply accepts a response with the ID of the query, also used for NBNS which shares the DNS header.
+func dnsReply(req, resp []byte) bool {
+	return len(req) >= 12 && len(resp) >= 12 && bytes.Equal(req[:2], resp[:2]) && resp[2]&0x80 != 0
+}
+
+// tftpReadRequest asks for a file that does not exist. TFTP servers answer from a new
+// port (RFC 1350 section 4), which the connected socket does not receive, so a TFTP
+// server usually shows up as open|filtered.
+func tftpReadRequest() []byte {
+	b := []byte{0, 1} // RRQ
+	b = append(b, "whosthere.txt"...)
+	b = append(b, 0)
+	b = append(b, "octet"...)
+	return append(b, 0)
+}
+
+// tftpReply accepts a DATA or ERROR packet.
+func tftpReply(_, resp []byte) bool {
+	return len(resp) >= 4 && resp[0] == 0 && (resp[1] == 3 || resp[1] == 5)
+}
+
+// ntpRequest is an NTPv3 client request (RFC 5905).
+func ntpRequest() []byte {
+	b := make([]byte, 48)
+	b[0] = 0x1b // leap indicator 0, version 3, mode 3 (client)
+	copy(b[40:], randomBytes(8))
+	return b
+}
+
+// ntpReply accepts a server response echoing the transmit timestamp of the request.
+func ntpReply(req, resp []byte) bool {
+	return len(resp) >= 48 && resp[0]&0x07 == 4 && bytes.Equal(resp[24:32], req[40:48])
+}
+
+// nbnsNodeStatus is a wildcard NBSTAT query (RFC 1002 section 4.2.17).
+func nbnsNodeStatus() []byte {
+	b := append(randomBytes(2), 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	// "*" padded with NUL bytes, first-level encoded
+	b = append(b, 0x20, 'C', 'K')
+	b = append(b, bytes.Repeat([]byte{'A'}, 30)...)
+	return append(b, 0x00, 0x00, 0x21, 0x00, 0x01)
+}
+
+// snmpGetRequest is an SNMPv2c get of sysDescr.0 with the community "public".
+func snmpGetRequest() []byte {
+	b := []byte{
+		0x30, 0x29, // message
+		0x02, 0x01, 0x01, // version 2c
+		0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+		0xa0, 0x1c, // GetRequest-PDU
+		0x02, 0x04, // request-id, filled in below
+	}
+	b = append(b, randomBytes(4)...)
+	return append(b,
+		0x02, 0x01, 0x00, // error-status
+		0x02, 0x01, 0x00, // error-index
+		0x30, 0x0e, 0x30, 0x0c, // variable bindings
+		0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, // 1.3.6.1.2.1.1.1.0
+		0x05, 0x00, // NULL
+	)
+}
+
+// snmpReply accepts a message carrying the request ID. Agents ignore wrong
+// communities, any answer means the community was accepted.
+func snmpReply(req, resp []byte) bool {
+	return len(resp) > 2 && resp[0] == 0x30 && bytes.Contains(resp, req[15:21])
+}
+
+// ikeMainMode is an IKEv1 main mode proposal (RFC 2408) offering 3DES, SHA-1,
+// a pre-shared key and MODP group 2. Responders answer with an SA or a notification.
+func ikeMainMode() []byte {
+	b := randomBytes(8)                   // initiator cookie
+	b = append(b, make([]byte, 8)...)     // responder cookie
+	b = append(b, 0x01, 0x10, 0x02, 0x00) // next payload SA, version 1.0, identity protection, no flags
+	b = append(b, 0x00, 0x00, 0x00, 0x00) // message ID
+	b = append(b, 0x00, 0x00, 0x00, 0x50) // length 80
+	// SA payload: DOI IPsec, situation identity only
+	b = append(b, 0x00, 0x00, 0x00, 0x34, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01)
+	// proposal 1, ISAKMP, no SPI, one transform
+	b = append(b, 0x00, 0x00, 0x00, 0x28, 0x01, 0x01, 0x00, 0x01)
+	// transform 1, KEY_IKE
+	b = append(b, 0x00, 0x00, 0x00, 0x20, 0x01, 0x01, 0x00, 0x00)
+	return append(b,
+		0x80, 0x01, 0x00, 0x05, // encryption 3DES-CBC
+		0x80, 0x02, 0x00, 0x02, // hash SHA
+		0x80, 0x03, 0x00, 0x01, // authentication pre-shared key
+		0x80, 0x04, 0x00, 0x02, // group MODP 1024
+		0x80, 0x0b, 0x00, 0x01, // life type seconds
+		0x80, 0x0c, 0x70, 0x80, // life duration 28800
+	)
+}
+
+// ikeReply accepts an ISAKMP message echoing the initiator cookie.
+func ikeReply(req, resp []byte) bool {
+	return len(resp) >= 28 && bytes.Equal(resp[:8], req[:8])
+}
+
+// ssdpSearch is a unicast M-SEARCH (UPnP Device Architecture 2.0 section 1.3.2).
+func ssdpSearch() []byte {
+	return []byte("M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"ST: ssdp:all\r\n\r\n")
+}
+
+func ssdpReply(_, resp []byte) bool {
+	return bytes.HasPrefix(resp, []byte("HTTP/1.1 200"))
+}
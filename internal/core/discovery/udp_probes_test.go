@@ -0,0 +1,53 @@
+package discovery
+
+import (
+	"testing"
+)
+
+func TestUDPProbeValidators(t *testing.T) {
+	dns := udpProbes[53].payload()
+	dnsResp := append([]byte{}, dns...)
+	dnsResp[2] |= 0x80
+
+	ntp := ntpRequest()
+	ntpResp := make([]byte, 48)
+	ntpResp[0] = 0x1c // version 3, mode 4 (server)
+	copy(ntpResp[24:32], ntp[40:48])
+
+	snmp := snmpGetRequest()
+	snmpResp := append([]byte{0x30, 0x10, 0xa2, 0x0e, 0x02, 0x04}, snmp[17:21]...)
+
+	ike := ikeMainMode()
+	ikeResp := append(append([]byte{}, ike[:8]...), make([]byte, 20)...)
+
+	tests := []struct {
+		name      string
+		valid     func(req, resp []byte) bool
+		req, resp []byte
+		want      bool
+	}{
+		{"dns response", dnsReply, dns, dnsResp, true},
+		{"dns query echoed", dnsReply, dns, dns, false},
+		{"dns other id", dnsReply, dns, append([]byte{dns[0] + 1, dns[1], 0x80}, dns[3:]...), false},
+		{"ntp response", ntpReply, ntp, ntpResp, true},
+		{"ntp other request", ntpReply, ntp, make([]byte, 48), false},
+		{"snmp response", snmpReply, snmp, snmpResp, true},
+		{"snmp other request id", snmpReply, snmp, []byte{0x30, 0x02, 0x05, 0x00}, false},
+		{"ike response", ikeReply, ike, ikeResp, true},
+		{"ike short", ikeReply, ike, ike[:8], false},
+		{"tftp error", tftpReply, nil, []byte{0, 5, 0, 1, 0}, true},
+		{"ssdp response", ssdpReply, nil, []byte("HTTP/1.1 200 OK\r\n"), true},
+	}
+	for _, tt := range tests {
+		if got := tt.valid(tt.req, tt.resp); got != tt.want {
+			t.Errorf("%s: valid = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProbeForUnknownPort(t *testing.T) {
+	p := probeFor(40000)
+	if len(p.payload()) != 0 || p.valid != nil {
+		t.Error("expected an empty probe accepting any reply for an unknown port")
+	}
+}
@@ -0,0 +1,136 @@
+package wsd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const (
+	actionProbe        = "http://schemas.xmlsoap.org/ws/2005/04/discovery/Probe"
+	actionProbeMatches = "http://schemas.xmlsoap.org/ws/2005/04/discovery/ProbeMatches"
+	// onvifScopePrefix starts the ONVIF scopes, e.g. onvif://www.onvif.org/hardware/DS-2CD2143G0-I.
+	onvifScopePrefix = "onvif://www.onvif.org/"
+	// onvifVideoTransmitter is the probe type of ONVIF cameras and encoders.
+	onvifVideoTransmitter = "dn:NetworkVideoTransmitter"
+)
+
+// probeTemplate is a WS-Discovery 1.0 Probe, %s are the message ID and the types element.
+// see http://specs.xmlsoap.org/ws/2005/04/discovery/ws-discovery.pdf section 5.2
+const probeTemplate = `<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns:wsa="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:wsd="http://schemas.xmlsoap.org/ws/2005/04/discovery" xmlns:dn="http://www.onvif.org/ver10/network/wsdl">
+<soap:Header>
+<wsa:To>urn:schemas-xmlsoap-org:ws:2005:04:discovery</wsa:To>
+<wsa:Action>` + actionProbe + `</wsa:Action>
+<wsa:MessageID>%s</wsa:MessageID>
+</soap:Header>
+<soap:Body><wsd:Probe>%s</wsd:Probe></soap:Body>
+</soap:Envelope>`
+
+// buildProbe returns a Probe for the given types (all devices when empty) and its message ID.
+func buildProbe(types string) ([]byte, string) {
+	id := newMessageID()
+	typesElement := ""
+	if types != "" {
+		typesElement = "<wsd:Types>" + types + "</wsd:Types>"
+	}
+	return fmt.Appendf(nil, probeTemplate, id, typesElement), id
+}
+
+// newMessageID returns a random urn:uuid message ID (RFC 4122 version 4).
+func newMessageID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// ProbeMatch is one device in a ProbeMatches response.
+type ProbeMatch struct {
+	Address string   // endpoint reference, a stable urn:uuid of the device
+	Types   []string // e.g. dn:NetworkVideoTransmitter, wsdp:Device, pub:Computer
+	Scopes  []string
+	XAddrs  []string // transport addresses of the device service
+}
+
+type envelope struct {
+	Header struct {
+		Action    string `xml:"Action"`
+		RelatesTo string `xml:"RelatesTo"`
+	} `xml:"Header"`
+	Body struct {
+		ProbeMatches struct {
+			Matches []struct {
+				Address string `xml:"EndpointReference>Address"`
+				Types   string `xml:"Types"`
+				Scopes  string `xml:"Scopes"`
+				XAddrs  string `xml:"XAddrs"`
+			} `xml:"ProbeMatch"`
+		} `xml:"ProbeMatches"`
+	} `xml:"Body"`
+}
+
+// parseProbeMatches decodes a ProbeMatches response and returns the message ID it relates to.
+func parseProbeMatches(b []byte) ([]ProbeMatch, string, error) {
+	var env envelope
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	if err := dec.Decode(&env); err != nil {
+		return nil, "", fmt.Errorf("decode envelope: %w", err)
+	}
+	if strings.TrimSpace(env.Header.Action) != actionProbeMatches {
+		return nil, "", errors.New("not a ProbeMatches message")
+	}
+
+	var matches []ProbeMatch
+	for _, m := range env.Body.ProbeMatches.Matches {
+		matches = append(matches, ProbeMatch{
+			Address: strings.TrimSpace(m.Address),
+			Types:   strings.Fields(m.Types),
+			Scopes:  strings.Fields(m.Scopes),
+			XAddrs:  strings.Fields(m.XAddrs),
+		})
+	}
+	return matches, strings.TrimSpace(env.Header.RelatesTo), nil
+}
+
+// onvifScope returns the unescaped value of the first ONVIF scope with the given key,
+// e.g. "hardware" for onvif://www.onvif.org/hardware/DS-2CD2143G0-I.
+func (m ProbeMatch) onvifScope(key string) string {
+	for _, scope := range m.Scopes {
+		rest, ok := strings.CutPrefix(scope, onvifScopePrefix)
+		if !ok {
+			continue
+		}
+		k, v, ok := strings.Cut(rest, "/")
+		if !ok || !strings.EqualFold(k, key) || v == "" {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(v); err == nil {
+			v = unescaped
+		}
+		return v
+	}
+	return ""
+}
+
+// isONVIF reports whether the match is an ONVIF device (camera, encoder or recorder).
+func (m ProbeMatch) isONVIF() bool {
+	for _, t := range m.Types {
+		if strings.HasSuffix(t, ":NetworkVideoTransmitter") || strings.HasSuffix(t, ":NetworkVideoDisplay") {
+			return true
+		}
+	}
+	for _, s := range m.Scopes {
+		if strings.HasPrefix(s, onvifScopePrefix) {
+			return true
+		}
+	}
+	return false
+}
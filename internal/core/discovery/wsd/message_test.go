@@ -0,0 +1,78 @@
+package wsd
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBuildProbe(t *testing.T) {
+	probe, id := buildProbe(onvifVideoTransmitter)
+	if !strings.HasPrefix(id, "urn:uuid:") || len(id) != len("urn:uuid:")+36 {
+		t.Errorf("unexpected message ID %q", id)
+	}
+	if !bytes.Contains(probe, []byte("<wsa:MessageID>"+id+"</wsa:MessageID>")) ||
+		!bytes.Contains(probe, []byte("<wsd:Types>dn:NetworkVideoTransmitter</wsd:Types>")) {
+		t.Errorf("unexpected probe %s", probe)
+	}
+	if probe, _ := buildProbe(""); bytes.Contains(probe, []byte("Types")) {
+		t.Errorf("an untyped probe must not restrict the types")
+	}
+}
+
+func TestParseProbeMatches(t *testing.T) {
+	matches, relatesTo, err := parseProbeMatches(readFixture(t, "probematches-onvif.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if relatesTo != "urn:uuid:8c7d6e5f-4a3b-4c2d-9e1f-0a1b2c3d4e5f" {
+		t.Errorf("unexpected RelatesTo %q", relatesTo)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected one match, got %d", len(matches))
+	}
+	m := matches[0]
+	if m.Address != "urn:uuid:3fa1fe68-b915-4053-a3e1-c4bf8b5a1e2d" || len(m.Types) != 2 || len(m.Scopes) != 5 || len(m.XAddrs) != 2 {
+		t.Errorf("unexpected match %+v", m)
+	}
+	if !m.isONVIF() || m.onvifScope("hardware") != "DS-2CD2143G0-I" || m.onvifScope("name") != "Front Door" {
+		t.Errorf("unexpected ONVIF scopes of %+v", m)
+	}
+
+	if _, _, err := parseProbeMatches([]byte(`<Envelope><Header><Action>` + actionProbe + `</Action></Header></Envelope>`)); err == nil {
+		t.Errorf("expected an error for a Probe")
+	}
+}
+
+func TestNewDevice(t *testing.T) {
+	matches, _, err := parseProbeMatches(readFixture(t, "probematches-onvif.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newDevice(net.ParseIP("192.168.1.64"), matches[0])
+
+	if d.DisplayName != "Front Door" || d.ExtraData[ExtraModel] != "DS-2CD2143G0-I" {
+		t.Errorf("unexpected device %+v", d)
+	}
+	if !strings.Contains(d.ExtraData[ExtraScopes], "onvif://www.onvif.org/type/video_encoder") {
+		t.Errorf("expected the scopes in the extra data, got %v", d.ExtraData)
+	}
+	if _, ok := d.Sources[Name]; !ok {
+		t.Errorf("expected source %s", Name)
+	}
+	svc, ok := d.Service("onvif")
+	if !ok || svc.Target != "192.168.1.64" || svc.Port != 80 {
+		t.Errorf("unexpected services %+v", d.Services)
+	}
+}
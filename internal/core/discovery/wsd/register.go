@@ -0,0 +1,17 @@
+package wsd
+
+import (
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+func init() {
+	discovery.Register(discovery.Registration{
+		Name:           Name,
+		Description:    "WS-Discovery probe for ONVIF cameras, printers and Windows hosts",
+		DefaultEnabled: true,
+		New: func(iface *discovery.InterfaceInfo, _ config.ScannerSettings) (discovery.Scanner, error) {
+			return NewScanner(iface), nil
+		},
+	})
+}
@@ -0,0 +1,178 @@
+package wsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"go.uber.org/zap"
+)
+
+// Name is the scanner and source name of the WS-Discovery scanner.
+const Name = "wsd"
+
+const (
+	// MulticastAddr is the IPv4 WS-Discovery group.
+	MulticastAddr = "239.255.255.250:3702"
+	// MulticastAddrV6 is the IPv6 link-local WS-Discovery group.
+	MulticastAddrV6 = "[ff02::c]:3702"
+	maxMessageSize  = 65536
+)
+
+// ExtraData keys set from ProbeMatches.
+const (
+	ExtraTypes  = "wsd_types"
+	ExtraScopes = "wsd_scopes"
+	ExtraXAddrs = "wsd_xaddrs"
+	ExtraModel  = "wsd_model"
+)
+
+var _ discovery.InterfaceScanner = (*Scanner)(nil)
+
+// Scanner finds devices through WS-Discovery, which ONVIF cameras and many Windows hosts
+// and printers answer. It multicasts a Probe for all devices and one for ONVIF video
+// transmitters, as some cameras only answer typed probes, and reads the ProbeMatches.
+type Scanner struct {
+	iface *discovery.InterfaceInfo
+}
+
+func NewScanner(iface *discovery.InterfaceInfo) *Scanner {
+	return &Scanner{iface: iface}
+}
+
+func (s *Scanner) Name() string { return Name }
+
+// Interface returns the network interface the scanner is bound to.
+func (s *Scanner) Interface() *discovery.InterfaceInfo { return s.iface }
+
+// Scan probes over IPv4 and IPv6, depending on the addresses of the interface, and
+// streams the matches until the ctx deadline.
+func (s *Scanner) Scan(ctx context.Context, out chan<- discovery.Device) error {
+	return discovery.ScanFamilies(s.iface, s.Name(), func(family discovery.IPFamily) error {
+		return s.scanFamily(ctx, family, out)
+	})
+}
+
+func (s *Scanner) scanFamily(ctx context.Context, family discovery.IPFamily, out chan<- discovery.Device) error {
+	log := zap.L().With(zap.String("scanner", Name), zap.Stringer("family", family))
+	network, host := "udp4", MulticastAddr
+	if family == discovery.IPv6 {
+		network, host = "udp6", MulticastAddrV6
+	}
+	group, err := net.ResolveUDPAddr(network, host)
+	if err != nil {
+		return fmt.Errorf("resolve wsd addr: %w", err)
+	}
+	if family == discovery.IPv6 {
+		group.Zone = s.iface.Interface.Name
+	}
+	conn, err := net.ListenUDP(network, s.iface.LocalUDPAddr(family))
+	if err != nil {
+		return fmt.Errorf("listen udp: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return errors.New("wsd scan requires context with deadline")
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return fmt.Errorf("set read deadline: %w", err)
+	}
+
+	sent := map[string]bool{}
+	for _, types := range []string{"", onvifVideoTransmitter} {
+		probe, id := buildProbe(types)
+		if _, err := conn.WriteToUDP(probe, group); err != nil {
+			return fmt.Errorf("send probe: %w", err)
+		}
+		sent[id] = true
+	}
+
+	seen := map[string]bool{}
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("read wsd: %w", err)
+		}
+		matches, relatesTo, err := parseProbeMatches(buf[:n])
+		if err != nil {
+			log.Debug("ignoring message", zap.Stringer("src", src), zap.Error(err))
+			continue
+		}
+		if relatesTo != "" && !sent[relatesTo] {
+			continue
+		}
+		for _, m := range matches {
+			key := m.Address + "@" + src.IP.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			select {
+			case out <- newDevice(src.IP, m):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// newDevice builds the device of a probe match received from ip.
+func newDevice(ip net.IP, m ProbeMatch) discovery.Device {
+	d := discovery.NewDevice(ip)
+	d.Sources[Name] = struct{}{}
+
+	model := m.onvifScope("hardware")
+	d.DisplayName = m.onvifScope("name")
+	if d.DisplayName == "" {
+		d.DisplayName = model
+	}
+	for key, value := range map[string]string{
+		ExtraTypes:  strings.Join(m.Types, " "),
+		ExtraScopes: strings.Join(m.Scopes, " "),
+		ExtraXAddrs: strings.Join(m.XAddrs, " "),
+		ExtraModel:  model,
+	} {
+		if value != "" {
+			d.ExtraData[key] = value
+		}
+	}
+
+	service := discovery.ServiceInstance{Type: "wsd", Protocol: "tcp", Source: Name, LastSeen: d.LastSeen}
+	if m.isONVIF() {
+		service.Type = "onvif"
+	}
+	if len(m.Types) > 0 {
+		service.TXT = map[string]string{"types": strings.Join(m.Types, " ")}
+	}
+	for _, xaddr := range m.XAddrs {
+		u, err := url.Parse(xaddr)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		service.Target = u.Hostname()
+		service.Port, _ = strconv.Atoi(u.Port())
+		if service.Port == 0 && u.Scheme == "https" {
+			service.Port = 443
+		} else if service.Port == 0 {
+			service.Port = 80
+		}
+		break
+	}
+	d.AddService(service)
+	return d
+}
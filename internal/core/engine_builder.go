@@ -1,52 +1,79 @@
 package core
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
 	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
-	"github.com/ramonvermeulen/whosthere/internal/core/discovery/arp"
-	"github.com/ramonvermeulen/whosthere/internal/core/discovery/mdns"
-	"github.com/ramonvermeulen/whosthere/internal/core/discovery/ssdp"
 	"github.com/ramonvermeulen/whosthere/internal/core/oui"
 )
 
-func BuildScanners(iface *discovery.InterfaceInfo, enabled []string) ([]discovery.Scanner, *arp.Sweeper) {
-	sweeper := arp.NewSweeper(iface, 5*time.Minute, time.Minute)
-	var scanners []discovery.Scanner
-	for _, e := range enabled {
-		switch e {
-		case "ssdp":
-			scanners = append(scanners, ssdp.NewScanner(iface))
-		case "arp":
-			scanners = append(scanners, arp.NewScanner(iface, sweeper))
-		case "mdns":
-			scanners = append(scanners, mdns.NewScanner(iface))
+// BuildScanners creates the enabled scanners through the scanner registry, using their
+// config block from scanners (defaults when not configured).
+func BuildScanners(iface *discovery.InterfaceInfo, scanners config.ScannersConfig, enabled []string) ([]discovery.Scanner, error) {
+	var out []discovery.Scanner
+	for _, name := range enabled {
+		s, err := discovery.NewScanner(name, iface, scanners.Settings(name))
+		if err != nil {
+			return nil, err
 		}
+		out = append(out, s)
 	}
-	return scanners, sweeper
+	return out, nil
 }
 
+// GetEnabledFromCfg returns the names of the scanners enabled in the config.
 func GetEnabledFromCfg(cfg *config.Config) []string {
-	var enabled []string
-	if cfg.Scanners.SSDP.Enabled {
-		enabled = append(enabled, "ssdp")
-	}
-	if cfg.Scanners.ARP.Enabled {
-		enabled = append(enabled, "arp")
+	return cfg.Scanners.EnabledNames()
+}
+
+// ScannerNames returns the names of all registered scanners.
+func ScannerNames() []string {
+	regs := discovery.Registrations()
+	names := make([]string, len(regs))
+	for i, r := range regs {
+		names[i] = r.Name
 	}
-	if cfg.Scanners.MDNS.Enabled {
-		enabled = append(enabled, "mdns")
+	return names
+}
+
+// ParseScannerNames validates scanner names given on the command line,
+// "all" selects every registered scanner.
+func ParseScannerNames(names []string) ([]string, error) {
+	var out []string
+	seen := map[string]bool{}
+	for _, n := range names {
+		if n == "all" {
+			return ScannerNames(), nil
+		}
+		if _, ok := discovery.LookupScanner(n); !ok {
+			return nil, fmt.Errorf("unknown scanner %q (available: %v)", n, ScannerNames())
+		}
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
 	}
-	return enabled
+	return out, nil
 }
 
-func BuildEngine(iface *discovery.InterfaceInfo, ouiDB *oui.Registry, enabled []string, timeout time.Duration) *discovery.Engine {
-	scanners, _ := BuildScanners(iface, enabled)
+// BuildEngine creates an engine running the enabled scanners on every interface in ifaces,
+// one scanner set per interface.
+func BuildEngine(ifaces []*discovery.InterfaceInfo, ouiDB *oui.Registry, scanners config.ScannersConfig, enabled []string, timeout time.Duration, opts ...discovery.EngineOption) (*discovery.Engine, error) {
+	var built []discovery.Scanner
+	for _, iface := range ifaces {
+		set, err := BuildScanners(iface, scanners, enabled)
+		if err != nil {
+			return nil, err
+		}
+		built = append(built, set...)
+	}
 
+	opts = append([]discovery.EngineOption{discovery.WithTimeout(timeout)}, opts...)
 	if ouiDB != nil {
-		return discovery.NewEngine(scanners, discovery.WithTimeout(timeout), discovery.WithOUIRegistry(ouiDB))
+		opts = append(opts, discovery.WithOUIRegistry(ouiDB))
 	}
 
-	return discovery.NewEngine(scanners, discovery.WithTimeout(timeout))
+	return discovery.NewEngine(built, opts...), nil
 }
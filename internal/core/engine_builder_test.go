@@ -1,6 +1,7 @@
 package core
 
 import (
+	"net"
 	"testing"
 	"time"
 
@@ -14,38 +15,47 @@ import (
 func TestBuildScanners(t *testing.T) {
 	iface := &discovery.InterfaceInfo{}
 	enabled := []string{"ssdp", "arp", "mdns"}
-	scanners, sweeper := BuildScanners(iface, enabled)
+	scanners, err := BuildScanners(iface, config.DefaultScannersConfig(), enabled)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	if len(scanners) != 3 {
-		t.Errorf("expected 3 scanners, got %d", len(scanners))
-	}
-	if sweeper == nil {
-		t.Errorf("expected sweeper")
+		t.Fatalf("expected 3 scanners, got %d", len(scanners))
 	}
 
 	if _, ok := scanners[0].(*ssdp.Scanner); !ok {
 		t.Errorf("expected ssdp scanner")
 	}
-	if _, ok := scanners[1].(*arp.Scanner); !ok {
+	a, ok := scanners[1].(*arp.Scanner)
+	if !ok {
 		t.Errorf("expected arp scanner")
+	} else if a.Sweeper == nil {
+		t.Errorf("expected sweeper")
 	}
 	if _, ok := scanners[2].(*mdns.Scanner); !ok {
 		t.Errorf("expected mdns scanner")
 	}
 }
 
+func TestBuildScannersUnknown(t *testing.T) {
+	if _, err := BuildScanners(&discovery.InterfaceInfo{}, nil, []string{"nope"}); err == nil {
+		t.Fatalf("expected error for unknown scanner")
+	}
+}
+
 func TestGetEnabledFromCfg(t *testing.T) {
 	cfg := &config.Config{
-		Scanners: config.ScannerConfig{
-			SSDP: config.ScannerToggle{Enabled: true},
-			ARP:  config.ScannerToggle{Enabled: false},
-			MDNS: config.ScannerToggle{Enabled: true},
+		Scanners: config.ScannersConfig{
+			"ssdp": &config.ScannerToggle{Enabled: true},
+			"arp":  &arp.Config{ScannerToggle: config.ScannerToggle{Enabled: false}},
+			"mdns": &config.ScannerToggle{Enabled: true},
 		},
 	}
 	enabled := GetEnabledFromCfg(cfg)
-	expected := []string{"ssdp", "mdns"}
+	expected := []string{"mdns", "ssdp"}
 	if len(enabled) != len(expected) {
-		t.Errorf("expected %v, got %v", expected, enabled)
+		t.Fatalf("expected %v, got %v", expected, enabled)
 	}
 	for i, e := range enabled {
 		if e != expected[i] {
@@ -54,11 +64,36 @@ func TestGetEnabledFromCfg(t *testing.T) {
 	}
 }
 
+func TestParseScannerNames(t *testing.T) {
+	all, err := ParseScannerNames([]string{"all"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(all) != len(discovery.Registrations()) {
+		t.Errorf("expected every registered scanner, got %v", all)
+	}
+
+	got, err := ParseScannerNames([]string{"arp", "arp", "mdns"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0] != "arp" || got[1] != "mdns" {
+		t.Errorf("expected [arp mdns], got %v", got)
+	}
+
+	if _, err := ParseScannerNames([]string{"bogus"}); err == nil {
+		t.Errorf("expected error for unknown scanner")
+	}
+}
+
 func TestBuildEngine(t *testing.T) {
 	iface := &discovery.InterfaceInfo{}
 	enabled := []string{"ssdp"}
 	timeout := 10 * time.Second
-	engine := BuildEngine(iface, nil, enabled, timeout)
+	engine, err := BuildEngine([]*discovery.InterfaceInfo{iface}, nil, nil, enabled, timeout)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	if len(engine.Scanners) != 1 {
 		t.Errorf("expected 1 scanner")
@@ -67,3 +102,22 @@ func TestBuildEngine(t *testing.T) {
 		t.Errorf("expected timeout %v, got %v", timeout, engine.Timeout)
 	}
 }
+
+func TestBuildEngineMultipleInterfaces(t *testing.T) {
+	eth0 := &discovery.InterfaceInfo{Interface: &net.Interface{Name: "eth0"}}
+	eth1 := &discovery.InterfaceInfo{Interface: &net.Interface{Name: "eth1"}}
+	engine, err := BuildEngine([]*discovery.InterfaceInfo{eth0, eth1}, nil, nil, []string{"mdns", "ssdp"}, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(engine.Scanners) != 4 {
+		t.Fatalf("expected a scanner set per interface, got %d scanners", len(engine.Scanners))
+	}
+	for i, want := range []*discovery.InterfaceInfo{eth0, eth0, eth1, eth1} {
+		s, ok := engine.Scanners[i].(discovery.InterfaceScanner)
+		if !ok || s.Interface() != want {
+			t.Errorf("scanner %d: expected to be bound to %s", i, want.Interface.Name)
+		}
+	}
+}
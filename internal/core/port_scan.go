@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+// InterfaceFor returns the scanned interface a device was seen on, the first one when unknown.
+func InterfaceFor(ifaces []*discovery.InterfaceInfo, d *discovery.Device) *discovery.InterfaceInfo {
+	if len(ifaces) == 0 {
+		return nil
+	}
+	for _, iface := range ifaces {
+		if iface.Interface != nil && iface.Interface.Name == d.Interface {
+			return iface
+		}
+	}
+	return ifaces[0]
+}
+
+// FilterDevices returns the devices seen on iface (every interface when empty) with an
+// IP address, interface, name, MAC address, manufacturer or OS matching the
+// case-insensitive regular expression pattern (every device when empty).
+func FilterDevices(devices []discovery.Device, iface, pattern string) ([]discovery.Device, error) {
+	var re *regexp.Regexp
+	if pattern = strings.TrimSpace(pattern); pattern != "" {
+		var err error
+		if re, err = regexp.Compile("(?i)" + pattern); err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+	var out []discovery.Device
+	for _, d := range devices {
+		if iface != "" && d.Interface != iface {
+			continue
+		}
+		if re != nil && !matchesDevice(re, &d) {
+			continue
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func matchesDevice(re *regexp.Regexp, d *discovery.Device) bool {
+	for _, field := range []string{d.IP.String(), d.Interface, d.DisplayName, d.MAC, d.Manufacturer, d.OS} {
+		if re.MatchString(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// PortScanDevices port scans the tcp ports (the configured ports when empty) and the
+// configured UDP ports of the devices, with the worker pool and rate limit of the timing
+// of cfg shared by all devices. The timeouts of a device start from its latency. The
+// open ports of every device are reset, the scan has a newer LastPortScan so merging
+// its devices replaces the ports found before. update receives the device before the
+// scan and after every port found, progress the progress of its host. The callbacks
+// are not called concurrently.
+func PortScanDevices(ctx context.Context, devices []discovery.Device, ifaces []*discovery.InterfaceInfo, cfg config.PortScannerConfig, tcp []int, update func(*discovery.Device), progress func(discovery.HostProgress)) error {
+	if len(tcp) == 0 {
+		tcp = cfg.TCP
+	}
+
+	targets := make([]discovery.BulkTarget, 0, len(devices))
+	byIP := make(map[string]*discovery.Device, len(devices))
+	now := time.Now()
+	for i := range devices {
+		d := &devices[i]
+		if d.IP == nil {
+			continue
+		}
+		ip := d.IP.String()
+		if _, dup := byIP[ip]; dup {
+			continue
+		}
+		d.OpenPorts = discovery.PortMap{}
+		d.LastPortScan = now
+		byIP[ip] = d
+		targets = append(targets, discovery.BulkTarget{IP: ip, Iface: InterfaceFor(ifaces, d), Latency: d.Latency})
+		if update != nil {
+			update(snapshot(d))
+		}
+	}
+
+	scanner := discovery.NewBulkScanner(discovery.TimingFromConfig(cfg))
+	return scanner.Scan(ctx, targets, tcp, cfg.UDP, func(ip, protocol string, port int, state discovery.PortState) {
+		d := byIP[ip]
+		d.OpenPorts.Add(protocol, port, state)
+		if update != nil {
+			update(snapshot(d))
+		}
+	}, progress)
+}
+
+// snapshot copies d with its own open ports, update may keep the device while the scan
+// adds ports to d.
+func snapshot(d *discovery.Device) *discovery.Device {
+	c := *d
+	c.OpenPorts = d.OpenPorts.Clone()
+	return &c
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+)
+
+func TestFilterDevices(t *testing.T) {
+	devices := []discovery.Device{
+		{IP: net.ParseIP("192.168.1.10"), Interface: "eth0", DisplayName: "raspberrypi", Manufacturer: "Raspberry Pi Trading Ltd"},
+		{IP: net.ParseIP("192.168.1.20"), Interface: "eth0", DisplayName: "printer", Manufacturer: "Brother"},
+		{IP: net.ParseIP("10.0.0.5"), Interface: "wlan0", DisplayName: "laptop", MAC: "aa:bb:cc:dd:ee:ff"},
+	}
+
+	tests := []struct {
+		name    string
+		iface   string
+		pattern string
+		want    []string
+	}{
+		{"all", "", "", []string{"192.168.1.10", "192.168.1.20", "10.0.0.5"}},
+		{"interface", "eth0", "", []string{"192.168.1.10", "192.168.1.20"}},
+		{"name case-insensitive", "", "RASPBERRY", []string{"192.168.1.10"}},
+		{"ip regexp", "", `^192\.168\.1\.2`, []string{"192.168.1.20"}},
+		{"mac", "", "aa:bb", []string{"10.0.0.5"}},
+		{"interface and pattern", "wlan0", "printer", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FilterDevices(devices, tt.iface, tt.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d devices, got %d", len(tt.want), len(got))
+			}
+			for i, d := range got {
+				if d.IP.String() != tt.want[i] {
+					t.Errorf("device %d: expected %s, got %s", i, tt.want[i], d.IP)
+				}
+			}
+		})
+	}
+
+	if _, err := FilterDevices(devices, "", "("); err == nil {
+		t.Errorf("expected error for invalid pattern")
+	}
+}
+
+func TestInterfaceFor(t *testing.T) {
+	eth0 := &discovery.InterfaceInfo{Interface: &net.Interface{Name: "eth0"}}
+	wlan0 := &discovery.InterfaceInfo{Interface: &net.Interface{Name: "wlan0"}}
+	ifaces := []*discovery.InterfaceInfo{eth0, wlan0}
+
+	if got := InterfaceFor(ifaces, &discovery.Device{Interface: "wlan0"}); got != wlan0 {
+		t.Errorf("expected wlan0")
+	}
+	if got := InterfaceFor(ifaces, &discovery.Device{Interface: "tun0"}); got != eth0 {
+		t.Errorf("expected first interface for unknown interface")
+	}
+	if got := InterfaceFor(nil, &discovery.Device{Interface: "eth0"}); got != nil {
+		t.Errorf("expected nil without interfaces")
+	}
+}
+
+func TestPortScanDevicesResetsOpenPorts(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	open := ln.Addr().(*net.TCPAddr).Port
+
+	index := discovery.NewDeviceIndex(nil)
+	d := discovery.NewDevice(net.ParseIP("127.0.0.1"))
+	d.OpenPorts.Add(discovery.ProtocolTCP, 9, discovery.PortOpen)
+	d.LastPortScan = time.Now().Add(-time.Hour)
+	index.Upsert(&d)
+
+	cfg := config.PortScannerConfig{Timeout: 200 * time.Millisecond, Timing: config.TimingNormal}
+	err = PortScanDevices(context.Background(), []discovery.Device{d}, nil, cfg, []int{open}, func(d *discovery.Device) { index.Upsert(d) }, nil)
+	if err != nil {
+		t.Fatalf("PortScanDevices failed: %v", err)
+	}
+	dev, _ := index.Lookup("127.0.0.1")
+	if got := dev.OpenPorts.Numbers(discovery.ProtocolTCP); !slices.Equal(got, []int{open}) {
+		t.Errorf("expected only port %d after the rescan, got %v", open, got)
+	}
+}
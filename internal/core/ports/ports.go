@@ -0,0 +1,167 @@
+package ports
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// embeddedTopPorts is the frequency-ranked list behind the topN specs, one port per line.
+//
+//go:embed top-ports.txt
+var embeddedTopPorts []byte
+
+const (
+	MinPort = 1
+	MaxPort = 65535
+)
+
+// Sets are the named port sets of a spec.
+var Sets = map[string][]int{
+	"web":     {80, 81, 443, 591, 3000, 5000, 8000, 8008, 8080, 8081, 8088, 8443, 8888, 9000, 9443},
+	"db":      {1433, 1521, 2483, 3306, 5432, 5984, 6379, 8086, 9042, 9200, 11211, 27017},
+	"iot":     {23, 80, 443, 502, 554, 1883, 2323, 8080, 8443, 8554, 8883, 9100, 49152},
+	"windows": {88, 135, 139, 389, 445, 464, 636, 3268, 3269, 3389, 5985, 5986, 9389},
+}
+
+var topPorts = parseTopPorts(embeddedTopPorts)
+
+func parseTopPorts(data []byte) []int {
+	var ports []int
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if port, err := strconv.Atoi(line); err == nil {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// Top returns the n most frequently open TCP ports, most frequent first.
+func Top(n int) []int {
+	n = min(max(n, 0), len(topPorts))
+	return slices.Clone(topPorts[:n])
+}
+
+// TopMax is the largest n of a topN spec.
+func TopMax() int { return len(topPorts) }
+
+// Parse expands a comma-separated port spec in the style of nmap. A term is a port
+// (22), a range (8000-8100, -1024 and 1- are open ended, - is every port), topN for
+// the N most frequently open TCP ports or the name of one of the Sets. The result
+// keeps the order of the spec without duplicates.
+func Parse(spec string) ([]int, error) {
+	var ports []int
+	seen := map[int]bool{}
+	add := func(p int) {
+		if !seen[p] {
+			seen[p] = true
+			ports = append(ports, p)
+		}
+	}
+
+	for _, term := range strings.Split(spec, ",") {
+		term = strings.ToLower(strings.TrimSpace(term))
+		switch {
+		case term == "":
+			continue
+		case Sets[term] != nil:
+			for _, p := range Sets[term] {
+				add(p)
+			}
+		case strings.HasPrefix(term, "top"):
+			n, err := strconv.Atoi(strings.TrimPrefix(term, "top"))
+			if err != nil || n < 1 || n > TopMax() {
+				return nil, fmt.Errorf("%q: expected top1 to top%d", term, TopMax())
+			}
+			for _, p := range topPorts[:n] {
+				add(p)
+			}
+		case strings.Contains(term, "-"):
+			lo, hi, err := parseRange(term)
+			if err != nil {
+				return nil, err
+			}
+			for p := lo; p <= hi; p++ {
+				add(p)
+			}
+		default:
+			p, err := parsePort(term)
+			if err != nil {
+				return nil, err
+			}
+			add(p)
+		}
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("%q: no ports", spec)
+	}
+	return ports, nil
+}
+
+func parseRange(term string) (int, int, error) {
+	from, to, _ := strings.Cut(term, "-")
+	lo, hi := MinPort, MaxPort
+	var err error
+	if from != "" {
+		if lo, err = parsePort(from); err != nil {
+			return 0, 0, err
+		}
+	}
+	if to != "" {
+		if hi, err = parsePort(to); err != nil {
+			return 0, 0, err
+		}
+	}
+	if lo > hi {
+		return 0, 0, fmt.Errorf("%q: range ends before it starts", term)
+	}
+	return lo, hi, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q: not a port, range, topN or one of %s", s, strings.Join(SetNames(), ", "))
+	}
+	if p < MinPort || p > MaxPort {
+		return 0, fmt.Errorf("%q: port out of range %d-%d", s, MinPort, MaxPort)
+	}
+	return p, nil
+}
+
+// SetNames returns the names of the Sets, sorted.
+func SetNames() []string {
+	names := make([]string, 0, len(Sets))
+	for name := range Sets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Format returns the spec of ports, collapsing runs of consecutive ports into ranges.
+func Format(ports []int) string {
+	var terms []string
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if j == i {
+			terms = append(terms, strconv.Itoa(ports[i]))
+		} else {
+			terms = append(terms, fmt.Sprintf("%d-%d", ports[i], ports[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(terms, ",")
+}
@@ -0,0 +1,85 @@
+package ports
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []int
+	}{
+		{"22", []int{22}},
+		{"1-3,3389, 8000-8002", []int{1, 2, 3, 3389, 8000, 8001, 8002}},
+		{"65534-", []int{65534, 65535}},
+		{"-2", []int{1, 2}},
+		{"443,80,443", []int{443, 80}},
+		{"top3", []int{80, 23, 443}},
+		{"TOP2,22", []int{80, 23, 22}},
+		{"windows", Sets["windows"]},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.spec, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+
+	all, err := Parse("-")
+	if err != nil || len(all) != MaxPort {
+		t.Errorf("expected every port for \"-\", got %d (%v)", len(all), err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{"", " , ", "0", "65536", "http", "100-10", "top0", "top1001", "topx", "1-2-3"} {
+		if ports, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) = %v, expected error", spec, ports)
+		}
+	}
+}
+
+func TestTop(t *testing.T) {
+	if TopMax() != 1000 {
+		t.Fatalf("expected 1000 ranked ports, got %d", TopMax())
+	}
+	top := Top(1000)
+	seen := map[int]bool{}
+	for _, p := range top {
+		if seen[p] || p < MinPort || p > MaxPort {
+			t.Fatalf("invalid or duplicate port %d in the ranking", p)
+		}
+		seen[p] = true
+	}
+	if got := Top(5); !slices.Equal(got, []int{80, 23, 443, 21, 22}) {
+		t.Errorf("unexpected top 5 %v", got)
+	}
+	if got := Top(110)[100:]; !slices.Equal(got, []int{1000, 3001, 5001, 82, 10010, 1030, 9090, 2107, 1024, 2103}) {
+		t.Errorf("unexpected top 101 to 110 %v", got)
+	}
+	if got, err := Parse("top250"); err != nil || len(got) != 250 {
+		t.Errorf("expected top250 to select 250 ports, got %d ports, %v", len(got), err)
+	}
+	if got, err := Parse("top1000"); err != nil || len(got) != 1000 {
+		t.Errorf("expected top1000 to select the whole list, got %d ports, %v", len(got), err)
+	}
+	if len(Top(5000)) != 1000 {
+		t.Error("expected Top to cap at the ranking size")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	ports := []int{80, 1, 2, 3, 3389, 8000, 8001}
+	spec := Format(ports)
+	if spec != "80,1-3,3389,8000-8001" {
+		t.Errorf("unexpected spec %q", spec)
+	}
+	if got, err := Parse(spec); err != nil || !slices.Equal(got, ports) {
+		t.Errorf("expected spec to round trip, got %v (%v)", got, err)
+	}
+}
@@ -0,0 +1,62 @@
+package ports
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"strconv"
+	"strings"
+)
+
+// embeddedServices is the port to service name registry in the column layout of the
+// IANA service name and port number registry, with the names nmap uses for common ports.
+//
+//go:embed services.csv
+var embeddedServices []byte
+
+// Service is a well-known service of a port.
+type Service struct {
+	Name        string // short service name, e.g. ssh or https
+	Port        int
+	Protocol    string // tcp or udp
+	Description string
+}
+
+type serviceKey struct {
+	port     int
+	protocol string
+}
+
+var services = parseServices(embeddedServices)
+
+func parseServices(data []byte) map[serviceKey]Service {
+	m := map[serviceKey]Service{}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return m
+	}
+	for _, rec := range records {
+		if len(rec) < 4 {
+			continue
+		}
+		port, err := strconv.Atoi(rec[1])
+		if err != nil {
+			continue // header
+		}
+		s := Service{Name: rec[0], Port: port, Protocol: rec[2], Description: rec[3]}
+		m[serviceKey{port, s.Protocol}] = s
+	}
+	return m
+}
+
+// Lookup returns the well-known service of the port, protocol is tcp or udp.
+func Lookup(port int, protocol string) (Service, bool) {
+	s, ok := services[serviceKey{port, strings.ToLower(protocol)}]
+	return s, ok
+}
+
+// ServiceName returns the name of the well-known service of the port, empty if unknown.
+func ServiceName(port int, protocol string) string {
+	s, _ := Lookup(port, protocol)
+	return s.Name
+}
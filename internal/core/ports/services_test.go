@@ -0,0 +1,37 @@
+package ports
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		port     int
+		protocol string
+		want     string
+	}{
+		{22, "tcp", "ssh"},
+		{443, "tcp", "https"},
+		{53, "udp", "domain"},
+		{161, "udp", "snmp"},
+		{22, "udp", ""},
+		{4, "tcp", ""}, // a top port without a registered service
+		{40000, "tcp", ""},
+	}
+	for _, tt := range tests {
+		if got := ServiceName(tt.port, tt.protocol); got != tt.want {
+			t.Errorf("ServiceName(%d, %q) = %q, want %q", tt.port, tt.protocol, got, tt.want)
+		}
+	}
+
+	s, ok := Lookup(3389, "TCP")
+	if !ok || s.Name != "ms-wbt-server" || s.Description == "" {
+		t.Errorf("unexpected service %+v", s)
+	}
+}
+
+func TestServicesCoverTop100(t *testing.T) {
+	for _, port := range Top(100) {
+		if _, ok := Lookup(port, "tcp"); !ok {
+			t.Errorf("no service for top port %d", port)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -17,7 +18,7 @@ var titleRe = regexp.MustCompile(`(?i)<title[^>]*>\s*([^<]+?)\s*</title>`)
 // GrabBanner connects to the given TCP port and reads any initial service
 // banner (SSH, FTP, SMTP, etc. send a greeting upon connection).
 func GrabBanner(ctx context.Context, ip string, port int, timeout time.Duration) string {
-	addr := fmt.Sprintf("%s:%d", ip, port)
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
 	d := net.Dialer{Timeout: timeout}
 	conn, err := d.DialContext(ctx, "tcp", addr)
 	if err != nil {
@@ -34,11 +35,47 @@ func GrabBanner(ctx context.Context, ip string, port int, timeout time.Duration)
 	return sanitizeBanner(string(buf[:n]))
 }
 
+// GrabTLSBanner completes a TLS handshake on the given TCP port and reads the
+// service banner (IMAPS, POP3S, FTPS, etc.). Services that wait for the client
+// are described by the name on their certificate instead. It skips certificate
+// verification.
+func GrabTLSBanner(ctx context.Context, ip string, port int, timeout time.Duration) string {
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
+	d := tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config:    &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
+	}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = conn.Close() }()
+
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 1024)
+	if n, _ := conn.Read(buf); n > 0 {
+		return sanitizeBanner(string(buf[:n]))
+	}
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "TLS"
+	}
+	name := certs[0].Subject.CommonName
+	if name == "" && len(certs[0].DNSNames) > 0 {
+		name = certs[0].DNSNames[0]
+	}
+	if name == "" {
+		return "TLS"
+	}
+	return sanitizeBanner("TLS " + name)
+}
+
 // FetchHTTPInfo sends an HTTP GET request and extracts the Server header
 // and HTML <title>. It skips TLS certificate verification.
 func FetchHTTPInfo(ctx context.Context, ip string, port int, timeout time.Duration) (title, server string) {
 	scheme := "http"
-	if port == 443 || port == 8443 {
+	if strategyFor(port) == strategyHTTPS {
 		scheme = "https"
 	}
 
@@ -59,7 +96,7 @@ func FetchHTTPInfo(ctx context.Context, ip string, port int, timeout time.Durati
 		},
 	}
 
-	url := fmt.Sprintf("%s://%s:%d/", scheme, ip, port)
+	url := fmt.Sprintf("%s://%s/", scheme, net.JoinHostPort(ip, strconv.Itoa(port)))
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return "", ""
@@ -23,25 +23,30 @@ const (
 )
 
 // Fingerprint attempts to classify a device based on available information:
-// MAC/OUI manufacturer, open ports, service banners, NetBIOS name, HTTP
-// server header, and mDNS/SSDP extra data.
-func Fingerprint(mac, manufacturer string, openPorts []int, banners map[int]string, netbiosName, httpServer string, extraData map[string]string) string {
+// SNMP sysDescr, MAC/OUI manufacturer, open ports, service banners, NetBIOS
+// name, HTTP server header, and mDNS/SSDP extra data.
+func Fingerprint(mac, manufacturer string, openPorts []int, banners map[int]string, netbiosName, httpServer, sysDescr string, extraData map[string]string) string {
 	mfr := strings.ToLower(manufacturer)
 	srv := strings.ToLower(httpServer)
 
 	allExtra := strings.ToLower(flattenMap(extraData))
 
-	// 1. Manufacturer-based classification (most reliable)
+	// 1. SNMP sysDescr, the device describes itself
+	if t := fingerprintBySysDescr(strings.ToLower(sysDescr)); t != "" {
+		return t
+	}
+
+	// 2. Manufacturer-based classification (most reliable)
 	if t := fingerprintByManufacturer(mfr); t != "" {
 		return t
 	}
 
-	// 2. Service/mDNS/SSDP data
+	// 3. Service/mDNS/SSDP data
 	if t := fingerprintByServices(allExtra); t != "" {
 		return t
 	}
 
-	// 3. Port & banner analysis
+	// 4. Port & banner analysis
 	if t := fingerprintByPorts(openPorts, banners, srv); t != "" {
 		return t
 	}
@@ -49,6 +54,35 @@ func Fingerprint(mac, manufacturer string, openPorts []int, banners map[int]stri
 	return TypeUnknown
 }
 
+func fingerprintBySysDescr(descr string) string {
+	if descr == "" {
+		return ""
+	}
+
+	rules := []struct {
+		keywords []string
+		dtype    string
+	}{
+		{[]string{"printer", "jetdirect", "laserjet", "officejet", "lexmark", "xerox", "ricoh"}, TypePrinter},
+		{[]string{"access point", "unifi ap", "aironet"}, TypeAP},
+		{[]string{"switch", "catalyst", "procurve", "nexus"}, TypeSwitch},
+		{[]string{"router", "routeros", "edgeos", "openwrt", "pfsense", "opnsense", "fortigate", "junos"}, TypeRouter},
+		{[]string{"synology", "diskstation", "qnap", "readynas", "truenas"}, TypeNAS},
+		{[]string{"camera", "hikvision", "network video"}, TypeCamera},
+		{[]string{"smart-ups", "network management card", "ups network"}, TypeIoT},
+		{[]string{"vmware esxi", "windows server"}, TypeServer},
+	}
+
+	for _, rule := range rules {
+		for _, kw := range rule.keywords {
+			if strings.Contains(descr, kw) {
+				return rule.dtype
+			}
+		}
+	}
+	return ""
+}
+
 func fingerprintByManufacturer(mfr string) string {
 	if mfr == "" {
 		return ""
@@ -93,12 +127,14 @@ func fingerprintByServices(extra string) string {
 		keywords []string
 		dtype    string
 	}{
-		{[]string{"printer", "_ipp.", "_pdl-"}, TypePrinter},
+		{[]string{"printer", "printdevicetype", "_ipp.", "_pdl-"}, TypePrinter},
 		{[]string{"chromecast", "googlecast", "smarttv", "roku", "airplay", "_raop."}, TypeSmartTV},
-		{[]string{"camera", "ipcam"}, TypeCamera},
+		// ONVIF devices announce onvif:// scopes and the NetworkVideoTransmitter type over WS-Discovery
+		{[]string{"camera", "ipcam", "onvif://", "networkvideotransmitter"}, TypeCamera},
 		{[]string{"_smb.", "_afp.", "timemachine"}, TypeNAS},
 		{[]string{"homekit", "_hap."}, TypeSmartHome},
 		{[]string{"playstation", "xbox", "nintendo"}, TypeGameConsole},
+		{[]string{"pub:computer"}, TypeDesktop},
 	}
 
 	for _, rule := range rules {
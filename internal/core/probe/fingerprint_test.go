@@ -0,0 +1,32 @@
+package probe
+
+import (
+	"testing"
+)
+
+func TestFingerprint_ONVIFScopes(t *testing.T) {
+	extra := map[string]string{
+		"wsd_types":  "dn:NetworkVideoTransmitter tds:Device",
+		"wsd_scopes": "onvif://www.onvif.org/type/video_encoder onvif://www.onvif.org/hardware/IPC-HDW2431T",
+	}
+	got := Fingerprint("", "", nil, nil, "", "", "", extra)
+	if got != TypeCamera {
+		t.Errorf("expected %q, got %q", TypeCamera, got)
+	}
+}
+
+func TestFingerprint_WSDComputer(t *testing.T) {
+	extra := map[string]string{"wsd_types": "wsdp:Device pub:Computer"}
+	got := Fingerprint("", "", nil, nil, "", "", "", extra)
+	if got != TypeDesktop {
+		t.Errorf("expected %q, got %q", TypeDesktop, got)
+	}
+}
+
+func TestFingerprint_SysDescr(t *testing.T) {
+	// sysDescr wins over the manufacturer, a Cisco OUI alone means router
+	got := Fingerprint("", "Cisco Systems", nil, nil, "", "", "Cisco IOS Software, Catalyst L3 Switch Software (CAT3K_CAA-UNIVERSALK9-M)", nil)
+	if got != TypeSwitch {
+		t.Errorf("expected %q, got %q", TypeSwitch, got)
+	}
+}
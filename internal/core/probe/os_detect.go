@@ -2,8 +2,8 @@ package probe
 
 import (
 	"context"
-	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -21,45 +21,51 @@ const (
 
 // DetectOS attempts to determine the operating system of a remote host by
 // combining multiple heuristic signals:
-//  1. SSH banner analysis (e.g. "OpenSSH_8.9p1 Ubuntu")
-//  2. HTTP Server header analysis (e.g. "Microsoft-IIS")
-//  3. Service banners from other ports
-//  4. mDNS/SSDP extra data keywords
-//  5. NetBIOS name presence (strong Windows signal)
-//  6. TCP TTL-based fingerprinting
-//  7. Open port heuristics
-func DetectOS(ctx context.Context, ip string, openPorts []int, banners map[int]string, httpServer, netbiosName string, extraData map[string]string, timeout time.Duration) string {
-	// 1. SSH banner — most reliable text signal
+//  1. SNMP sysDescr (e.g. "Linux nas 5.10.55 #42962 SMP x86_64")
+//  2. SSH banner analysis (e.g. "OpenSSH_8.9p1 Ubuntu")
+//  3. HTTP Server header analysis (e.g. "Microsoft-IIS")
+//  4. Service banners from other ports
+//  5. mDNS/SSDP extra data keywords
+//  6. NetBIOS name presence (strong Windows signal)
+//  7. TCP TTL-based fingerprinting
+//  8. Open port heuristics
+func DetectOS(ctx context.Context, ip string, openPorts []int, banners map[int]string, httpServer, netbiosName, sysDescr string, extraData map[string]string, timeout time.Duration) string {
+	// 1. SNMP sysDescr — the host reports its own OS
+	if os := osFromSysDescr(sysDescr); os != "" {
+		return os
+	}
+
+	// 2. SSH banner — most reliable text signal
 	if os := osFromSSHBanner(banners); os != "" {
 		return os
 	}
 
-	// 2. HTTP Server header
+	// 3. HTTP Server header
 	if os := osFromHTTPServer(httpServer); os != "" {
 		return os
 	}
 
-	// 3. Other service banners
+	// 4. Other service banners
 	if os := osFromBanners(banners); os != "" {
 		return os
 	}
 
-	// 4. mDNS / SSDP extra data
+	// 5. mDNS / SSDP extra data
 	if os := osFromExtraData(extraData); os != "" {
 		return os
 	}
 
-	// 5. NetBIOS name present → likely Windows
+	// 6. NetBIOS name present → likely Windows
 	if netbiosName != "" {
 		return OSWindows
 	}
 
-	// 6. TCP TTL fingerprint
+	// 7. TCP TTL fingerprint
 	if os := osFromTTL(ctx, ip, openPorts, timeout); os != "" {
 		return os
 	}
 
-	// 7. Port-based heuristics
+	// 8. Port-based heuristics
 	if os := osFromPorts(openPorts); os != "" {
 		return os
 	}
@@ -67,6 +73,29 @@ func DetectOS(ctx context.Context, ip string, openPorts []int, banners map[int]s
 	return OSUnknown
 }
 
+// osFromSysDescr inspects the SNMP sysDescr, e.g. "Hardware: Intel64 Family 6 ...
+// Software: Windows Version 6.3" or "Linux router 4.14.180 #0 SMP armv7l".
+func osFromSysDescr(descr string) string {
+	if descr == "" {
+		return ""
+	}
+	d := strings.ToLower(descr)
+
+	switch {
+	case strings.Contains(d, "android"):
+		return OSAndroid
+	case strings.Contains(d, "windows"):
+		return OSWindows
+	case strings.Contains(d, "darwin") || strings.Contains(d, "macos"):
+		return OSMacOS
+	case strings.Contains(d, "freebsd"):
+		return OSFreeBSD
+	case strings.Contains(d, "linux"):
+		return OSLinux
+	}
+	return ""
+}
+
 // osFromSSHBanner inspects the SSH banner on port 22 for OS hints.
 func osFromSSHBanner(banners map[int]string) string {
 	banner, ok := banners[22]
@@ -234,7 +263,7 @@ func osFromTTL(ctx context.Context, ip string, knownPorts []int, timeout time.Du
 // getTTL connects to the given addr via TCP and reads the TTL from the
 // IP header of the SYN-ACK. Works on Linux via syscall control messages.
 func getTTL(ip string, port int, timeout time.Duration) int {
-	addr := fmt.Sprintf("%s:%d", ip, port)
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
 	d := net.Dialer{Timeout: timeout}
 
 	conn, err := d.Dial("tcp", addr)
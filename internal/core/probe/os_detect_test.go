@@ -198,15 +198,30 @@ func TestOsFromPorts_Unknown(t *testing.T) {
 
 func TestDetectOS_SSHBannerPriority(t *testing.T) {
 	banners := map[int]string{22: "SSH-2.0-OpenSSH_8.6p1 Ubuntu-4ubuntu0.5"}
-	got := DetectOS(nil, "127.0.0.1", []int{22, 3389}, banners, "", "", nil, 0)
+	got := DetectOS(nil, "127.0.0.1", []int{22, 3389}, banners, "", "", "", nil, 0)
 	if got != OSLinux {
 		t.Errorf("expected %q, got %q", OSLinux, got)
 	}
 }
 
 func TestDetectOS_NetBIOSFallback(t *testing.T) {
-	got := DetectOS(nil, "127.0.0.1", nil, nil, "", "WORKSTATION", nil, 0)
+	got := DetectOS(nil, "127.0.0.1", nil, nil, "", "WORKSTATION", "", nil, 0)
 	if got != OSWindows {
 		t.Errorf("expected %q, got %q", OSWindows, got)
 	}
 }
+
+func TestOsFromSysDescr(t *testing.T) {
+	cases := map[string]string{
+		"Hardware: Intel64 Family 6 Model 158 Stepping 10 AT/AT COMPATIBLE - Software: Windows Version 6.3 (Build 19045 Multiprocessor Free)": OSWindows,
+		"Linux nas 4.4.302+ #69057 SMP Fri Jan 12 17:02:28 CST 2024 x86_64":                                                                   OSLinux,
+		"FreeBSD fw.example.lan 13.2-RELEASE-p3 FreeBSD 13.2-RELEASE-p3 amd64":                                                                OSFreeBSD,
+		"Darwin mac-mini 23.1.0 Darwin Kernel Version 23.1.0":                                                                                 OSMacOS,
+		"Cisco IOS Software, C2960 Software (C2960-LANBASEK9-M), Version 15.0(2)SE11":                                                         OSUnknown,
+	}
+	for descr, want := range cases {
+		if got := osFromSysDescr(descr); got != want {
+			t.Errorf("osFromSysDescr(%q) = %q, want %q", descr, got, want)
+		}
+	}
+}
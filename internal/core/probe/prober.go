@@ -1,15 +1,19 @@
 // Package probe provides network probing utilities for deep device inspection.
 // It includes TCP ping, reverse DNS, banner grabbing, HTTP info, NetBIOS name
-// queries, Wake-on-LAN, and device-type fingerprinting.
+// and SNMP system queries, Wake-on-LAN, and device-type fingerprinting.
 package probe
 
 import (
 	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery/snmp"
+	"github.com/ramonvermeulen/whosthere/internal/core/ports"
 	"go.uber.org/zap"
 )
 
@@ -23,16 +27,31 @@ type Result struct {
 	DeviceType  string
 	OS          string
 	NetBIOSName string
+	SNMP        discovery.SNMPSystem
 }
 
 // Prober orchestrates various network probes against discovered devices.
 type Prober struct {
 	timeout time.Duration
+	snmp    []snmp.Params
+}
+
+// Option configures a Prober.
+type Option func(*Prober)
+
+// WithSNMP sets the SNMP params tried in order to read the system group of a device,
+// see snmp.ParamsFromConfig. Without params no SNMP query is sent.
+func WithSNMP(params []snmp.Params) Option {
+	return func(p *Prober) { p.snmp = params }
 }
 
 // New creates a new Prober with the given per-probe timeout.
-func New(timeout time.Duration) *Prober {
-	return &Prober{timeout: timeout}
+func New(timeout time.Duration, opts ...Option) *Prober {
+	p := &Prober{timeout: timeout}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // RunAll executes all available probes against the given device IP and returns
@@ -56,7 +75,13 @@ func (p *Prober) RunAll(ctx context.Context, ip, mac, manufacturer string, openP
 	log.Debug("NetBIOS query", zap.String("ip", ip))
 	result.NetBIOSName = QueryNetBIOS(ip, p.timeout)
 
-	// 4. Banner grabbing on open ports
+	// 4. SNMP system group
+	if len(p.snmp) > 0 {
+		log.Debug("SNMP query", zap.String("ip", ip))
+		result.SNMP, _ = QuerySNMP(ctx, ip, p.snmp)
+	}
+
+	// 5. Banner grabbing on open ports
 	if len(openPorts) > 0 {
 		// Generic service banners (SSH, FTP, SMTP, etc.)
 		for _, port := range openPorts {
@@ -65,11 +90,18 @@ func (p *Prober) RunAll(ctx context.Context, ip, mac, manufacturer string, openP
 				break
 			default:
 			}
-			if isHTTPPort(port) {
+			var banner string
+			switch strategyFor(port) {
+			case strategyHTTP, strategyHTTPS:
 				continue // handled separately below
+			case strategyTLS:
+				log.Debug("grabbing TLS banner", zap.String("ip", ip), zap.Int("port", port))
+				banner = GrabTLSBanner(ctx, ip, port, p.timeout)
+			default:
+				log.Debug("grabbing banner", zap.String("ip", ip), zap.Int("port", port))
+				banner = GrabBanner(ctx, ip, port, p.timeout)
 			}
-			log.Debug("grabbing banner", zap.String("ip", ip), zap.Int("port", port))
-			if banner := GrabBanner(ctx, ip, port, p.timeout); banner != "" {
+			if banner != "" {
 				result.Banners[port] = banner
 			}
 		}
@@ -80,7 +112,7 @@ func (p *Prober) RunAll(ctx context.Context, ip, mac, manufacturer string, openP
 				break
 			default:
 			}
-			if !isHTTPPort(port) {
+			if s := strategyFor(port); s != strategyHTTP && s != strategyHTTPS {
 				continue
 			}
 			log.Debug("fetching HTTP info", zap.String("ip", ip), zap.Int("port", port))
@@ -105,19 +137,65 @@ func (p *Prober) RunAll(ctx context.Context, ip, mac, manufacturer string, openP
 		}
 	}
 
-	// 5. Device fingerprinting
+	// 6. Device fingerprinting
 	log.Debug("fingerprinting device", zap.String("ip", ip))
-	result.DeviceType = Fingerprint(mac, manufacturer, openPorts, result.Banners, result.NetBIOSName, result.HTTPServer, extraData)
+	result.DeviceType = Fingerprint(mac, manufacturer, openPorts, result.Banners, result.NetBIOSName, result.HTTPServer, result.SNMP.Descr, extraData)
 
-	// 6. OS detection
+	// 7. OS detection
 	log.Debug("detecting OS", zap.String("ip", ip))
-	result.OS = DetectOS(ctx, ip, openPorts, result.Banners, result.HTTPServer, result.NetBIOSName, extraData, p.timeout)
+	result.OS = DetectOS(ctx, ip, openPorts, result.Banners, result.HTTPServer, result.NetBIOSName, result.SNMP.Descr, extraData, p.timeout)
 
 	return result
 }
 
-func isHTTPPort(port int) bool {
-	return port == 80 || port == 443 || port == 8080 || port == 8443 || port == 9090
+// strategy is how a service is probed for its banner.
+type strategy int
+
+const (
+	strategyBanner strategy = iota // read the greeting the service sends on connect
+	strategyHTTP                   // GET / and read the Server header and title
+	strategyHTTPS                  // as strategyHTTP over TLS
+	strategyTLS                    // read the greeting after the TLS handshake
+)
+
+// serviceStrategies picks the strategy by the well-known service name of the port,
+// services that are not listed get strategyBanner.
+var serviceStrategies = map[string]strategy{
+	"http":          strategyHTTP,
+	"http-alt":      strategyHTTP,
+	"http-proxy":    strategyHTTP,
+	"squid-http":    strategyHTTP,
+	"upnp":          strategyHTTP,
+	"vnc-http":      strategyHTTP,
+	"wsman":         strategyHTTP,
+	"docker":        strategyHTTP,
+	"couchdb":       strategyHTTP,
+	"elasticsearch": strategyHTTP,
+	"influxdb":      strategyHTTP,
+	"kibana":        strategyHTTP,
+
+	"https":      strategyHTTPS,
+	"https-alt":  strategyHTTPS,
+	"wsmans":     strategyHTTPS,
+	"docker-s":   strategyHTTPS,
+	"cpanel-ssl": strategyHTTPS,
+	"kubernetes": strategyHTTPS,
+
+	"ftps":            strategyTLS,
+	"telnets":         strategyTLS,
+	"imaps":           strategyTLS,
+	"pop3s":           strategyTLS,
+	"submissions":     strategyTLS,
+	"ldaps":           strategyTLS,
+	"globalcat-ldaps": strategyTLS,
+	"sip-tls":         strategyTLS,
+	"secure-mqtt":     strategyTLS,
+	"oracle-dbs":      strategyTLS,
+}
+
+// strategyFor returns the strategy of a TCP port by its service in the ports registry.
+func strategyFor(port int) strategy {
+	return serviceStrategies[ports.ServiceName(port, "tcp")]
 }
 
 // ReverseDNS performs a reverse DNS lookup (PTR record) for the given IP address.
@@ -154,7 +232,7 @@ func TCPPing(ctx context.Context, ip string, knownPorts []int, timeout time.Dura
 			return 0
 		default:
 		}
-		addr := fmt.Sprintf("%s:%d", ip, port)
+		addr := net.JoinHostPort(ip, strconv.Itoa(port))
 		start := time.Now()
 		d := net.Dialer{Timeout: timeout}
 		conn, err := d.DialContext(ctx, "tcp", addr)
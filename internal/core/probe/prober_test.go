@@ -0,0 +1,67 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStrategyFor(t *testing.T) {
+	tests := []struct {
+		port int
+		want strategy
+	}{
+		{22, strategyBanner},
+		{80, strategyHTTP},
+		{8888, strategyHTTP},
+		{9090, strategyHTTP},
+		{443, strategyHTTPS},
+		{8443, strategyHTTPS},
+		{993, strategyTLS},
+		{40000, strategyBanner},
+	}
+	for _, tt := range tests {
+		if got := strategyFor(tt.port); got != tt.want {
+			t.Errorf("strategyFor(%d) = %d, want %d", tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestGrabTLSBanner(t *testing.T) {
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	defer srv.Close()
+	cert := srv.TLS.Certificates[0]
+
+	// a service greeting the client after the handshake
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = conn.Write([]byte("* OK IMAP4rev1 ready\r\n"))
+		time.Sleep(100 * time.Millisecond)
+	}()
+
+	ctx := context.Background()
+	port := ln.Addr().(*net.TCPAddr).Port
+	if got := GrabTLSBanner(ctx, "127.0.0.1", port, time.Second); got != "* OK IMAP4rev1 ready" {
+		t.Errorf("unexpected greeting %q", got)
+	}
+
+	// the HTTPS server waits for the request, the certificate names it
+	port = srv.Listener.Addr().(*net.TCPAddr).Port
+	if got := GrabTLSBanner(ctx, "127.0.0.1", port, 200*time.Millisecond); !strings.HasPrefix(got, "TLS") {
+		t.Errorf("expected the certificate name, got %q", got)
+	}
+}
@@ -0,0 +1,29 @@
+package probe
+
+import (
+	"context"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery/snmp"
+)
+
+// QuerySNMP reads the MIB-2 system group (sysName, sysDescr, ...) of the host at
+// the given IP, trying the params in order until one is answered. Returns false
+// when the host does not answer any of them.
+func QuerySNMP(ctx context.Context, ip string, params []snmp.Params) (discovery.SNMPSystem, bool) {
+	for _, p := range params {
+		if ctx.Err() != nil {
+			break
+		}
+		client, err := snmp.Dial(ctx, ip, p)
+		if err != nil {
+			continue
+		}
+		sys, err := client.System(ctx)
+		_ = client.Close()
+		if err == nil && !sys.IsZero() {
+			return sys, true
+		}
+	}
+	return discovery.SNMPSystem{}, false
+}
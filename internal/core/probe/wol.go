@@ -25,7 +25,7 @@ func SendWoL(macStr string, broadcastAddr string) error {
 		copy(packet[6+i*6:], mac)
 	}
 
-	addr := fmt.Sprintf("%s:%d", broadcastAddr, 9)
+	addr := net.JoinHostPort(broadcastAddr, "9")
 	conn, err := net.Dial("udp", addr)
 	if err != nil {
 		return fmt.Errorf("dial broadcast %s: %w", addr, err)
@@ -0,0 +1,16 @@
+package core
+
+// Built-in scanners register themselves with the discovery registry on import.
+// To add a scanner, implement discovery.Scanner in its own package, call
+// discovery.Register from init and add a blank import here.
+import (
+	_ "github.com/ramonvermeulen/whosthere/internal/core/discovery/arp"
+	_ "github.com/ramonvermeulen/whosthere/internal/core/discovery/dhcpleases"
+	_ "github.com/ramonvermeulen/whosthere/internal/core/discovery/icmp"
+	_ "github.com/ramonvermeulen/whosthere/internal/core/discovery/mdns"
+	_ "github.com/ramonvermeulen/whosthere/internal/core/discovery/netbios"
+	_ "github.com/ramonvermeulen/whosthere/internal/core/discovery/rdns"
+	_ "github.com/ramonvermeulen/whosthere/internal/core/discovery/snmp"
+	_ "github.com/ramonvermeulen/whosthere/internal/core/discovery/ssdp"
+	_ "github.com/ramonvermeulen/whosthere/internal/core/discovery/wsd"
+)
@@ -1,6 +1,7 @@
 package state
 
 import (
+	"slices"
 	"sort"
 	"sync"
 
@@ -14,7 +15,7 @@ import (
 type ReadOnly interface {
 	DevicesSnapshot() []discovery.Device
 	Selected() (discovery.Device, bool)
-	SelectedIP() string
+	SelectedID() string
 	CurrentTheme() string
 	PreviousTheme() string
 	Version() string
@@ -23,14 +24,17 @@ type ReadOnly interface {
 	IsPortscanning() bool
 	IsProbing() bool
 	Config() config.Config
-	GetDevice(ip string) (discovery.Device, bool)
+	GetDevice(id string) (discovery.Device, bool)
 	SearchActive() bool
 	SearchText() string
 	SearchError() bool
 	NoColor() bool
-	ActiveInterface() string
-	LocalIP() string
+	ActiveInterfaces() []string
+	InterfaceFilter() string
 	AvailableInterfaces() []discovery.InterfaceEntry
+	LastScanReport() (discovery.ScanReport, bool)
+	PortScanTargets() []string
+	PortScanProgress() []discovery.HostProgress
 }
 
 // AppState holds application-level state shared across views and
@@ -38,8 +42,9 @@ type ReadOnly interface {
 type AppState struct {
 	mu sync.RWMutex
 
-	devices             map[string]discovery.Device
-	selectedIP          string
+	devices             *discovery.DeviceIndex
+	mergePolicy         *discovery.MergePolicy
+	selectedID          string
 	previousTheme       string
 	version             string
 	filterPattern       string
@@ -50,18 +55,22 @@ type AppState struct {
 	searchError         bool
 	searchActive        bool
 	noColor             bool
-	activeInterface     string
-	localIP             string
+	activeInterfaces    []string
+	interfaceFilter     string
 	availableInterfaces []discovery.InterfaceEntry
+	lastScanReport      *discovery.ScanReport
+	portScanTargets     []string
+	portScanProgress    map[string]discovery.HostProgress
 }
 
 func NewAppState(cfg *config.Config, version string) *AppState {
 	s := &AppState{
-		devices: make(map[string]discovery.Device),
-		version: version,
-		cfg:     cfg,
-		noColor: theme.IsNoColor(),
+		mergePolicy: discovery.MergePolicyFromConfig(cfg),
+		version:     version,
+		cfg:         cfg,
+		noColor:     theme.IsNoColor(),
 	}
+	s.devices = discovery.NewDeviceIndex(s.mergePolicy)
 
 	themeName := config.DefaultThemeName
 	if cfg != nil && cfg.Theme.Name != "" {
@@ -72,25 +81,17 @@ func NewAppState(cfg *config.Config, version string) *AppState {
 	return s
 }
 
-// UpsertDevice merges a device into the canonical device map.
+// UpsertDevice merges a device into the canonical device index, correlating it with
+// known devices on MAC, hostname or IP address.
 func (s *AppState) UpsertDevice(d *discovery.Device) {
-	if d.IP == nil {
-		return
-	}
-	key := d.IP.String()
-	if key == "" {
+	if d == nil || d.IP == nil {
 		return
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if existing, ok := s.devices[key]; ok {
-		existing.Merge(d)
-		s.devices[key] = existing
-	} else {
-		s.devices[key] = *d
-	}
+	s.devices.Upsert(d)
 }
 
 // DevicesSnapshot returns a copy of all devices for rendering.
@@ -98,19 +99,19 @@ func (s *AppState) DevicesSnapshot() []discovery.Device {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	out := make([]discovery.Device, 0, len(s.devices))
-	for _, d := range s.devices {
-		out = append(out, d)
+	out := make([]discovery.Device, 0, s.devices.Len())
+	for _, d := range s.devices.Devices() {
+		out = append(out, *d)
 	}
 	sort.Slice(out, func(i, j int) bool { return out[i].IP.String() < out[j].IP.String() })
 	return out
 }
 
-// SetSelectedIP stores the currently selected device IP.
-func (s *AppState) SetSelectedIP(ip string) {
+// SetSelectedID stores the currently selected device ID (or MAC/IP address).
+func (s *AppState) SetSelectedID(id string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.selectedIP = ip
+	s.selectedID = id
 }
 
 // Selected returns the currently selected device, if any.
@@ -118,18 +119,21 @@ func (s *AppState) Selected() (discovery.Device, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if s.selectedIP == "" {
+	if s.selectedID == "" {
 		return discovery.Device{}, false
 	}
-	d, ok := s.devices[s.selectedIP]
-	return d, ok
+	d, ok := s.devices.Lookup(s.selectedID)
+	if !ok {
+		return discovery.Device{}, false
+	}
+	return *d, true
 }
 
-// SelectedIP returns the currently selected device IP, if any.
-func (s *AppState) SelectedIP() string {
+// SelectedID returns the currently selected device ID, if any.
+func (s *AppState) SelectedID() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.selectedIP
+	return s.selectedID
 }
 
 // SetCurrentTheme sets the current theme.
@@ -242,13 +246,16 @@ func (s *AppState) ReadOnly() ReadOnly {
 	return s
 }
 
-// GetDevice retrieves a device by IP address.
-func (s *AppState) GetDevice(ip string) (discovery.Device, bool) {
+// GetDevice retrieves a device by ID, MAC address, IP address or hostname.
+func (s *AppState) GetDevice(id string) (discovery.Device, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	device, ok := s.devices[ip]
-	return device, ok
+	device, ok := s.devices.Lookup(id)
+	if !ok {
+		return discovery.Device{}, false
+	}
+	return *device, true
 }
 
 // SearchActive returns the search active state.
@@ -293,32 +300,36 @@ func (s *AppState) NoColor() bool {
 	return s.noColor
 }
 
-// SetActiveInterface sets the active network interface name.
-func (s *AppState) SetActiveInterface(name string) {
+// SetActiveInterfaces sets the names of the network interfaces being scanned. The
+// interface filter is reset when its interface is no longer scanned.
+func (s *AppState) SetActiveInterfaces(names []string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.activeInterface = name
+	s.activeInterfaces = append([]string(nil), names...)
+	if !slices.Contains(s.activeInterfaces, s.interfaceFilter) {
+		s.interfaceFilter = ""
+	}
 }
 
-// ActiveInterface returns the active network interface name.
-func (s *AppState) ActiveInterface() string {
+// ActiveInterfaces returns the names of the network interfaces being scanned.
+func (s *AppState) ActiveInterfaces() []string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.activeInterface
+	return append([]string(nil), s.activeInterfaces...)
 }
 
-// SetLocalIP stores the local IP address of the active interface.
-func (s *AppState) SetLocalIP(ip string) {
+// SetInterfaceFilter limits the device list to devices seen on the named interface, "" shows all.
+func (s *AppState) SetInterfaceFilter(name string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.localIP = ip
+	s.interfaceFilter = name
 }
 
-// LocalIP returns the local IP address of the active interface.
-func (s *AppState) LocalIP() string {
+// InterfaceFilter returns the interface the device list is limited to, "" for all.
+func (s *AppState) InterfaceFilter() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.localIP
+	return s.interfaceFilter
 }
 
 // SetAvailableInterfaces stores the list of available network interfaces.
@@ -335,9 +346,80 @@ func (s *AppState) AvailableInterfaces() []discovery.InterfaceEntry {
 	return s.availableInterfaces
 }
 
-// ClearDevices removes all discovered devices (used when switching interfaces).
+// RetainInterfaces removes the devices seen on interfaces that are not in names, used
+// when interfaces stop being scanned. Devices without an interface are kept.
+func (s *AppState) RetainInterfaces(names []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.devices.RemoveWhere(func(d *discovery.Device) bool {
+		return d.Interface != "" && !slices.Contains(names, d.Interface)
+	})
+}
+
+// ClearDevices removes all discovered devices.
 func (s *AppState) ClearDevices() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.devices = make(map[string]discovery.Device)
+	s.devices = discovery.NewDeviceIndex(s.mergePolicy)
+}
+
+// SetLastScanReport stores the report of the most recent discovery scan.
+func (s *AppState) SetLastScanReport(r discovery.ScanReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastScanReport = &r
+}
+
+// LastScanReport returns the report of the most recent discovery scan, if any.
+func (s *AppState) LastScanReport() (discovery.ScanReport, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.lastScanReport == nil {
+		return discovery.ScanReport{}, false
+	}
+	return *s.lastScanReport, true
+}
+
+// SetPortScanTargets sets the IDs of the devices the next port scan covers, the
+// selected device when empty.
+func (s *AppState) SetPortScanTargets(ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.portScanTargets = slices.Clone(ids)
+}
+
+// PortScanTargets returns the IDs of the devices the next port scan covers.
+func (s *AppState) PortScanTargets() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.portScanTargets)
+}
+
+// SetPortScanProgress records the progress of a host of the running port scan.
+func (s *AppState) SetPortScanProgress(p discovery.HostProgress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.portScanProgress == nil {
+		s.portScanProgress = map[string]discovery.HostProgress{}
+	}
+	s.portScanProgress[p.IP] = p
+}
+
+// ClearPortScanProgress forgets the progress of the previous port scan.
+func (s *AppState) ClearPortScanProgress() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.portScanProgress = nil
+}
+
+// PortScanProgress returns the progress of every host of the last port scan, sorted by IP.
+func (s *AppState) PortScanProgress() []discovery.HostProgress {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]discovery.HostProgress, 0, len(s.portScanProgress))
+	for _, p := range s.portScanProgress {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].IP < out[j].IP })
+	return out
 }
@@ -66,7 +66,7 @@ func TestSelected(t *testing.T) {
 	device := discovery.Device{IP: ip}
 	state.UpsertDevice(&device)
 
-	state.SetSelectedIP("192.168.1.1")
+	state.SetSelectedID("192.168.1.1")
 	selected, ok := state.Selected()
 	if !ok {
 		t.Errorf("expected selected device")
@@ -75,7 +75,7 @@ func TestSelected(t *testing.T) {
 		t.Errorf("expected selected IP 192.168.1.1, got %s", selected.IP.String())
 	}
 
-	state.SetSelectedIP("192.168.1.2")
+	state.SetSelectedID("192.168.1.2")
 	_, ok = state.Selected()
 	if ok {
 		t.Errorf("expected no selected device")
@@ -127,6 +127,35 @@ func TestIsPortscanning(t *testing.T) {
 	}
 }
 
+func TestPortScanProgress(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+
+	state.SetPortScanTargets([]string{"a", "b"})
+	if got := state.PortScanTargets(); len(got) != 2 {
+		t.Errorf("expected 2 targets, got %v", got)
+	}
+
+	state.SetPortScanProgress(discovery.HostProgress{IP: "10.0.0.2", Done: 1, Total: 4})
+	state.SetPortScanProgress(discovery.HostProgress{IP: "10.0.0.1", Done: 2, Total: 4})
+	state.SetPortScanProgress(discovery.HostProgress{IP: "10.0.0.2", Done: 4, Total: 4, Open: 1})
+
+	progress := state.PortScanProgress()
+	if len(progress) != 2 {
+		t.Fatalf("expected 2 hosts, got %d", len(progress))
+	}
+	if progress[0].IP != "10.0.0.1" || progress[1].IP != "10.0.0.2" {
+		t.Errorf("expected hosts sorted by IP, got %v", progress)
+	}
+	if !progress[1].Finished() || progress[1].Open != 1 {
+		t.Errorf("expected latest progress of 10.0.0.2, got %+v", progress[1])
+	}
+
+	state.ClearPortScanProgress()
+	if len(state.PortScanProgress()) != 0 {
+		t.Errorf("expected no progress after clear")
+	}
+}
+
 func TestGetDevice(t *testing.T) {
 	state := NewAppState(config.DefaultConfig(), "1.0.0")
 
@@ -143,6 +172,37 @@ func TestGetDevice(t *testing.T) {
 	}
 }
 
+func TestSnapshotIsNotChangedByLaterMerges(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+
+	ip := net.ParseIP("192.168.1.1")
+	state.UpsertDevice(&discovery.Device{
+		IP:          ip,
+		DisplayName: "printer",
+		Sources:     map[string]struct{}{"arp": {}},
+		ExtraData:   map[string]string{"a": "1"},
+		Banners:     map[int]string{22: "SSH-2.0"},
+	})
+	// a second merge attributes the display name to arp
+	state.UpsertDevice(&discovery.Device{IP: ip, Sources: map[string]struct{}{"arp": {}}})
+	snap, _ := state.GetDevice("192.168.1.1")
+
+	state.UpsertDevice(&discovery.Device{
+		IP:          ip,
+		DisplayName: "office printer",
+		Sources:     map[string]struct{}{"mdns": {}},
+		ExtraData:   map[string]string{"b": "2"},
+		Banners:     map[int]string{80: "nginx"},
+	})
+
+	if len(snap.Sources) != 1 || len(snap.ExtraData) != 1 || len(snap.Banners) != 1 {
+		t.Errorf("snapshot changed: sources %v, extra %v, banners %v", snap.Sources, snap.ExtraData, snap.Banners)
+	}
+	if src := snap.FieldSources[discovery.FieldDisplayName]; src != "arp" {
+		t.Errorf("expected snapshot field source arp, got %q", src)
+	}
+}
+
 func TestSearch(t *testing.T) {
 	state := NewAppState(config.DefaultConfig(), "1.0.0")
 
@@ -161,3 +221,43 @@ func TestSearch(t *testing.T) {
 		t.Errorf("expected search text search, got %s", state.SearchText())
 	}
 }
+
+func TestGetDeviceByMACAndIP(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+
+	state.UpsertDevice(&discovery.Device{IP: net.ParseIP("192.168.1.10"), MAC: "AA:BB:CC:DD:EE:FF"})
+	state.UpsertDevice(&discovery.Device{IP: net.ParseIP("192.168.1.11"), MAC: "aa:bb:cc:dd:ee:ff"})
+
+	if n := len(state.DevicesSnapshot()); n != 1 {
+		t.Fatalf("expected devices with the same MAC to be merged, got %d", n)
+	}
+	for _, id := range []string{"aa:bb:cc:dd:ee:ff", "192.168.1.10", "192.168.1.11"} {
+		if _, ok := state.GetDevice(id); !ok {
+			t.Errorf("expected device for %q", id)
+		}
+	}
+}
+
+func TestRetainInterfaces(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+	for ip, iface := range map[string]string{"192.168.1.10": "eth0", "10.20.0.10": "eth0.20", "192.168.1.11": ""} {
+		d := discovery.NewDevice(net.ParseIP(ip))
+		d.Interface = iface
+		state.UpsertDevice(&d)
+	}
+	state.SetActiveInterfaces([]string{"eth0", "eth0.20"})
+	state.SetInterfaceFilter("eth0.20")
+
+	state.SetActiveInterfaces([]string{"eth0"})
+	state.RetainInterfaces([]string{"eth0"})
+
+	if got := len(state.DevicesSnapshot()); got != 2 {
+		t.Errorf("expected 2 devices, got %d", got)
+	}
+	if _, ok := state.GetDevice("10.20.0.10"); ok {
+		t.Errorf("expected device of the removed interface to be dropped")
+	}
+	if state.InterfaceFilter() != "" {
+		t.Errorf("expected filter of the removed interface to be reset, got %q", state.InterfaceFilter())
+	}
+}
@@ -2,7 +2,9 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/ramonvermeulen/whosthere/internal/core"
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
 	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery/snmp"
 	"github.com/ramonvermeulen/whosthere/internal/core/oui"
 	"github.com/ramonvermeulen/whosthere/internal/core/probe"
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
@@ -37,17 +40,21 @@ type App struct {
 	cfg           *config.Config
 	events        chan events.Event
 	emit          func(events.Event)
-	portScanner   *discovery.PortScanner
 	isReady       bool
 	clipboard     *clipboard.Clipboard
 	ouiDB         *oui.Registry
 	scanCancel    context.CancelFunc
 	scanMu        sync.Mutex
 	prober        *probe.Prober
-	iface         *discovery.InterfaceInfo
+	ifaces        []*discovery.InterfaceInfo
+
+	// portScanCancel stops the running port scan, nil when none runs
+	portScanCancel context.CancelFunc
+	portScanMu     sync.Mutex
 }
 
-func NewApp(cfg *config.Config, ouiDB *oui.Registry, version string) (*App, error) {
+// NewApp creates the TUI scanning ifaces, the interfaces from cfg when ifaces is empty.
+func NewApp(cfg *config.Config, ouiDB *oui.Registry, ifaces []*discovery.InterfaceInfo, version string) (*App, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("config cannot be nil")
 	}
@@ -70,26 +77,17 @@ func NewApp(cfg *config.Config, ouiDB *oui.Registry, version string) (*App, erro
 
 	a.applyTheme(appState.CurrentTheme())
 	a.setupPages(cfg)
-	err := a.setupEngine(cfg, ouiDB)
+	// Store ouiDB for engine rebuilds when switching interfaces
+	a.ouiDB = ouiDB
+
+	err := a.setupEngine(cfg, ifaces)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup engine: %w", err)
 	}
 
-	// Store ouiDB for engine rebuilds when switching interfaces
-	a.ouiDB = ouiDB
-
-	// Populate available interfaces and track the active one
+	// Populate available interfaces and track the scanned ones
 	a.state.SetAvailableInterfaces(discovery.ListAllInterfaces())
-	if a.engine != nil && len(a.engine.Scanners) > 0 {
-		// Determine active interface from the engine's first scanner's interface info
-		iface, ifErr := discovery.NewInterfaceInfo(cfg.NetworkInterface)
-		if ifErr == nil {
-			a.state.SetActiveInterface(iface.Interface.Name)
-			if iface.IPv4Addr != nil {
-				a.state.SetLocalIP(iface.IPv4Addr.String())
-			}
-		}
-	}
+	a.state.SetActiveInterfaces(a.interfaceNames())
 
 	app.SetRoot(a.pages, true)
 	app.SetInputCapture(a.handleGlobalKeys)
@@ -122,7 +120,11 @@ func (a *App) Run() error {
 		a.startDiscoveryScanLoop()
 	}
 
-	return a.Application.Run()
+	err := a.Application.Run()
+	if a.engine != nil {
+		_ = a.engine.Close()
+	}
+	return err
 }
 
 func (a *App) setupPages(cfg *config.Config) {
@@ -148,20 +150,48 @@ func (a *App) setupPages(cfg *config.Config) {
 }
 
 // methods like this can be re-used for other commands
-func (a *App) setupEngine(cfg *config.Config, ouiDB *oui.Registry) error {
-	iface, err := discovery.NewInterfaceInfo(cfg.NetworkInterface)
+func (a *App) setupEngine(cfg *config.Config, ifaces []*discovery.InterfaceInfo) error {
+	if len(ifaces) == 0 {
+		var err error
+		if ifaces, err = discovery.NewInterfaceInfos(cfg.Interfaces()); err != nil {
+			return fmt.Errorf("failed to get network interface: %w", err)
+		}
+	}
+
+	engine, err := core.BuildEngine(ifaces, a.ouiDB, cfg.Scanners, core.GetEnabledFromCfg(cfg), cfg.ScanDuration, discovery.WithMergePolicy(discovery.MergePolicyFromConfig(cfg)))
 	if err != nil {
-		return fmt.Errorf("failed to get network interface: %w", err)
+		return fmt.Errorf("failed to build engine: %w", err)
 	}
 
-	a.portScanner = discovery.NewPortScanner(100, iface)
+	if a.engine != nil {
+		// stop scanners that run across scans of the previous engine, e.g. the mDNS listener
+		if err := a.engine.Close(); err != nil {
+			zap.L().Warn("failed to close previous engine", zap.Error(err))
+		}
+	}
+	a.engine = engine
+	a.ifaces = ifaces
+	var snmpParams []snmp.Params
+	if cfg.SNMP.Enabled {
+		snmpParams = snmp.ParamsFromConfig(cfg.SNMP.SNMPCredentials)
+	}
+	a.prober = probe.New(5*time.Second, probe.WithSNMP(snmpParams))
 
-	a.engine = core.BuildEngine(iface, ouiDB, core.GetEnabledFromCfg(cfg), cfg.ScanDuration)
+	return nil
+}
 
-	a.iface = iface
-	a.prober = probe.New(5 * time.Second)
+// interfaceNames returns the names of the scanned interfaces.
+func (a *App) interfaceNames() []string {
+	names := make([]string, 0, len(a.ifaces))
+	for _, iface := range a.ifaces {
+		names = append(names, iface.Interface.Name)
+	}
+	return names
+}
 
-	return nil
+// interfaceFor returns the scanned interface a device was seen on, the first one when unknown.
+func (a *App) interfaceFor(d *discovery.Device) *discovery.InterfaceInfo {
+	return core.InterfaceFor(a.ifaces, d)
 }
 
 func (a *App) handleGlobalKeys(event *tcell.EventKey) *tcell.EventKey {
@@ -233,10 +263,14 @@ func (a *App) performScan() {
 	a.scanCancel = cancel
 	a.scanMu.Unlock()
 
-	_, _ = a.engine.Stream(ctx, func(d *discovery.Device) {
+	_, report, err := a.engine.Stream(ctx, func(d *discovery.Device) {
 		a.state.UpsertDevice(d)
 	})
 	cancel()
+	if err != nil {
+		zap.L().Warn("discovery scan failed", zap.Error(err))
+	}
+	a.state.SetLastScanReport(report)
 
 	// Inject local device (self) into the device list
 	a.injectLocalDevice()
@@ -294,7 +328,7 @@ func (a *App) handleEvents() {
 		zap.L().Debug("Handling event: ", zap.Any("event", e))
 		switch event := e.(type) {
 		case events.DeviceSelected:
-			a.state.SetSelectedIP(event.IP)
+			a.state.SetSelectedID(event.ID)
 		case events.FilterChanged:
 			a.state.SetFilterPattern(event.Pattern)
 		case events.NavigateTo:
@@ -320,10 +354,17 @@ func (a *App) handleEvents() {
 			a.state.SetIsDiscovering(true)
 		case events.DiscoveryStopped:
 			a.state.SetIsDiscovering(false)
+		case events.PortScanRequested:
+			a.state.SetPortScanTargets(event.IDs)
+			a.emit(events.NavigateTo{Route: routes.RoutePortScan, Overlay: true})
 		case events.PortScanStarted:
-			a.state.SetIsPortscanning(true)
 			a.emit(events.HideView{})
-			go a.startPortscan()
+			if !a.state.IsPortscanning() {
+				a.state.SetIsPortscanning(true)
+				go a.startPortscan(event.TCP, event.Timing)
+			}
+		case events.PortScanCancelled:
+			a.cancelPortscan()
 		case events.PortScanStopped:
 			a.state.SetIsPortscanning(false)
 		case events.SearchStarted:
@@ -348,7 +389,11 @@ func (a *App) handleEvents() {
 				}
 			}
 		case events.InterfaceSelected:
-			go a.switchInterface(event.Name)
+			go a.switchInterfaces([]string{event.Name})
+		case events.InterfaceToggled:
+			go a.toggleInterface(event.Name)
+		case events.InterfaceFilterChanged:
+			a.state.SetInterfaceFilter(event.Name)
 		case events.ProbeStarted:
 			a.state.SetIsProbing(true)
 			go a.startProbe()
@@ -361,41 +406,87 @@ func (a *App) handleEvents() {
 	}
 }
 
-func (a *App) startPortscan() {
-	device, ok := a.state.Selected()
-	if !ok {
+// startPortscan scans the tcp ports, the configured ports when empty, and the configured
+// UDP ports of the port scan targets, or of the selected device when there are none, with
+// the timing profile, the configured one when empty. All devices share the worker pool and
+// rate limit of the timing.
+func (a *App) startPortscan(tcp []int, timing config.TimingProfile) {
+	devices := a.portScanDevices()
+	if len(devices) == 0 {
 		a.emit(events.PortScanStopped{})
 		return
 	}
-	ip := device.IP.String()
-	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ScanDuration)
-	defer cancel()
 
-	device.OpenPorts = map[string][]int{}
-	device.LastPortScan = time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	a.portScanMu.Lock()
+	a.portScanCancel = cancel
+	a.portScanMu.Unlock()
+	defer func() {
+		a.portScanMu.Lock()
+		a.portScanCancel = nil
+		a.portScanMu.Unlock()
+		cancel()
+	}()
 
-	// todo(ramon) handle errors properly
-	var mu sync.Mutex
-	_ = a.portScanner.Stream(ctx, ip, a.cfg.PortScanner.TCP, a.cfg.PortScanner.Timeout, func(port int) {
-		mu.Lock()
-		defer mu.Unlock()
-		device.OpenPorts["tcp"] = append(device.OpenPorts["tcp"], port)
-		a.state.UpsertDevice(&device)
-	})
+	cfg := a.cfg.PortScanner
+	if timing != "" {
+		cfg.Timing = timing
+	}
+	a.state.ClearPortScanProgress()
+	err := core.PortScanDevices(ctx, devices, a.ifaces, cfg, tcp, a.state.UpsertDevice, a.state.SetPortScanProgress)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		zap.L().Warn("port scan failed", zap.Error(err))
+	}
 
 	a.emit(events.PortScanStopped{})
 }
 
-// switchInterface rebuilds the discovery engine for a new network interface,
-// cancels any in-progress scan, clears discovered devices, and restarts scanning.
-func (a *App) switchInterface(ifaceName string) {
-	currentActive := a.state.ActiveInterface()
-	if ifaceName == currentActive {
-		zap.L().Debug("interface unchanged, skipping switch", zap.String("interface", ifaceName))
+// portScanDevices returns the devices of the port scan targets, the selected device when
+// there are none.
+func (a *App) portScanDevices() []discovery.Device {
+	ids := a.state.PortScanTargets()
+	if len(ids) == 0 {
+		if device, ok := a.state.Selected(); ok {
+			return []discovery.Device{device}
+		}
+		return nil
+	}
+	devices := make([]discovery.Device, 0, len(ids))
+	for _, id := range ids {
+		if device, ok := a.state.GetDevice(id); ok {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// cancelPortscan stops the running port scan, the ports found so far are kept.
+func (a *App) cancelPortscan() {
+	a.portScanMu.Lock()
+	defer a.portScanMu.Unlock()
+	if a.portScanCancel != nil {
+		a.portScanCancel()
+	}
+}
+
+// switchInterfaces rebuilds the discovery engine for a new set of network interfaces,
+// cancels any in-progress scan, drops devices seen on interfaces that are no longer
+// scanned, and restarts scanning.
+func (a *App) switchInterfaces(names []string) {
+	current := a.state.ActiveInterfaces()
+	if slices.Equal(names, current) {
+		zap.L().Debug("interfaces unchanged, skipping switch", zap.Strings("interfaces", names))
 		return
 	}
 
-	zap.L().Info("switching network interface", zap.String("from", currentActive), zap.String("to", ifaceName))
+	zap.L().Info("switching network interfaces", zap.Strings("from", current), zap.Strings("to", names))
+
+	// Build new interface info
+	ifaces, err := discovery.NewInterfaceInfos(names)
+	if err != nil {
+		zap.L().Error("failed to switch interfaces", zap.Strings("interfaces", names), zap.Error(err))
+		return
+	}
 
 	// Cancel any in-progress scan
 	a.scanMu.Lock()
@@ -409,29 +500,36 @@ func (a *App) switchInterface(ifaceName string) {
 		a.scanTicker.Stop()
 	}
 
-	// Build new interface info
-	iface, err := discovery.NewInterfaceInfo(ifaceName)
-	if err != nil {
-		zap.L().Error("failed to switch interface", zap.String("interface", ifaceName), zap.Error(err))
+	// Rebuild engine
+	if err := a.setupEngine(a.cfg, ifaces); err != nil {
+		zap.L().Error("failed to rebuild engine", zap.Strings("interfaces", names), zap.Error(err))
+		a.startDiscoveryScanLoop()
 		return
 	}
 
-	// Rebuild engine
-	a.engine = core.BuildEngine(iface, a.ouiDB, core.GetEnabledFromCfg(a.cfg), a.cfg.ScanDuration)
-	a.portScanner = discovery.NewPortScanner(100, iface)
-	a.iface = iface
-
 	// Update state
-	a.state.SetActiveInterface(ifaceName)
-	if iface.IPv4Addr != nil {
-		a.state.SetLocalIP(iface.IPv4Addr.String())
-	}
-	a.state.ClearDevices()
+	a.state.SetActiveInterfaces(names)
+	a.state.RetainInterfaces(names)
 
 	// Restart the scan loop
 	a.startDiscoveryScanLoop()
 }
 
+// toggleInterface adds the named interface to the scanned interfaces, or removes it when
+// it is already scanned. The last interface cannot be removed.
+func (a *App) toggleInterface(name string) {
+	names := a.state.ActiveInterfaces()
+	if i := slices.Index(names, name); i >= 0 {
+		if len(names) == 1 {
+			return
+		}
+		names = slices.Delete(names, i, i+1)
+	} else {
+		names = append(names, name)
+	}
+	a.switchInterfaces(names)
+}
+
 // startProbe runs all network probes on the currently selected device.
 func (a *App) startProbe() {
 	device, ok := a.state.Selected()
@@ -441,18 +539,17 @@ func (a *App) startProbe() {
 	}
 
 	ip := device.IP.String()
-	var openPorts []int
-	if tcpPorts, ok := device.OpenPorts["tcp"]; ok {
-		openPorts = tcpPorts
-	}
+	openPorts := device.OpenPorts.Numbers(discovery.ProtocolTCP)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result := a.prober.RunAll(ctx, ip, device.MAC, device.Manufacturer, openPorts, device.ExtraData)
+	result := a.prober.RunAll(ctx, ip, device.MAC, device.Manufacturer, openPorts, device.FingerprintData())
 
 	// Apply results to device
-	device.Latency = result.Latency
+	if result.Latency > 0 {
+		device.Latency = result.Latency
+	}
 	device.ReverseDNS = result.ReverseDNS
 	device.Banners = result.Banners
 	device.HTTPTitle = result.HTTPTitle
@@ -460,12 +557,17 @@ func (a *App) startProbe() {
 	device.DeviceType = result.DeviceType
 	device.OS = result.OS
 	device.NetBIOSName = result.NetBIOSName
+	if !result.SNMP.IsZero() {
+		device.SNMP = result.SNMP
+	}
 	device.LastProbe = time.Now()
 
 	// Enrich display name from probe results
 	if device.DisplayName == "" {
 		if result.NetBIOSName != "" {
 			device.DisplayName = result.NetBIOSName
+		} else if result.SNMP.Name != "" {
+			device.DisplayName = result.SNMP.Name
 		} else if result.ReverseDNS != "" {
 			device.DisplayName = result.ReverseDNS
 		}
@@ -485,12 +587,13 @@ func (a *App) sendWoL() {
 		zap.L().Warn("cannot send WoL: device has no MAC address")
 		return
 	}
-	if a.iface == nil || a.iface.IPv4Net == nil {
+	iface := a.interfaceFor(&device)
+	if iface == nil || iface.IPv4Net == nil {
 		zap.L().Warn("cannot send WoL: no network interface info")
 		return
 	}
 
-	broadcast := probe.BroadcastAddr(a.iface.IPv4Net)
+	broadcast := probe.BroadcastAddr(iface.IPv4Net)
 	if broadcast == nil {
 		zap.L().Warn("cannot compute broadcast address")
 		return
@@ -503,20 +606,30 @@ func (a *App) sendWoL() {
 	}
 }
 
-// injectLocalDevice adds the local machine as a device in the discovered list.
+// injectLocalDevice adds the local machine as a device in the discovered list, once
+// per scanned interface.
 func (a *App) injectLocalDevice() {
-	if a.iface == nil || a.iface.IPv4Addr == nil {
-		return
-	}
+	for _, iface := range a.ifaces {
+		if iface.PrimaryIP() == nil {
+			continue
+		}
 
-	localDev := discovery.NewDevice(*a.iface.IPv4Addr)
-	localDev.MAC = a.iface.Interface.HardwareAddr.String()
-	localDev.DisplayName = "(this device)"
-	localDev.Sources["local"] = struct{}{}
+		localDev := discovery.NewDevice(iface.PrimaryIP())
+		for _, ip := range iface.Addresses() {
+			if !ip.Equal(localDev.IP) {
+				localDev.Addresses = append(localDev.Addresses, discovery.AddressRecord{IP: ip, FirstSeen: localDev.FirstSeen, LastSeen: localDev.LastSeen, Current: true})
+			}
+		}
+		localDev.MAC = iface.Interface.HardwareAddr.String()
+		localDev.Interface = iface.Interface.Name
+		localDev.Subnet = iface.SubnetOf(localDev.IP)
+		localDev.DisplayName = "(this device)"
+		localDev.Sources["local"] = struct{}{}
+
+		if a.ouiDB != nil {
+			localDev.Manufacturer, _ = a.ouiDB.Lookup(localDev.MAC)
+		}
 
-	if a.ouiDB != nil {
-		localDev.Manufacturer, _ = a.ouiDB.Lookup(localDev.MAC)
+		a.state.UpsertDevice(&localDev)
 	}
-
-	a.state.UpsertDevice(&localDev)
 }
@@ -3,6 +3,7 @@ package components
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -24,6 +25,9 @@ type DeviceTable struct {
 	filterRE    *regexp.Regexp
 	searching   bool
 	searchInput string
+	interfaces  []string                          // scanned interfaces, cycled through by the interface filter
+	ifaceFilter string                            // only show devices seen on this interface, "" for all
+	portScans   map[string]discovery.HostProgress // IP -> progress of the last port scan
 
 	emit func(events.Event)
 }
@@ -74,6 +78,17 @@ func (dt *DeviceTable) handleNormalKey(ev *tcell.EventKey) *tcell.EventKey {
 	case ev.Rune() == 'G':
 		dt.SelectLast()
 		return nil
+	case ev.Rune() == 'i':
+		dt.cycleInterfaceFilter()
+		return nil
+	case ev.Rune() == 'P':
+		if ids := dt.VisibleIDs(); len(ids) > 0 {
+			dt.emit(events.PortScanRequested{IDs: ids})
+		}
+		return nil
+	case ev.Rune() == 'x':
+		dt.emit(events.PortScanCancelled{})
+		return nil
 	case ev.Rune() == 'y':
 		ip := dt.SelectedIP()
 		if ip != "" {
@@ -153,9 +168,32 @@ func (dt *DeviceTable) applySearch(pattern string) {
 	dt.emit(events.SearchError{Error: false})
 }
 
+// cycleInterfaceFilter moves the interface filter to the next scanned interface, after
+// the last one it shows all devices again.
+func (dt *DeviceTable) cycleInterfaceFilter() {
+	next := ""
+	if len(dt.interfaces) > 1 {
+		i := slices.Index(dt.interfaces, dt.ifaceFilter)
+		if i+1 < len(dt.interfaces) {
+			next = dt.interfaces[i+1]
+		}
+	}
+	dt.ifaceFilter = next
+	if dt.emit != nil {
+		dt.emit(events.InterfaceFilterChanged{Name: next})
+	}
+	dt.refresh()
+}
+
 // Render updates the table with the latest devices from state.
 func (dt *DeviceTable) Render(st state.ReadOnly) {
 	dt.devices = st.DevicesSnapshot()
+	dt.interfaces = st.ActiveInterfaces()
+	dt.ifaceFilter = st.InterfaceFilter()
+	dt.portScans = map[string]discovery.HostProgress{}
+	for _, p := range st.PortScanProgress() {
+		dt.portScans[p.IP] = p
+	}
 	_ = dt.SetFilter(st.FilterPattern())
 }
 
@@ -172,6 +210,30 @@ func (dt *DeviceTable) SelectedIP() string {
 	return cell.Text
 }
 
+// SelectedID returns the device ID for the currently selected row, if any.
+func (dt *DeviceTable) SelectedID() string {
+	row, _ := dt.GetSelection()
+	if row <= 0 {
+		return ""
+	}
+	cell := dt.GetCell(row, 0)
+	if cell == nil {
+		return ""
+	}
+	id, _ := cell.GetReference().(string)
+	return id
+}
+
+// VisibleIDs returns the IDs of the devices that pass the interface and search filters.
+func (dt *DeviceTable) VisibleIDs() []string {
+	rows := dt.buildRows()
+	ids := make([]string, len(rows))
+	for i, r := range rows {
+		ids[i] = r.id
+	}
+	return ids
+}
+
 // SelectFirst selects the first data row below the header, if any.
 func (dt *DeviceTable) SelectFirst() {
 	if dt.GetRowCount() > 1 {
@@ -188,20 +250,31 @@ func (dt *DeviceTable) SelectLast() {
 }
 
 type tableRow struct {
-	ip, hostname, mac, manufacturer, os, lastSeen string
+	id, ip, iface, hostname, mac, manufacturer, os, lastSeen, portScan string
 }
 
 func (dt *DeviceTable) buildRows() []tableRow {
 	rows := make([]tableRow, 0, len(dt.devices))
 	for _, d := range dt.devices {
 		row := tableRow{
+			id:           d.ID,
 			ip:           d.IP.String(),
+			iface:        d.Interface,
 			hostname:     d.DisplayName,
 			mac:          d.MAC,
 			manufacturer: d.Manufacturer,
 			os:           d.OS,
 			lastSeen:     utils.FmtDuration(time.Since(d.LastSeen)),
 		}
+		if d.Departed() {
+			row.lastSeen = "departed"
+		}
+		if p, ok := dt.portScans[row.ip]; ok {
+			row.portScan = portScanText(p)
+		}
+		if dt.ifaceFilter != "" && row.iface != dt.ifaceFilter {
+			continue
+		}
 		if dt.filterRE != nil && !dt.rowMatches(&row) {
 			continue
 		}
@@ -211,11 +284,14 @@ func (dt *DeviceTable) buildRows() []tableRow {
 }
 
 func (dt *DeviceTable) refresh() {
-	selectedIP := dt.SelectedIP()
+	selectedID := dt.SelectedID()
 	dt.Clear()
 	const maxColWidth = 30
 
-	headers := []string{"IP", "Display Name", "MAC", "Manufacturer", "OS", "Last Seen"}
+	headers := []string{"IP", "Interface", "Display Name", "MAC", "Manufacturer", "OS", "Last Seen"}
+	if len(dt.portScans) > 0 {
+		headers = append(headers, "Port Scan")
+	}
 
 	for i, h := range headers {
 		text := utils.Truncate(h, maxColWidth)
@@ -228,6 +304,9 @@ func (dt *DeviceTable) refresh() {
 	rows := dt.buildRows()
 
 	title := fmt.Sprintf(" Devices (%v) ", len(rows))
+	if dt.ifaceFilter != "" {
+		title += fmt.Sprintf(" [%s]@%s[-] ", utils.ColorToHexTag(tview.Styles.SecondaryTextColor), dt.ifaceFilter)
+	}
 	if dt.filterRE != nil {
 		title += fmt.Sprintf(" [%s]<%s>[-] ", utils.ColorToHexTag(tview.Styles.SecondaryTextColor), dt.filterRE.String())
 	}
@@ -237,24 +316,29 @@ func (dt *DeviceTable) refresh() {
 		r := rowIndex + 1
 
 		ipText := utils.Truncate(rowData.ip, maxColWidth)
+		ifaceText := utils.Truncate(rowData.iface, maxColWidth)
 		hostText := utils.Truncate(rowData.hostname, maxColWidth)
 		macText := utils.Truncate(rowData.mac, maxColWidth)
 		manuText := utils.Truncate(rowData.manufacturer, maxColWidth)
 		osText := utils.Truncate(rowData.os, maxColWidth)
 		seenText := utils.Truncate(rowData.lastSeen, maxColWidth)
 
-		dt.SetCell(r, 0, tview.NewTableCell(ipText).SetExpansion(1))
-		dt.SetCell(r, 1, tview.NewTableCell(hostText).SetExpansion(1))
-		dt.SetCell(r, 2, tview.NewTableCell(macText).SetExpansion(1))
-		dt.SetCell(r, 3, tview.NewTableCell(manuText).SetExpansion(1))
-		dt.SetCell(r, 4, tview.NewTableCell(osText).SetExpansion(1))
-		dt.SetCell(r, 5, tview.NewTableCell(seenText).SetExpansion(1))
+		dt.SetCell(r, 0, tview.NewTableCell(ipText).SetReference(rowData.id).SetExpansion(1))
+		dt.SetCell(r, 1, tview.NewTableCell(ifaceText).SetExpansion(1))
+		dt.SetCell(r, 2, tview.NewTableCell(hostText).SetExpansion(1))
+		dt.SetCell(r, 3, tview.NewTableCell(macText).SetExpansion(1))
+		dt.SetCell(r, 4, tview.NewTableCell(manuText).SetExpansion(1))
+		dt.SetCell(r, 5, tview.NewTableCell(osText).SetExpansion(1))
+		dt.SetCell(r, 6, tview.NewTableCell(seenText).SetExpansion(1))
+		if len(dt.portScans) > 0 {
+			dt.SetCell(r, 7, tview.NewTableCell(utils.Truncate(rowData.portScan, maxColWidth)).SetExpansion(1))
+		}
 	}
 	// Restore selection if possible, otherwise select first.
 	if dt.GetRowCount() > 1 {
 		selectedRow := -1
 		for i, row := range rows {
-			if utils.Truncate(row.ip, maxColWidth) == selectedIP {
+			if row.id == selectedID {
 				selectedRow = i + 1 // +1 for header
 				break
 			}
@@ -267,11 +351,21 @@ func (dt *DeviceTable) refresh() {
 	}
 }
 
+// portScanText is the Port Scan cell of a host, the share of its ports probed while the
+// scan runs and the number of open ports found.
+func portScanText(p discovery.HostProgress) string {
+	if p.Finished() {
+		return fmt.Sprintf("done, %d open", p.Open)
+	}
+	return fmt.Sprintf("%d%%, %d open", p.Done*100/max(p.Total, 1), p.Open)
+}
+
 func (dt *DeviceTable) rowMatches(r *tableRow) bool {
 	if dt.filterRE == nil {
 		return true
 	}
 	return dt.filterRE.MatchString(r.ip) ||
+		dt.filterRE.MatchString(r.iface) ||
 		dt.filterRE.MatchString(r.hostname) ||
 		dt.filterRE.MatchString(r.mac) ||
 		dt.filterRE.MatchString(r.manufacturer) ||
@@ -1,6 +1,8 @@
 package components
 
 import (
+	"strings"
+
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
 	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
 	"github.com/rivo/tview"
@@ -32,11 +34,29 @@ func (h *Header) Render(s state.ReadOnly) {
 	if version := s.Version(); version != "" {
 		text = baseTitle + " - v" + version
 	}
-	if localIP := s.LocalIP(); localIP != "" {
-		text += "  |  " + localIP
-		if iface := s.ActiveInterface(); iface != "" {
-			text += " (" + iface + ")"
-		}
+	if local := localAddresses(s); len(local) > 0 {
+		text += "  |  " + strings.Join(local, ", ")
 	}
 	h.SetText(text)
 }
+
+// localAddresses formats the address of every scanned interface, e.g. "192.168.1.5 (eth0)".
+func localAddresses(s state.ReadOnly) []string {
+	var out []string
+	available := s.AvailableInterfaces()
+	for _, name := range s.ActiveInterfaces() {
+		for _, e := range available {
+			if e.Name != name {
+				continue
+			}
+			ip := e.IPv4
+			if ip == "" {
+				ip = e.IPv6
+			}
+			if ip != "" {
+				out = append(out, ip+" ("+name+")")
+			}
+		}
+	}
+	return out
+}
@@ -2,6 +2,7 @@ package components
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
@@ -51,6 +52,12 @@ func (ip *InterfacePicker) setupInputHandling() {
 				ip.SetCurrentItem(prevIdx)
 			}
 			return nil
+		case event.Rune() == ' ':
+			currentIdx := ip.GetCurrentItem()
+			if currentIdx >= 0 && currentIdx < len(ip.interfaces) {
+				ip.emit(events.InterfaceToggled{Name: ip.interfaces[currentIdx].Name})
+			}
+			return nil
 		case event.Key() == tcell.KeyEnter:
 			currentIdx := ip.GetCurrentItem()
 			if currentIdx >= 0 && currentIdx < len(ip.interfaces) {
@@ -68,9 +75,14 @@ func (ip *InterfacePicker) setupInputHandling() {
 
 // Render implements UIComponent.
 func (ip *InterfacePicker) Render(s state.ReadOnly) {
+	// keep the cursor in place while toggling interfaces
+	selected := -1
+	if ip.GetItemCount() == len(s.AvailableInterfaces()) {
+		selected = ip.GetCurrentItem()
+	}
 	ip.Clear()
 	ip.interfaces = s.AvailableInterfaces()
-	activeIface := s.ActiveInterface()
+	active := s.ActiveInterfaces()
 
 	ip.SetBorder(true).
 		SetTitle(fmt.Sprintf(" Network Interface (%d) ", len(ip.interfaces))).
@@ -79,19 +91,24 @@ func (ip *InterfacePicker) Render(s state.ReadOnly) {
 		SetBorderColor(tview.Styles.BorderColor).
 		SetBackgroundColor(tview.Styles.PrimitiveBackgroundColor)
 
-	var currentIndex int
+	currentIndex := -1
 
 	for i, iface := range ip.interfaces {
 		displayName := iface.Name
-		if iface.Name == activeIface {
+		if slices.Contains(active, iface.Name) {
 			displayName = "✓ " + displayName
-			currentIndex = i
+			if currentIndex < 0 {
+				currentIndex = i
+			}
 		}
 		if iface.IsVPN {
 			displayName += " [VPN/TUN]"
 		}
 
 		secondaryText := fmt.Sprintf("  %s  %s  %s", iface.IPv4, iface.Subnet, iface.Flags)
+		if iface.IPv6 != "" {
+			secondaryText += "  " + iface.IPv6
+		}
 		if iface.MAC != "" {
 			secondaryText += "  " + iface.MAC
 		}
@@ -103,6 +120,9 @@ func (ip *InterfacePicker) Render(s state.ReadOnly) {
 		})
 	}
 
-	ip.SetCurrentItem(currentIndex)
+	if selected >= 0 && selected < len(ip.interfaces) {
+		currentIndex = selected
+	}
+	ip.SetCurrentItem(max(currentIndex, 0))
 	ip.setupInputHandling()
 }
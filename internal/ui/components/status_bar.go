@@ -1,6 +1,11 @@
 package components
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
 	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
 	"github.com/rivo/tview"
@@ -8,28 +13,34 @@ import (
 
 var _ UIComponent = &StatusBar{}
 
-// StatusBar combines a Spinner with a right-aligned help text into a single flex row.
+// StatusBar combines a Spinner, the last scan summary and a right-aligned help text into a single flex row.
 type StatusBar struct {
 	*tview.Flex
 	spinner *Spinner
+	scan    *tview.TextView
 	help    *tview.TextView
 }
 
 func NewStatusBar() *StatusBar {
 	sp := NewSpinner()
+	scan := tview.NewTextView().
+		SetTextAlign(tview.AlignLeft)
 	help := tview.NewTextView().
 		SetTextAlign(tview.AlignRight)
 	row := tview.NewFlex().
 		SetDirection(tview.FlexColumn).
 		AddItem(sp, 0, 1, false).
+		AddItem(scan, 0, 1, false).
 		AddItem(help, 0, 2, false)
 
+	theme.RegisterPrimitive(scan)
 	theme.RegisterPrimitive(help)
 	theme.RegisterPrimitive(row)
 
 	return &StatusBar{
 		Flex:    row,
 		spinner: sp,
+		scan:    scan,
 		help:    help,
 	}
 }
@@ -44,6 +55,33 @@ func (s *StatusBar) SetHelp(text string) {
 }
 
 // Render implements UIComponent.
-func (s *StatusBar) Render(_ state.ReadOnly) {
-	// StatusBar is updated via SetHelp, no state update needed.
+func (s *StatusBar) Render(st state.ReadOnly) {
+	report, ok := st.LastScanReport()
+	if !ok {
+		s.scan.SetText("")
+		return
+	}
+	s.scan.SetText(scanSummary(&report))
+}
+
+// scanSummary formats a short one-line summary of a scan report, e.g.
+// "Last scan: 12 devices in 10.0s (mdns failed, arp timed out, icmp disabled)".
+func scanSummary(r *discovery.ScanReport) string {
+	text := fmt.Sprintf("Last scan: %d devices in %s", r.Devices, r.Duration.Round(100*time.Millisecond))
+
+	var issues []string
+	for _, sr := range r.Scanners {
+		switch {
+		case sr.Err != nil:
+			issues = append(issues, sr.Label()+" failed")
+		case sr.Running:
+			issues = append(issues, sr.Label()+" timed out")
+		case sr.Disabled != "":
+			issues = append(issues, sr.Label()+" disabled")
+		}
+	}
+	if len(issues) > 0 {
+		text += " (" + strings.Join(issues, ", ") + ")"
+	}
+	return text
 }
@@ -1,11 +1,13 @@
 package events
 
+import "github.com/ramonvermeulen/whosthere/internal/core/config"
+
 // Event represents a UI event emitted by components or views.
 type Event interface{}
 
 // DeviceSelected is emitted when a device is selected in the table.
 type DeviceSelected struct {
-	IP string
+	ID string // device ID, see discovery.DeviceIndex
 }
 
 // FilterChanged is emitted when the filter pattern changes.
@@ -41,8 +43,20 @@ type DiscoveryStarted struct{}
 // DiscoveryStopped is emitted when discovery stops.
 type DiscoveryStopped struct{}
 
+// PortScanRequested is emitted to open the port scan dialog for the devices with the
+// given IDs, the selected device when empty.
+type PortScanRequested struct {
+	IDs []string
+}
+
+// PortScanCancelled is emitted to stop the running port scan.
+type PortScanCancelled struct{}
+
 // PortScanStarted is emitted when port scan starts.
-type PortScanStarted struct{}
+type PortScanStarted struct {
+	TCP    []int                // ports to scan, the configured ports when empty
+	Timing config.TimingProfile // timing of the scan, the configured timing when empty
+}
 
 // PortScanStopped is emitted when port scan stops.
 type PortScanStopped struct{}
@@ -63,11 +77,22 @@ type CopyIP struct {
 	IP string
 }
 
-// InterfaceSelected is emitted when a network interface is selected from the picker.
+// InterfaceSelected is emitted when a network interface is selected from the picker,
+// it replaces the scanned interfaces with this one.
 type InterfaceSelected struct {
 	Name string
 }
 
+// InterfaceToggled is emitted to add an interface to or remove it from the scanned interfaces.
+type InterfaceToggled struct {
+	Name string
+}
+
+// InterfaceFilterChanged is emitted when the device list is limited to an interface ("" for all).
+type InterfaceFilterChanged struct {
+	Name string
+}
+
 // ProbeStarted is emitted when a deep device probe begins.
 type ProbeStarted struct{}
 
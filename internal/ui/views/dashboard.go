@@ -1,7 +1,10 @@
 package views
 
 import (
+	"fmt"
+
 	"github.com/gdamore/tcell/v2"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
 	"github.com/ramonvermeulen/whosthere/internal/ui/components"
 	"github.com/ramonvermeulen/whosthere/internal/ui/events"
@@ -34,7 +37,7 @@ func NewDashboardView(emit func(events.Event), queue func(f func())) *DashboardV
 
 	statusBar := components.NewStatusBar()
 	statusBar.Spinner().SetSuffix(" Discovering Devices...")
-	statusBar.SetHelp("j/k: up/down" + components.Divider + "g/G: top/bottom" + components.Divider + "y: Copy IP" + components.Divider + "Enter: details" + components.Divider + "Ctrl+I: interface" + components.Divider + "Ctrl+T: theme" + components.Divider + "Ctrl+Q: quit")
+	statusBar.SetHelp("j/k: up/down" + components.Divider + "g/G: top/bottom" + components.Divider + "y: Copy IP" + components.Divider + "i: filter interface" + components.Divider + "Enter: details" + components.Divider + "P: port scan all" + components.Divider + "Ctrl+I: interface" + components.Divider + "Ctrl+T: theme" + components.Divider + "Ctrl+Q: quit")
 
 	filterBar := components.NewFilterBar()
 
@@ -53,11 +56,11 @@ func NewDashboardView(emit func(events.Event), queue func(f func())) *DashboardV
 	d.updateFooter(false)
 	t.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey { return t.HandleInput(ev) })
 	t.SetSelectedFunc(func(row, col int) {
-		ip := t.SelectedIP()
-		if ip == "" {
+		id := t.SelectedID()
+		if id == "" {
 			return
 		}
-		d.emit(events.DeviceSelected{IP: ip})
+		d.emit(events.DeviceSelected{ID: id})
 		d.emit(events.NavigateTo{Route: routes.RouteDetail})
 	})
 
@@ -74,13 +77,33 @@ func (d *DashboardView) Render(s state.ReadOnly) {
 
 	d.updateFooter(s.SearchActive())
 
-	if s.IsDiscovering() {
+	switch {
+	case s.IsPortscanning():
+		d.statusBar.Spinner().SetSuffix(portScanSuffix(s.PortScanProgress()))
+		d.statusBar.Spinner().Start(d.queue)
+	case s.IsDiscovering():
+		d.statusBar.Spinner().SetSuffix(" Discovering Devices...")
 		d.statusBar.Spinner().Start(d.queue)
-	} else {
+	default:
 		d.statusBar.Spinner().Stop(d.queue)
 	}
 }
 
+// portScanSuffix is the spinner text of a port scan, with the number of hosts finished
+// when more than one host is scanned.
+func portScanSuffix(progress []discovery.HostProgress) string {
+	if len(progress) <= 1 {
+		return " Port scanning..."
+	}
+	finished := 0
+	for _, p := range progress {
+		if p.Finished() {
+			finished++
+		}
+	}
+	return fmt.Sprintf(" Port scanning %d/%d hosts...", finished, len(progress))
+}
+
 func (d *DashboardView) updateFooter(showFilter bool) {
 	if d.Flex == nil || d.statusBar == nil || d.filterBar == nil {
 		return
@@ -1,11 +1,17 @@
 package views
 
 import (
+	"cmp"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/ramonvermeulen/whosthere/internal/core/discovery"
+	"github.com/ramonvermeulen/whosthere/internal/core/ports"
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
 	"github.com/ramonvermeulen/whosthere/internal/ui/components"
 	"github.com/ramonvermeulen/whosthere/internal/ui/events"
@@ -37,7 +43,7 @@ func NewDetailView(emit func(events.Event), queue func(f func())) *DetailView {
 		SetTitle(" Details ")
 
 	statusBar := components.NewStatusBar()
-	statusBar.SetHelp("Esc/q: Back" + components.Divider + "y: Copy IP" + components.Divider + "p: Port Scan" + components.Divider + "r: Probe" + components.Divider + "w: WoL")
+	statusBar.SetHelp("Esc/q: Back" + components.Divider + "y: Copy IP" + components.Divider + "p: Port Scan" + components.Divider + "x: Stop Scan" + components.Divider + "r: Probe" + components.Divider + "w: WoL")
 
 	main.AddItem(header, 1, 0, false)
 	main.AddItem(info, 0, 1, true)
@@ -70,7 +76,10 @@ func handleInput(p *DetailView) func(ev *tcell.EventKey) *tcell.EventKey {
 			p.emit(events.NavigateTo{Route: routes.RouteDashboard, Overlay: true})
 			return nil
 		case ev.Rune() == 'p':
-			p.emit(events.NavigateTo{Route: routes.RoutePortScan, Overlay: true})
+			p.emit(events.PortScanRequested{})
+			return nil
+		case ev.Rune() == 'x':
+			p.emit(events.PortScanCancelled{})
 			return nil
 		case ev.Rune() == 'r':
 			p.emit(events.ProbeStarted{})
@@ -113,6 +122,20 @@ func (d *DetailView) Render(s state.ReadOnly) {
 		}
 	}
 
+	// writeField writes a merged device field together with the source its value came from.
+	writeField := func(label, field, value string) {
+		src := device.FieldSource(field)
+		if value == "" || src == "" {
+			writeLine(label, value)
+			return
+		}
+		if noColor {
+			_, _ = fmt.Fprintf(d.info, "%s: %s (%s)\n", label, value, src)
+		} else {
+			_, _ = fmt.Fprintf(d.info, "[%s::b]%s:[-::-] [%s::]%s[-::-] [%s::d](%s)[-::-]\n", labelColor, label, valueColor, value, labelColor, src)
+		}
+	}
+
 	writeSection := func(label string) {
 		if noColor {
 			_, _ = fmt.Fprintf(d.info, "%s:\n", label)
@@ -145,28 +168,53 @@ func (d *DetailView) Render(s state.ReadOnly) {
 	}
 
 	writeLine("IP", device.IP.String())
-	writeLine("Display Name", device.DisplayName)
-	writeLine("MAC", device.MAC)
-	writeLine("Manufacturer", device.Manufacturer)
+	if device.Interface != "" {
+		network := device.Interface
+		if device.Subnet != "" {
+			network += " (" + device.Subnet + ")"
+		}
+		writeLine("Interface", network)
+	}
+	writeField("Display Name", discovery.FieldDisplayName, device.DisplayName)
+	if device.Hostname != "" {
+		writeField("Hostname", discovery.FieldHostname, device.Hostname)
+	}
+	writeField("MAC", discovery.FieldMAC, device.MAC)
+	writeField("Manufacturer", discovery.FieldManufacturer, device.Manufacturer)
 	if device.DeviceType != "" {
-		writeLine("Device Type", device.DeviceType)
+		writeField("Device Type", discovery.FieldDeviceType, device.DeviceType)
 	}
 	if device.OS != "" {
-		writeLine("OS", device.OS)
+		writeField("OS", discovery.FieldOS, device.OS)
 	}
 	if device.ReverseDNS != "" {
-		writeLine("Reverse DNS", device.ReverseDNS)
+		writeField("Reverse DNS", discovery.FieldReverseDNS, device.ReverseDNS)
 	}
 	if device.NetBIOSName != "" {
-		writeLine("NetBIOS Name", device.NetBIOSName)
+		writeField("NetBIOS Name", discovery.FieldNetBIOSName, device.NetBIOSName)
 	}
 	if device.Latency > 0 {
 		writeLine("Latency", device.Latency.Round(time.Microsecond).String())
 	}
 	writeLine("First Seen", formatTime(device.FirstSeen))
 	writeLine("Last Seen", formatTime(device.LastSeen))
+	if device.Departed() {
+		writeLine("Departed", formatTime(device.DepartedAt))
+	}
 	_, _ = fmt.Fprintln(d.info)
 
+	if len(device.Addresses) > 1 {
+		writeSection("Addresses")
+		for _, a := range device.Addresses {
+			status := "current"
+			if !a.Current {
+				status = "historical, last seen " + formatTime(a.LastSeen)
+			}
+			_, _ = fmt.Fprintf(d.info, "  %s (%s)\n", a.IP, status)
+		}
+		_, _ = fmt.Fprintln(d.info)
+	}
+
 	writeSection("Sources")
 	if len(device.Sources) == 0 {
 		_, _ = fmt.Fprintln(d.info, "  (none)")
@@ -176,30 +224,42 @@ func (d *DetailView) Render(s state.ReadOnly) {
 		}
 	}
 
+	_, _ = fmt.Fprintln(d.info)
+	writeSection("Services")
+	if len(device.Services) == 0 {
+		_, _ = fmt.Fprintln(d.info, "  (none)")
+	} else {
+		d.writeServices(device.Services, labelColor, noColor)
+	}
+
 	_, _ = fmt.Fprintln(d.info)
 	writeSection("Open Ports")
 	if len(device.OpenPorts) == 0 {
 		_, _ = fmt.Fprintln(d.info, "  (no ports scanned yet)")
 	} else {
 		for _, key := range utils.SortedKeys(device.OpenPorts) {
-			ports := device.OpenPorts[key]
-			if len(ports) > 0 {
+			openPorts := device.OpenPorts[key]
+			if len(openPorts) > 0 {
 				writeProto(key)
-				for _, port := range ports {
+				for _, port := range openPorts {
+					portText := strings.TrimSpace(fmt.Sprintf("%-5d %s", port.Number, ports.ServiceName(port.Number, key)))
+					if port.State == discovery.PortOpenFiltered {
+						portText += " (open|filtered)"
+					}
 					bannerText := ""
 					if device.Banners != nil {
-						if b, ok := device.Banners[port]; ok && b != "" {
+						if b, ok := device.Banners[port.Number]; ok && b != "" {
 							bannerText = b
 						}
 					}
 					if bannerText != "" {
 						if noColor {
-							_, _ = fmt.Fprintf(d.info, "    %d  %s\n", port, bannerText)
+							_, _ = fmt.Fprintf(d.info, "    %s  %s\n", portText, bannerText)
 						} else {
-							_, _ = fmt.Fprintf(d.info, "    [%s::]%d[-::-]  [%s::]%s[-::-]\n", valueColor, port, labelColor, bannerText)
+							_, _ = fmt.Fprintf(d.info, "    [%s::]%s[-::-]  [%s::]%s[-::-]\n", valueColor, portText, labelColor, bannerText)
 						}
 					} else {
-						_, _ = fmt.Fprintf(d.info, "    %d\n", port)
+						_, _ = fmt.Fprintf(d.info, "    %s\n", portText)
 					}
 				}
 				_, _ = fmt.Fprintln(d.info)
@@ -220,6 +280,25 @@ func (d *DetailView) Render(s state.ReadOnly) {
 		}
 	}
 
+	if !device.SNMP.IsZero() {
+		_, _ = fmt.Fprintln(d.info)
+		writeSection("SNMP")
+		for _, f := range []struct{ label, value string }{
+			{"Name", device.SNMP.Name},
+			{"Description", device.SNMP.Descr},
+			{"Object ID", device.SNMP.ObjectID},
+			{"Contact", device.SNMP.Contact},
+			{"Location", device.SNMP.Location},
+		} {
+			if f.value != "" {
+				_, _ = fmt.Fprintf(d.info, "  %s: %s\n", f.label, utils.SanitizeString(f.value))
+			}
+		}
+		if device.SNMP.UpTime > 0 {
+			_, _ = fmt.Fprintf(d.info, "  Uptime: %s\n", device.SNMP.UpTime.Truncate(time.Second))
+		}
+	}
+
 	if device.HTTPTitle != "" || device.HTTPServer != "" {
 		_, _ = fmt.Fprintln(d.info)
 		writeSection("HTTP Info")
@@ -244,7 +323,7 @@ func (d *DetailView) Render(s state.ReadOnly) {
 		d.statusBar.Spinner().SetSuffix(" Probing device...")
 		d.statusBar.Spinner().Start(d.queue)
 	case s.IsPortscanning():
-		d.statusBar.Spinner().SetSuffix(" Port scanning...")
+		d.statusBar.Spinner().SetSuffix(hostScanSuffix(s.PortScanProgress(), device.IP.String()))
 		d.statusBar.Spinner().Start(d.queue)
 	case s.IsDiscovering():
 		d.statusBar.Spinner().SetSuffix(" Discovering Devices...")
@@ -253,3 +332,72 @@ func (d *DetailView) Render(s state.ReadOnly) {
 		d.statusBar.Spinner().Stop(d.queue)
 	}
 }
+
+// hostScanSuffix is the spinner text of a port scan, with the ports probed on ip.
+func hostScanSuffix(progress []discovery.HostProgress, ip string) string {
+	for _, p := range progress {
+		if p.IP == ip {
+			return fmt.Sprintf(" Port scanning %d/%d ports...", p.Done, p.Total)
+		}
+	}
+	return " Port scanning..."
+}
+
+// writeServices writes the service instances of a device as a table, sorted by type and
+// instance name, with the TXT pairs of every instance on the line below it.
+func (d *DetailView) writeServices(services []discovery.ServiceInstance, headerColor string, noColor bool) {
+	services = slices.Clone(services)
+	slices.SortFunc(services, func(a, b discovery.ServiceInstance) int {
+		return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.Protocol, b.Protocol), cmp.Compare(a.Instance, b.Instance))
+	})
+
+	header := []string{"INSTANCE", "TYPE", "PORT", "TARGET", "SOURCE", "LAST SEEN"}
+	rows := make([][]string, 0, len(services))
+	for _, svc := range services {
+		serviceType := svc.Type
+		if svc.Protocol != "" {
+			serviceType += "/" + svc.Protocol
+		}
+		port := "-"
+		if svc.Port > 0 {
+			port = strconv.Itoa(svc.Port)
+		}
+		lastSeen := "-"
+		if !svc.LastSeen.IsZero() {
+			lastSeen = svc.LastSeen.Format("15:04:05")
+		}
+		rows = append(rows, []string{cmp.Or(svc.Instance, "-"), serviceType, port, cmp.Or(svc.Target, "-"), svc.Source, lastSeen})
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, col := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(col))
+		}
+	}
+	format := func(row []string) string {
+		var b strings.Builder
+		b.WriteString(" ")
+		for i, col := range row {
+			b.WriteString(" " + tview.Escape(fmt.Sprintf("%-*s", widths[i], col)))
+		}
+		return strings.TrimRight(b.String(), " ")
+	}
+
+	if noColor {
+		_, _ = fmt.Fprintln(d.info, format(header))
+	} else {
+		_, _ = fmt.Fprintf(d.info, "[%s::b]%s[-::-]\n", headerColor, format(header))
+	}
+	for i, svc := range services {
+		_, _ = fmt.Fprintln(d.info, format(rows[i]))
+		if len(svc.TXT) == 0 {
+			continue
+		}
+		pairs := make([]string, 0, len(svc.TXT))
+		for _, k := range utils.SortedKeys(svc.TXT) {
+			pairs = append(pairs, k+"="+svc.TXT[k])
+		}
+		_, _ = fmt.Fprintf(d.info, "    %s\n", tview.Escape(strings.Join(pairs, " ")))
+	}
+}
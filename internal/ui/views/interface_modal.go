@@ -25,7 +25,7 @@ func NewInterfaceModalView(emit func(events.Event)) *InterfaceModalView {
 	footer := tview.NewTextView()
 	footer.SetDynamicColors(true).
 		SetTextAlign(tview.AlignCenter).
-		SetText("j/k: navigate" + components.Divider + "Enter: select" + components.Divider + "Esc: cancel")
+		SetText("j/k: navigate" + components.Divider + "Enter: select" + components.Divider + "Space: add/remove" + components.Divider + "Esc: cancel")
 	footer.SetTextColor(tview.Styles.SecondaryTextColor)
 	footer.SetBackgroundColor(tview.Styles.PrimitiveBackgroundColor)
 
@@ -2,8 +2,12 @@ package views
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/ports"
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
 	"github.com/ramonvermeulen/whosthere/internal/ui/events"
 	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
@@ -13,55 +17,132 @@ import (
 var _ View = &PortScanModalView{}
 
 // PortScanModalView is a modal overlay page for port scanning the selected device.
+// The TCP ports are an editable port spec, prefilled with the configured ports, and the
+// timing profile is selectable, preselected with the configured profile.
 type PortScanModalView struct {
-	*tview.Modal
-	emit func(events.Event)
+	*tview.Flex
+	content *tview.Flex
+	info    *tview.TextView
+	status  *tview.TextView
+	form    *tview.Form
+	tcp     *tview.InputField
+	timing  *tview.DropDown
+
+	// prefilled is set once the fields hold the configured ports and timing, later edits are kept.
+	prefilled bool
+	emit      func(events.Event)
 }
 
 func NewPortScanModalView(emit func(events.Event)) *PortScanModalView {
-	modal := tview.NewModal().
-		SetText("").
-		AddButtons([]string{"Start Scan", "Cancel"}).
-		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-			if buttonIndex == 0 {
-				emit(events.PortScanStarted{})
-			} else {
-				emit(events.HideView{})
-			}
-		})
+	info := tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignCenter)
+	status := tview.NewTextView().
+		SetTextAlign(tview.AlignCenter)
+	tcp := tview.NewInputField().
+		SetLabel("TCP ports: ")
+	profiles := make([]string, len(config.TimingProfiles))
+	for i, profile := range config.TimingProfiles {
+		profiles[i] = string(profile)
+	}
+	timing := tview.NewDropDown().
+		SetLabel("Timing:    ").
+		SetOptions(profiles, nil)
+	form := tview.NewForm().
+		AddFormItem(tcp).
+		AddFormItem(timing).
+		SetButtonsAlign(tview.AlignCenter)
+
+	content := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(info, 0, 1, false).
+		AddItem(status, 1, 0, false).
+		AddItem(form, 7, 0, true)
+	content.SetBorder(true).SetTitleAlign(tview.AlignCenter)
+
+	modalWidth, modalHeight := 72, 19
+
+	root := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+			AddItem(nil, 0, 1, false).
+			AddItem(content, modalWidth, 0, true).
+			AddItem(nil, 0, 1, false), modalHeight, 0, true).
+		AddItem(nil, 0, 1, false)
 
 	p := &PortScanModalView{
-		Modal: modal,
-		emit:  emit,
+		Flex:    root,
+		content: content,
+		info:    info,
+		status:  status,
+		form:    form,
+		tcp:     tcp,
+		timing:  timing,
+		emit:    emit,
 	}
 
-	modal.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyEsc {
-			emit(events.HideView{})
+	form.AddButton("Start Scan", p.start).
+		AddButton("Cancel", func() { emit(events.HideView{}) }).
+		SetCancelFunc(func() { emit(events.HideView{}) })
+
+	// Enter in the spec field starts the scan instead of moving to the buttons
+	form.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if item, _ := form.GetFocusedItemIndex(); item == 0 && event.Key() == tcell.KeyEnter {
+			p.start()
 			return nil
 		}
 		return event
 	})
 
-	theme.RegisterPrimitive(p.Modal)
+	theme.RegisterPrimitive(content)
+	theme.RegisterPrimitive(info)
+	theme.RegisterPrimitive(status)
+	theme.RegisterPrimitive(form)
+	theme.RegisterPrimitive(tcp)
+	theme.RegisterPrimitive(timing)
 
 	return p
 }
 
-func (p *PortScanModalView) FocusTarget() tview.Primitive { return p.Modal }
+// start parses the spec field and starts the scan, an invalid spec is shown instead.
+func (p *PortScanModalView) start() {
+	tcp, err := ports.Parse(p.tcp.GetText())
+	if err != nil {
+		p.status.SetTextColor(tcell.ColorRed).SetText(err.Error())
+		return
+	}
+	p.status.SetText("")
+	_, timing := p.timing.GetCurrentOption()
+	p.emit(events.PortScanStarted{TCP: tcp, Timing: config.TimingProfile(timing)})
+}
+
+func (p *PortScanModalView) FocusTarget() tview.Primitive { return p.form }
 
 func (p *PortScanModalView) Render(s state.ReadOnly) {
-	device, ok := s.Selected()
-	if !ok {
-		p.SetText("No device selected.")
+	title := ""
+	if targets := s.PortScanTargets(); len(targets) > 0 {
+		title = fmt.Sprintf(" %d devices ", len(targets))
+	} else if device, ok := s.Selected(); ok {
+		title = fmt.Sprintf(" IP: %s ", device.IP)
+	} else {
+		p.info.SetText("No device selected.")
 		return
 	}
 	cfg := s.Config()
-	tcpPorts := cfg.PortScanner.TCP
+	if !p.prefilled {
+		p.tcp.SetText(ports.Format(cfg.PortScanner.TCP))
+		p.timing.SetCurrentOption(slices.Index(config.TimingProfiles, cfg.PortScanner.Timing))
+		p.prefilled = true
+	}
 
-	text := "The following ports will be scanned:\n\n"
-	text += fmt.Sprintf("TCP: %v\n\n", tcpPorts)
-	text += "Only scan hosts that you have permission to scan!"
+	text := "\nEnter the TCP ports to scan: ports, ranges such as 8000-8100,\n"
+	text += fmt.Sprintf("top1 to top%d, or the sets %s.\n\n", ports.TopMax(), strings.Join(ports.SetNames(), ", "))
+	if udpPorts := cfg.PortScanner.UDP; len(udpPorts) > 0 {
+		text += fmt.Sprintf("UDP: %v\n\n", udpPorts)
+	}
+	text += "Polite timing is slow but gentle on fragile IoT devices.\n"
+	text += "Only scan hosts that you have permission to scan!\n"
+	text += "Press x to stop a running scan."
 
-	p.Modal.SetText(text).SetTitle(fmt.Sprintf(" IP: %s ", device.IP))
+	p.info.SetText(text)
+	p.content.SetTitle(title)
 }